@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/services"
+	"github.com/Marketen/duties-indexer/internal/config"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// epochReport is the output of "check-epoch --format json".
+type epochReport struct {
+	Epoch      domain.Epoch  `json:"epoch"`
+	Finalized  bool          `json:"finalized"`
+	Validators int           `json:"validators"`
+	CheckedAt  time.Time     `json:"checked_at"`
+	Summary    reportSummary `json:"summary"`
+	// Network is the network baseline of the epoch, when it could be
+	// computed.
+	Network *domain.NetworkBaseline `json:"network,omitempty"`
+	Results []reportResult          `json:"results"`
+}
+
+type reportSummary struct {
+	Duties  int `json:"duties"`
+	Success int `json:"success"`
+	Missed  int `json:"missed"`
+	Unknown int `json:"unknown"`
+}
+
+// reportResult is a DutyResult with the group of its validator.
+type reportResult struct {
+	domain.DutyResult
+	Group string `json:"group,omitempty"`
+}
+
+// runCheckEpochCommand implements "check-epoch": it checks the duties of
+// one epoch against the configured beacon nodes, prints every outcome and
+// exits.
+func runCheckEpochCommand(args []string) int {
+	return checkEpoch(args, os.Stdout)
+}
+
+// checkEpoch runs "check-epoch" with args, writing the report to stdout.
+func checkEpoch(args []string, stdout io.Writer) int {
+	var epochFlag int64
+	var format string
+	cfg, err := config.LoadWithFlags(args, func(fs *flag.FlagSet) {
+		fs.Int64Var(&epochFlag, "epoch", -1, "epoch to check (required)")
+		fs.StringVar(&format, "format", "text", "output format: text or json")
+	})
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if epochFlag < 0 || (format != "text" && format != "json") {
+		fmt.Fprintln(os.Stderr, "usage: duties-indexer check-epoch --epoch N [--validators 1,2,3] [--format text|json] [flags]")
+		return exitUsage
+	}
+	logger.Setup(cfg.LogFormat, os.Stderr)
+	logger.SetLevel(cfg.LogLevel)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	failoverAdapter, err := adapters.NewFailoverBeaconAdapter(ctx, beaconNodes(cfg), cfg.BeaconHealthInterval)
+	if err != nil {
+		logger.Error("Failed to create beacon HTTP adapter: %v", err)
+		return exitError
+	}
+	beacon := adapters.NewRetryingBeaconAdapter(failoverAdapter, retryPolicy(cfg))
+
+	epoch := domain.Epoch(epochFlag)
+	finalized, err := beacon.GetFinalizedEpoch(ctx)
+	if err != nil {
+		logger.Error("Failed to fetch finalized epoch: %v", err)
+		return exitError
+	}
+	if epoch > finalized {
+		logger.Warn("Epoch %d is not finalized yet (finalized epoch %d); outcomes may still change", epoch, finalized)
+	}
+	indices, err := resolveValidatorIndices(ctx, cfg, beacon)
+	if err != nil {
+		logger.Error("Failed to fetch active validator indices: %v", err)
+		return exitError
+	}
+
+	checker := services.NewDutiesChecker(beacon, cfg.PollInterval, indices)
+	checker.SetConcurrency(cfg.Concurrency)
+	checker.SetNetworkThresholds(cfg.NetworkThresholds())
+	results, err := checker.CheckEpoch(ctx, epoch)
+	if err != nil {
+		logger.Error("Failed to check epoch %d: %v", epoch, err)
+		return exitError
+	}
+
+	report := epochReport{
+		Epoch:      epoch,
+		Finalized:  epoch <= finalized,
+		Validators: len(indices),
+		CheckedAt:  time.Now().UTC(),
+		Results:    make([]reportResult, len(results)),
+	}
+	if baseline, ok := checker.LastBaseline(); ok {
+		report.Network = &baseline
+	}
+	groups := cfg.ValidatorGroups()
+	for i, r := range results {
+		report.Results[i] = reportResult{DutyResult: r, Group: groups[r.ValidatorIndex]}
+		report.Summary.Duties++
+		switch r.Outcome {
+		case domain.OutcomeSuccess:
+			report.Summary.Success++
+		case domain.OutcomeMissed:
+			report.Summary.Missed++
+		case domain.OutcomeUnknown:
+			report.Summary.Unknown++
+		}
+	}
+
+	if format == "json" {
+		err = writeEpochReportJSON(stdout, report)
+	} else {
+		err = writeEpochReportText(stdout, report)
+	}
+	if err != nil {
+		logger.Error("Writing report: %v", err)
+		return exitError
+	}
+	logger.Info("Epoch %d: %s", epoch, summarizeResults(results))
+	return exitOK
+}
+
+func writeEpochReportJSON(w io.Writer, report epochReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// writeEpochReportText prints the network baseline and one aligned row per
+// duty.
+func writeEpochReportText(w io.Writer, report epochReport) error {
+	if report.Network != nil {
+		if _, err := fmt.Fprintf(w, "Network: %s\n\n", networkText(*report.Network)); err != nil {
+			return err
+		}
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tVALIDATOR\tGROUP\tSLOT\tCOMMITTEE\tOUTCOME\tINCLUDED\tNETWORK\tREASON")
+	for _, r := range report.Results {
+		committee, included := "-", "-"
+		if r.Type == domain.DutyTypeAttestation {
+			committee = fmt.Sprint(r.CommitteeIndex)
+		}
+		if r.Outcome == domain.OutcomeSuccess {
+			included = fmt.Sprint(r.InclusionSlot)
+		}
+		group := r.Group
+		if group == "" {
+			group = "-"
+		}
+		network := "-"
+		if r.NetworkDegraded {
+			network = "degraded"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			r.Type, r.ValidatorIndex, group, r.Slot, committee, r.Outcome, included, network, r.Reason)
+	}
+	return tw.Flush()
+}
+
+// networkText summarizes a network baseline in one line.
+func networkText(b domain.NetworkBaseline) string {
+	participation := "unknown"
+	if b.Participation != nil {
+		participation = fmt.Sprintf("%.2f%%", 100**b.Participation)
+	}
+	text := fmt.Sprintf("participation %s, %d of %d slots missed", participation, b.MissedSlots, b.ProposedSlots+b.MissedSlots)
+	if b.UnavailableSlots > 0 {
+		text += fmt.Sprintf(", %d blocks unavailable", b.UnavailableSlots)
+	}
+	return text
+}
+
+// beaconNodes converts the configured beacon nodes for the adapters.
+func beaconNodes(cfg *config.Config) []adapters.BeaconNode {
+	nodes := make([]adapters.BeaconNode, len(cfg.BeaconNodes))
+	for i, n := range cfg.BeaconNodes {
+		nodes[i] = adapters.BeaconNode{Name: n.Name, URL: n.URL, RateLimit: n.RateLimit}
+	}
+	return nodes
+}
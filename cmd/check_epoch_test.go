@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/beaconmock"
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/testutil"
+)
+
+func TestCheckEpoch(t *testing.T) {
+	chain := testutil.NewChain(t, fakechain.Config{
+		Seed: 8, Validators: 512, CommitteesPerSlot: 4, Epochs: 4,
+		MissedSlotRate: 0.1, MissedAttestationRate: 0.1, MaxInclusionDelay: 1,
+	})
+	node := beaconmock.New(chain)
+	defer node.Close()
+
+	indices := testutil.Validators(100)
+	list := make([]string, len(indices))
+	for i, v := range indices {
+		list[i] = fmt.Sprint(v)
+	}
+	flags := func(extra ...string) []string {
+		return append([]string{
+			"--beacon-node-url", node.URL,
+			"--validators", strings.Join(list, ","),
+			"--data-dir", t.TempDir(),
+			"--log-level", "ERROR",
+		}, extra...)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		// check inspects the output of a successful run.
+		check func(t *testing.T, out string)
+	}{
+		{
+			name:     "json",
+			args:     flags("--epoch", "2", "--format", "json"),
+			wantCode: exitOK,
+			check: func(t *testing.T, out string) {
+				var report epochReport
+				if err := json.Unmarshal([]byte(out), &report); err != nil {
+					t.Fatal(err)
+				}
+				want := chain.Expected(2, indices)
+				if report.Epoch != 2 || !report.Finalized || report.Validators != len(indices) {
+					t.Errorf("epoch %d, finalized %t, %d validators; want 2, true, %d",
+						report.Epoch, report.Finalized, report.Validators, len(indices))
+				}
+				var results []domain.DutyResult
+				var wantSummary reportSummary
+				for _, r := range report.Results {
+					results = append(results, r.DutyResult)
+				}
+				for _, r := range want {
+					wantSummary.Duties++
+					switch r.Outcome {
+					case domain.OutcomeSuccess:
+						wantSummary.Success++
+					case domain.OutcomeMissed:
+						wantSummary.Missed++
+					case domain.OutcomeUnknown:
+						wantSummary.Unknown++
+					}
+				}
+				if report.Summary != wantSummary {
+					t.Errorf("summary %+v, want %+v", report.Summary, wantSummary)
+				}
+				if report.Network == nil {
+					t.Error("no network baseline")
+				}
+				testutil.AssertResults(t, results, want)
+			},
+		},
+		{
+			name:     "text",
+			args:     flags("--epoch", "2"),
+			wantCode: exitOK,
+			check: func(t *testing.T, out string) {
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				if !strings.HasPrefix(lines[0], "Network: participation ") {
+					t.Errorf("first line %q, want the network baseline", lines[0])
+				}
+				// The network line, a blank line, the header and one row per duty.
+				if want := 3 + len(chain.Expected(2, indices)); len(lines) != want {
+					t.Errorf("%d lines, want %d", len(lines), want)
+				}
+				if !strings.HasPrefix(lines[2], "TYPE") {
+					t.Errorf("header %q", lines[2])
+				}
+			},
+		},
+		{
+			name:     "unfinalized epoch",
+			args:     flags("--epoch", "3", "--format", "json"),
+			wantCode: exitOK,
+			check: func(t *testing.T, out string) {
+				var report epochReport
+				if err := json.Unmarshal([]byte(out), &report); err != nil {
+					t.Fatal(err)
+				}
+				if report.Finalized {
+					t.Error("epoch 3 reported as finalized")
+				}
+			},
+		},
+		{name: "no epoch", args: flags("--format", "json"), wantCode: exitUsage},
+		{name: "unknown format", args: flags("--epoch", "2", "--format", "xml"), wantCode: exitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if code := checkEpoch(tt.args, &out); code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d", code, tt.wantCode)
+			}
+			if tt.check != nil {
+				tt.check(t, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Marketen/duties-indexer/internal/config"
+)
+
+// runConfigCommand implements "config validate": it loads the configuration
+// exactly as "run" would and prints the effective merged result.
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintf(os.Stderr, "usage: duties-indexer config validate [flags]\n")
+		return 2
+	}
+
+	cfg, err := config.Load(args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	out, err := cfg.YAML()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rendering config: %v\n", err)
+		return 1
+	}
+	fmt.Println("# Configuration is valid. Effective configuration:")
+	fmt.Print(string(out))
+	return 0
+}
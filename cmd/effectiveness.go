@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Marketen/duties-indexer/internal/adapters"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/services"
+	"github.com/Marketen/duties-indexer/internal/config"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// defaultEffectivenessEpochs is the default rolling window: one day.
+const defaultEffectivenessEpochs = 225
+
+// runEffectivenessCommand implements "effectiveness": it ranks validators,
+// groups or labels by their effectiveness over the last stored epochs.
+func runEffectivenessCommand(args []string) int {
+	var epochs, limit int
+	var by, format string
+	cfg, err := config.LoadWithFlags(args, func(fs *flag.FlagSet) {
+		fs.IntVar(&epochs, "epochs", defaultEffectivenessEpochs, "rolling window, in epochs up to the latest stored one")
+		fs.StringVar(&by, "by", "validator", "rank validators, groups or the values of a label: validator, group or label:KEY")
+		fs.IntVar(&limit, "limit", 0, "print only the first N ranks, 0 for all")
+		fs.StringVar(&format, "format", "text", "output format: text or json")
+	})
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	groups, labels := cfg.ValidatorGroups(), cfg.ValidatorLabels()
+	keyOf, err := rankKeyFunc(by,
+		func(v domain.ValidatorIndex) string { return groups[v] },
+		func(v domain.ValidatorIndex, key string) string { return labels[v][key] })
+	if err != nil || epochs <= 0 || limit < 0 || (format != "text" && format != "json") {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		fmt.Fprintln(os.Stderr, "usage: duties-indexer effectiveness [--epochs N] [--by validator|group|label:KEY] [--limit N] [--format text|json] [flags]")
+		return exitUsage
+	}
+	logger.Setup(cfg.LogFormat, os.Stderr)
+	logger.SetLevel(cfg.LogLevel)
+
+	reader := adapters.NewFileResultReader(filepath.Join(cfg.DataDir, "results"))
+	ranking, err := services.RankEffectiveness(reader, epochs, by, keyOf)
+	if err != nil {
+		logger.Error("Failed to rank effectiveness: %v", err)
+		return exitError
+	}
+	if limit > 0 && len(ranking.Ranks) > limit {
+		ranking.Ranks = ranking.Ranks[:limit]
+	}
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(ranking)
+	} else {
+		err = writeRankingText(os.Stdout, ranking)
+	}
+	if err != nil {
+		logger.Error("Writing the ranking: %v", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// rankKeyFunc returns what validators are ranked by: their index, their
+// group or the value of one of their labels ("label:machine").
+func rankKeyFunc(
+	by string,
+	groupOf func(domain.ValidatorIndex) string,
+	labelOf func(domain.ValidatorIndex, string) string,
+) (func(domain.ValidatorIndex) string, error) {
+	switch {
+	case by == "validator":
+		return func(v domain.ValidatorIndex) string { return strconv.FormatUint(uint64(v), 10) }, nil
+	case by == "group":
+		return groupOf, nil
+	case strings.HasPrefix(by, "label:") && len(by) > len("label:"):
+		key := strings.TrimPrefix(by, "label:")
+		return func(v domain.ValidatorIndex) string { return labelOf(v, key) }, nil
+	default:
+		return nil, fmt.Errorf("by: %q is not one of validator, group, label:KEY", by)
+	}
+}
+
+// writeRankingText prints one aligned row per rank.
+func writeRankingText(w io.Writer, ranking domain.EffectivenessRanking) error {
+	fmt.Fprintf(w, "Effectiveness by %s, epochs %d to %d\n", ranking.By, ranking.FromEpoch, ranking.ToEpoch)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RANK\tKEY\tVALIDATORS\tEPOCHS\tEARNED\tPOSSIBLE\tSCORE")
+	for i, r := range ranking.Ranks {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%d\t%.2f%%\n",
+			i+1, r.Key, r.Validators, r.Scored, r.Earned, r.Possible, 100*r.Score)
+	}
+	return tw.Flush()
+}
+
+// serveEffectiveness serves GET /api/v1/effectiveness with the effectiveness
+// command's options as query parameters: epochs, by and limit. Like the
+// export, it needs the export token: the ranking lists every validator.
+func (e *resultsExport) serveEffectiveness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !e.authorized(w, r) {
+		return
+	}
+	params := r.URL.Query()
+	epochs, limit := defaultEffectivenessEpochs, 0
+	for name, n := range map[string]*int{"epochs": &epochs, "limit": &limit} {
+		if v := params.Get(name); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed < 0 || (name == "epochs" && parsed == 0) {
+				http.Error(w, fmt.Sprintf("%s: %q is not a valid count", name, v), http.StatusBadRequest)
+				return
+			}
+			*n = parsed
+		}
+	}
+	by := params.Get("by")
+	if by == "" {
+		by = "validator"
+	}
+	groupOf := func(domain.ValidatorIndex) string { return "" }
+	labelOf := func(domain.ValidatorIndex, string) string { return "" }
+	if checker := e.checker.Load(); checker != nil {
+		groupOf, labelOf = checker.ValidatorGroup, checker.ValidatorLabel
+	}
+	keyOf, err := rankKeyFunc(by, groupOf, labelOf)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ranking, err := services.RankEffectiveness(e.reader, epochs, by, keyOf)
+	if err != nil {
+		logger.Error("Ranking effectiveness failed: %v", err)
+		http.Error(w, "ranking failed", http.StatusInternalServerError)
+		return
+	}
+	if limit > 0 && len(ranking.Ranks) > limit {
+		ranking.Ranks = ranking.Ranks[:limit]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ranking)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/Marketen/duties-indexer/internal/adapters"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/services"
+	"github.com/Marketen/duties-indexer/internal/config"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// runExplainCommand implements "explain": it evaluates the duties of one
+// validator in one epoch and prints the data behind each verdict.
+func runExplainCommand(args []string) int {
+	var validatorFlag, epochFlag int64
+	var format string
+	cfg, err := config.LoadWithFlags(args, func(fs *flag.FlagSet) {
+		fs.Int64Var(&validatorFlag, "validator", -1, "validator index (required)")
+		fs.Int64Var(&epochFlag, "epoch", -1, "epoch of the duty (required)")
+		fs.StringVar(&format, "format", "text", "output format: text or json")
+	})
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if validatorFlag < 0 || epochFlag < 0 || (format != "text" && format != "json") {
+		fmt.Fprintln(os.Stderr, "usage: duties-indexer explain --validator N --epoch N [--format text|json] [flags]")
+		return exitUsage
+	}
+	logger.Setup(cfg.LogFormat, os.Stderr)
+	logger.SetLevel(cfg.LogLevel)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	failoverAdapter, err := adapters.NewFailoverBeaconAdapter(ctx, beaconNodes(cfg), cfg.BeaconHealthInterval)
+	if err != nil {
+		logger.Error("Failed to create beacon HTTP adapter: %v", err)
+		return exitError
+	}
+	beacon := adapters.NewRetryingBeaconAdapter(failoverAdapter, retryPolicy(cfg))
+
+	validator := domain.ValidatorIndex(validatorFlag)
+	checker := services.NewDutiesChecker(beacon, cfg.PollInterval, []domain.ValidatorIndex{validator})
+	checker.SetConcurrency(cfg.Concurrency)
+	checker.SetNetworkThresholds(cfg.NetworkThresholds())
+	explanation, err := checker.ExplainDuties(ctx, domain.Epoch(epochFlag), validator)
+	if err != nil {
+		logger.Error("Failed to explain the duties of validator %d in epoch %d: %v", validator, epochFlag, err)
+		return exitError
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(explanation)
+	} else {
+		err = writeExplanationText(os.Stdout, explanation)
+	}
+	if err != nil {
+		logger.Error("Writing explanation: %v", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// writeExplanationText prints an explanation for a reader following the
+// checker's steps.
+func writeExplanationText(w io.Writer, x services.DutyExplanation) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Validator %d, epoch %d\n\n", x.ValidatorIndex, x.Epoch)
+
+	if len(x.Proposals) == 0 {
+		b.WriteString("Proposer duties: none\n")
+	}
+	for _, p := range x.Proposals {
+		fmt.Fprintf(&b, "Proposer duty at slot %d: %s", p.Slot, p.Outcome)
+		if p.Reason != "" {
+			fmt.Fprintf(&b, " (%s)", p.Reason)
+		}
+		b.WriteString("\n")
+	}
+	if len(x.SyncCommittee) > 0 {
+		var signed int
+		var missed, unknown []domain.Slot
+		for _, r := range x.SyncCommittee {
+			switch r.Outcome {
+			case domain.OutcomeSuccess:
+				signed++
+			case domain.OutcomeMissed:
+				missed = append(missed, r.Slot)
+			default:
+				unknown = append(unknown, r.Slot)
+			}
+		}
+		fmt.Fprintf(&b, "Sync committee: signature included in %d of %d blocks", signed, len(x.SyncCommittee))
+		if len(missed) > 0 {
+			fmt.Fprintf(&b, ", missed at %s", joinSlots(missed))
+		}
+		if len(unknown) > 0 {
+			fmt.Fprintf(&b, ", unknown at %s", joinSlots(unknown))
+		}
+		b.WriteString("\n")
+	}
+
+	a := x.Attestation
+	if a == nil {
+		b.WriteString("Attester duty: none\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	fmt.Fprintf(&b, "Attester duty at slot %d: committee %d of %d, position %d of %d\n\n",
+		a.Slot, a.CommitteeIndex, a.CommitteesAtSlot, a.Position, a.CommitteeLength)
+
+	if a.CommitteesError != "" {
+		fmt.Fprintf(&b, "Committee sizes: unavailable (%s)\n", a.CommitteesError)
+	} else {
+		committees := make([]domain.CommitteeIndex, 0, len(a.CommitteeSizes))
+		for index := range a.CommitteeSizes {
+			committees = append(committees, index)
+		}
+		sort.Slice(committees, func(i, j int) bool { return committees[i] < committees[j] })
+		fmt.Fprintf(&b, "Committee sizes at slot %d:", a.Slot)
+		for _, index := range committees {
+			fmt.Fprintf(&b, " %d:%d", index, a.CommitteeSizes[index])
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "Inclusion window: blocks %d-%d", a.WindowFirstSlot, a.WindowLastSlot)
+	if len(a.MissedSlots) > 0 {
+		fmt.Fprintf(&b, ", no block at %s", joinSlots(a.MissedSlots))
+	}
+	b.WriteString("\n")
+	unavailable := make([]domain.Slot, 0, len(a.UnavailableSlots))
+	for slot := range a.UnavailableSlots {
+		unavailable = append(unavailable, slot)
+	}
+	sort.Slice(unavailable, func(i, j int) bool { return unavailable[i] < unavailable[j] })
+	for _, slot := range unavailable {
+		fmt.Fprintf(&b, "  block %d unavailable: %s\n", slot, a.UnavailableSlots[slot])
+	}
+
+	fmt.Fprintf(&b, "\nAttestations for slot %d in the window: %d (and %d for other slots)\n",
+		a.Slot, len(a.Candidates), a.OtherAttestations)
+	for _, c := range a.Candidates {
+		mark := " "
+		if c.Match {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "%s block %d  committee bits %s  committees %v  %s\n",
+			mark, c.InclusionSlot, c.CommitteeBits, c.Committees, c.Why)
+	}
+
+	fmt.Fprintf(&b, "\nOutcome: %s", a.Result.Outcome)
+	switch {
+	case a.Result.Outcome == domain.OutcomeSuccess:
+		fmt.Fprintf(&b, ", included in block %d (the first match)\n", a.Result.InclusionSlot)
+	case a.Result.Reason != "":
+		fmt.Fprintf(&b, ", %s\n", a.Result.Reason)
+	default:
+		b.WriteString("\n")
+	}
+	if a.Result.Outcome == domain.OutcomeSuccess {
+		fmt.Fprintf(&b, "Head vote: %s, target vote: %s\n", voteText(a.Result.Head), voteText(a.Result.Target))
+	}
+	if e := a.Result.Effectiveness; e.Possible > 0 {
+		fmt.Fprintf(&b, "Effectiveness: %d of %d (%.2f%%)\n", e.Earned, e.Possible, 100*e.Score())
+	}
+	if a.Network != nil {
+		fmt.Fprintf(&b, "Network: %s", networkText(*a.Network))
+		if a.Result.NetworkDegraded {
+			b.WriteString(", degraded at this slot")
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func voteText(v domain.Vote) string {
+	if v == domain.VoteUnknown {
+		return "unknown"
+	}
+	return string(v)
+}
+
+func joinSlots(slots []domain.Slot) string {
+	s := make([]string, len(slots))
+	for i, slot := range slots {
+		s[i] = fmt.Sprint(slot)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/application/services"
+	"github.com/Marketen/duties-indexer/internal/config"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// runExportCommand implements "export": it writes the results stored under
+// <data_dir>/results as CSV or Parquet.
+func runExportCommand(args []string) int {
+	var format, output, from, to, group string
+	var fromEpoch, toEpoch uint64
+	var flags *flag.FlagSet
+	cfg, err := config.LoadWithFlags(args, func(fs *flag.FlagSet) {
+		flags = fs
+		fs.StringVar(&format, "format", "csv", "output format: csv or parquet")
+		fs.StringVar(&output, "output", "-", "output file, - for stdout")
+		fs.Uint64Var(&fromEpoch, "from-epoch", 0, "first epoch to export")
+		fs.Uint64Var(&toEpoch, "to-epoch", 0, "last epoch to export, 0 for the latest stored")
+		fs.StringVar(&from, "from", "", "export duties from this time on (RFC 3339 or YYYY-MM-DD)")
+		fs.StringVar(&to, "to", "", "export duties before this time (RFC 3339 or YYYY-MM-DD)")
+		fs.StringVar(&group, "group", "", "export only the validators of this group")
+	})
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	q := services.ExportQuery{FromEpoch: domain.Epoch(fromEpoch), ToEpoch: domain.Epoch(toEpoch), Group: group}
+	q.From, err = parseExportTime(from)
+	if err == nil {
+		q.To, err = parseExportTime(to)
+	}
+	if err != nil || (format != "csv" && format != "parquet") {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		fmt.Fprintln(os.Stderr, "usage: duties-indexer export [--format csv|parquet] [--output FILE] [--from-epoch N] [--to-epoch N] [--from TIME] [--to TIME] [--validators 1,2,3] [--group NAME] [flags]")
+		return exitUsage
+	}
+	// Only validators given on the command line filter the export, not the
+	// tracked validators of the config file.
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "validators" {
+			q.Validators = cfg.ValidatorIndices
+		}
+	})
+	logger.Setup(cfg.LogFormat, os.Stderr)
+	logger.SetLevel(cfg.LogLevel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	genesis, err := resolveGenesisTime(ctx, cfg, nil)
+	if err != nil {
+		logger.Error("%v", err)
+		return exitError
+	}
+
+	out := os.Stdout
+	if output != "-" {
+		if out, err = os.Create(output); err != nil {
+			logger.Error("Failed to create the export file: %v", err)
+			return exitError
+		}
+	}
+	groups := cfg.ValidatorGroups()
+	reader := adapters.NewFileResultReader(filepath.Join(cfg.DataDir, "results"))
+	rows, err := exportResults(out, format, reader, q, genesis, func(v domain.ValidatorIndex) string { return groups[v] })
+	if err == nil && output != "-" {
+		err = out.Close()
+	}
+	if err != nil {
+		logger.Error("Export failed: %v", err)
+		return exitError
+	}
+	logger.Info("Exported %d duty results", rows)
+	return exitOK
+}
+
+// exportResults writes the results selected by q to out in format, buffered.
+func exportResults(
+	out io.Writer,
+	format string,
+	reader ports.ResultReader,
+	q services.ExportQuery,
+	genesis time.Time,
+	groupOf func(domain.ValidatorIndex) string,
+) (int, error) {
+	buf := bufio.NewWriterSize(out, 1<<16)
+	var w ports.ResultRowWriter
+	if format == "parquet" {
+		w = adapters.NewParquetResultWriter(buf)
+	} else {
+		var err error
+		if w, err = adapters.NewCSVResultWriter(buf); err != nil {
+			return 0, err
+		}
+	}
+	rows, err := services.ExportResults(reader, q, genesis, groupOf, w)
+	if err != nil {
+		return rows, err
+	}
+	if err := w.Close(); err != nil {
+		return rows, err
+	}
+	return rows, buf.Flush()
+}
+
+// parseExportTime parses an RFC 3339 time or a UTC date; "" is the zero time.
+func parseExportTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: want RFC 3339 or YYYY-MM-DD", s)
+	}
+	return t, nil
+}
+
+// resultsExport serves GET /api/v1/results/export and the effectiveness
+// ranking, both per validator, to requests bearing token. Groups come from
+// the running checker, once set, so they follow config reloads.
+type resultsExport struct {
+	reader  ports.ResultReader
+	genesis time.Time
+	token   string
+	checker atomic.Pointer[services.DutiesChecker]
+}
+
+// serveExport takes the export command's options as query parameters:
+// format, from_epoch, to_epoch, from, to, validators and group.
+func (e *resultsExport) serveExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !e.authorized(w, r) {
+		return
+	}
+	params := r.URL.Query()
+	format := params.Get("format")
+	var contentType string
+	switch format {
+	case "", "csv":
+		format, contentType = "csv", "text/csv"
+	case "parquet":
+		contentType = "application/vnd.apache.parquet"
+	default:
+		http.Error(w, fmt.Sprintf("format: %q is not one of csv, parquet", format), http.StatusBadRequest)
+		return
+	}
+	q, err := parseExportParams(params.Get)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	groupOf := func(domain.ValidatorIndex) string { return "" }
+	if checker := e.checker.Load(); checker != nil {
+		groupOf = checker.ValidatorGroup
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=duty-results.%s", format))
+	// Headers are sent with the first rows: a later error can only cut the
+	// response short, which leaves a Parquet file without its footer.
+	if _, err := exportResults(w, format, e.reader, q, e.genesis, groupOf); err != nil {
+		logger.Error("Export of duty results failed: %v", err)
+	}
+}
+
+// authorized reports whether r bears the token, and answers 401 if not.
+func (e *resultsExport) authorized(w http.ResponseWriter, r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(e.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// parseExportParams reads an export query from the query parameters.
+func parseExportParams(get func(string) string) (services.ExportQuery, error) {
+	var q services.ExportQuery
+	for name, epoch := range map[string]*domain.Epoch{"from_epoch": &q.FromEpoch, "to_epoch": &q.ToEpoch} {
+		if v := get(name); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return q, fmt.Errorf("%s: %q is not an epoch", name, v)
+			}
+			*epoch = domain.Epoch(n)
+		}
+	}
+	var err error
+	if q.From, err = parseExportTime(get("from")); err != nil {
+		return q, fmt.Errorf("from: %w", err)
+	}
+	if q.To, err = parseExportTime(get("to")); err != nil {
+		return q, fmt.Errorf("to: %w", err)
+	}
+	if v := get("validators"); v != "" {
+		if q.Validators, err = config.ParseIndices(v); err != nil {
+			return q, fmt.Errorf("validators: %w", err)
+		}
+	}
+	q.Group = get("group")
+	return q, nil
+}
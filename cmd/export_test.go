@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Marketen/duties-indexer/internal/adapters"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+// TestServeExportToken checks that the per-validator endpoints answer only
+// requests bearing the export token.
+func TestServeExportToken(t *testing.T) {
+	e := &resultsExport{
+		reader:  adapters.NewFileResultReader(t.TempDir()),
+		genesis: domain.MainnetGenesisTime,
+		token:   "s3cret",
+	}
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "no token", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", authorization: "Bearer guess", wantStatus: http.StatusUnauthorized},
+		{name: "not a bearer token", authorization: "s3cret", wantStatus: http.StatusUnauthorized},
+		{name: "token", authorization: "Bearer s3cret", wantStatus: http.StatusOK},
+	}
+	endpoints := map[string]http.HandlerFunc{
+		"/api/v1/results/export": e.serveExport,
+		"/api/v1/effectiveness":  e.serveEffectiveness,
+	}
+	for path, serve := range endpoints {
+		for _, tt := range tests {
+			t.Run(path+"/"+tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				if tt.authorization != "" {
+					req.Header.Set("Authorization", tt.authorization)
+				}
+				rec := httptest.NewRecorder()
+				serve(rec, req)
+				if rec.Code != tt.wantStatus {
+					t.Errorf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/application/services"
+)
+
+// healthCheck is the result of one readiness check.
+type healthCheck struct {
+	OK      bool        `json:"ok"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// healthResponse is the JSON body of /healthz and /readyz.
+type healthResponse struct {
+	Status string                 `json:"status"` // "ok" or "fail"
+	Checks map[string]healthCheck `json:"checks,omitempty"`
+}
+
+// readiness holds what /readyz checks. The beacon monitor and checker are
+// set once the service has started; until then the instance is not ready.
+type readiness struct {
+	dataDir     string
+	maxEpochLag domain.Epoch
+
+	beacon  atomic.Pointer[ports.BeaconNodeMonitor]
+	checker atomic.Pointer[services.DutiesChecker]
+}
+
+// setService makes the readiness checks use the running service.
+func (r *readiness) setService(beacon ports.BeaconNodeMonitor, checker *services.DutiesChecker) {
+	r.beacon.Store(&beacon)
+	r.checker.Store(checker)
+}
+
+// serveHealthz answers 200 as long as the process can serve HTTP.
+func serveHealthz(w http.ResponseWriter, _ *http.Request) {
+	writeHealth(w, healthResponse{Status: "ok"})
+}
+
+// serveReadyz answers 200 only if every check passes, 503 otherwise, with
+// the outcome of each check in the body.
+func (r *readiness) serveReadyz(w http.ResponseWriter, _ *http.Request) {
+	beacon, finalized := r.checkBeacon()
+	checks := map[string]healthCheck{
+		"beacon":     beacon,
+		"processing": r.checkProcessing(finalized),
+		"storage":    r.checkStorage(),
+	}
+	resp := healthResponse{Status: "ok", Checks: checks}
+	for _, c := range checks {
+		if !c.OK {
+			resp.Status = "fail"
+		}
+	}
+	writeHealth(w, resp)
+}
+
+// checkBeacon passes if at least one beacon node is reachable and synced. It
+// also returns the highest finalized epoch among those nodes.
+func (r *readiness) checkBeacon() (healthCheck, domain.Epoch) {
+	monitor := r.beacon.Load()
+	if monitor == nil {
+		return healthCheck{Message: "starting"}, 0
+	}
+	statuses := (*monitor).NodeStatuses()
+	var healthy []string
+	var finalized domain.Epoch
+	for _, s := range statuses {
+		if s.Reachable && !s.Syncing {
+			healthy = append(healthy, s.Name)
+			finalized = max(finalized, s.FinalizedEpoch)
+		}
+	}
+	if len(healthy) == 0 {
+		return healthCheck{Message: "no beacon node is reachable and synced", Details: statuses}, 0
+	}
+	return healthCheck{
+		OK:      true,
+		Message: fmt.Sprintf("%d/%d beacon nodes reachable and synced: %s", len(healthy), len(statuses), strings.Join(healthy, ", ")),
+		Details: statuses,
+	}, finalized
+}
+
+// checkProcessing passes if the last processed epoch is within maxEpochLag
+// epochs of the finalized epoch reported by the beacon nodes.
+func (r *readiness) checkProcessing(finalized domain.Epoch) healthCheck {
+	checker := r.checker.Load()
+	if checker == nil {
+		return healthCheck{Message: "starting"}
+	}
+	processed, ok := checker.LastProcessedEpoch()
+	if !ok {
+		return healthCheck{Message: "no epoch processed yet"}
+	}
+	var lag domain.Epoch
+	if finalized > processed {
+		lag = finalized - processed
+	}
+	msg := fmt.Sprintf("last processed epoch %d, finalized epoch %d (%d behind, max %d)", processed, finalized, lag, r.maxEpochLag)
+	return healthCheck{OK: lag <= r.maxEpochLag, Message: msg}
+}
+
+// checkStorage passes if a file can be created in the data directory.
+func (r *readiness) checkStorage() healthCheck {
+	if err := os.MkdirAll(r.dataDir, 0o755); err != nil {
+		return healthCheck{Message: fmt.Sprintf("data_dir %s: %v", r.dataDir, err)}
+	}
+	f, err := os.CreateTemp(r.dataDir, ".readyz-*")
+	if err != nil {
+		return healthCheck{Message: fmt.Sprintf("data_dir %s is not writable: %v", r.dataDir, err)}
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return healthCheck{OK: true, Message: fmt.Sprintf("data_dir %s is writable", r.dataDir)}
+}
+
+func writeHealth(w http.ResponseWriter, resp healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(resp)
+}
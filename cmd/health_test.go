@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/application/services"
+	"github.com/Marketen/duties-indexer/internal/testutil"
+)
+
+// staticMonitor is a ports.BeaconNodeMonitor reporting fixed statuses.
+type staticMonitor []domain.BeaconNodeStatus
+
+var _ ports.BeaconNodeMonitor = staticMonitor(nil)
+
+func (m staticMonitor) NodeStatuses() []domain.BeaconNodeStatus { return m }
+
+func TestCheckBeacon(t *testing.T) {
+	synced := func(name string, finalized domain.Epoch) domain.BeaconNodeStatus {
+		return domain.BeaconNodeStatus{Name: name, Reachable: true, FinalizedEpoch: finalized}
+	}
+	tests := []struct {
+		name          string
+		statuses      []domain.BeaconNodeStatus
+		wantOK        bool
+		wantFinalized domain.Epoch
+	}{
+		{name: "all synced", statuses: []domain.BeaconNodeStatus{synced("a", 10), synced("b", 11)}, wantOK: true, wantFinalized: 11},
+		{
+			name: "syncing node ignored",
+			statuses: []domain.BeaconNodeStatus{
+				synced("a", 10),
+				{Name: "b", Reachable: true, Syncing: true, FinalizedEpoch: 20},
+			},
+			wantOK: true, wantFinalized: 10,
+		},
+		{
+			name: "none usable",
+			statuses: []domain.BeaconNodeStatus{
+				{Name: "a"},
+				{Name: "b", Reachable: true, Syncing: true, FinalizedEpoch: 20},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &readiness{}
+			r.setService(staticMonitor(tt.statuses), nil)
+			check, finalized := r.checkBeacon()
+			if check.OK != tt.wantOK || finalized != tt.wantFinalized {
+				t.Errorf("ok %t, finalized %d (%s); want %t, %d", check.OK, finalized, check.Message, tt.wantOK, tt.wantFinalized)
+			}
+		})
+	}
+
+	if check, _ := (&readiness{}).checkBeacon(); check.OK || check.Message != "starting" {
+		t.Errorf("before start: %+v, want not ok, starting", check)
+	}
+}
+
+func TestCheckProcessing(t *testing.T) {
+	// The chain is finalized at epoch 2, which Run processes right away.
+	chain := testutil.NewChain(t, fakechain.Config{Seed: 2, Validators: 64, CommitteesPerSlot: 1, Epochs: 4})
+	processed := services.NewDutiesChecker(chain, time.Minute, testutil.Validators(64))
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		processed.Run(context.Background(), stop)
+	}()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		if _, ok := processed.LastProcessedEpoch(); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no epoch processed")
+		}
+	}
+	close(stop)
+	<-done
+
+	tests := []struct {
+		name      string
+		checker   *services.DutiesChecker
+		finalized domain.Epoch
+		wantOK    bool
+	}{
+		{name: "starting"},
+		{name: "nothing processed", checker: services.NewDutiesChecker(chain, time.Minute, nil), finalized: 2},
+		{name: "up to date", checker: processed, finalized: 2, wantOK: true},
+		{name: "lag at the maximum", checker: processed, finalized: 5, wantOK: true},
+		{name: "lag over the maximum", checker: processed, finalized: 6},
+		{name: "beacon nodes behind", checker: processed, finalized: 0, wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &readiness{maxEpochLag: 3}
+			if tt.checker != nil {
+				r.setService(staticMonitor(nil), tt.checker)
+			}
+			if check := r.checkProcessing(tt.finalized); check.OK != tt.wantOK {
+				t.Errorf("ok %t (%s), want %t", check.OK, check.Message, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCheckStorage(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		dataDir string
+		wantOK  bool
+	}{
+		{name: "existing", dataDir: dir, wantOK: true},
+		{name: "created", dataDir: filepath.Join(dir, "new", "data"), wantOK: true},
+		{name: "under a file", dataDir: filepath.Join(file, "data")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &readiness{dataDir: tt.dataDir}
+			if check := r.checkStorage(); check.OK != tt.wantOK {
+				t.Errorf("ok %t (%s), want %t", check.OK, check.Message, tt.wantOK)
+			}
+			// The probe file must not be left behind.
+			if probes, _ := filepath.Glob(filepath.Join(tt.dataDir, ".readyz-*")); len(probes) > 0 {
+				t.Errorf("probe files left: %v", probes)
+			}
+		})
+	}
+}
+
+func TestServeReadyz(t *testing.T) {
+	r := &readiness{dataDir: t.TempDir(), maxEpochLag: 3}
+	rec := httptest.NewRecorder()
+	r.serveReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d before start, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	for _, check := range []string{`"beacon"`, `"processing"`, `"storage"`, `"status": "fail"`} {
+		if !strings.Contains(rec.Body.String(), check) {
+			t.Errorf("body lacks %s:\n%s", check, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/logger"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// startHTTPServer serves the operational endpoints (/metrics, /healthz,
+// /readyz) and, if exports is not nil and has an export token, the results
+// export and the effectiveness ranking on addr in the background. The caller
+// closes the returned server on shutdown.
+func startHTTPServer(addr string, ready *readiness, exports *resultsExport) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", serveHealthz)
+	mux.HandleFunc("/readyz", ready.serveReadyz)
+	if exports != nil && exports.token != "" {
+		mux.HandleFunc("/api/v1/results/export", exports.serveExport)
+		mux.HandleFunc("/api/v1/effectiveness", exports.serveEffectiveness)
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		logger.Info("HTTP server listening on %s", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("HTTP server failed: %v", err)
+		}
+	}()
+	return srv
+}
@@ -1,68 +1,57 @@
 package main
 
 import (
-	"context"
+	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
-
-	"github.com/Marketen/duties-indexer/internal/adapters"
-	"github.com/Marketen/duties-indexer/internal/application/services"
-	"github.com/Marketen/duties-indexer/internal/config"
-	"github.com/Marketen/duties-indexer/internal/logger"
+	"strings"
 )
 
-func main() {
-	cfg, err := config.Load()
-	if err != nil {
-		logger.Error("Failed to load config: %v", err)
-		os.Exit(1)
-	}
+const usage = `Usage: duties-indexer [command] [flags]
 
-	logger.Info("Starting duties-indexer")
-	logger.Info("Beacon node URL: %s", cfg.BeaconNodeURL)
-	logger.Info("Poll interval: %s", cfg.PollInterval)
-	logger.Info("Tracking %d validators", len(cfg.ValidatorIndices))
+Commands:
+  run               Run the duties checker (default)
+  config validate   Validate the configuration and print the effective merged config
+  check-epoch       Check the duties of one epoch, print every outcome and exit
+  explain           Show how the duties of one validator in one epoch were decided
+  export            Export stored duty results as CSV or Parquet
+  report            Build the performance report of a period from the stored results
+  effectiveness     Rank validators, groups or labels by their stored effectiveness
+  record            Check one epoch and record the beacon API calls into a fixture
+  replay            Check the epoch of a fixture offline, printing results as JSON Lines
 
-	beaconAdapter, err := adapters.NewBeaconAttestantAdapter(cfg.BeaconNodeURL)
-	if err != nil {
-		logger.Error("Failed to create beacon HTTP adapter: %v", err)
-		os.Exit(1)
-	}
+Run "duties-indexer <command> -h" for the flags of a command.
+`
 
-	// Decide which validator indices to track:
-	// - If VALIDATOR_INDICES is set in config, use those.
-	// - If empty, fall back to all active validators from the beacon node.
-	validatorIndices := cfg.ValidatorIndices
-	if len(validatorIndices) == 0 {
-		logger.Info("No validator indices configured; fetching all active validators from beacon node")
-		activeValidatorIndices, err := beaconAdapter.GetAllActiveValidatorIndices(context.Background())
-		if err != nil {
-			logger.Error("Failed to fetch active validator indices: %v", err)
-			os.Exit(1)
-		}
-		validatorIndices = activeValidatorIndices
+func main() {
+	args := os.Args[1:]
+	cmd := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
 	}
 
-	logger.Info("Tracking %d validators", len(validatorIndices))
-
-	dutiesChecker := services.NewDutiesChecker(
-		beaconAdapter,
-		cfg.PollInterval,
-		validatorIndices,
-	)
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Handle SIGINT / SIGTERM for graceful shutdown
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		dutiesChecker.Run(ctx)
-	}()
-
-	sig := <-sigCh
-	logger.Warn("Received signal %s, shutting down...", sig)
+	switch cmd {
+	case "run":
+		os.Exit(runService(args))
+	case "config":
+		os.Exit(runConfigCommand(args))
+	case "check-epoch":
+		os.Exit(runCheckEpochCommand(args))
+	case "explain":
+		os.Exit(runExplainCommand(args))
+	case "export":
+		os.Exit(runExportCommand(args))
+	case "report":
+		os.Exit(runReportCommand(args))
+	case "effectiveness":
+		os.Exit(runEffectivenessCommand(args))
+	case "record":
+		os.Exit(runRecordCommand(args))
+	case "replay":
+		os.Exit(runReplayCommand(args))
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(exitUsage)
+	}
 }
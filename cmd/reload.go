@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+
+	"github.com/Marketen/duties-indexer/internal/adapters"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/application/services"
+	"github.com/Marketen/duties-indexer/internal/config"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// reloadConfig re-reads the configuration with the original command-line
+// arguments and applies the settings that are safe to change at runtime:
+// tracked validators and groups, poll interval, concurrency, network
+// baseline thresholds, correlated failures, notifier targets, log level and
+// shutdown grace period. The checker keeps its last processed epoch. It
+// returns the configuration now in effect, which is the old one if the new
+// one is invalid.
+func reloadConfig(
+	ctx context.Context,
+	args []string,
+	current *config.Config,
+	beacon ports.BeaconChainAdapter,
+	checker *services.DutiesChecker,
+) *config.Config {
+	logger.Info("Received SIGHUP, reloading configuration")
+	next, err := config.Load(args)
+	if err != nil {
+		logger.Error("Config reload rejected, keeping current configuration: %v", err)
+		return current
+	}
+
+	// Settings bound at startup are kept as they are until the next restart.
+	kept := current.KeepRestartOnly(next)
+	for _, k := range kept {
+		logger.Warn("Config reload: %s, changing it requires a restart", k)
+	}
+	changes := current.Diff(next)
+	if len(changes) == 0 {
+		logger.Info("Config reload: no changes applied")
+		return current
+	}
+	for _, c := range changes {
+		logger.Info("Config reload: %s", c)
+	}
+
+	indices, err := resolveValidatorIndices(ctx, next, beacon)
+	if err != nil {
+		logger.Error("Config reload rejected, could not resolve validators: %v", err)
+		return current
+	}
+
+	logger.SetLevel(next.LogLevel)
+	checker.SetPollInterval(next.PollInterval)
+	checker.SetConcurrency(next.Concurrency)
+	checker.SetValidatorIndices(indices)
+	checker.SetValidatorGroups(next.ValidatorGroups())
+	checker.SetValidatorLabels(next.ValidatorLabels())
+	checker.SetNetworkThresholds(next.NetworkThresholds())
+	checker.SetCorrelationPolicy(next.CorrelationPolicy())
+	if next.Notifications != current.Notifications {
+		// Batches already queued on the old webhook are still delivered.
+		var notifier ports.Notifier
+		if next.Notifications.WebhookURL != "" {
+			notifier = adapters.NewWebhookNotifier(next.Notifications.WebhookURL)
+		}
+		closeNotifier(checker.SetNotifier(notifier))
+	}
+	logger.Info("Config reloaded; tracking %d validators", len(indices))
+	return next
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/services"
+	"github.com/Marketen/duties-indexer/internal/config"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+func TestReloadConfig(t *testing.T) {
+	for _, name := range []string{"CONFIG_FILE", "BEACON_NODE_URL", "POLL_INTERVAL_SECONDS", "CONCURRENCY", "VALIDATOR_INDICES", "DATA_DIR"} {
+		t.Setenv(name, "")
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("beacon_nodes: [{url: http://a:5052}]\nvalidators: [1, 2]\n")
+	args := []string{"--config", path}
+	current, err := config.Load(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := services.NewDutiesChecker(nil, current.PollInterval, current.TrackedValidators())
+
+	var logs bytes.Buffer
+	logger.Setup(logger.FormatJSON, &logs)
+	logger.SetLevel("INFO")
+	t.Cleanup(func() {
+		logger.Setup(os.Getenv("LOG_FORMAT"), os.Stderr)
+		logger.SetLevel(os.Getenv("LOG_LEVEL"))
+	})
+
+	write(`beacon_nodes: [{url: http://b:5052}]
+validators: [1, 2, 3]
+poll_interval: 2m
+data_dir: /var/lib/other
+notifications: {webhook_url: "https://hooks.example.com/services/secret"}
+`)
+	next := reloadConfig(context.Background(), args, current, nil, checker)
+	t.Cleanup(func() { closeNotifier(checker.SetNotifier(nil)) })
+
+	if next.PollInterval != 2*time.Minute || len(next.TrackedValidators()) != 3 {
+		t.Errorf("reloadable settings not applied: %+v", next)
+	}
+	if next.BeaconNodes[0].URL != "http://a:5052" || next.DataDir != "data" {
+		t.Errorf("restart-only settings changed: %+v", next)
+	}
+	out := logs.String()
+	if strings.Contains(out, "secret") {
+		t.Errorf("webhook secret logged:\n%s", out)
+	}
+	for _, field := range []string{"beacon_nodes", "data_dir"} {
+		if n := strings.Count(out, field); n != 1 {
+			t.Errorf("%s logged %d times, want once as kept:\n%s", field, n, out)
+		}
+	}
+	for _, want := range []string{"data_dir: keeping data, changing it requires a restart", "poll_interval: 1m0s -> 2m0s", "validators: +1 -0"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log does not contain %q:\n%s", want, out)
+		}
+	}
+
+	write("beacon_nodes: [{url: http://a:5052}]\nvalidators: [x]\n")
+	if got := reloadConfig(context.Background(), args, next, nil, checker); got != next {
+		t.Error("invalid configuration applied")
+	}
+}
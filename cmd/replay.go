@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/services"
+	"github.com/Marketen/duties-indexer/internal/config"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// runRecordCommand implements "record": it checks one epoch against the
+// configured beacon nodes, like "run" would, and records every beacon API
+// call into a fixture directory for "replay".
+func runRecordCommand(args []string) int {
+	var dir, note string
+	var epochFlag int64
+	cfg, err := config.LoadWithFlags(args, func(fs *flag.FlagSet) {
+		fs.StringVar(&dir, "fixture", "", "directory to write the fixture to (required)")
+		fs.Int64Var(&epochFlag, "epoch", -1, "finalized epoch to record (default the latest)")
+		fs.StringVar(&note, "note", "", "free text stored in the manifest, e.g. why the epoch is recorded")
+	})
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if dir == "" {
+		fmt.Fprintln(os.Stderr, "usage: duties-indexer record --fixture <dir> [--epoch N] [flags]")
+		return exitUsage
+	}
+	logger.Setup(cfg.LogFormat, os.Stderr)
+	logger.SetLevel(cfg.LogLevel)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	nodes := beaconNodes(cfg)
+	names := make([]string, len(nodes))
+	for i, n := range nodes {
+		names[i] = n.Name
+	}
+	failoverAdapter, err := adapters.NewFailoverBeaconAdapter(ctx, nodes, cfg.BeaconHealthInterval)
+	if err != nil {
+		logger.Error("Failed to create beacon HTTP adapter: %v", err)
+		return exitError
+	}
+	recorder, err := adapters.NewRecordingBeaconAdapter(adapters.NewRetryingBeaconAdapter(failoverAdapter, retryPolicy(cfg)), dir)
+	if err != nil {
+		logger.Error("Failed to create fixture: %v", err)
+		return exitError
+	}
+
+	manifest := adapters.FixtureManifest{RecordedAt: time.Now().UTC(), Source: strings.Join(names, ","), Note: note}
+	results, err := recordEpoch(ctx, cfg, recorder, epochFlag, &manifest)
+	if closeErr := recorder.Close(manifest); closeErr != nil {
+		err = errors.Join(err, closeErr)
+	}
+	if err != nil {
+		logger.Error("Failed to record epoch: %v", err)
+		return exitError
+	}
+	logger.Info("Recorded epoch %d for %d validators into %s: %s",
+		manifest.Epoch, len(manifest.Validators), dir, summarizeResults(results))
+	return exitOK
+}
+
+// recordEpoch resolves the validators and the epoch, filling them into
+// manifest, and checks the epoch through beacon.
+func recordEpoch(
+	ctx context.Context,
+	cfg *config.Config,
+	beacon adapters.RecordingBeaconAdapter,
+	epochFlag int64,
+	manifest *adapters.FixtureManifest,
+) ([]domain.DutyResult, error) {
+	indices, err := resolveValidatorIndices(ctx, cfg, beacon)
+	if err != nil {
+		return nil, fmt.Errorf("resolving validators: %w", err)
+	}
+	manifest.Validators = indices
+	if epochFlag >= 0 {
+		manifest.Epoch = domain.Epoch(epochFlag)
+	} else if manifest.Epoch, err = beacon.GetFinalizedEpoch(ctx); err != nil {
+		return nil, fmt.Errorf("fetching finalized epoch: %w", err)
+	}
+	checker := services.NewDutiesChecker(beacon, cfg.PollInterval, indices)
+	checker.SetConcurrency(cfg.Concurrency)
+	checker.SetNetworkThresholds(cfg.NetworkThresholds())
+	return checker.CheckEpoch(ctx, manifest.Epoch)
+}
+
+// runReplayCommand implements "replay": it checks the epoch of a fixture
+// offline and prints the results as JSON Lines on stdout.
+func runReplayCommand(args []string) int {
+	fs := flag.NewFlagSet("duties-indexer replay", flag.ContinueOnError)
+	dir := fs.String("fixture", "", "fixture directory written by \"record\" (required)")
+	validators := fs.String("validators", "", "comma-separated subset of the recorded validators (default all)")
+	concurrency := fs.Int("concurrency", domain.DefaultConcurrency, "calls kept in flight per fan-out")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if *dir == "" || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: duties-indexer replay --fixture <dir> [--validators 1,2,3]")
+		return exitUsage
+	}
+
+	beacon, manifest, err := adapters.OpenReplayBeaconAdapter(*dir)
+	if err != nil {
+		logger.Error("Failed to open fixture: %v", err)
+		return exitError
+	}
+	indices := manifest.Validators
+	if *validators != "" {
+		if indices, err = config.ParseIndices(*validators); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --validators: %v\n", err)
+			return exitUsage
+		}
+	}
+	logger.Info("Replaying epoch %d for %d validators, recorded %s from %s",
+		manifest.Epoch, len(indices), manifest.RecordedAt.Format(time.RFC3339), manifest.Source)
+
+	checker := services.NewDutiesChecker(beacon, 0, indices)
+	checker.SetConcurrency(*concurrency)
+	results, err := checker.CheckEpoch(context.Background(), manifest.Epoch)
+	if err != nil {
+		logger.Error("Replay failed: %v", err)
+		return exitError
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, r := range results {
+		if err := enc.Encode(r); err != nil {
+			logger.Error("Writing results: %v", err)
+			return exitError
+		}
+	}
+	logger.Info("Epoch %d: %s", manifest.Epoch, summarizeResults(results))
+	return exitOK
+}
+
+// summarizeResults counts results by outcome.
+func summarizeResults(results []domain.DutyResult) string {
+	counts := make(map[domain.DutyOutcome]int)
+	for _, r := range results {
+		counts[r.Outcome]++
+	}
+	return fmt.Sprintf("%d duties, %d successful, %d missed, %d unknown", len(results),
+		counts[domain.OutcomeSuccess], counts[domain.OutcomeMissed], counts[domain.OutcomeUnknown])
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/application/services"
+	"github.com/Marketen/duties-indexer/internal/config"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// reportDelay is how long after the end of a period its scheduled report is
+// built, so that the last epochs of the period are finalized and stored.
+const reportDelay = 30 * time.Minute
+
+// runReportCommand implements "report": it builds the performance report of
+// a period from the stored results and prints it.
+func runReportCommand(args []string) int {
+	var period, from, to, format, output string
+	var notify bool
+	cfg, err := config.LoadWithFlags(args, func(fs *flag.FlagSet) {
+		fs.StringVar(&period, "period", services.ReportWeekly, "report on the last complete day or week: daily or weekly")
+		fs.StringVar(&from, "from", "", "start of the period instead (RFC 3339 or YYYY-MM-DD)")
+		fs.StringVar(&to, "to", "", "end of the period, excluded (RFC 3339 or YYYY-MM-DD)")
+		fs.StringVar(&format, "format", "markdown", "output format: markdown, html or json")
+		fs.StringVar(&output, "output", "-", "output file, - for stdout")
+		fs.BoolVar(&notify, "notify", false, "also send the report through the configured webhook")
+	})
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	start, end := services.LastReportPeriod(period, time.Now())
+	if from != "" || to != "" {
+		if start, err = parseExportTime(from); err == nil {
+			end, err = parseExportTime(to)
+		}
+	}
+	if err != nil || start.IsZero() || !end.After(start) || !adapters.IsReportFormat(format) ||
+		(period != services.ReportDaily && period != services.ReportWeekly) ||
+		(notify && cfg.Notifications.WebhookURL == "") {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		fmt.Fprintln(os.Stderr, "usage: duties-indexer report [--period daily|weekly | --from TIME --to TIME] [--format markdown|html|json] [--output FILE] [--notify] [flags]")
+		return exitUsage
+	}
+	logger.Setup(cfg.LogFormat, os.Stderr)
+	logger.SetLevel(cfg.LogLevel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	genesis, err := resolveGenesisTime(ctx, cfg, nil)
+	if err != nil {
+		logger.Error("%v", err)
+		return exitError
+	}
+
+	groups := cfg.ValidatorGroups()
+	reader := adapters.NewFileResultReader(filepath.Join(cfg.DataDir, "results"))
+	report, err := services.BuildReport(reader, start, end, genesis, func(v domain.ValidatorIndex) string { return groups[v] })
+	if err != nil {
+		logger.Error("Failed to build the report: %v", err)
+		return exitError
+	}
+
+	out := os.Stdout
+	if output != "-" {
+		if out, err = os.Create(output); err != nil {
+			logger.Error("Failed to create the report file: %v", err)
+			return exitError
+		}
+	}
+	err = adapters.RenderReport(out, format, report)
+	if output != "-" {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		logger.Error("Writing the report: %v", err)
+		return exitError
+	}
+
+	if notify {
+		notifier := adapters.NewWebhookNotifier(cfg.Notifications.WebhookURL)
+		ctx, cancel := context.WithTimeout(context.Background(), notifierFlushTimeout)
+		defer cancel()
+		err := notifier.Notify(ctx, []domain.Notification{reportNotification(report)})
+		if err == nil {
+			err = notifier.Close(ctx)
+		}
+		if err != nil {
+			logger.Error("Failed to send the report: %v", err)
+			return exitError
+		}
+	}
+	return exitOK
+}
+
+// reportScheduler builds the scheduled reports of the running service.
+type reportScheduler struct {
+	cfg     config.ReportsConfig
+	dir     string
+	reader  ports.ResultReader
+	genesis time.Time
+	checker *services.DutiesChecker
+}
+
+// run builds a report reportDelay after the end of every period until ctx
+// is done. Periods that ended before the service started are not reported,
+// unless it started within reportDelay of their end.
+func (s *reportScheduler) run(ctx context.Context) {
+	periodDays := 1
+	if s.cfg.Schedule == services.ReportWeekly {
+		periodDays = 7
+	}
+	for {
+		_, end := services.LastReportPeriod(s.cfg.Schedule, time.Now())
+		next := end.Add(reportDelay)
+		if !next.After(time.Now()) {
+			next = next.AddDate(0, 0, periodDays)
+		}
+		logger.Debug("Next %s report at %s", s.cfg.Schedule, next.Format(time.RFC3339))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		s.generate(ctx, next)
+	}
+}
+
+// generate builds and delivers the report of the last period ended at now.
+func (s *reportScheduler) generate(ctx context.Context, now time.Time) {
+	from, to := services.LastReportPeriod(s.cfg.Schedule, now.Add(-reportDelay))
+	report, err := services.BuildReport(s.reader, from, to, s.genesis, s.checker.ValidatorGroup)
+	if err != nil {
+		logger.Error("Failed to build the %s report of %s: %v", s.cfg.Schedule, from.Format(time.DateOnly), err)
+		return
+	}
+	name := fmt.Sprintf("%s-%s", s.cfg.Schedule, from.Format(time.DateOnly))
+	paths, err := adapters.WriteReportFiles(s.dir, name, s.cfg.Formats, report)
+	if err != nil {
+		logger.Error("Failed to write the %s report of %s: %v", s.cfg.Schedule, from.Format(time.DateOnly), err)
+	} else {
+		logger.Info("Wrote the %s report of %s: %v", s.cfg.Schedule, from.Format(time.DateOnly), paths)
+	}
+	if notifier := s.checker.Notifier(); s.cfg.Notify && notifier != nil {
+		if err := notifier.Notify(ctx, []domain.Notification{reportNotification(report)}); err != nil {
+			logger.Error("Failed to send the %s report: %v", s.cfg.Schedule, err)
+		}
+	}
+}
+
+// reportNotification wraps report for the notifier, with a one-line summary.
+func reportNotification(report domain.PerformanceReport) domain.Notification {
+	msg := fmt.Sprintf("Performance report %s to %s: %.2f%% of attestations included, %d of %d proposals missed",
+		report.From.Format(time.DateOnly), report.To.Format(time.DateOnly),
+		100*report.Total.Attestations.InclusionRate, report.Total.Proposals.Missed, report.Total.Proposals.Duties)
+	return domain.Notification{Kind: domain.NotificationPerformanceReport, Message: msg, Report: &report}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/application/services"
+	"github.com/Marketen/duties-indexer/internal/config"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// Process exit codes.
+const (
+	exitOK = 0
+	// exitError is returned when the service could not start.
+	exitError = 1
+	// exitUsage is returned for an unknown command.
+	exitUsage = 2
+	// exitInterrupted is returned when the epoch in progress had to be
+	// aborted at shutdown, because the grace period expired or a second
+	// signal arrived. Its results were rolled back.
+	exitInterrupted = 3
+)
+
+// notifierFlushTimeout bounds how long pending notifications are given to be
+// delivered at shutdown.
+const notifierFlushTimeout = 10 * time.Second
+
+// runService runs the long-lived duties checker and returns the process exit code.
+func runService(args []string) int {
+	cfg, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		logger.Error("Failed to load config: %v", err)
+		return exitError
+	}
+	logger.Setup(cfg.LogFormat, os.Stderr)
+	logger.SetLevel(cfg.LogLevel)
+
+	logger.Info("Starting duties-indexer")
+	for _, n := range cfg.BeaconNodes {
+		logger.Info("Beacon node %s: %s", n.Name, n.URL)
+	}
+	logger.Info("Poll interval: %s", cfg.PollInterval)
+	logger.Info("Configured %d groups", len(cfg.Groups))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	failoverAdapter, err := adapters.NewFailoverBeaconAdapter(ctx, beaconNodes(cfg), cfg.BeaconHealthInterval)
+	if err != nil {
+		logger.Error("Failed to create beacon HTTP adapter: %v", err)
+		return exitError
+	}
+	// Only result exports and reports convert slots to times.
+	var genesis time.Time
+	if cfg.Results.Enabled || cfg.Reports.Schedule != "" {
+		if genesis, err = resolveGenesisTime(ctx, cfg, failoverAdapter); err != nil {
+			logger.Error("%v", err)
+			return exitError
+		}
+	}
+
+	ready := &readiness{dataDir: cfg.DataDir, maxEpochLag: domain.Epoch(cfg.Readiness.MaxEpochLag)}
+	var exports *resultsExport
+	if cfg.Results.Enabled {
+		exports = &resultsExport{
+			reader:  adapters.NewFileResultReader(filepath.Join(cfg.DataDir, "results")),
+			genesis: genesis,
+			token:   cfg.Results.ExportToken,
+		}
+	}
+	if cfg.HTTPListenAddress != "" {
+		srv := startHTTPServer(cfg.HTTPListenAddress, ready, exports)
+		defer srv.Close()
+	}
+
+	retryingAdapter := adapters.NewRetryingBeaconAdapter(failoverAdapter, retryPolicy(cfg))
+	beaconAdapter := adapters.NewCachingBeaconAdapter(retryingAdapter,
+		adapters.BlockCachePolicy{MaxBlocks: cfg.BlockCache.MaxBlocks, MaxAge: cfg.BlockCache.MaxAge},
+	)
+
+	validatorIndices, err := resolveValidatorIndices(ctx, cfg, beaconAdapter)
+	if err != nil {
+		logger.Error("Failed to fetch active validator indices: %v", err)
+		return exitError
+	}
+
+	logger.Info("Tracking %d validators", len(validatorIndices))
+
+	dutiesChecker := services.NewDutiesChecker(
+		beaconAdapter,
+		cfg.PollInterval,
+		validatorIndices,
+	)
+	dutiesChecker.SetConcurrency(cfg.Concurrency)
+	dutiesChecker.SetValidatorGroups(cfg.ValidatorGroups())
+	dutiesChecker.SetValidatorLabels(cfg.ValidatorLabels())
+	dutiesChecker.SetNetworkThresholds(cfg.NetworkThresholds())
+	dutiesChecker.SetCorrelationPolicy(cfg.CorrelationPolicy())
+	if cfg.Events.Enabled {
+		dutiesChecker.UseEventStream(failoverAdapter.EventSource(cfg.Events.IdleTimeout))
+	}
+	if cfg.HeadTracking.Enabled {
+		// Head blocks can be reorged, so they must not go through the block cache.
+		dutiesChecker.EnableHeadTracking(retryingAdapter)
+	}
+	dutiesChecker.SetNonFinalityPolicy(services.NonFinalityPolicy{
+		Threshold:  domain.Epoch(cfg.NonFinality.ThresholdEpochs),
+		Fallback:   cfg.NonFinality.Fallback,
+		HeadOffset: domain.Epoch(cfg.NonFinality.HeadOffset),
+	}, retryingAdapter)
+	if cfg.Notifications.WebhookURL != "" {
+		dutiesChecker.SetNotifier(adapters.NewWebhookNotifier(cfg.Notifications.WebhookURL))
+	}
+	if cfg.Results.Enabled {
+		store, err := adapters.NewFileResultStore(filepath.Join(cfg.DataDir, "results"), cfg.Results.RetentionEpochs)
+		if err == nil {
+			err = dutiesChecker.SetResultStore(store)
+		}
+		if err != nil {
+			logger.Error("Failed to open the results store: %v", err)
+			return exitError
+		}
+		baselines, err := adapters.NewFileBaselineStore(filepath.Join(cfg.DataDir, "baseline"), cfg.Results.RetentionEpochs)
+		if err != nil {
+			logger.Error("Failed to open the network baseline store: %v", err)
+			return exitError
+		}
+		dutiesChecker.SetBaselineStore(baselines)
+	}
+
+	if cfg.Verification.Enabled {
+		enableVerification(cfg, dutiesChecker)
+	}
+
+	ready.setService(failoverAdapter, dutiesChecker)
+	if exports != nil {
+		exports.checker.Store(dutiesChecker)
+	}
+	if cfg.Reports.Schedule != "" {
+		scheduler := &reportScheduler{
+			cfg:     cfg.Reports,
+			dir:     filepath.Join(cfg.DataDir, "reports"),
+			reader:  adapters.NewFileResultReader(filepath.Join(cfg.DataDir, "results")),
+			genesis: genesis,
+			checker: dutiesChecker,
+		}
+		go scheduler.run(ctx)
+	}
+
+	// Handle SIGINT / SIGTERM for graceful shutdown, SIGHUP for config reload
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dutiesChecker.Run(ctx, stop)
+	}()
+
+	for sig := range sigCh {
+		if sig == syscall.SIGHUP {
+			cfg = reloadConfig(ctx, args, cfg, beaconAdapter, dutiesChecker)
+			continue
+		}
+		logger.Warn("Received signal %s, shutting down after the current check (grace period %s)...", sig, cfg.ShutdownGracePeriod)
+		break
+	}
+
+	code := shutdown(cfg.ShutdownGracePeriod, sigCh, stop, done, cancel)
+	closeNotifier(dutiesChecker.SetNotifier(nil))
+	logger.Info("Shut down")
+	return code
+}
+
+// closeNotifier delivers the notifications still pending on notifier, if
+// any, for at most notifierFlushTimeout.
+func closeNotifier(notifier ports.Notifier) {
+	if notifier == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifierFlushTimeout)
+	defer cancel()
+	if err := notifier.Close(ctx); err != nil {
+		logger.Error("Failed to flush notifications: %v", err)
+	}
+}
+
+// shutdown stops the checker from starting new checks and waits up to grace
+// for the one in progress. When grace expires or another SIGINT / SIGTERM
+// arrives, abort cancels the check, which rolls back its results, and
+// exitInterrupted is returned.
+func shutdown(grace time.Duration, sigCh <-chan os.Signal, stop chan<- struct{}, done <-chan struct{}, abort context.CancelFunc) int {
+	close(stop)
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	for aborting := false; !aborting; {
+		select {
+		case <-done:
+			return exitOK
+		case <-timer.C:
+			logger.Warn("Grace period of %s expired, aborting the current check", grace)
+			aborting = true
+		case sig := <-sigCh:
+			if sig != syscall.SIGHUP {
+				logger.Warn("Received signal %s again, aborting the current check", sig)
+				aborting = true
+			}
+		}
+	}
+	abort()
+	<-done
+	return exitInterrupted
+}
+
+// enableVerification creates one independent adapter per verification node,
+// so each node's answers can be compared, and a recorder for the discrepancies.
+// Verification is optional: a node that cannot be set up is left out, and
+// with fewer than two nodes left the service runs without verification.
+func enableVerification(cfg *config.Config, checker *services.DutiesChecker) {
+	var nodes []services.VerificationNode
+	for _, n := range cfg.VerificationNodes() {
+		adapter, err := adapters.NewBeaconAttestantAdapter(n.URL, n.RateLimit)
+		if err != nil {
+			logger.Error("Verification: leaving out beacon node %s: %v", n.Name, err)
+			continue
+		}
+		nodes = append(nodes, services.VerificationNode{
+			Name:    n.Name,
+			Adapter: adapters.NewRetryingBeaconAdapter(adapter, retryPolicy(cfg)),
+		})
+	}
+	if len(nodes) < 2 {
+		logger.Error("Verification: %d usable beacon nodes, at least 2 are needed; running without verification", len(nodes))
+		return
+	}
+	recorder, err := adapters.NewFileDiscrepancyRecorder(filepath.Join(cfg.DataDir, "discrepancies"))
+	if err != nil {
+		logger.Error("Verification: could not open the discrepancy recorder, running without verification: %v", err)
+		return
+	}
+	checker.EnableVerification(nodes, recorder)
+	logger.Info("Verification mode: cross-checking %d beacon nodes", len(nodes))
+}
+
+func retryPolicy(cfg *config.Config) adapters.RetryPolicy {
+	return adapters.RetryPolicy{
+		MaxAttempts:    cfg.BeaconRetry.MaxAttempts,
+		InitialBackoff: cfg.BeaconRetry.InitialBackoff,
+		MaxBackoff:     cfg.BeaconRetry.MaxBackoff,
+	}
+}
+
+// resolveGenesisTime returns the configured genesis_time or, when it is
+// unset, the genesis time of the beacon nodes, connecting to them if beacon
+// is nil.
+func resolveGenesisTime(ctx context.Context, cfg *config.Config, beacon adapters.FailoverBeaconAdapter) (time.Time, error) {
+	if !cfg.GenesisTime.IsZero() {
+		return cfg.GenesisTime, nil
+	}
+	if beacon == nil {
+		var err error
+		if beacon, err = adapters.NewFailoverBeaconAdapter(ctx, beaconNodes(cfg), cfg.BeaconHealthInterval); err != nil {
+			return time.Time{}, fmt.Errorf("creating beacon HTTP adapter to fetch the genesis time: %w", err)
+		}
+	}
+	genesis, err := beacon.GetGenesisTime(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fetching the genesis time from the beacon nodes (set genesis_time to skip this): %w", err)
+	}
+	logger.Info("Genesis time %s, from the beacon nodes", genesis.Format(time.RFC3339))
+	return genesis, nil
+}
+
+// resolveValidatorIndices decides which validator indices to track:
+//   - If validators or groups are configured, use those.
+//   - If empty, fall back to all active validators from the beacon node.
+func resolveValidatorIndices(ctx context.Context, cfg *config.Config, beacon ports.BeaconChainAdapter) ([]domain.ValidatorIndex, error) {
+	validatorIndices := cfg.TrackedValidators()
+	if len(validatorIndices) > 0 {
+		return validatorIndices, nil
+	}
+	logger.Info("No validator indices configured; fetching all active validators from beacon node")
+	return beacon.GetAllActiveValidatorIndices(ctx)
+}
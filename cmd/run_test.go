@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/beaconmock"
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/config"
+	"github.com/Marketen/duties-indexer/internal/testutil"
+)
+
+func TestShutdown(t *testing.T) {
+	tests := []struct {
+		name string
+		// checkTime is how long the check in progress takes to finish on its
+		// own after stop; 0 means it only ends when aborted.
+		checkTime   time.Duration
+		grace       time.Duration
+		signals     []os.Signal
+		wantCode    int
+		wantAborted bool
+	}{
+		{name: "check finishes within grace", checkTime: 10 * time.Millisecond, grace: time.Minute, wantCode: exitOK},
+		{name: "grace expires", grace: 20 * time.Millisecond, wantCode: exitInterrupted, wantAborted: true},
+		{name: "second signal", grace: time.Minute, signals: []os.Signal{syscall.SIGTERM}, wantCode: exitInterrupted, wantAborted: true},
+		{name: "reload signal ignored", checkTime: 50 * time.Millisecond, grace: time.Minute, signals: []os.Signal{syscall.SIGHUP}, wantCode: exitOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, abort := context.WithCancel(context.Background())
+			defer abort()
+			sigCh := make(chan os.Signal, len(tt.signals))
+			for _, sig := range tt.signals {
+				sigCh <- sig
+			}
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				<-stop
+				if tt.checkTime == 0 {
+					<-ctx.Done()
+					return
+				}
+				select {
+				case <-time.After(tt.checkTime):
+				case <-ctx.Done():
+				}
+			}()
+
+			if code := shutdown(tt.grace, sigCh, stop, done, abort); code != tt.wantCode {
+				t.Errorf("exit code %d, want %d", code, tt.wantCode)
+			}
+			if aborted := ctx.Err() != nil; aborted != tt.wantAborted {
+				t.Errorf("check aborted %t, want %t", aborted, tt.wantAborted)
+			}
+		})
+	}
+}
+
+func TestResolveGenesisTime(t *testing.T) {
+	node := beaconmock.New(testutil.NewChain(t, fakechain.Config{Seed: 1, Validators: 64, CommitteesPerSlot: 1, Epochs: 2}))
+	defer node.Close()
+	configured := time.Date(2025, 3, 17, 12, 10, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		genesis time.Time
+		url     string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "configured", genesis: configured, url: "http://127.0.0.1:1", want: configured},
+		{name: "from the beacon node", url: node.URL, want: domain.MainnetGenesisTime},
+		{name: "beacon node unreachable", url: "http://127.0.0.1:1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cfg := &config.Config{
+				BeaconNodes:          []config.BeaconNodeConfig{{Name: "mock", URL: tt.url}},
+				BeaconHealthInterval: time.Minute,
+				GenesisTime:          tt.genesis,
+			}
+			got, err := resolveGenesisTime(ctx, cfg, nil)
+			if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+				t.Errorf("got %s, %v; want %s, error %t", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package adapters
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+)
+
+const (
+	baselineFilePrefix = "baseline-"
+	baselineFileSuffix = ".json"
+)
+
+// fileBaselineStore implements ports.BaselineStore with one JSON file per
+// epoch, <dir>/baseline-<N>.json.
+type fileBaselineStore struct {
+	dir       string
+	retention int
+}
+
+// NewFileBaselineStore opens the baseline store in dir, keeping the
+// baselines of the latest retention epochs (0 keeps everything), like the
+// result store.
+func NewFileBaselineStore(dir string, retention int) (ports.BaselineStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating baseline directory: %w", err)
+	}
+	return &fileBaselineStore{dir: dir, retention: retention}, nil
+}
+
+func (s *fileBaselineStore) SaveBaseline(baseline domain.NetworkBaseline) error {
+	data, err := json.Marshal(baseline)
+	if err != nil {
+		return err
+	}
+	path := epochFilePath(s.dir, baselineFilePrefix, baseline.Epoch, baselineFileSuffix)
+	// Write to a temporary file first so a crash never leaves a truncated file behind.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	pruneEpochFiles(s.dir, baselineFilePrefix, baselineFileSuffix, s.retention, "baselines")
+	return nil
+}
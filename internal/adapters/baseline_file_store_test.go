@@ -0,0 +1,41 @@
+package adapters
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+func TestFileBaselineStore(t *testing.T) {
+	dir := t.TempDir()
+	// Files of other stores sharing the directory are left alone.
+	if err := os.WriteFile(filepath.Join(dir, "epoch-1.jsonl.gz"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewFileBaselineStore(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, epoch := range []domain.Epoch{1, 2, 3} {
+		if err := store.SaveBaseline(domain.NetworkBaseline{Epoch: epoch}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	// The baseline of epoch 1 is pruned.
+	want := []string{"baseline-2.json", "baseline-3.json", "epoch-1.jsonl.gz"}
+	if !slices.Equal(got, want) {
+		t.Errorf("files %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,309 @@
+package adapters
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/metrics"
+)
+
+// BlockCachePolicy bounds the block cache: at most MaxBlocks blocks, each
+// kept for at most MaxAge. MaxBlocks 0 disables the cache.
+type BlockCachePolicy struct {
+	MaxBlocks int
+	MaxAge    time.Duration
+}
+
+// cachedBlock is one cache entry. ready is closed once the block (or a
+// definitive ErrNotFound for a missed slot) has been fetched, so concurrent
+// callers asking for the same slot share a single download. A pending entry
+// was fetched before its slot was finalized and is not served until settle
+// has checked that it is still on the canonical chain.
+type cachedBlock struct {
+	slot      domain.Slot
+	ready     chan struct{}
+	block     domain.Block
+	err       error
+	fetchedAt time.Time
+	pending   bool
+	elem      *list.Element
+}
+
+// cachingBeaconAdapter is a decorator that caches finalized blocks by slot.
+// The proposal check and the attestation sweep of an epoch ask for the same
+// blocks, which never change once finalized, so each one is downloaded once.
+// Slots up to the first slot of the latest finalized epoch seen through
+// GetFinalizedEpoch or GetFinalityCheckpoints are served from the cache.
+// Later slots, such as those of the next epoch that the sweep also reads,
+// may still be reorged: they are always fetched, and the latest answer is
+// kept as a pending entry. Once their slots are finalized, pending entries
+// linked by parent root to the finalized checkpoint root are served like any
+// other; those reorged out are dropped and fetched again. Missed slots are
+// cached too; transient errors are not. Every other call is passed through
+// unchanged.
+type cachingBeaconAdapter struct {
+	ports.BeaconChainAdapter
+	policy BlockCachePolicy
+
+	mu      sync.Mutex
+	entries map[domain.Slot]*cachedBlock
+	lru     *list.List // front is most recently used
+	// finalizedEnd is the first slot after the finalized checkpoint of the
+	// latest finalized epoch seen; slots before it are served from the
+	// cache. 0 until a finalized epoch is seen.
+	finalizedEnd domain.Slot
+	// settledEnd is the finalizedEnd up to which pending entries have been
+	// checked against the finalized checkpoint root.
+	settledEnd domain.Slot
+
+	// settleMu serialises settle, so that one checkpoint request settles
+	// the entries every waiting caller asks for.
+	settleMu sync.Mutex
+}
+
+// NewCachingBeaconAdapter wraps inner with a block cache bounded by policy.
+// With policy.MaxBlocks 0, inner is returned as is.
+func NewCachingBeaconAdapter(inner ports.BeaconChainAdapter, policy BlockCachePolicy) ports.BeaconChainAdapter {
+	if policy.MaxBlocks <= 0 {
+		return inner
+	}
+	return &cachingBeaconAdapter{
+		BeaconChainAdapter: inner,
+		policy:             policy,
+		entries:            make(map[domain.Slot]*cachedBlock),
+		lru:                list.New(),
+	}
+}
+
+func (c *cachingBeaconAdapter) GetFinalizedEpoch(ctx context.Context) (domain.Epoch, error) {
+	epoch, err := c.BeaconChainAdapter.GetFinalizedEpoch(ctx)
+	if err == nil {
+		c.mu.Lock()
+		c.observeFinalized(epoch)
+		c.mu.Unlock()
+	}
+	return epoch, err
+}
+
+func (c *cachingBeaconAdapter) GetFinalityCheckpoints(ctx context.Context) (domain.FinalityCheckpoints, error) {
+	checkpoints, err := c.BeaconChainAdapter.GetFinalityCheckpoints(ctx)
+	if err == nil {
+		c.mu.Lock()
+		c.observeFinalized(checkpoints.Finalized)
+		c.mu.Unlock()
+	}
+	return checkpoints, err
+}
+
+// observeFinalized moves the cached range forward to the finalized
+// checkpoint of epoch, its first slot: the rest of the epoch may still be
+// reorged. Callers hold c.mu.
+func (c *cachingBeaconAdapter) observeFinalized(epoch domain.Epoch) {
+	end := domain.Slot(epoch)*slotsPerEpoch + 1
+	c.finalizedEnd = max(c.finalizedEnd, end)
+}
+
+func (c *cachingBeaconAdapter) GetBlock(ctx context.Context, slot domain.Slot) (domain.Block, error) {
+	c.mu.Lock()
+	if slot >= c.finalizedEnd {
+		c.mu.Unlock()
+		metrics.BlockCacheRequests.WithLabelValues("unfinalized").Inc()
+		block, err := c.BeaconChainAdapter.GetBlock(ctx, slot)
+		c.keepPending(slot, block, err)
+		return block, err
+	}
+	if e, ok := c.entries[slot]; ok && e.pending {
+		c.mu.Unlock()
+		c.settle(ctx)
+		c.mu.Lock()
+	}
+	if e, ok := c.entries[slot]; ok && !e.pending && !c.expired(e) {
+		c.lru.MoveToFront(e.elem)
+		c.mu.Unlock()
+		metrics.BlockCacheRequests.WithLabelValues("hit").Inc()
+		select {
+		case <-e.ready:
+			return e.block, e.err
+		case <-ctx.Done():
+			return domain.Block{}, ctx.Err()
+		}
+	} else if ok {
+		c.remove(e)
+	}
+	e := &cachedBlock{slot: slot, ready: make(chan struct{})}
+	e.elem = c.lru.PushFront(e)
+	c.entries[slot] = e
+	c.mu.Unlock()
+	metrics.BlockCacheRequests.WithLabelValues("miss").Inc()
+
+	block, err := c.BeaconChainAdapter.GetBlock(ctx, slot)
+
+	c.mu.Lock()
+	e.block, e.err, e.fetchedAt = block, err, time.Now()
+	if err != nil && !errors.Is(err, ports.ErrNotFound) {
+		// Callers already waiting get the error; the next one fetches again.
+		c.remove(e)
+	}
+	close(e.ready)
+	c.evict()
+	c.mu.Unlock()
+	return block, err
+}
+
+// keepPending replaces the pending entry of an unfinalized slot with the
+// latest answer for it. A transient error drops the entry, since the slot's
+// block is then unknown.
+func (c *cachingBeaconAdapter) keepPending(slot domain.Slot, block domain.Block, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.entries[slot]; ok {
+		c.remove(old)
+	}
+	if err != nil && !errors.Is(err, ports.ErrNotFound) {
+		return
+	}
+	e := &cachedBlock{slot: slot, ready: make(chan struct{}), block: block, err: err, fetchedAt: time.Now(), pending: true}
+	close(e.ready)
+	e.elem = c.lru.PushFront(e)
+	c.entries[slot] = e
+	c.evict()
+}
+
+// settle checks the pending entries of finalized slots against the finalized
+// checkpoint. Walking down from the checkpoint root, a pending block whose
+// root is the one expected is canonical, and the next one expected is its
+// parent; a pending missed slot is canonical once the walk finds the block
+// below it. Every other pending entry of a finalized slot was reorged out,
+// or cannot be checked, and is dropped.
+func (c *cachingBeaconAdapter) settle(ctx context.Context) {
+	c.settleMu.Lock()
+	defer c.settleMu.Unlock()
+	c.mu.Lock()
+	done := c.settledEnd >= c.finalizedEnd
+	c.mu.Unlock()
+	if done {
+		return
+	}
+
+	checkpoints, err := c.BeaconChainAdapter.GetFinalityCheckpoints(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// A node behind the one that answered GetFinalizedEpoch may report an
+	// older checkpoint: pending entries above it cannot be checked.
+	var root domain.Root
+	var checked domain.Slot
+	if err == nil {
+		c.observeFinalized(checkpoints.Finalized)
+		root = checkpoints.FinalizedRoot
+		checked = domain.Slot(checkpoints.Finalized)*slotsPerEpoch + 1
+	}
+	end := c.finalizedEnd
+	slots := make([]domain.Slot, 0, len(c.entries))
+	for slot := range c.entries {
+		if slot < end {
+			slots = append(slots, slot)
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] > slots[j] })
+
+	// missed are the pending missed slots above the block expected next.
+	var missed []*cachedBlock
+	keepMissed := func(keep bool) {
+		for _, m := range missed {
+			if keep {
+				m.pending = false
+			} else {
+				c.remove(m)
+			}
+		}
+		missed = nil
+	}
+	walking := err == nil && !root.IsZero()
+	for _, slot := range slots {
+		e := c.entries[slot]
+		switch {
+		case !walking || slot >= checked:
+			if e.pending {
+				c.remove(e)
+			}
+		case !isReady(e):
+			// A block still being fetched: the walk cannot go below it.
+			keepMissed(false)
+			walking = false
+		case errors.Is(e.err, ports.ErrNotFound):
+			if e.pending {
+				missed = append(missed, e)
+			}
+		case !e.pending:
+			// Fetched after its slot was finalized, so canonical.
+			keepMissed(e.block.Root == root)
+			root = e.block.ParentRoot
+		case e.block.Root == root:
+			keepMissed(true)
+			e.pending = false
+			root = e.block.ParentRoot
+		default:
+			c.remove(e)
+		}
+	}
+	keepMissed(false)
+	c.settledEnd = end
+	metrics.BlockCacheSize.Set(float64(len(c.entries)))
+}
+
+// isReady reports whether e has been fetched.
+func isReady(e *cachedBlock) bool {
+	select {
+	case <-e.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// expired reports whether a fetched entry is older than MaxAge. Entries still
+// being fetched never expire. Callers hold c.mu.
+func (c *cachingBeaconAdapter) expired(e *cachedBlock) bool {
+	if c.policy.MaxAge <= 0 {
+		return false
+	}
+	select {
+	case <-e.ready:
+		return time.Since(e.fetchedAt) > c.policy.MaxAge
+	default:
+		return false
+	}
+}
+
+// remove drops e from the cache if it is still the entry for its slot.
+// Callers hold c.mu.
+func (c *cachingBeaconAdapter) remove(e *cachedBlock) {
+	if c.entries[e.slot] != e {
+		return
+	}
+	delete(c.entries, e.slot)
+	c.lru.Remove(e.elem)
+}
+
+// evict drops the least recently used fetched entries until the cache fits
+// MaxBlocks. Entries still being fetched are skipped. Callers hold c.mu.
+func (c *cachingBeaconAdapter) evict() {
+	for elem := c.lru.Back(); elem != nil && len(c.entries) > c.policy.MaxBlocks; {
+		prev := elem.Prev()
+		e := elem.Value.(*cachedBlock)
+		select {
+		case <-e.ready:
+			c.remove(e)
+		default:
+		}
+		elem = prev
+	}
+	metrics.BlockCacheSize.Set(float64(len(c.entries)))
+}
@@ -0,0 +1,215 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+)
+
+// linearChain serves blocks for slots 0 to last, each building on the one
+// before, except for the missed slots. It counts the downloads per slot.
+type linearChain struct {
+	ports.BeaconChainAdapter
+
+	mu             sync.Mutex
+	finalized      domain.Epoch
+	blocks         map[domain.Slot]domain.Block
+	calls          map[domain.Slot]int
+	checkpointsErr error
+}
+
+func newLinearChain(last domain.Slot, missed ...domain.Slot) *linearChain {
+	c := &linearChain{blocks: make(map[domain.Slot]domain.Block), calls: make(map[domain.Slot]int)}
+	skip := make(map[domain.Slot]bool)
+	for _, slot := range missed {
+		skip[slot] = true
+	}
+	var parent domain.Root
+	for slot := domain.Slot(0); slot <= last; slot++ {
+		if skip[slot] {
+			continue
+		}
+		c.blocks[slot] = domain.Block{Slot: slot, Root: testRoot(0xb1, slot), ParentRoot: parent}
+		parent = testRoot(0xb1, slot)
+	}
+	return c
+}
+
+func testRoot(prefix byte, slot domain.Slot) domain.Root {
+	return domain.Root{prefix, byte(slot >> 8), byte(slot)}
+}
+
+func (c *linearChain) GetFinalizedEpoch(context.Context) (domain.Epoch, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.finalized, nil
+}
+
+func (c *linearChain) GetFinalityCheckpoints(context.Context) (domain.FinalityCheckpoints, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checkpointsErr != nil {
+		return domain.FinalityCheckpoints{}, c.checkpointsErr
+	}
+	slot := domain.Slot(c.finalized) * slotsPerEpoch
+	for ; slot > 0; slot-- {
+		if _, ok := c.blocks[slot]; ok {
+			break
+		}
+	}
+	return domain.FinalityCheckpoints{Justified: c.finalized + 1, Finalized: c.finalized, FinalizedRoot: c.blocks[slot].Root}, nil
+}
+
+func (c *linearChain) GetBlock(_ context.Context, slot domain.Slot) (domain.Block, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[slot]++
+	b, ok := c.blocks[slot]
+	if !ok {
+		return domain.Block{}, fmt.Errorf("%w: no block at slot %d", ports.ErrNotFound, slot)
+	}
+	return b, nil
+}
+
+func (c *linearChain) downloads(slot domain.Slot) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[slot]
+}
+
+// finalize moves the chain's finality to epoch and lets cache see it.
+func (c *linearChain) finalize(t *testing.T, cache ports.BeaconChainAdapter, epoch domain.Epoch) {
+	t.Helper()
+	c.mu.Lock()
+	c.finalized = epoch
+	c.mu.Unlock()
+	if _, err := cache.GetFinalizedEpoch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// getBlocks reads slots from through to through cache, and fails on any
+// error but a missed slot.
+func getBlocks(t *testing.T, cache ports.BeaconChainAdapter, from, to domain.Slot) map[domain.Slot]domain.Block {
+	t.Helper()
+	blocks := make(map[domain.Slot]domain.Block)
+	for slot := from; slot <= to; slot++ {
+		b, err := cache.GetBlock(context.Background(), slot)
+		if errors.Is(err, ports.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("slot %d: %v", slot, err)
+		}
+		blocks[slot] = b
+	}
+	return blocks
+}
+
+func TestCachingBeaconAdapterFinalizedBoundary(t *testing.T) {
+	chain := newLinearChain(200)
+	cache := NewCachingBeaconAdapter(chain, BlockCachePolicy{MaxBlocks: 256})
+	chain.finalize(t, cache, 2)
+
+	// The checkpoint block of epoch 2, at its first slot, is finalized; the
+	// rest of the epoch is not.
+	for range 3 {
+		getBlocks(t, cache, 63, 65)
+	}
+	for slot, want := range map[domain.Slot]int{63: 1, 64: 1, 65: 3} {
+		if got := chain.downloads(slot); got != want {
+			t.Errorf("slot %d downloaded %d times, want %d", slot, got, want)
+		}
+	}
+}
+
+func TestCachingBeaconAdapterSettlesPendingBlocks(t *testing.T) {
+	tests := []struct {
+		name string
+		// fork replaces the blocks first seen at these slots: a block root
+		// of 0xf0 is on a fork, a zero block is a slot seen as missed.
+		fork           map[domain.Slot]domain.Block
+		checkpointsErr error
+		// refetched are the slots of epoch 2 downloaded again once it is
+		// finalized; the others are served from the cache.
+		refetched []domain.Slot
+	}{
+		{name: "canonical"},
+		{
+			name:      "reorged block",
+			fork:      map[domain.Slot]domain.Block{90: {Slot: 90, Root: testRoot(0xf0, 90), ParentRoot: testRoot(0xb1, 89)}},
+			refetched: slotRange(65, 90),
+		},
+		{
+			name:      "block reorged into a missed slot",
+			fork:      map[domain.Slot]domain.Block{80: {Slot: 80, Root: testRoot(0xf0, 80), ParentRoot: testRoot(0xb1, 79)}},
+			refetched: []domain.Slot{80},
+		},
+		{
+			name:      "missed slot reorged into a block",
+			fork:      map[domain.Slot]domain.Block{85: {}},
+			refetched: slotRange(65, 85),
+		},
+		{
+			name:           "checkpoints unavailable",
+			checkpointsErr: fmt.Errorf("%w: status 503", ports.ErrServer),
+			refetched:      slotRange(65, 96),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := newLinearChain(200, 70, 80)
+			canonical := make(map[domain.Slot]domain.Block)
+			for slot, b := range tt.fork {
+				canonical[slot] = chain.blocks[slot]
+				if b.Root.IsZero() {
+					delete(chain.blocks, slot)
+				} else {
+					chain.blocks[slot] = b
+				}
+			}
+			cache := NewCachingBeaconAdapter(chain, BlockCachePolicy{MaxBlocks: 256})
+			chain.finalize(t, cache, 2)
+			getBlocks(t, cache, 64, 127)
+
+			chain.mu.Lock()
+			for slot, b := range canonical {
+				chain.blocks[slot] = b
+			}
+			chain.checkpointsErr = tt.checkpointsErr
+			chain.mu.Unlock()
+			chain.finalize(t, cache, 3)
+			got := getBlocks(t, cache, 64, 127)
+
+			refetched := make(map[domain.Slot]bool)
+			for _, slot := range tt.refetched {
+				refetched[slot] = true
+			}
+			for slot := domain.Slot(64); slot <= 127; slot++ {
+				want := 1
+				if refetched[slot] || slot > 96 {
+					want = 2
+				}
+				if n := chain.downloads(slot); n != want {
+					t.Errorf("slot %d downloaded %d times, want %d", slot, n, want)
+				}
+				if got[slot].Root != chain.blocks[slot].Root {
+					t.Errorf("slot %d: got block %s, want %s", slot, got[slot].Root, chain.blocks[slot].Root)
+				}
+			}
+		})
+	}
+}
+
+func slotRange(from, to domain.Slot) []domain.Slot {
+	var slots []domain.Slot
+	for slot := from; slot <= to; slot++ {
+		slots = append(slots, slot)
+	}
+	return slots
+}
@@ -0,0 +1,175 @@
+package adapters
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/logger"
+	"github.com/Marketen/duties-indexer/internal/metrics"
+)
+
+// eventTopics are the /eth/v1/events topics the checker subscribes to. Block
+// events arrive every slot and double as a heartbeat for the idle timeout.
+var eventTopics = []domain.ChainEventType{
+	domain.ChainEventFinalizedCheckpoint,
+	domain.ChainEventBlock,
+	domain.ChainEventChainReorg,
+}
+
+// beaconEventSource implements ports.ChainEventSource on top of the beacon
+// node event stream (server-sent events on /eth/v1/events) of the nodes of a
+// failover adapter.
+type beaconEventSource struct {
+	failover    *failoverBeaconAdapter
+	idleTimeout time.Duration
+}
+
+// EventSource returns an event source over the nodes of the adapter. Each
+// subscription connects to the first node that accepts it, in the order
+// calls are routed (see rankedNodes), and counts against the node's rate
+// limit.
+func (f *failoverBeaconAdapter) EventSource(idleTimeout time.Duration) ports.ChainEventSource {
+	return &beaconEventSource{failover: f, idleTimeout: idleTimeout}
+}
+
+func (s *beaconEventSource) SubscribeChainEvents(ctx context.Context, handler func(domain.ChainEvent)) error {
+	const method = "SubscribeChainEvents"
+	var (
+		errs []error
+		prev *failoverNode
+	)
+	for _, n := range s.failover.rankedNodes() {
+		if prev != nil {
+			metrics.BeaconFailovers.WithLabelValues(prev.name, method).Inc()
+		}
+		prev = n
+		body, cancel, err := n.client.connectEvents(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			metrics.BeaconRequests.WithLabelValues(n.name, method, "error").Inc()
+			logger.Warn("Could not subscribe to events of beacon node %s: %v", n.name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", n.name, err))
+			continue
+		}
+		metrics.BeaconRequests.WithLabelValues(n.name, method, "success").Inc()
+		logger.Info("Subscribed to events of beacon node %s", n.name)
+		err = s.read(ctx, body, cancel, handler)
+		body.Close()
+		cancel()
+		return fmt.Errorf("event stream of beacon node %s: %w", n.name, err)
+	}
+	return fmt.Errorf("%w: no beacon node accepted the event subscription: %w", ports.ErrUnavailable, errors.Join(errs...))
+}
+
+// connectEvents opens the event stream of the node. The returned cancel func
+// aborts the stream.
+func (b *beaconAttestantClient) connectEvents(ctx context.Context) (io.ReadCloser, context.CancelFunc, error) {
+	topics := make([]string, len(eventTopics))
+	for i, t := range eventTopics {
+		topics[i] = "topics=" + string(t)
+	}
+	url := strings.TrimSuffix(b.endpoint, "/") + "/eth/v1/events?" + strings.Join(topics, "&")
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	req, err := nethttp.NewRequestWithContext(streamCtx, nethttp.MethodGet, url, nil)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := b.events.Do(req)
+	if err != nil {
+		cancel()
+		return nil, nil, classifyError(err)
+	}
+	if resp.StatusCode != nethttp.StatusOK {
+		resp.Body.Close()
+		cancel()
+		if resp.StatusCode >= 500 {
+			return nil, nil, fmt.Errorf("%w: status %d", ports.ErrServer, resp.StatusCode)
+		}
+		return nil, nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.Body, cancel, nil
+}
+
+// read parses the server-sent events of body and hands them to handler until
+// the stream ends, ctx is cancelled or nothing arrives for idleTimeout.
+func (s *beaconEventSource) read(ctx context.Context, body io.Reader, cancel context.CancelFunc, handler func(domain.ChainEvent)) error {
+	var timedOut atomic.Bool
+	idle := time.AfterFunc(s.idleTimeout, func() {
+		timedOut.Store(true)
+		cancel()
+	})
+	defer idle.Stop()
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var eventType, data string
+	for scanner.Scan() {
+		idle.Reset(s.idleTimeout)
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data != "" {
+				if event, err := decodeChainEvent(domain.ChainEventType(eventType), data); err != nil {
+					logger.Warn("Ignoring malformed %s event: %v", eventType, err)
+				} else {
+					handler(event)
+				}
+			}
+			eventType, data = "", ""
+		case strings.HasPrefix(line, "event:"):
+			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case timedOut.Load():
+		return fmt.Errorf("%w: no event for %s", ports.ErrTimeout, s.idleTimeout)
+	case scanner.Err() != nil:
+		return fmt.Errorf("%w: %w", ports.ErrUnavailable, scanner.Err())
+	default:
+		return fmt.Errorf("%w: stream closed by the node", ports.ErrUnavailable)
+	}
+}
+
+// decodeChainEvent maps the JSON payload of one event. Quantities are
+// encoded as decimal strings by the beacon API.
+func decodeChainEvent(eventType domain.ChainEventType, data string) (domain.ChainEvent, error) {
+	var payload struct {
+		Slot  uint64 `json:"slot,string"`
+		Epoch uint64 `json:"epoch,string"`
+		Depth uint64 `json:"depth,string"`
+	}
+	switch eventType {
+	case domain.ChainEventFinalizedCheckpoint, domain.ChainEventBlock, domain.ChainEventChainReorg:
+	default:
+		return domain.ChainEvent{}, fmt.Errorf("unexpected event type %q", eventType)
+	}
+	if err := json.Unmarshal([]byte(data), &payload); err != nil {
+		return domain.ChainEvent{}, err
+	}
+	return domain.ChainEvent{
+		Type:  eventType,
+		Epoch: domain.Epoch(payload.Epoch),
+		Slot:  domain.Slot(payload.Slot),
+		Depth: payload.Depth,
+	}, nil
+}
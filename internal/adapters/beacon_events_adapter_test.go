@@ -0,0 +1,81 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/beaconmock"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+)
+
+func TestDecodeChainEvent(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType domain.ChainEventType
+		data      string
+		want      domain.ChainEvent
+		wantErr   bool
+	}{
+		{
+			name:      "block",
+			eventType: domain.ChainEventBlock,
+			data:      `{"slot":"10","block":"0x9a2fefd2fdb57f74993c7780ea5b9030d2897b615b89f808011ca5aebed54eaf","execution_optimistic":false}`,
+			want:      domain.ChainEvent{Type: domain.ChainEventBlock, Slot: 10},
+		},
+		{
+			name:      "finalized checkpoint",
+			eventType: domain.ChainEventFinalizedCheckpoint,
+			data:      `{"block":"0x9a2fefd2fdb57f74993c7780ea5b9030d2897b615b89f808011ca5aebed54eaf","state":"0x600e852a08c1200654ddf11025f1ceacb3c2e74bdd5c630cde0838b2591b69f9","epoch":"2","execution_optimistic":false}`,
+			want:      domain.ChainEvent{Type: domain.ChainEventFinalizedCheckpoint, Epoch: 2},
+		},
+		{
+			name:      "chain reorg",
+			eventType: domain.ChainEventChainReorg,
+			data:      `{"slot":"200","depth":"50","old_head_block":"0x9a2fefd2fdb57f74993c7780ea5b9030d2897b615b89f808011ca5aebed54eaf","new_head_block":"0x76262e91970d375a19bfe8a867288d7b9cde43c8635f598d93d39d041706fc76","old_head_state":"0x9a2fefd2fdb57f74993c7780ea5b9030d2897b615b89f808011ca5aebed54eaf","new_head_state":"0x600e852a08c1200654ddf11025f1ceacb3c2e74bdd5c630cde0838b2591b69f9","epoch":"6","execution_optimistic":false}`,
+			want:      domain.ChainEvent{Type: domain.ChainEventChainReorg, Slot: 200, Epoch: 6, Depth: 50},
+		},
+		{name: "unexpected type", eventType: "head", data: `{"slot":"10"}`, wantErr: true},
+		{name: "malformed", eventType: domain.ChainEventBlock, data: `{"slot":10}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeChainEvent(tt.eventType, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventSourceReadsStreamUntilClosed(t *testing.T) {
+	_, node := newMockNode(t)
+	events := []domain.ChainEvent{
+		{Type: domain.ChainEventBlock, Slot: 95},
+		{Type: domain.ChainEventChainReorg, Slot: 95, Epoch: 2, Depth: 2},
+		{Type: domain.ChainEventFinalizedCheckpoint, Epoch: 1},
+	}
+	node.SetEvents(events...)
+	f := newTestFailover(t, []*beaconmock.Server{node}, []nodeHealth{healthyNode})
+
+	var got []domain.ChainEvent
+	err := f.EventSource(time.Second).SubscribeChainEvents(context.Background(), func(event domain.ChainEvent) {
+		got = append(got, event)
+	})
+	if !reflect.DeepEqual(got, events) {
+		t.Errorf("got events %+v, want %+v", got, events)
+	}
+	// The node closing the stream is transient: the caller reconnects.
+	if !errors.Is(err, ports.ErrUnavailable) || !ports.IsTransient(err) {
+		t.Errorf("got error %v, want %v", err, ports.ErrUnavailable)
+	}
+	if requests(f, 0, "SubscribeChainEvents", "success") != 1 {
+		t.Error("the subscription was not counted")
+	}
+}
@@ -0,0 +1,325 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/logger"
+	"github.com/Marketen/duties-indexer/internal/metrics"
+	"github.com/attestantio/go-eth2-client/api"
+)
+
+// BeaconNode is a named beacon node endpoint for the failover adapter.
+// RateLimit caps the requests per second sent to it; 0 means unlimited.
+type BeaconNode struct {
+	Name      string
+	URL       string
+	RateLimit float64
+}
+
+// nodeHealth is the result of the last health check of a node.
+type nodeHealth struct {
+	reachable      bool
+	syncing        bool
+	headSlot       domain.Slot
+	finalizedEpoch domain.Epoch
+	peers          int
+}
+
+// healthy reports whether the node can be trusted to serve finalized data.
+func (h nodeHealth) healthy() bool {
+	return h.reachable && !h.syncing
+}
+
+type failoverNode struct {
+	name   string
+	client *beaconAttestantClient
+
+	mu     sync.Mutex
+	health nodeHealth
+}
+
+// FailoverBeaconAdapter is a beacon adapter over several nodes that also
+// reports their health and streams their events.
+type FailoverBeaconAdapter interface {
+	ports.BeaconChainAdapter
+	ports.BeaconNodeMonitor
+	// EventSource returns a source of the chain events of the nodes. A
+	// stream that stays silent for longer than idleTimeout is treated as
+	// dropped.
+	EventSource(idleTimeout time.Duration) ports.ChainEventSource
+	// GetGenesisTime returns the genesis time of the chain the nodes follow.
+	GetGenesisTime(ctx context.Context) (time.Time, error)
+}
+
+// failoverBeaconAdapter implements ports.BeaconChainAdapter on top of several
+// beacon nodes. Every call goes to the healthiest node first and, if it fails,
+// to the next one, so a single syncing or unreachable node doesn't stop the
+// checker.
+type failoverBeaconAdapter struct {
+	nodes []*failoverNode
+}
+
+// NewFailoverBeaconAdapter creates an adapter over the given nodes and starts
+// health-checking them every healthInterval until ctx is cancelled. Nodes do
+// not need to be reachable at startup.
+func NewFailoverBeaconAdapter(ctx context.Context, nodes []BeaconNode, healthInterval time.Duration) (FailoverBeaconAdapter, error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("at least one beacon node is required")
+	}
+	f := &failoverBeaconAdapter{}
+	for _, n := range nodes {
+		c, err := newBeaconAttestantClient(n.URL, n.RateLimit, defaultRequestTimeout, true)
+		if err != nil {
+			return nil, fmt.Errorf("creating client for beacon node %s: %w", n.Name, err)
+		}
+		f.nodes = append(f.nodes, &failoverNode{name: n.Name, client: c})
+	}
+
+	f.checkHealth(ctx)
+	go func() {
+		ticker := time.NewTicker(healthInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				f.checkHealth(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return f, nil
+}
+
+// checkHealth refreshes the health of every node concurrently.
+func (f *failoverBeaconAdapter) checkHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, n := range f.nodes {
+		wg.Add(1)
+		go func(n *failoverNode) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+			h := n.client.health(checkCtx)
+
+			n.mu.Lock()
+			wasHealthy := n.health.healthy()
+			n.health = h
+			n.mu.Unlock()
+
+			if wasHealthy && !h.healthy() {
+				logger.Warn("Beacon node %s is unhealthy (reachable=%t syncing=%t)", n.name, h.reachable, h.syncing)
+			} else if !wasHealthy && h.healthy() {
+				logger.Info("Beacon node %s is healthy (head slot %d, finalized epoch %d, %d peers)",
+					n.name, h.headSlot, h.finalizedEpoch, h.peers)
+			}
+
+			healthy := 0.0
+			if h.healthy() {
+				healthy = 1
+			}
+			metrics.BeaconNodeHealthy.WithLabelValues(n.name).Set(healthy)
+			metrics.BeaconNodeHeadSlot.WithLabelValues(n.name).Set(float64(h.headSlot))
+			metrics.BeaconNodeFinalizedEpoch.WithLabelValues(n.name).Set(float64(h.finalizedEpoch))
+			metrics.BeaconNodePeers.WithLabelValues(n.name).Set(float64(h.peers))
+		}(n)
+	}
+	wg.Wait()
+}
+
+// health queries sync status, finality and peer count of the node. A node
+// that can't answer the sync status or finality query is unreachable; a
+// failing peer query only leaves the peer count at zero, as not every client
+// implements it.
+func (b *beaconAttestantClient) health(ctx context.Context) nodeHealth {
+	var h nodeHealth
+	syncing, err := b.client.NodeSyncing(ctx, &api.NodeSyncingOpts{})
+	if err != nil {
+		return h
+	}
+	finality, err := b.client.Finality(ctx, &api.FinalityOpts{State: "head"})
+	if err != nil {
+		return h
+	}
+	h.reachable = true
+	h.syncing = syncing.Data.IsSyncing
+	h.headSlot = domain.Slot(syncing.Data.HeadSlot)
+	h.finalizedEpoch = domain.Epoch(finality.Data.Finalized.Epoch)
+	if peers, err := b.client.NodePeers(ctx, &api.NodePeersOpts{State: []string{"connected"}}); err == nil {
+		h.peers = len(peers.Data)
+	}
+	return h
+}
+
+// NodeStatuses returns the result of the last health check of every node, in
+// configured order.
+func (f *failoverBeaconAdapter) NodeStatuses() []domain.BeaconNodeStatus {
+	statuses := make([]domain.BeaconNodeStatus, len(f.nodes))
+	for i, n := range f.nodes {
+		n.mu.Lock()
+		h := n.health
+		n.mu.Unlock()
+		statuses[i] = domain.BeaconNodeStatus{
+			Name:           n.name,
+			Reachable:      h.reachable,
+			Syncing:        h.syncing,
+			HeadSlot:       h.headSlot,
+			FinalizedEpoch: h.finalizedEpoch,
+			Peers:          h.peers,
+		}
+	}
+	return statuses
+}
+
+// rankedNodes returns the nodes in the order they should be tried: healthy
+// nodes first, preferring the highest finalized epoch, then head slot, then
+// peer count; unhealthy nodes are kept at the end as a last resort. Ties keep
+// the configured order, so the first node stays primary while it's healthy.
+func (f *failoverBeaconAdapter) rankedNodes() []*failoverNode {
+	type ranked struct {
+		node   *failoverNode
+		health nodeHealth
+	}
+	rs := make([]ranked, len(f.nodes))
+	for i, n := range f.nodes {
+		n.mu.Lock()
+		rs[i] = ranked{node: n, health: n.health}
+		n.mu.Unlock()
+	}
+	sort.SliceStable(rs, func(i, j int) bool {
+		a, b := rs[i].health, rs[j].health
+		if a.healthy() != b.healthy() {
+			return a.healthy()
+		}
+		if a.finalizedEpoch != b.finalizedEpoch {
+			return a.finalizedEpoch > b.finalizedEpoch
+		}
+		if a.headSlot != b.headSlot {
+			return a.headSlot > b.headSlot
+		}
+		return a.peers > b.peers
+	})
+	result := make([]*failoverNode, len(rs))
+	for i, r := range rs {
+		result[i] = r.node
+	}
+	return result
+}
+
+// route calls fn on each node in rank order until one succeeds. Definitive
+// errors (not found, e.g. a missed slot, and unsupported fork) are returned
+// without trying the other nodes.
+func route[T any](ctx context.Context, f *failoverBeaconAdapter, method string, fn func(*beaconAttestantClient) (T, error)) (T, error) {
+	var (
+		zero    T
+		lastErr error
+		prev    *failoverNode
+	)
+	for _, n := range f.rankedNodes() {
+		if prev != nil {
+			if ctx.Err() != nil {
+				return zero, ctx.Err()
+			}
+			metrics.BeaconFailovers.WithLabelValues(prev.name, method).Inc()
+		}
+		prev = n
+		start := time.Now()
+		res, err := fn(n.client)
+		metrics.BeaconRequestDuration.WithLabelValues(n.name, method).Observe(time.Since(start).Seconds())
+		if err == nil {
+			metrics.BeaconRequests.WithLabelValues(n.name, method, "success").Inc()
+			return res, nil
+		}
+		if isDefinitive(err) {
+			metrics.BeaconRequests.WithLabelValues(n.name, method, "definitive").Inc()
+			return res, err
+		}
+		metrics.BeaconRequests.WithLabelValues(n.name, method, "error").Inc()
+		logger.Warn("Beacon node %s failed %s: %v", n.name, method, err)
+		lastErr = fmt.Errorf("%s: %w", n.name, err)
+	}
+	return zero, fmt.Errorf("all beacon nodes failed %s, last error: %w", method, lastErr)
+}
+
+func isDefinitive(err error) bool {
+	return errors.Is(err, ports.ErrNotFound) || errors.Is(err, ports.ErrUnsupportedFork)
+}
+
+func (f *failoverBeaconAdapter) GetFinalizedEpoch(ctx context.Context) (domain.Epoch, error) {
+	return route(ctx, f, "GetFinalizedEpoch", func(c *beaconAttestantClient) (domain.Epoch, error) {
+		return c.GetFinalizedEpoch(ctx)
+	})
+}
+
+func (f *failoverBeaconAdapter) GetFinalityCheckpoints(ctx context.Context) (domain.FinalityCheckpoints, error) {
+	return route(ctx, f, "GetFinalityCheckpoints", func(c *beaconAttestantClient) (domain.FinalityCheckpoints, error) {
+		return c.GetFinalityCheckpoints(ctx)
+	})
+}
+
+func (f *failoverBeaconAdapter) GetGenesisTime(ctx context.Context) (time.Time, error) {
+	return route(ctx, f, "GetGenesisTime", func(c *beaconAttestantClient) (time.Time, error) {
+		return c.GetGenesisTime(ctx)
+	})
+}
+
+func (f *failoverBeaconAdapter) GetHeadSlot(ctx context.Context) (domain.Slot, error) {
+	return route(ctx, f, "GetHeadSlot", func(c *beaconAttestantClient) (domain.Slot, error) {
+		return c.GetHeadSlot(ctx)
+	})
+}
+
+func (f *failoverBeaconAdapter) GetValidatorDutiesBatch(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ValidatorDuty, error) {
+	return route(ctx, f, "GetValidatorDutiesBatch", func(c *beaconAttestantClient) ([]domain.ValidatorDuty, error) {
+		return c.GetValidatorDutiesBatch(ctx, epoch, indices)
+	})
+}
+
+func (f *failoverBeaconAdapter) GetProposerDuties(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ProposerDuty, error) {
+	return route(ctx, f, "GetProposerDuties", func(c *beaconAttestantClient) ([]domain.ProposerDuty, error) {
+		return c.GetProposerDuties(ctx, epoch, indices)
+	})
+}
+
+func (f *failoverBeaconAdapter) GetSyncCommitteeDuties(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.SyncCommitteeDuty, error) {
+	return route(ctx, f, "GetSyncCommitteeDuties", func(c *beaconAttestantClient) ([]domain.SyncCommitteeDuty, error) {
+		return c.GetSyncCommitteeDuties(ctx, epoch, indices)
+	})
+}
+
+func (f *failoverBeaconAdapter) GetAttestationRewards(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ValidatorReward, error) {
+	return route(ctx, f, "GetAttestationRewards", func(c *beaconAttestantClient) ([]domain.ValidatorReward, error) {
+		return c.GetAttestationRewards(ctx, epoch, indices)
+	})
+}
+
+func (f *failoverBeaconAdapter) GetSyncCommitteeRewards(ctx context.Context, slot domain.Slot, indices []domain.ValidatorIndex) ([]domain.ValidatorReward, error) {
+	return route(ctx, f, "GetSyncCommitteeRewards", func(c *beaconAttestantClient) ([]domain.ValidatorReward, error) {
+		return c.GetSyncCommitteeRewards(ctx, slot, indices)
+	})
+}
+
+func (f *failoverBeaconAdapter) GetBlock(ctx context.Context, slot domain.Slot) (domain.Block, error) {
+	return route(ctx, f, "GetBlock", func(c *beaconAttestantClient) (domain.Block, error) {
+		return c.GetBlock(ctx, slot)
+	})
+}
+
+func (f *failoverBeaconAdapter) GetEpochCommittees(ctx context.Context, epoch domain.Epoch) (domain.EpochCommittees, error) {
+	return route(ctx, f, "GetEpochCommittees", func(c *beaconAttestantClient) (domain.EpochCommittees, error) {
+		return c.GetEpochCommittees(ctx, epoch)
+	})
+}
+
+func (f *failoverBeaconAdapter) GetAllActiveValidatorIndices(ctx context.Context) ([]domain.ValidatorIndex, error) {
+	return route(ctx, f, "GetAllActiveValidatorIndices", func(c *beaconAttestantClient) ([]domain.ValidatorIndex, error) {
+		return c.GetAllActiveValidatorIndices(ctx)
+	})
+}
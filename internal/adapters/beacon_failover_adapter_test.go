@@ -0,0 +1,127 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/beaconmock"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/metrics"
+	prom "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestFailover builds a failover adapter over nodes with the given
+// health, skipping the health checks. Node names are made unique per test so
+// the metrics of one test do not leak into another.
+func newTestFailover(t *testing.T, nodes []*beaconmock.Server, health []nodeHealth) *failoverBeaconAdapter {
+	t.Helper()
+	f := &failoverBeaconAdapter{}
+	for i, node := range nodes {
+		f.nodes = append(f.nodes, &failoverNode{
+			name:   t.Name() + "/" + string(rune('a'+i)),
+			client: newMockClient(t, node, 5*time.Second),
+			health: health[i],
+		})
+	}
+	return f
+}
+
+func requests(f *failoverBeaconAdapter, node int, method, result string) float64 {
+	return prom.ToFloat64(metrics.BeaconRequests.WithLabelValues(f.nodes[node].name, method, result))
+}
+
+var healthyNode = nodeHealth{reachable: true, finalizedEpoch: 2, headSlot: 100, peers: 50}
+
+func TestFailoverOrder(t *testing.T) {
+	ctx := context.Background()
+	chain, a := newMockNode(t)
+	b := beaconmock.New(chain)
+	t.Cleanup(b.Close)
+	want, _ := chain.GetFinalizedEpoch(ctx)
+
+	f := newTestFailover(t, []*beaconmock.Server{a, b}, []nodeHealth{healthyNode, healthyNode})
+	if got, err := f.GetFinalizedEpoch(ctx); err != nil || got != want {
+		t.Fatalf("got %d, %v, want %d", got, err, want)
+	}
+	if requests(f, 0, "GetFinalizedEpoch", "success") != 1 || requests(f, 1, "GetFinalizedEpoch", "success") != 0 {
+		t.Error("the first of two equally healthy nodes was not tried first")
+	}
+
+	a.Fail("/eth/v1/beacon/states/head/finality_checkpoints", beaconmock.Fault{Status: http.StatusServiceUnavailable})
+	if got, err := f.GetFinalizedEpoch(ctx); err != nil || got != want {
+		t.Fatalf("after a failure on the first node: got %d, %v, want %d", got, err, want)
+	}
+	if requests(f, 0, "GetFinalizedEpoch", "error") != 1 || requests(f, 1, "GetFinalizedEpoch", "success") != 1 {
+		t.Error("the call did not fail over to the second node")
+	}
+	if n := prom.ToFloat64(metrics.BeaconFailovers.WithLabelValues(f.nodes[0].name, "GetFinalizedEpoch")); n != 1 {
+		t.Errorf("%g failovers counted from the first node, want 1", n)
+	}
+
+	b.Fail("/eth/v1/beacon/states/head/finality_checkpoints", beaconmock.Fault{Status: http.StatusServiceUnavailable})
+	if _, err := f.GetFinalizedEpoch(ctx); err == nil || !errors.Is(err, ports.ErrServer) {
+		t.Errorf("with every node failing: got %v, want a server error", err)
+	}
+}
+
+func TestFailoverRanking(t *testing.T) {
+	ctx := context.Background()
+	chain, a := newMockNode(t)
+	b := beaconmock.New(chain)
+	t.Cleanup(b.Close)
+	c := beaconmock.New(chain)
+	t.Cleanup(c.Close)
+
+	behind := healthyNode
+	behind.finalizedEpoch--
+	f := newTestFailover(t, []*beaconmock.Server{a, b, c}, []nodeHealth{
+		{reachable: true, syncing: true, finalizedEpoch: 2, headSlot: 100},
+		behind,
+		healthyNode,
+	})
+	if _, err := f.GetHeadSlot(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if requests(f, 2, "GetHeadSlot", "success") != 1 || requests(f, 0, "GetHeadSlot", "success")+requests(f, 1, "GetHeadSlot", "success") != 0 {
+		t.Error("the call was not served by the healthy node with the highest finalized epoch")
+	}
+
+	// Unhealthy nodes are the last resort.
+	b.Fail("/eth/v1/beacon/headers/head", beaconmock.Fault{Status: http.StatusInternalServerError})
+	c.Fail("/eth/v1/beacon/headers/head", beaconmock.Fault{Status: http.StatusInternalServerError})
+	if _, err := f.GetHeadSlot(ctx); err != nil {
+		t.Fatalf("the syncing node was not tried last: %v", err)
+	}
+	if requests(f, 0, "GetHeadSlot", "success") != 1 {
+		t.Error("the syncing node did not serve the call once the others failed")
+	}
+}
+
+func TestFailoverDefinitiveErrors(t *testing.T) {
+	ctx := context.Background()
+	chain, a := newMockNode(t)
+	b := beaconmock.New(chain)
+	t.Cleanup(b.Close)
+	missed := domain.Slot(0)
+	for slot := domain.Slot(1); ; slot++ {
+		if _, err := chain.GetBlock(ctx, slot); errors.Is(err, ports.ErrNotFound) {
+			missed = slot
+			break
+		}
+	}
+
+	f := newTestFailover(t, []*beaconmock.Server{a, b}, []nodeHealth{healthyNode, healthyNode})
+	if _, err := f.GetBlock(ctx, missed); !errors.Is(err, ports.ErrNotFound) {
+		t.Fatalf("missed slot %d: got %v, want not found", missed, err)
+	}
+	if requests(f, 0, "GetBlock", "definitive") != 1 || requests(f, 0, "GetBlock", "success") != 0 {
+		t.Error("a not found answer was not counted as definitive")
+	}
+	if requests(f, 1, "GetBlock", "success")+requests(f, 1, "GetBlock", "definitive")+requests(f, 1, "GetBlock", "error") != 0 {
+		t.Error("a not found answer failed over to the second node")
+	}
+}
@@ -6,51 +6,126 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"net"
 	nethttp "net/http"
 	"time"
 
 	"github.com/Marketen/duties-indexer/internal/application/domain"
 	"github.com/Marketen/duties-indexer/internal/application/ports"
 	apiv1 "github.com/attestantio/go-eth2-client/api/v1"
-	"github.com/rs/zerolog"
 
+	eth2client "github.com/attestantio/go-eth2-client"
 	"github.com/attestantio/go-eth2-client/api"
 	"github.com/attestantio/go-eth2-client/http"
+	"github.com/attestantio/go-eth2-client/spec"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/rs/zerolog"
 )
 
+// slotsPerEpoch is the consensus SLOTS_PER_EPOCH, used to address the state at an epoch boundary.
+const slotsPerEpoch = 32
+
+// defaultRequestTimeout bounds every request to a beacon node.
+const defaultRequestTimeout = 20 * time.Second
+
 type beaconAttestantClient struct {
 	client *http.Service
+	// endpoint and events serve the event stream, which go-eth2-client
+	// does not expose; events shares the node's rate limit.
+	endpoint string
+	events   *nethttp.Client
 }
 
-func NewBeaconAttestantAdapter(endpoint string) (ports.BeaconChainAdapter, error) {
-	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+// NewBeaconAttestantAdapter creates an adapter for a single beacon node.
+// rateLimit caps the requests per second sent to the node; 0 means unlimited.
+func NewBeaconAttestantAdapter(endpoint string, rateLimit float64) (ports.BeaconChainAdapter, error) {
+	return newBeaconAttestantClient(endpoint, rateLimit, defaultRequestTimeout, false)
+}
+
+// newBeaconAttestantClient creates the client for one beacon node. With
+// allowDelayedStart the node does not need to be reachable yet, which the
+// failover adapter relies on so that one node being down at startup is not fatal.
+func newBeaconAttestantClient(endpoint string, rateLimit float64, timeout time.Duration, allowDelayedStart bool) (*beaconAttestantClient, error) {
+	// Keep enough idle connections for concurrent fetches to reuse them.
+	transport := nethttp.DefaultTransport.(*nethttp.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 64
+	// The event stream gets its own transport: its connection stays open,
+	// and only the response headers are bounded in time.
+	eventTransport := nethttp.DefaultTransport.(*nethttp.Transport).Clone()
+	eventTransport.ResponseHeaderTimeout = 10 * time.Second
+	var roundTripper, eventRoundTripper nethttp.RoundTripper = consensusVersionTransport{next: transport}, eventTransport
+	if rateLimit > 0 {
+		limiter := newRateLimiter(rateLimit)
+		roundTripper = &rateLimitedTransport{limiter: limiter, next: roundTripper}
+		eventRoundTripper = &rateLimitedTransport{limiter: limiter, next: eventTransport}
+	}
 
 	customHttpClient := &nethttp.Client{
-		Timeout: 2000 * time.Second,
+		Timeout:   2000 * time.Second,
+		Transport: roundTripper,
 	}
 
 	client, err := http.New(context.Background(),
 		http.WithAddress(endpoint),
 		http.WithHTTPClient(customHttpClient),
-		http.WithTimeout(20*time.Second), // important as attestant API overrides my timeout TODO: investigate how
+		http.WithTimeout(timeout), // important as attestant API overrides my timeout TODO: investigate how
+		http.WithAllowDelayedStart(allowDelayedStart),
+		// The client keeps the level it is created with; leave it open so
+		// the global level, which a config reload changes, alone applies.
+		http.WithLogLevel(zerolog.TraceLevel),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	return &beaconAttestantClient{client: client.(*http.Service)}, nil
+	return &beaconAttestantClient{
+		client:   client.(*http.Service),
+		endpoint: endpoint,
+		// No overall timeout: the response body is a stream that never ends.
+		events: &nethttp.Client{Transport: eventRoundTripper},
+	}, nil
 }
 
 // GetFinalizedEpoch retrieves the latest finalized epoch from the beacon chain.
 func (b *beaconAttestantClient) GetFinalizedEpoch(ctx context.Context) (domain.Epoch, error) {
 	finality, err := b.client.Finality(ctx, &api.FinalityOpts{State: "head"})
 	if err != nil {
-		return 0, err
+		return 0, classifyError(err)
 	}
 	return domain.Epoch(finality.Data.Finalized.Epoch), nil
 }
 
+// GetFinalityCheckpoints retrieves the justified and finalized checkpoints of the head state.
+func (b *beaconAttestantClient) GetFinalityCheckpoints(ctx context.Context) (domain.FinalityCheckpoints, error) {
+	finality, err := b.client.Finality(ctx, &api.FinalityOpts{State: "head"})
+	if err != nil {
+		return domain.FinalityCheckpoints{}, classifyError(err)
+	}
+	return domain.FinalityCheckpoints{
+		Justified:     domain.Epoch(finality.Data.Justified.Epoch),
+		Finalized:     domain.Epoch(finality.Data.Finalized.Epoch),
+		FinalizedRoot: domain.Root(finality.Data.Finalized.Root),
+	}, nil
+}
+
+// GetGenesisTime retrieves the genesis time of the chain.
+func (b *beaconAttestantClient) GetGenesisTime(ctx context.Context) (time.Time, error) {
+	genesis, err := b.client.Genesis(ctx, &api.GenesisOpts{})
+	if err != nil {
+		return time.Time{}, classifyError(err)
+	}
+	return genesis.Data.GenesisTime.UTC(), nil
+}
+
+// GetHeadSlot retrieves the slot of the current head block.
+func (b *beaconAttestantClient) GetHeadSlot(ctx context.Context) (domain.Slot, error) {
+	header, err := b.client.BeaconBlockHeader(ctx, &api.BeaconBlockHeaderOpts{Block: "head"})
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	return domain.Slot(header.Data.Header.Message.Slot), nil
+}
+
 // internal/adapters/beaconchain_adapter.go
 func (b *beaconAttestantClient) GetValidatorDutiesBatch(ctx context.Context, epoch domain.Epoch, validatorIndices []domain.ValidatorIndex) ([]domain.ValidatorDuty, error) {
 	// Convert to phase0.ValidatorIndex
@@ -64,7 +139,7 @@ func (b *beaconAttestantClient) GetValidatorDutiesBatch(ctx context.Context, epo
 		Indices: indices,
 	})
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 
 	// Map the response to domain.ValidatorDuty
@@ -89,7 +164,7 @@ func (b *beaconAttestantClient) GetValidatorDuties(ctx context.Context, epoch do
 		Indices: []phase0.ValidatorIndex{phase0.ValidatorIndex(validatorIndex)},
 	})
 	if err != nil {
-		return domain.ValidatorDuty{}, err
+		return domain.ValidatorDuty{}, classifyError(err)
 	}
 
 	// 🚨 TODO: how to log this here? needed for validators loaded into web3signer but exited (no duties)
@@ -105,43 +180,241 @@ func (b *beaconAttestantClient) GetValidatorDuties(ctx context.Context, epoch do
 	}, nil
 }
 
-// GetBlockAttestations retrieves all attestations include in a slot
-func (b *beaconAttestantClient) GetBlockAttestations(ctx context.Context, slot domain.Slot) ([]domain.Attestation, error) {
-	block, err := b.client.SignedBeaconBlock(ctx, &api.SignedBeaconBlockOpts{
+// GetSyncCommitteeDuties retrieves the sync committee positions of the given
+// validators in an epoch. Before Altair there is no sync committee.
+func (b *beaconAttestantClient) GetSyncCommitteeDuties(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.SyncCommitteeDuty, error) {
+	// As with proposer duties, no indices would mean no filter.
+	if len(indices) == 0 {
+		return nil, nil
+	}
+	resp, err := b.client.SyncCommitteeDuties(ctx, &api.SyncCommitteeDutiesOpts{
+		Epoch:   phase0.Epoch(epoch),
+		Indices: beaconIndices(indices),
+	})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+
+	var duties []domain.SyncCommitteeDuty
+	for _, d := range resp.Data {
+		positions := make([]uint64, len(d.ValidatorSyncCommitteeIndices))
+		for i, p := range d.ValidatorSyncCommitteeIndices {
+			positions[i] = uint64(p)
+		}
+		duties = append(duties, domain.SyncCommitteeDuty{
+			ValidatorIndex: domain.ValidatorIndex(d.ValidatorIndex),
+			Positions:      positions,
+		})
+	}
+	return duties, nil
+}
+
+// GetAttestationRewards retrieves the attestation rewards of the given
+// validators for an epoch. The node reports ideal rewards per effective
+// balance, so each validator's effective balance is read from the state at
+// the epoch's first slot to match its ideal reward.
+func (b *beaconAttestantClient) GetAttestationRewards(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ValidatorReward, error) {
+	if len(indices) == 0 {
+		return nil, nil
+	}
+	resp, err := b.client.AttestationRewards(ctx, &api.AttestationRewardsOpts{
+		Epoch:   phase0.Epoch(epoch),
+		Indices: beaconIndices(indices),
+	})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	validators, err := b.client.Validators(ctx, &api.ValidatorsOpts{
+		State:   fmt.Sprintf("%d", uint64(epoch)*slotsPerEpoch),
+		Indices: beaconIndices(indices),
+	})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+
+	ideal := make(map[phase0.Gwei]int64, len(resp.Data.IdealRewards))
+	for _, r := range resp.Data.IdealRewards {
+		ideal[r.EffectiveBalance] = int64(r.Head) + int64(r.Target) + int64(r.Source) + gweiOrZero(r.InclusionDelay)
+	}
+	var rewards []domain.ValidatorReward
+	for _, r := range resp.Data.TotalRewards {
+		v, ok := validators.Data[r.ValidatorIndex]
+		if !ok || v.Validator == nil {
+			return nil, fmt.Errorf("no effective balance for validator %d at epoch %d", r.ValidatorIndex, epoch)
+		}
+		rewards = append(rewards, domain.ValidatorReward{
+			ValidatorIndex: domain.ValidatorIndex(r.ValidatorIndex),
+			Ideal:          ideal[v.Validator.EffectiveBalance],
+			Actual:         int64(r.Head) + r.Target + r.Source + gweiOrZero(r.InclusionDelay),
+		})
+	}
+	return rewards, nil
+}
+
+// GetSyncCommitteeRewards retrieves the sync committee rewards of the block at
+// a slot. A member that signed is paid what it could have been; one that did
+// not is penalised by the same amount, so the ideal reward is its magnitude.
+func (b *beaconAttestantClient) GetSyncCommitteeRewards(ctx context.Context, slot domain.Slot, indices []domain.ValidatorIndex) ([]domain.ValidatorReward, error) {
+	if len(indices) == 0 {
+		return nil, nil
+	}
+	resp, err := b.client.SyncCommitteeRewards(ctx, &api.SyncCommitteeRewardsOpts{
+		Block:   fmt.Sprintf("%d", slot),
+		Indices: beaconIndices(indices),
+	})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+
+	var rewards []domain.ValidatorReward
+	for _, r := range resp.Data {
+		rewards = append(rewards, domain.ValidatorReward{
+			ValidatorIndex: domain.ValidatorIndex(r.ValidatorIndex),
+			Ideal:          max(r.Reward, -r.Reward),
+			Actual:         r.Reward,
+		})
+	}
+	return rewards, nil
+}
+
+func beaconIndices(indices []domain.ValidatorIndex) []phase0.ValidatorIndex {
+	out := make([]phase0.ValidatorIndex, len(indices))
+	for i, idx := range indices {
+		out[i] = phase0.ValidatorIndex(idx)
+	}
+	return out
+}
+
+func gweiOrZero(g *phase0.Gwei) int64 {
+	if g == nil {
+		return 0
+	}
+	return int64(*g)
+}
+
+// GetBlock retrieves the block at a slot with its attestations. A 404 (no
+// block at a missed slot) is returned as ports.ErrNotFound, a block of a
+// fork this build cannot decode as ports.ErrUnsupportedFork.
+func (b *beaconAttestantClient) GetBlock(ctx context.Context, slot domain.Slot) (domain.Block, error) {
+	// TODO: are we sure we can assume that a 404 means the block was not proposed?
+	// What error code is returned in all consensus if the block is not in their state?
+	var version string
+	block, err := b.client.SignedBeaconBlock(context.WithValue(ctx, consensusVersionKey{}, &version), &api.SignedBeaconBlockOpts{
 		Block: fmt.Sprintf("%d", slot),
 	})
+	if err != nil {
+		// go-eth2-client rejects a version it does not know without
+		// returning the response, so the version comes from the transport.
+		if version != "" && !supportedVersion(version) {
+			return domain.Block{}, fmt.Errorf("%w: block version %s: %w", ports.ErrUnsupportedFork, version, err)
+		}
+		return domain.Block{}, classifyError(err)
+	}
+	if block == nil || block.Data == nil {
+		return domain.Block{}, fmt.Errorf("%w: empty block response for slot %d", ports.ErrNotFound, slot)
+	}
+	root, err := block.Data.Root()
+	if err != nil {
+		return domain.Block{}, err
+	}
+	proposer, err := block.Data.ProposerIndex()
+	if err != nil {
+		return domain.Block{}, err
+	}
+	parentRoot, err := block.Data.ParentRoot()
+	if err != nil {
+		return domain.Block{}, err
+	}
+	attestations, err := blockAttestations(block.Data)
+	if err != nil {
+		return domain.Block{}, err
+	}
+	// Blocks before Altair carry no sync aggregate.
+	var syncBits []byte
+	if block.Data.Version >= spec.DataVersionAltair {
+		aggregate, err := block.Data.SyncAggregate()
+		if err != nil {
+			return domain.Block{}, err
+		}
+		syncBits = aggregate.SyncCommitteeBits
+	}
+	return domain.Block{
+		Slot:              slot,
+		Root:              domain.Root(root),
+		ProposerIndex:     domain.ValidatorIndex(proposer),
+		ParentRoot:        domain.Root(parentRoot),
+		Attestations:      attestations,
+		SyncCommitteeBits: syncBits,
+	}, nil
+}
+
+// blockAttestations maps the attestations of a block of any supported fork.
+// Pre-Electra attestations cover the single committee in data.index; it is
+// expressed as committee bits so the checker handles every fork the same way.
+func blockAttestations(block *spec.VersionedSignedBeaconBlock) ([]domain.Attestation, error) {
+	if !supportedVersion(block.Version.String()) {
+		return nil, fmt.Errorf("%w: block version %s", ports.ErrUnsupportedFork, block.Version)
+	}
+	atts, err := block.Attestations()
 	if err != nil {
 		return nil, err
 	}
 
-	var attestations []domain.Attestation
-	//TODO: fork-sensitive. include all block types
-	for _, att := range block.Data.Fulu.Message.Body.Attestations {
+	attestations := make([]domain.Attestation, 0, len(atts))
+	for _, att := range atts {
+		data, err := att.Data()
+		if err != nil {
+			return nil, err
+		}
+		aggregationBits, err := att.AggregationBits()
+		if err != nil {
+			return nil, err
+		}
+		var committeeBits []byte
+		if block.Version >= spec.DataVersionElectra {
+			committeeBits, err = att.CommitteeBits()
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			committeeBits = make([]byte, 8)
+			committeeBits[data.Index/8] |= 1 << (data.Index % 8)
+		}
 		attestations = append(attestations, domain.Attestation{
-			DataSlot:        domain.Slot(att.Data.Slot),
-			CommitteeBits:   att.CommitteeBits,
-			AggregationBits: att.AggregationBits,
+			DataSlot:        domain.Slot(data.Slot),
+			CommitteeBits:   committeeBits,
+			AggregationBits: aggregationBits,
+			BeaconBlockRoot: domain.Root(data.BeaconBlockRoot),
+			TargetRoot:      domain.Root(data.Target.Root),
 		})
 	}
 	return attestations, nil
 }
 
-// GetCommitteeSizeMap retrieves the size of each attestation committee for a specific slot.
-func (b *beaconAttestantClient) GetCommitteeSizeMap(ctx context.Context, slot domain.Slot) (domain.CommitteeSizeMap, error) {
+// GetEpochCommittees retrieves all attestation committees of an epoch with a
+// single committees call against the state at the epoch's first slot.
+func (b *beaconAttestantClient) GetEpochCommittees(ctx context.Context, epoch domain.Epoch) (domain.EpochCommittees, error) {
+	phase0Epoch := phase0.Epoch(epoch)
 	committees, err := b.client.BeaconCommittees(ctx, &api.BeaconCommitteesOpts{
-		State: fmt.Sprintf("%d", slot),
+		State: fmt.Sprintf("%d", uint64(epoch)*slotsPerEpoch),
+		Epoch: &phase0Epoch,
 	})
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
-	sizeMap := make(domain.CommitteeSizeMap)
+	result := make(domain.EpochCommittees)
 	for _, committee := range committees.Data {
-		if domain.Slot(committee.Slot) != slot {
-			continue
+		slot := domain.Slot(committee.Slot)
+		if result[slot] == nil {
+			result[slot] = make(map[domain.CommitteeIndex][]domain.ValidatorIndex)
+		}
+		members := make([]domain.ValidatorIndex, len(committee.Validators))
+		for i, v := range committee.Validators {
+			members[i] = domain.ValidatorIndex(v)
 		}
-		sizeMap[domain.CommitteeIndex(committee.Index)] = len(committee.Validators)
+		result[slot][domain.CommitteeIndex(committee.Index)] = members
 	}
-	return sizeMap, nil
+	return result, nil
 }
 
 func (b *beaconAttestantClient) GetValidatorIndicesByPubkeys(ctx context.Context, pubkeys []string) ([]domain.ValidatorIndex, error) {
@@ -177,7 +450,7 @@ func (b *beaconAttestantClient) GetValidatorIndicesByPubkeys(ctx context.Context
 		},
 	})
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 
 	var indices []domain.ValidatorIndex
@@ -189,6 +462,10 @@ func (b *beaconAttestantClient) GetValidatorIndicesByPubkeys(ctx context.Context
 
 // GetProposerDuties retrieves proposer duties for the given epoch and validator indices.
 func (b *beaconAttestantClient) GetProposerDuties(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ProposerDuty, error) {
+	// go-eth2-client treats no indices as no filter and returns every proposer.
+	if len(indices) == 0 {
+		return nil, nil
+	}
 	var beaconIndices []phase0.ValidatorIndex
 	for _, idx := range indices {
 		beaconIndices = append(beaconIndices, phase0.ValidatorIndex(idx))
@@ -199,7 +476,7 @@ func (b *beaconAttestantClient) GetProposerDuties(ctx context.Context, epoch dom
 		Indices: beaconIndices,
 	})
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 
 	var duties []domain.ProposerDuty
@@ -212,22 +489,6 @@ func (b *beaconAttestantClient) GetProposerDuties(ctx context.Context, epoch dom
 	return duties, nil
 }
 
-// DidProposeBlock checks a given slot includes a block proposed
-func (b *beaconAttestantClient) DidProposeBlock(ctx context.Context, slot domain.Slot) (bool, error) {
-	block, err := b.client.SignedBeaconBlock(ctx, &api.SignedBeaconBlockOpts{
-		Block: fmt.Sprintf("%d", slot),
-	})
-	if err != nil {
-		// TODO: are we sure we can assume that a 404 means the block was not proposed?
-		// What error code is returned in all consensus if the block is not in their state?
-		if apiErr, ok := err.(*api.Error); ok && apiErr.StatusCode == 404 {
-			return false, nil // Block was not proposed
-		}
-		return false, err // Real error
-	}
-	return block != nil && block.Data != nil, nil
-}
-
 func (b *beaconAttestantClient) GetAllActiveValidatorIndices(ctx context.Context) ([]domain.ValidatorIndex, error) {
 	validators, err := b.client.Validators(ctx, &api.ValidatorsOpts{
 		State: "head",
@@ -238,7 +499,7 @@ func (b *beaconAttestantClient) GetAllActiveValidatorIndices(ctx context.Context
 		},
 	})
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 
 	var indices []domain.ValidatorIndex
@@ -247,3 +508,55 @@ func (b *beaconAttestantClient) GetAllActiveValidatorIndices(ctx context.Context
 	}
 	return indices, nil
 }
+
+// classifyError wraps err with the ports error class it belongs to, so callers
+// can tell definitive answers (not found) from failures worth retrying.
+// Errors that fit no class are returned unchanged.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var (
+		apiErr *api.Error
+		netErr net.Error
+	)
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == nethttp.StatusNotFound:
+		return fmt.Errorf("%w: %w", ports.ErrNotFound, err)
+	case errors.As(err, &apiErr) && apiErr.StatusCode >= 500:
+		return fmt.Errorf("%w: %w", ports.ErrServer, err)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Errorf("%w: %w", ports.ErrTimeout, err)
+	case errors.Is(err, eth2client.ErrNotActive), errors.As(err, &netErr):
+		return fmt.Errorf("%w: %w", ports.ErrUnavailable, err)
+	}
+	return err
+}
+
+// supportedVersion reports whether blocks of the consensus version named
+// version, as in the Eth-Consensus-Version header, can be decoded.
+func supportedVersion(version string) bool {
+	var v spec.DataVersion
+	if err := v.UnmarshalJSON(fmt.Appendf(nil, "%q", version)); err != nil {
+		return false
+	}
+	return v >= spec.DataVersionPhase0 && v <= spec.DataVersionFulu
+}
+
+// consensusVersionKey is the request context key of the *string a
+// consensusVersionTransport stores the response's Eth-Consensus-Version in.
+type consensusVersionKey struct{}
+
+// consensusVersionTransport records the Eth-Consensus-Version header of each
+// response for requests whose context asks for it (see consensusVersionKey).
+type consensusVersionTransport struct {
+	next nethttp.RoundTripper
+}
+
+func (t consensusVersionTransport) RoundTrip(req *nethttp.Request) (*nethttp.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if version, ok := req.Context().Value(consensusVersionKey{}).(*string); ok && err == nil {
+		*version = resp.Header.Get("Eth-Consensus-Version")
+	}
+	return resp, err
+}
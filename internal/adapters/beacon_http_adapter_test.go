@@ -0,0 +1,256 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/beaconmock"
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/application/services"
+	"github.com/Marketen/duties-indexer/internal/testutil"
+)
+
+// mockChainConfig keeps blocks within Electra's limit of 8 attestations so
+// they can be served as SSZ.
+var mockChainConfig = fakechain.Config{
+	Seed: 3, Validators: 1024, CommitteesPerSlot: 4, Epochs: 4,
+	MissedSlotRate: 0.1, MissedAttestationRate: 0.05, MaxInclusionDelay: 1,
+}
+
+func newMockNode(t *testing.T) (*fakechain.Chain, *beaconmock.Server) {
+	t.Helper()
+	chain, err := fakechain.New(mockChainConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := beaconmock.New(chain)
+	t.Cleanup(node.Close)
+	return chain, node
+}
+
+func newMockClient(t *testing.T, node *beaconmock.Server, timeout time.Duration) *beaconAttestantClient {
+	t.Helper()
+	client, err := newBeaconAttestantClient(node.URL, 0, timeout, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// TestAttestantAdapterMatchesFakeChain runs the checker through the adapter
+// against a mock node serving each fork's block format, as SSZ and as JSON,
+// and expects the outcomes the chain was generated with.
+func TestAttestantAdapterMatchesFakeChain(t *testing.T) {
+	ctx := context.Background()
+	chain, node := newMockNode(t)
+	indices := testutil.Validators(mockChainConfig.Validators)
+	epoch, _ := chain.GetFinalizedEpoch(ctx)
+	want := chain.Expected(epoch, indices)
+
+	schedules := map[string][]beaconmock.Fork{
+		"phase0":    {{Epoch: 0, Version: "phase0"}},
+		"altair":    {{Epoch: 0, Version: "altair"}},
+		"bellatrix": {{Epoch: 0, Version: "bellatrix"}},
+		"capella":   {{Epoch: 0, Version: "capella"}},
+		"deneb":     {{Epoch: 0, Version: "deneb"}},
+		"electra":   {{Epoch: 0, Version: "electra"}},
+		"fulu":      {{Epoch: 0, Version: "fulu"}},
+		// Attestations of the last Deneb epoch are included in Electra blocks.
+		"deneb to electra": {{Epoch: 0, Version: "deneb"}, {Epoch: epoch + 1, Version: "electra"}},
+	}
+	for name, forks := range schedules {
+		for _, jsonOnly := range []bool{false, true} {
+			encoding := "ssz"
+			if jsonOnly {
+				encoding = "json"
+			}
+			t.Run(name+"/"+encoding, func(t *testing.T) {
+				node.SetForks(forks...)
+				node.SetJSONOnly(jsonOnly)
+				client := newMockClient(t, node, time.Second)
+				got, err := services.NewDutiesChecker(client, 0, indices).CheckEpoch(ctx, epoch)
+				if err != nil {
+					t.Fatal(err)
+				}
+				testutil.AssertResults(t, got, want)
+			})
+		}
+	}
+}
+
+// TestAttestantAdapterSyncCommittee checks sync committee duties, the sync
+// aggregate of blocks and the rewards APIs through the adapter.
+func TestAttestantAdapterSyncCommittee(t *testing.T) {
+	ctx := context.Background()
+	cfg := mockChainConfig
+	cfg.SyncCommitteeSize, cfg.MissedSyncRate = 512, 0.1
+	chain, err := fakechain.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := beaconmock.New(chain)
+	t.Cleanup(node.Close)
+	indices := testutil.Validators(cfg.Validators)
+	epoch, _ := chain.GetFinalizedEpoch(ctx)
+	want := chain.Expected(epoch, indices)
+
+	got, err := services.NewDutiesChecker(newMockClient(t, node, time.Second), 0, indices).CheckEpoch(ctx, epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertResults(t, got, want)
+	missed := 0
+	for _, r := range want {
+		if r.Type == domain.DutyTypeSyncCommittee && r.MissedReward > 0 {
+			missed++
+		}
+	}
+	if missed == 0 {
+		t.Error("no missed sync committee signature with a reward, the test checks nothing")
+	}
+}
+
+func TestAttestantAdapterChainState(t *testing.T) {
+	ctx := context.Background()
+	chain, node := newMockNode(t)
+	client := newMockClient(t, node, time.Second)
+
+	finalized, err := client.GetFinalizedEpoch(ctx)
+	if want, _ := chain.GetFinalizedEpoch(ctx); err != nil || finalized != want {
+		t.Errorf("finalized epoch: got %d, %v, want %d", finalized, err, want)
+	}
+	checkpoints, err := client.GetFinalityCheckpoints(ctx)
+	if want, _ := chain.GetFinalityCheckpoints(ctx); err != nil || checkpoints != want {
+		t.Errorf("finality checkpoints: got %+v, %v, want %+v", checkpoints, err, want)
+	}
+	head, err := client.GetHeadSlot(ctx)
+	if want, _ := chain.GetHeadSlot(ctx); err != nil || head != want {
+		t.Errorf("head slot: got %d, %v, want %d", head, err, want)
+	}
+
+	pubkeys := []string{beaconmock.PubKey(5).String(), beaconmock.PubKey(700).String(), "0x" + fmt.Sprintf("%096x", 1)}
+	got, err := client.GetValidatorIndicesByPubkeys(ctx, pubkeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[domain.ValidatorIndex]bool{}
+	for _, v := range got {
+		found[v] = true
+	}
+	if len(got) != 2 || !found[5] || !found[700] {
+		t.Errorf("indices by pubkey: got %v, want [5 700]", got)
+	}
+
+	proposers, err := client.GetProposerDuties(ctx, finalized, []domain.ValidatorIndex{})
+	if err != nil || len(proposers) != 0 {
+		t.Errorf("proposer duties of no validators: got %v, %v", proposers, err)
+	}
+}
+
+// TestAttestantAdapterErrors checks that node failures reach the checker as
+// the ports error classes it decides on.
+func TestAttestantAdapterErrors(t *testing.T) {
+	ctx := context.Background()
+	chain, node := newMockNode(t)
+	epoch, _ := chain.GetFinalizedEpoch(ctx)
+	slot := domain.Slot(epoch)*slotsPerEpoch + 3
+	blockPath := fmt.Sprintf("/eth/v2/beacon/blocks/%d", slot)
+	committeesPath := fmt.Sprintf("/eth/v1/beacon/states/%d/committees", domain.Slot(epoch)*slotsPerEpoch)
+	dutiesPath := fmt.Sprintf("/eth/v1/validator/duties/attester/%d", epoch)
+
+	var missed domain.Slot
+	for s := domain.Slot(epoch) * slotsPerEpoch; ; s++ {
+		if _, err := chain.GetBlock(ctx, s); errors.Is(err, ports.ErrNotFound) {
+			missed = s
+			break
+		}
+	}
+
+	tests := []struct {
+		name  string
+		setup func()
+		call  func(*beaconAttestantClient) error
+		want  error
+	}{
+		{
+			name: "missed slot",
+			call: func(c *beaconAttestantClient) error { _, err := c.GetBlock(ctx, missed); return err },
+			want: ports.ErrNotFound,
+		},
+		{
+			name:  "404",
+			setup: func() { node.Fail(committeesPath, beaconmock.Fault{Status: http.StatusNotFound}) },
+			call:  func(c *beaconAttestantClient) error { _, err := c.GetEpochCommittees(ctx, epoch); return err },
+			want:  ports.ErrNotFound,
+		},
+		{
+			name:  "500",
+			setup: func() { node.Fail(blockPath, beaconmock.Fault{Status: http.StatusInternalServerError}) },
+			call:  func(c *beaconAttestantClient) error { _, err := c.GetBlock(ctx, slot); return err },
+			want:  ports.ErrServer,
+		},
+		{
+			name:  "503",
+			setup: func() { node.Fail(dutiesPath, beaconmock.Fault{Status: http.StatusServiceUnavailable}) },
+			call: func(c *beaconAttestantClient) error {
+				_, err := c.GetValidatorDutiesBatch(ctx, epoch, []domain.ValidatorIndex{1})
+				return err
+			},
+			want: ports.ErrServer,
+		},
+		{
+			name:  "timeout",
+			setup: func() { node.Fail(blockPath, beaconmock.Fault{Delay: 5 * time.Second}) },
+			call:  func(c *beaconAttestantClient) error { _, err := c.GetBlock(ctx, slot); return err },
+			want:  ports.ErrTimeout,
+		},
+		{
+			name:  "unknown fork",
+			setup: func() { node.SetForks(beaconmock.Fork{Epoch: 0, Version: "gloas"}) },
+			call:  func(c *beaconAttestantClient) error { _, err := c.GetBlock(ctx, slot); return err },
+			want:  ports.ErrUnsupportedFork,
+		},
+		{
+			name:  "unknown fork as json",
+			setup: func() { node.SetForks(beaconmock.Fork{Epoch: 0, Version: "gloas"}); node.SetJSONOnly(true) },
+			call:  func(c *beaconAttestantClient) error { _, err := c.GetBlock(ctx, slot); return err },
+			want:  ports.ErrUnsupportedFork,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node.Fail(blockPath, beaconmock.Fault{})
+			node.Fail(committeesPath, beaconmock.Fault{})
+			node.Fail(dutiesPath, beaconmock.Fault{})
+			node.SetForks(beaconmock.Fork{Epoch: 0, Version: "electra"})
+			node.SetJSONOnly(false)
+			client := newMockClient(t, node, 200*time.Millisecond)
+			if tt.setup != nil {
+				tt.setup()
+			}
+			err := tt.call(client)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if ports.IsTransient(err) != ports.IsTransient(tt.want) {
+				t.Errorf("transient: got %v", ports.IsTransient(err))
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		_, node := newMockNode(t)
+		client := newMockClient(t, node, 200*time.Millisecond)
+		node.Close()
+		_, err := client.GetBlock(ctx, slot)
+		if !errors.Is(err, ports.ErrUnavailable) {
+			t.Fatalf("got %v, want %v", err, ports.ErrUnavailable)
+		}
+	})
+}
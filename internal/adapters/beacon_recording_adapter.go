@@ -0,0 +1,147 @@
+package adapters
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+)
+
+// RecordingBeaconAdapter is a BeaconChainAdapter decorator that records every
+// call into a fixture directory. Close must be called to complete the fixture.
+type RecordingBeaconAdapter interface {
+	ports.BeaconChainAdapter
+	// Close writes the manifest and flushes the recorded calls.
+	Close(manifest FixtureManifest) error
+}
+
+type recordingBeaconAdapter struct {
+	inner ports.BeaconChainAdapter
+	dir   string
+
+	mu   sync.Mutex
+	file *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+	err  error // first write error, reported by Close
+}
+
+// NewRecordingBeaconAdapter wraps inner and records its calls and responses
+// into dir, which is created if needed. An existing fixture in dir is
+// overwritten.
+func NewRecordingBeaconAdapter(inner ports.BeaconChainAdapter, dir string) (RecordingBeaconAdapter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating fixture directory: %w", err)
+	}
+	f, err := os.Create(filepath.Join(dir, fixtureCallsFile))
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	return &recordingBeaconAdapter{inner: inner, dir: dir, file: f, gz: gz, enc: json.NewEncoder(gz)}, nil
+}
+
+// record appends one call. Calls run concurrently; lines are written whole.
+func (r *recordingBeaconAdapter) record(call fixtureCall, result any, err error) {
+	if err != nil {
+		call.Error = newFixtureError(err)
+	} else if call.Result, err = json.Marshal(result); err != nil {
+		call.Error = newFixtureError(fmt.Errorf("encoding result: %w", err))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = r.enc.Encode(call)
+	}
+}
+
+func (r *recordingBeaconAdapter) Close(manifest FixtureManifest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.gz.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if r.err != nil {
+		return fmt.Errorf("writing recorded calls: %w", r.err)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.dir, fixtureManifestFile), data, 0o644)
+}
+
+func (r *recordingBeaconAdapter) GetFinalizedEpoch(ctx context.Context) (domain.Epoch, error) {
+	epoch, err := r.inner.GetFinalizedEpoch(ctx)
+	r.record(fixtureCall{Method: callFinalizedEpoch}, epoch, err)
+	return epoch, err
+}
+
+func (r *recordingBeaconAdapter) GetFinalityCheckpoints(ctx context.Context) (domain.FinalityCheckpoints, error) {
+	checkpoints, err := r.inner.GetFinalityCheckpoints(ctx)
+	r.record(fixtureCall{Method: callFinalityCheckpoints}, checkpoints, err)
+	return checkpoints, err
+}
+
+func (r *recordingBeaconAdapter) GetHeadSlot(ctx context.Context) (domain.Slot, error) {
+	slot, err := r.inner.GetHeadSlot(ctx)
+	r.record(fixtureCall{Method: callHeadSlot}, slot, err)
+	return slot, err
+}
+
+func (r *recordingBeaconAdapter) GetValidatorDutiesBatch(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ValidatorDuty, error) {
+	duties, err := r.inner.GetValidatorDutiesBatch(ctx, epoch, indices)
+	r.record(fixtureCall{Method: callValidatorDuties, Epoch: &epoch, Indices: indices}, duties, err)
+	return duties, err
+}
+
+func (r *recordingBeaconAdapter) GetProposerDuties(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ProposerDuty, error) {
+	duties, err := r.inner.GetProposerDuties(ctx, epoch, indices)
+	r.record(fixtureCall{Method: callProposerDuties, Epoch: &epoch, Indices: indices}, duties, err)
+	return duties, err
+}
+
+func (r *recordingBeaconAdapter) GetSyncCommitteeDuties(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.SyncCommitteeDuty, error) {
+	duties, err := r.inner.GetSyncCommitteeDuties(ctx, epoch, indices)
+	r.record(fixtureCall{Method: callSyncCommitteeDuties, Epoch: &epoch, Indices: indices}, duties, err)
+	return duties, err
+}
+
+func (r *recordingBeaconAdapter) GetAttestationRewards(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ValidatorReward, error) {
+	rewards, err := r.inner.GetAttestationRewards(ctx, epoch, indices)
+	r.record(fixtureCall{Method: callAttestationRewards, Epoch: &epoch, Indices: indices}, rewards, err)
+	return rewards, err
+}
+
+func (r *recordingBeaconAdapter) GetSyncCommitteeRewards(ctx context.Context, slot domain.Slot, indices []domain.ValidatorIndex) ([]domain.ValidatorReward, error) {
+	rewards, err := r.inner.GetSyncCommitteeRewards(ctx, slot, indices)
+	r.record(fixtureCall{Method: callSyncCommitteeRewards, Slot: &slot, Indices: indices}, rewards, err)
+	return rewards, err
+}
+
+func (r *recordingBeaconAdapter) GetBlock(ctx context.Context, slot domain.Slot) (domain.Block, error) {
+	block, err := r.inner.GetBlock(ctx, slot)
+	r.record(fixtureCall{Method: callBlock, Slot: &slot}, block, err)
+	return block, err
+}
+
+func (r *recordingBeaconAdapter) GetEpochCommittees(ctx context.Context, epoch domain.Epoch) (domain.EpochCommittees, error) {
+	committees, err := r.inner.GetEpochCommittees(ctx, epoch)
+	r.record(fixtureCall{Method: callEpochCommittees, Epoch: &epoch}, committees, err)
+	return committees, err
+}
+
+func (r *recordingBeaconAdapter) GetAllActiveValidatorIndices(ctx context.Context) ([]domain.ValidatorIndex, error) {
+	indices, err := r.inner.GetAllActiveValidatorIndices(ctx)
+	r.record(fixtureCall{Method: callActiveValidators}, indices, err)
+	return indices, err
+}
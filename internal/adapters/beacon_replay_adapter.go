@@ -0,0 +1,284 @@
+package adapters
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+)
+
+// errNotRecorded is returned for calls the fixture has no answer for. It
+// deliberately matches no ports sentinel: the data is unknown, not absent.
+var errNotRecorded = errors.New("not recorded in the fixture")
+
+// replayBeaconAdapter implements ports.BeaconChainAdapter from a fixture,
+// without network access. Calls are matched on their arguments: duties are
+// served for any subset of the validators they were recorded for, and the
+// single-valued calls (finalized epoch, checkpoints, head slot) return their
+// first recorded answer.
+type replayBeaconAdapter struct {
+	finalizedEpoch      *replayed[domain.Epoch]
+	finalityCheckpoints *replayed[domain.FinalityCheckpoints]
+	headSlot            *replayed[domain.Slot]
+	activeValidators    *replayed[[]domain.ValidatorIndex]
+	blocks              map[domain.Slot]*replayed[domain.Block]
+	committees          map[domain.Epoch]*replayed[domain.EpochCommittees]
+	attesterDuties      map[domain.Epoch]*replayedDuties[domain.ValidatorDuty]
+	proposerDuties      map[domain.Epoch]*replayedDuties[domain.ProposerDuty]
+	syncDuties          map[domain.Epoch]*replayedDuties[domain.SyncCommitteeDuty]
+	attestationRewards  map[domain.Epoch]*replayedDuties[domain.ValidatorReward]
+	syncRewards         map[domain.Slot]*replayedDuties[domain.ValidatorReward]
+}
+
+// replayed is the recorded answer of one call.
+type replayed[T any] struct {
+	value T
+	err   error
+}
+
+// replayedDuties merges the duty calls of one epoch, which are made for
+// chunks of validators. Every validator requested is covered, whether it had
+// a duty or not.
+type replayedDuties[T any] struct {
+	requested map[domain.ValidatorIndex]error // nil error: answered
+	duties    map[domain.ValidatorIndex][]T
+}
+
+// OpenReplayBeaconAdapter loads the fixture in dir.
+func OpenReplayBeaconAdapter(dir string) (ports.BeaconChainAdapter, FixtureManifest, error) {
+	var manifest FixtureManifest
+	data, err := os.ReadFile(filepath.Join(dir, fixtureManifestFile))
+	if err != nil {
+		return nil, manifest, fmt.Errorf("reading fixture manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, manifest, fmt.Errorf("decoding fixture manifest: %w", err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, fixtureCallsFile))
+	if err != nil {
+		return nil, manifest, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, manifest, fmt.Errorf("reading %s: %w", fixtureCallsFile, err)
+	}
+	r := &replayBeaconAdapter{
+		blocks:             make(map[domain.Slot]*replayed[domain.Block]),
+		committees:         make(map[domain.Epoch]*replayed[domain.EpochCommittees]),
+		attesterDuties:     make(map[domain.Epoch]*replayedDuties[domain.ValidatorDuty]),
+		proposerDuties:     make(map[domain.Epoch]*replayedDuties[domain.ProposerDuty]),
+		syncDuties:         make(map[domain.Epoch]*replayedDuties[domain.SyncCommitteeDuty]),
+		attestationRewards: make(map[domain.Epoch]*replayedDuties[domain.ValidatorReward]),
+		syncRewards:        make(map[domain.Slot]*replayedDuties[domain.ValidatorReward]),
+	}
+	dec := json.NewDecoder(bufio.NewReader(gz))
+	for line := 1; dec.More(); line++ {
+		var call fixtureCall
+		if err := dec.Decode(&call); err != nil {
+			return nil, manifest, fmt.Errorf("%s call %d: %w", fixtureCallsFile, line, err)
+		}
+		if err := r.load(call); err != nil {
+			return nil, manifest, fmt.Errorf("%s call %d (%s): %w", fixtureCallsFile, line, call.Method, err)
+		}
+	}
+	return r, manifest, nil
+}
+
+// load adds one recorded call. Later answers for the same block or
+// committees replace earlier ones, since the recorder sees retries.
+func (r *replayBeaconAdapter) load(call fixtureCall) error {
+	var err error
+	switch call.Method {
+	case callFinalizedEpoch:
+		if r.finalizedEpoch == nil {
+			r.finalizedEpoch, err = decodeReplayed[domain.Epoch](call)
+		}
+	case callFinalityCheckpoints:
+		if r.finalityCheckpoints == nil {
+			r.finalityCheckpoints, err = decodeReplayed[domain.FinalityCheckpoints](call)
+		}
+	case callHeadSlot:
+		if r.headSlot == nil {
+			r.headSlot, err = decodeReplayed[domain.Slot](call)
+		}
+	case callActiveValidators:
+		if r.activeValidators == nil {
+			r.activeValidators, err = decodeReplayed[[]domain.ValidatorIndex](call)
+		}
+	case callBlock:
+		if call.Slot == nil {
+			return errors.New("missing slot")
+		}
+		r.blocks[*call.Slot], err = decodeReplayed[domain.Block](call)
+	case callEpochCommittees:
+		if call.Epoch == nil {
+			return errors.New("missing epoch")
+		}
+		r.committees[*call.Epoch], err = decodeReplayed[domain.EpochCommittees](call)
+	case callValidatorDuties:
+		if call.Epoch == nil {
+			return errors.New("missing epoch")
+		}
+		if r.attesterDuties[*call.Epoch] == nil {
+			r.attesterDuties[*call.Epoch] = newReplayedDuties[domain.ValidatorDuty]()
+		}
+		err = r.attesterDuties[*call.Epoch].add(call, func(d domain.ValidatorDuty) domain.ValidatorIndex { return d.ValidatorIndex })
+	case callProposerDuties:
+		if call.Epoch == nil {
+			return errors.New("missing epoch")
+		}
+		if r.proposerDuties[*call.Epoch] == nil {
+			r.proposerDuties[*call.Epoch] = newReplayedDuties[domain.ProposerDuty]()
+		}
+		err = r.proposerDuties[*call.Epoch].add(call, func(d domain.ProposerDuty) domain.ValidatorIndex { return d.ValidatorIndex })
+	case callSyncCommitteeDuties:
+		if call.Epoch == nil {
+			return errors.New("missing epoch")
+		}
+		if r.syncDuties[*call.Epoch] == nil {
+			r.syncDuties[*call.Epoch] = newReplayedDuties[domain.SyncCommitteeDuty]()
+		}
+		err = r.syncDuties[*call.Epoch].add(call, func(d domain.SyncCommitteeDuty) domain.ValidatorIndex { return d.ValidatorIndex })
+	case callAttestationRewards:
+		if call.Epoch == nil {
+			return errors.New("missing epoch")
+		}
+		if r.attestationRewards[*call.Epoch] == nil {
+			r.attestationRewards[*call.Epoch] = newReplayedDuties[domain.ValidatorReward]()
+		}
+		err = r.attestationRewards[*call.Epoch].add(call, func(w domain.ValidatorReward) domain.ValidatorIndex { return w.ValidatorIndex })
+	case callSyncCommitteeRewards:
+		if call.Slot == nil {
+			return errors.New("missing slot")
+		}
+		if r.syncRewards[*call.Slot] == nil {
+			r.syncRewards[*call.Slot] = newReplayedDuties[domain.ValidatorReward]()
+		}
+		err = r.syncRewards[*call.Slot].add(call, func(w domain.ValidatorReward) domain.ValidatorIndex { return w.ValidatorIndex })
+	default:
+		return errors.New("unknown method")
+	}
+	return err
+}
+
+func decodeReplayed[T any](call fixtureCall) (*replayed[T], error) {
+	r := &replayed[T]{}
+	if call.Error != nil {
+		r.err = call.Error.err()
+		return r, nil
+	}
+	if err := json.Unmarshal(call.Result, &r.value); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *replayed[T]) get(what string) (T, error) {
+	if r == nil {
+		var zero T
+		return zero, fmt.Errorf("%s: %w", what, errNotRecorded)
+	}
+	return r.value, r.err
+}
+
+func newReplayedDuties[T any]() *replayedDuties[T] {
+	return &replayedDuties[T]{
+		requested: make(map[domain.ValidatorIndex]error),
+		duties:    make(map[domain.ValidatorIndex][]T),
+	}
+}
+
+func (d *replayedDuties[T]) add(call fixtureCall, validatorOf func(T) domain.ValidatorIndex) error {
+	result, err := decodeReplayed[[]T](call)
+	if err != nil {
+		return err
+	}
+	for _, v := range call.Indices {
+		d.requested[v] = result.err
+	}
+	for _, duty := range result.value {
+		v := validatorOf(duty)
+		d.duties[v] = append(d.duties[v], duty)
+	}
+	return nil
+}
+
+// get returns the duties of indices, in the recorded order per validator, or
+// the recorded error of the first validator whose call failed.
+func (d *replayedDuties[T]) get(what string, indices []domain.ValidatorIndex) ([]T, error) {
+	if d == nil {
+		return nil, fmt.Errorf("%s: %w", what, errNotRecorded)
+	}
+	var duties []T
+	for _, v := range indices {
+		err, ok := d.requested[v]
+		if !ok {
+			return nil, fmt.Errorf("%s of validator %d: %w", what, v, errNotRecorded)
+		}
+		if err != nil {
+			return nil, err
+		}
+		duties = append(duties, d.duties[v]...)
+	}
+	return duties, nil
+}
+
+func (r *replayBeaconAdapter) GetFinalizedEpoch(context.Context) (domain.Epoch, error) {
+	return r.finalizedEpoch.get("finalized epoch")
+}
+
+func (r *replayBeaconAdapter) GetFinalityCheckpoints(context.Context) (domain.FinalityCheckpoints, error) {
+	return r.finalityCheckpoints.get("finality checkpoints")
+}
+
+func (r *replayBeaconAdapter) GetHeadSlot(context.Context) (domain.Slot, error) {
+	return r.headSlot.get("head slot")
+}
+
+func (r *replayBeaconAdapter) GetValidatorDutiesBatch(_ context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ValidatorDuty, error) {
+	return r.attesterDuties[epoch].get(fmt.Sprintf("attester duties for epoch %d", epoch), indices)
+}
+
+func (r *replayBeaconAdapter) GetProposerDuties(_ context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ProposerDuty, error) {
+	duties, err := r.proposerDuties[epoch].get(fmt.Sprintf("proposer duties for epoch %d", epoch), indices)
+	if err != nil {
+		return nil, err
+	}
+	// Keep the slot order of the beacon API.
+	sort.Slice(duties, func(i, j int) bool { return duties[i].Slot < duties[j].Slot })
+	return duties, nil
+}
+
+func (r *replayBeaconAdapter) GetSyncCommitteeDuties(_ context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.SyncCommitteeDuty, error) {
+	return r.syncDuties[epoch].get(fmt.Sprintf("sync committee duties for epoch %d", epoch), indices)
+}
+
+func (r *replayBeaconAdapter) GetAttestationRewards(_ context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ValidatorReward, error) {
+	return r.attestationRewards[epoch].get(fmt.Sprintf("attestation rewards for epoch %d", epoch), indices)
+}
+
+func (r *replayBeaconAdapter) GetSyncCommitteeRewards(_ context.Context, slot domain.Slot, indices []domain.ValidatorIndex) ([]domain.ValidatorReward, error) {
+	return r.syncRewards[slot].get(fmt.Sprintf("sync committee rewards at slot %d", slot), indices)
+}
+
+func (r *replayBeaconAdapter) GetBlock(_ context.Context, slot domain.Slot) (domain.Block, error) {
+	return r.blocks[slot].get(fmt.Sprintf("block at slot %d", slot))
+}
+
+func (r *replayBeaconAdapter) GetEpochCommittees(_ context.Context, epoch domain.Epoch) (domain.EpochCommittees, error) {
+	return r.committees[epoch].get(fmt.Sprintf("committees for epoch %d", epoch))
+}
+
+func (r *replayBeaconAdapter) GetAllActiveValidatorIndices(context.Context) ([]domain.ValidatorIndex, error) {
+	return r.activeValidators.get("active validators")
+}
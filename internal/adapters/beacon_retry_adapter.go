@@ -0,0 +1,122 @@
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// RetryPolicy configures how transient beacon errors are retried: up to
+// MaxAttempts calls in total, waiting InitialBackoff after the first failure
+// and doubling the wait after each further one, capped at MaxBackoff.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// retryingBeaconAdapter is a decorator that retries calls failing with a
+// transient error (see ports.IsTransient). Definitive errors such as
+// ports.ErrNotFound are returned immediately.
+type retryingBeaconAdapter struct {
+	inner  ports.BeaconChainAdapter
+	policy RetryPolicy
+	// after waits out a backoff; time.After but in tests.
+	after func(time.Duration) <-chan time.Time
+}
+
+// NewRetryingBeaconAdapter wraps inner so that transient errors are retried
+// with exponential backoff according to policy.
+func NewRetryingBeaconAdapter(inner ports.BeaconChainAdapter, policy RetryPolicy) ports.BeaconChainAdapter {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return &retryingBeaconAdapter{inner: inner, policy: policy, after: time.After}
+}
+
+func retry[T any](ctx context.Context, r *retryingBeaconAdapter, method string, fn func() (T, error)) (T, error) {
+	backoff := r.policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		res, err := fn()
+		if err == nil || !ports.IsTransient(err) || attempt >= r.policy.MaxAttempts {
+			return res, err
+		}
+		logger.Debug("%s failed (attempt %d/%d), retrying in %s: %v", method, attempt, r.policy.MaxAttempts, backoff, err)
+		select {
+		case <-r.after(backoff):
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+		backoff = min(backoff*2, r.policy.MaxBackoff)
+	}
+}
+
+func (r *retryingBeaconAdapter) GetFinalizedEpoch(ctx context.Context) (domain.Epoch, error) {
+	return retry(ctx, r, "GetFinalizedEpoch", func() (domain.Epoch, error) {
+		return r.inner.GetFinalizedEpoch(ctx)
+	})
+}
+
+func (r *retryingBeaconAdapter) GetFinalityCheckpoints(ctx context.Context) (domain.FinalityCheckpoints, error) {
+	return retry(ctx, r, "GetFinalityCheckpoints", func() (domain.FinalityCheckpoints, error) {
+		return r.inner.GetFinalityCheckpoints(ctx)
+	})
+}
+
+func (r *retryingBeaconAdapter) GetHeadSlot(ctx context.Context) (domain.Slot, error) {
+	return retry(ctx, r, "GetHeadSlot", func() (domain.Slot, error) {
+		return r.inner.GetHeadSlot(ctx)
+	})
+}
+
+func (r *retryingBeaconAdapter) GetValidatorDutiesBatch(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ValidatorDuty, error) {
+	return retry(ctx, r, "GetValidatorDutiesBatch", func() ([]domain.ValidatorDuty, error) {
+		return r.inner.GetValidatorDutiesBatch(ctx, epoch, indices)
+	})
+}
+
+func (r *retryingBeaconAdapter) GetProposerDuties(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ProposerDuty, error) {
+	return retry(ctx, r, "GetProposerDuties", func() ([]domain.ProposerDuty, error) {
+		return r.inner.GetProposerDuties(ctx, epoch, indices)
+	})
+}
+
+func (r *retryingBeaconAdapter) GetSyncCommitteeDuties(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.SyncCommitteeDuty, error) {
+	return retry(ctx, r, "GetSyncCommitteeDuties", func() ([]domain.SyncCommitteeDuty, error) {
+		return r.inner.GetSyncCommitteeDuties(ctx, epoch, indices)
+	})
+}
+
+func (r *retryingBeaconAdapter) GetAttestationRewards(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ValidatorReward, error) {
+	return retry(ctx, r, "GetAttestationRewards", func() ([]domain.ValidatorReward, error) {
+		return r.inner.GetAttestationRewards(ctx, epoch, indices)
+	})
+}
+
+func (r *retryingBeaconAdapter) GetSyncCommitteeRewards(ctx context.Context, slot domain.Slot, indices []domain.ValidatorIndex) ([]domain.ValidatorReward, error) {
+	return retry(ctx, r, "GetSyncCommitteeRewards", func() ([]domain.ValidatorReward, error) {
+		return r.inner.GetSyncCommitteeRewards(ctx, slot, indices)
+	})
+}
+
+func (r *retryingBeaconAdapter) GetBlock(ctx context.Context, slot domain.Slot) (domain.Block, error) {
+	return retry(ctx, r, "GetBlock", func() (domain.Block, error) {
+		return r.inner.GetBlock(ctx, slot)
+	})
+}
+
+func (r *retryingBeaconAdapter) GetEpochCommittees(ctx context.Context, epoch domain.Epoch) (domain.EpochCommittees, error) {
+	return retry(ctx, r, "GetEpochCommittees", func() (domain.EpochCommittees, error) {
+		return r.inner.GetEpochCommittees(ctx, epoch)
+	})
+}
+
+func (r *retryingBeaconAdapter) GetAllActiveValidatorIndices(ctx context.Context) ([]domain.ValidatorIndex, error) {
+	return retry(ctx, r, "GetAllActiveValidatorIndices", func() ([]domain.ValidatorIndex, error) {
+		return r.inner.GetAllActiveValidatorIndices(ctx)
+	})
+}
@@ -0,0 +1,130 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+)
+
+// flakyBeacon fails GetFinalizedEpoch with err for the first failures calls.
+type flakyBeacon struct {
+	ports.BeaconChainAdapter
+	err      error
+	failures int
+	calls    int
+}
+
+func (f *flakyBeacon) GetFinalizedEpoch(context.Context) (domain.Epoch, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return 0, f.err
+	}
+	return 7, nil
+}
+
+// newTestRetrying returns a retrying adapter over inner that records the
+// backoffs it waits instead of sleeping.
+func newTestRetrying(inner ports.BeaconChainAdapter, policy RetryPolicy) (*retryingBeaconAdapter, *[]time.Duration) {
+	r := NewRetryingBeaconAdapter(inner, policy).(*retryingBeaconAdapter)
+	var waits []time.Duration
+	r.after = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Time{}
+		return ch
+	}
+	return r, &waits
+}
+
+func TestRetryingBeaconAdapter(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	transient := fmt.Errorf("%w: status 503", ports.ErrServer)
+	unclassified := errors.New("bad response")
+	tests := []struct {
+		name      string
+		err       error
+		failures  int
+		wantCalls int
+		wantWaits []time.Duration
+		wantErr   error
+	}{
+		{name: "success", wantCalls: 1},
+		{
+			name: "transient then success", err: transient, failures: 3, wantCalls: 4,
+			wantWaits: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond},
+		},
+		{
+			name: "attempts exhausted", err: transient, failures: 10, wantCalls: 5,
+			wantWaits: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond},
+			wantErr:   ports.ErrServer,
+		},
+		{name: "timeout", err: ports.ErrTimeout, failures: 1, wantCalls: 2, wantWaits: []time.Duration{100 * time.Millisecond}},
+		{name: "unavailable", err: ports.ErrUnavailable, failures: 1, wantCalls: 2, wantWaits: []time.Duration{100 * time.Millisecond}},
+		{name: "not found", err: fmt.Errorf("block: %w", ports.ErrNotFound), failures: 1, wantCalls: 1, wantErr: ports.ErrNotFound},
+		{name: "unsupported fork", err: ports.ErrUnsupportedFork, failures: 1, wantCalls: 1, wantErr: ports.ErrUnsupportedFork},
+		{name: "unclassified", err: unclassified, failures: 1, wantCalls: 1, wantErr: unclassified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &flakyBeacon{err: tt.err, failures: tt.failures}
+			r, waits := newTestRetrying(inner, policy)
+			epoch, err := r.GetFinalizedEpoch(context.Background())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("got error %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil || epoch != 7 {
+				t.Errorf("got %d, %v, want 7", epoch, err)
+			}
+			if inner.calls != tt.wantCalls {
+				t.Errorf("%d calls, want %d", inner.calls, tt.wantCalls)
+			}
+			if !reflect.DeepEqual(*waits, tt.wantWaits) {
+				t.Errorf("waited %v, want %v", *waits, tt.wantWaits)
+			}
+		})
+	}
+}
+
+func TestRetryingBeaconAdapterCancelled(t *testing.T) {
+	inner := &flakyBeacon{err: ports.ErrTimeout, failures: 10}
+	r := NewRetryingBeaconAdapter(inner, RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.GetFinalizedEpoch(ctx)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("backoff not interrupted by the cancelled context")
+	}
+	if inner.calls != 1 {
+		t.Errorf("%d calls, want 1 before the cancellation", inner.calls)
+	}
+}
+
+func TestRetryingBeaconAdapterSingleAttempt(t *testing.T) {
+	inner := &flakyBeacon{err: ports.ErrServer, failures: 1}
+	r, waits := newTestRetrying(inner, RetryPolicy{})
+	if _, err := r.GetFinalizedEpoch(context.Background()); !errors.Is(err, ports.ErrServer) {
+		t.Errorf("got %v, want the error of the only attempt", err)
+	}
+	if inner.calls != 1 || len(*waits) != 0 {
+		t.Errorf("%d calls and waits %v, want a single call without retries", inner.calls, *waits)
+	}
+}
@@ -0,0 +1,518 @@
+// Package beaconmock is an httptest beacon node serving the Beacon API
+// endpoints the attestant adapter uses, backed by any
+// ports.BeaconChainAdapter (usually a fakechain.Chain).
+//
+// Responses are built from go-eth2-client's own types, so what the adapter
+// decodes is what a real node would send: blocks come as JSON or SSZ
+// depending on the Accept header, in the format of the fork scheduled for
+// their epoch, with pre-Electra attestations split into one per committee.
+// Faults (status codes, delays) can be injected per path to exercise the
+// adapter's error classification. The event stream sends the events set with
+// SetEvents and then ends, like a node dropping the connection.
+package beaconmock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	apiv1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+const slotsPerEpoch = 32
+
+// Fork schedules a block format from Epoch on. Version is a consensus
+// version name as sent in the Eth-Consensus-Version header ("phase0", ...,
+// "fulu"); an unknown name is sent as is with an Electra body, like a node
+// that is ahead of the client.
+type Fork struct {
+	Epoch   domain.Epoch
+	Version string
+}
+
+// Fault is injected into every request for a path.
+type Fault struct {
+	// Status, when non-zero, is returned instead of the response.
+	Status int
+	// Delay holds the response back, or until the client gives up.
+	Delay time.Duration
+}
+
+// Server is a mock beacon node. Close it when done.
+type Server struct {
+	*httptest.Server
+	source ports.BeaconChainAdapter
+
+	mu       sync.Mutex
+	forks    []Fork
+	faults   map[string]Fault
+	jsonOnly bool
+
+	events        []domain.ChainEvent
+	subscriptions int
+}
+
+// New starts a mock node serving source. All epochs use the Electra format
+// until SetForks is called.
+func New(source ports.BeaconChainAdapter) *Server {
+	s := &Server{
+		source: source,
+		forks:  []Fork{{Epoch: 0, Version: spec.DataVersionElectra.String()}},
+		faults: make(map[string]Fault),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /eth/v1/beacon/genesis", s.handleGenesis)
+	mux.HandleFunc("GET /eth/v1/node/syncing", s.handleSyncing)
+	mux.HandleFunc("GET /eth/v1/node/version", s.handleVersion)
+	mux.HandleFunc("GET /eth/v1/config/spec", s.handleSpec)
+	mux.HandleFunc("GET /eth/v1/beacon/states/{state}/finality_checkpoints", s.handleFinality)
+	mux.HandleFunc("GET /eth/v1/beacon/headers/head", s.handleHeadHeader)
+	mux.HandleFunc("POST /eth/v1/validator/duties/attester/{epoch}", s.handleAttesterDuties)
+	mux.HandleFunc("GET /eth/v1/validator/duties/proposer/{epoch}", s.handleProposerDuties)
+	mux.HandleFunc("GET /eth/v2/beacon/blocks/{block}", s.handleBlock)
+	mux.HandleFunc("GET /eth/v1/beacon/states/{state}/committees", s.handleCommittees)
+	mux.HandleFunc("POST /eth/v1/beacon/states/{state}/validators", s.handleValidators)
+	mux.HandleFunc("POST /eth/v1/validator/duties/sync/{epoch}", s.handleSyncCommitteeDuties)
+	mux.HandleFunc("POST /eth/v1/beacon/rewards/attestations/{epoch}", s.handleAttestationRewards)
+	mux.HandleFunc("POST /eth/v1/beacon/rewards/sync_committee/{block}", s.handleSyncCommitteeRewards)
+	mux.HandleFunc("GET /eth/v1/events", s.handleEvents)
+	s.Server = httptest.NewServer(s.withFaults(mux))
+	return s
+}
+
+// SetForks replaces the fork schedule. Forks must be in ascending epoch
+// order, the first one starting at epoch 0.
+func (s *Server) SetForks(forks ...Fork) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forks = forks
+}
+
+// Fail injects f into every request whose URL path is path; a zero Fault
+// removes it.
+func (s *Server) Fail(path string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f == (Fault{}) {
+		delete(s.faults, path)
+		return
+	}
+	s.faults[path] = f
+}
+
+// SetJSONOnly makes blocks be served as JSON even to clients preferring SSZ.
+func (s *Server) SetJSONOnly(jsonOnly bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jsonOnly = jsonOnly
+}
+
+// PubKey is the public key the mock gives validator index.
+func PubKey(index domain.ValidatorIndex) phase0.BLSPubKey {
+	var key phase0.BLSPubKey
+	key[0] = 0xa0
+	for i := 0; i < 8; i++ {
+		key[40+i] = byte(uint64(index) >> (56 - 8*i))
+	}
+	return key
+}
+
+func (s *Server) version(epoch domain.Epoch) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	version := s.forks[0].Version
+	for _, f := range s.forks {
+		if f.Epoch <= epoch {
+			version = f.Version
+		}
+	}
+	return version
+}
+
+func (s *Server) withFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		f, ok := s.faults[r.URL.Path]
+		s.mu.Unlock()
+		if ok && f.Delay > 0 {
+			select {
+			case <-time.After(f.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if ok && f.Status != 0 {
+			writeError(w, f.Status, fmt.Sprintf("injected fault for %s", r.URL.Path))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleGenesis serves domain.MainnetGenesisTime as the genesis time.
+func (s *Server) handleGenesis(w http.ResponseWriter, _ *http.Request) {
+	writeData(w, &apiv1.Genesis{GenesisTime: domain.MainnetGenesisTime})
+}
+
+func (s *Server) handleSyncing(w http.ResponseWriter, r *http.Request) {
+	head, err := s.source.GetHeadSlot(r.Context())
+	if err != nil {
+		writeSourceError(w, err)
+		return
+	}
+	writeData(w, &apiv1.SyncState{HeadSlot: phase0.Slot(head)})
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, _ *http.Request) {
+	writeData(w, map[string]string{"version": "beaconmock/v0.0.0"})
+}
+
+func (s *Server) handleSpec(w http.ResponseWriter, _ *http.Request) {
+	writeData(w, map[string]string{"SLOTS_PER_EPOCH": strconv.Itoa(slotsPerEpoch)})
+}
+
+func (s *Server) handleFinality(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("state") != "head" {
+		writeError(w, http.StatusBadRequest, "only the head state is served")
+		return
+	}
+	checkpoints, err := s.source.GetFinalityCheckpoints(r.Context())
+	if err != nil {
+		writeSourceError(w, err)
+		return
+	}
+	previous := checkpoints.Justified
+	if previous > 0 {
+		previous--
+	}
+	writeData(w, &apiv1.Finality{
+		Finalized:         &phase0.Checkpoint{Epoch: phase0.Epoch(checkpoints.Finalized), Root: phase0.Root(checkpoints.FinalizedRoot)},
+		Justified:         &phase0.Checkpoint{Epoch: phase0.Epoch(checkpoints.Justified)},
+		PreviousJustified: &phase0.Checkpoint{Epoch: phase0.Epoch(previous)},
+	})
+}
+
+func (s *Server) handleHeadHeader(w http.ResponseWriter, r *http.Request) {
+	head, err := s.source.GetHeadSlot(r.Context())
+	if err != nil {
+		writeSourceError(w, err)
+		return
+	}
+	writeData(w, &apiv1.BeaconBlockHeader{
+		Canonical: true,
+		Header: &phase0.SignedBeaconBlockHeader{
+			Message: &phase0.BeaconBlockHeader{Slot: phase0.Slot(head)},
+		},
+	})
+}
+
+func (s *Server) handleAttesterDuties(w http.ResponseWriter, r *http.Request) {
+	epoch, ok := pathUint(w, r, "epoch")
+	if !ok {
+		return
+	}
+	indices, ok := readIndices(w, r)
+	if !ok {
+		return
+	}
+	duties, err := s.source.GetValidatorDutiesBatch(r.Context(), domain.Epoch(epoch), indices)
+	if err != nil {
+		writeSourceError(w, err)
+		return
+	}
+	data := make([]*apiv1.AttesterDuty, len(duties))
+	for i, d := range duties {
+		data[i] = &apiv1.AttesterDuty{
+			PubKey:                  PubKey(d.ValidatorIndex),
+			Slot:                    phase0.Slot(d.Slot),
+			ValidatorIndex:          phase0.ValidatorIndex(d.ValidatorIndex),
+			CommitteeIndex:          phase0.CommitteeIndex(d.CommitteeIndex),
+			CommitteeLength:         d.CommitteeLength,
+			CommitteesAtSlot:        d.CommitteesAtSlot,
+			ValidatorCommitteeIndex: d.ValidatorCommitteeIdx,
+		}
+	}
+	writeData(w, data)
+}
+
+// handleProposerDuties serves the proposers of every slot of the epoch, as
+// nodes do; the client filters them.
+func (s *Server) handleProposerDuties(w http.ResponseWriter, r *http.Request) {
+	epoch, ok := pathUint(w, r, "epoch")
+	if !ok {
+		return
+	}
+	all, err := s.source.GetAllActiveValidatorIndices(r.Context())
+	if err != nil {
+		writeSourceError(w, err)
+		return
+	}
+	duties, err := s.source.GetProposerDuties(r.Context(), domain.Epoch(epoch), all)
+	if err != nil {
+		writeSourceError(w, err)
+		return
+	}
+	data := make([]*apiv1.ProposerDuty, len(duties))
+	for i, d := range duties {
+		data[i] = &apiv1.ProposerDuty{
+			PubKey:         PubKey(d.ValidatorIndex),
+			Slot:           phase0.Slot(d.Slot),
+			ValidatorIndex: phase0.ValidatorIndex(d.ValidatorIndex),
+		}
+	}
+	writeData(w, data)
+}
+
+func (s *Server) handleCommittees(w http.ResponseWriter, r *http.Request) {
+	if _, ok := pathUint(w, r, "state"); !ok {
+		return
+	}
+	epoch, err := strconv.ParseUint(r.URL.Query().Get("epoch"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "the epoch parameter is required")
+		return
+	}
+	committees, err := s.source.GetEpochCommittees(r.Context(), domain.Epoch(epoch))
+	if err != nil {
+		writeSourceError(w, err)
+		return
+	}
+	var data []*apiv1.BeaconCommittee
+	firstSlot := domain.Slot(epoch) * slotsPerEpoch
+	for slot := firstSlot; slot < firstSlot+slotsPerEpoch; slot++ {
+		for index := 0; index < len(committees[slot]); index++ {
+			members := committees[slot][domain.CommitteeIndex(index)]
+			committee := &apiv1.BeaconCommittee{
+				Slot:       phase0.Slot(slot),
+				Index:      phase0.CommitteeIndex(index),
+				Validators: make([]phase0.ValidatorIndex, len(members)),
+			}
+			for i, v := range members {
+				committee.Validators[i] = phase0.ValidatorIndex(v)
+			}
+			data = append(data, committee)
+		}
+	}
+	writeData(w, data)
+}
+
+// handleValidators serves the active validators among the requested ids,
+// which may be indices or public keys. Status filters other than active
+// ones match nothing.
+func (s *Server) handleValidators(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		IDs      []string `json:"ids"`
+		Statuses []string `json:"statuses"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if len(body.Statuses) > 0 && !containsActive(body.Statuses) {
+		writeData(w, []*apiv1.Validator{})
+		return
+	}
+	all, err := s.source.GetAllActiveValidatorIndices(r.Context())
+	if err != nil {
+		writeSourceError(w, err)
+		return
+	}
+	byID := make(map[string]domain.ValidatorIndex, 2*len(all))
+	for _, v := range all {
+		byID[strconv.FormatUint(uint64(v), 10)] = v
+		byID[PubKey(v).String()] = v
+	}
+	data := []*apiv1.Validator{}
+	for _, id := range body.IDs {
+		v, ok := byID[strings.ToLower(id)]
+		if !ok {
+			continue
+		}
+		data = append(data, &apiv1.Validator{
+			Index:   phase0.ValidatorIndex(v),
+			Balance: 32_000_000_000,
+			Status:  apiv1.ValidatorStateActiveOngoing,
+			Validator: &phase0.Validator{
+				PublicKey:                  PubKey(v),
+				WithdrawalCredentials:      make([]byte, 32),
+				EffectiveBalance:           32_000_000_000,
+				ActivationEligibilityEpoch: 0,
+				ActivationEpoch:            0,
+				ExitEpoch:                  phase0.Epoch(^uint64(0)),
+				WithdrawableEpoch:          phase0.Epoch(^uint64(0)),
+			},
+		})
+	}
+	writeData(w, data)
+}
+
+func (s *Server) handleSyncCommitteeDuties(w http.ResponseWriter, r *http.Request) {
+	epoch, ok := pathUint(w, r, "epoch")
+	if !ok {
+		return
+	}
+	indices, ok := readIndices(w, r)
+	if !ok {
+		return
+	}
+	duties, err := s.source.GetSyncCommitteeDuties(r.Context(), domain.Epoch(epoch), indices)
+	if err != nil {
+		writeSourceError(w, err)
+		return
+	}
+	data := make([]*apiv1.SyncCommitteeDuty, len(duties))
+	for i, d := range duties {
+		positions := make([]phase0.CommitteeIndex, len(d.Positions))
+		for j, p := range d.Positions {
+			positions[j] = phase0.CommitteeIndex(p)
+		}
+		data[i] = &apiv1.SyncCommitteeDuty{
+			PubKey:                        PubKey(d.ValidatorIndex),
+			ValidatorIndex:                phase0.ValidatorIndex(d.ValidatorIndex),
+			ValidatorSyncCommitteeIndices: positions,
+		}
+	}
+	writeData(w, data)
+}
+
+// handleAttestationRewards serves the source's rewards. Every validator has
+// an effective balance of 32 ETH, so there is a single ideal reward, that
+// of the first validator; all of it is put in the head component and all
+// of the reward paid in the target one, which may be negative.
+func (s *Server) handleAttestationRewards(w http.ResponseWriter, r *http.Request) {
+	epoch, ok := pathUint(w, r, "epoch")
+	if !ok {
+		return
+	}
+	indices, ok := readIndices(w, r)
+	if !ok {
+		return
+	}
+	rewards, err := s.source.GetAttestationRewards(r.Context(), domain.Epoch(epoch), indices)
+	if err != nil {
+		writeSourceError(w, err)
+		return
+	}
+	data := &apiv1.AttestationRewards{
+		IdealRewards: []apiv1.IdealAttestationRewards{},
+		TotalRewards: make([]apiv1.ValidatorAttestationRewards, len(rewards)),
+	}
+	if len(rewards) > 0 {
+		data.IdealRewards = append(data.IdealRewards, apiv1.IdealAttestationRewards{
+			EffectiveBalance: 32_000_000_000,
+			Head:             phase0.Gwei(rewards[0].Ideal),
+		})
+	}
+	for i, reward := range rewards {
+		data.TotalRewards[i] = apiv1.ValidatorAttestationRewards{
+			ValidatorIndex: phase0.ValidatorIndex(reward.ValidatorIndex),
+			Target:         reward.Actual,
+		}
+	}
+	writeData(w, data)
+}
+
+func (s *Server) handleSyncCommitteeRewards(w http.ResponseWriter, r *http.Request) {
+	slot, ok := pathUint(w, r, "block")
+	if !ok {
+		return
+	}
+	indices, ok := readIndices(w, r)
+	if !ok {
+		return
+	}
+	rewards, err := s.source.GetSyncCommitteeRewards(r.Context(), domain.Slot(slot), indices)
+	if err != nil {
+		writeSourceError(w, err)
+		return
+	}
+	data := make([]*apiv1.SyncCommitteeReward, len(rewards))
+	for i, reward := range rewards {
+		data[i] = &apiv1.SyncCommitteeReward{
+			ValidatorIndex: phase0.ValidatorIndex(reward.ValidatorIndex),
+			Reward:         reward.Actual,
+		}
+	}
+	writeData(w, data)
+}
+
+func containsActive(statuses []string) bool {
+	for _, status := range statuses {
+		if status == "active" || strings.HasPrefix(status, "active_") {
+			return true
+		}
+	}
+	return false
+}
+
+// readIndices reads the validator indices of a request body.
+func readIndices(w http.ResponseWriter, r *http.Request) ([]domain.ValidatorIndex, bool) {
+	var ids []string
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return nil, false
+	}
+	indices := make([]domain.ValidatorIndex, len(ids))
+	for i, id := range ids {
+		v, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid validator index %q", id))
+			return nil, false
+		}
+		indices[i] = domain.ValidatorIndex(v)
+	}
+	return indices, true
+}
+
+func pathUint(w http.ResponseWriter, r *http.Request, name string) (uint64, bool) {
+	v, err := strconv.ParseUint(r.PathValue(name), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s %q", name, r.PathValue(name)))
+		return 0, false
+	}
+	return v, true
+}
+
+// writeData writes the {"data": ...} envelope of Beacon API responses.
+func writeData(w http.ResponseWriter, data any) {
+	writeJSON(w, http.StatusOK, map[string]any{"data": data})
+}
+
+// writeError writes the error body nodes send with non-2xx statuses.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{"code": status, "message": message})
+}
+
+// writeSourceError maps an error of the source to the status a node would
+// answer with.
+func writeSourceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ports.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, context.Canceled):
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,299 @@
+package beaconmock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/holiman/uint256"
+	"github.com/prysmaticlabs/go-bitfield"
+)
+
+// signedBlock is what every fork's SignedBeaconBlock can do.
+type signedBlock interface {
+	json.Marshaler
+	MarshalSSZ() ([]byte, error)
+}
+
+// handleBlock serves the block at a slot in the format of its epoch's fork,
+// as SSZ when the client prefers it.
+func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request) {
+	slot, ok := pathUint(w, r, "block")
+	if !ok {
+		return
+	}
+	block, err := s.source.GetBlock(r.Context(), domain.Slot(slot))
+	if err != nil {
+		writeSourceError(w, err)
+		return
+	}
+	version := s.version(domain.Epoch(slot / slotsPerEpoch))
+	signed, err := s.buildBlock(r.Context(), version, block)
+	if err != nil {
+		writeSourceError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	jsonOnly := s.jsonOnly
+	s.mu.Unlock()
+	w.Header().Set("Eth-Consensus-Version", version)
+	if !jsonOnly && strings.HasPrefix(r.Header.Get("Accept"), "application/octet-stream") {
+		body, err := signed.MarshalSSZ()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("encoding block %d as SSZ: %v", slot, err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(body)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"version":              version,
+		"execution_optimistic": false,
+		"data":                 signed,
+	})
+}
+
+// buildBlock turns block into the SignedBeaconBlock of version. Before
+// Electra an attestation covers one committee, so each aggregate is split
+// per committee using the committees of its duty slot.
+func (s *Server) buildBlock(ctx context.Context, version string, block domain.Block) (signedBlock, error) {
+	dataVersion, err := spec.DataVersionFromString(version)
+	if err != nil {
+		// Unknown to go-eth2-client: the client must reject it on the
+		// header alone, so the body does not matter.
+		dataVersion = spec.DataVersionElectra
+	}
+
+	slot, proposer := phase0.Slot(block.Slot), phase0.ValidatorIndex(block.ProposerIndex)
+	parent := phase0.Root(block.ParentRoot)
+
+	if dataVersion >= spec.DataVersionElectra {
+		atts := make([]*electra.Attestation, len(block.Attestations))
+		for i, att := range block.Attestations {
+			indices, sizes, err := s.committees(ctx, att)
+			if err != nil {
+				return nil, err
+			}
+			n := 0
+			for _, index := range indices {
+				n += sizes[index]
+			}
+			committeeBits := bitfield.NewBitvector64()
+			copy(committeeBits, att.CommitteeBits)
+			atts[i] = &electra.Attestation{
+				AggregationBits: toBitlist(att.AggregationBits, 0, n),
+				Data:            attestationData(att, 0),
+				CommitteeBits:   committeeBits,
+			}
+		}
+		return &electra.SignedBeaconBlock{Message: &electra.BeaconBlock{
+			Slot: slot, ProposerIndex: proposer, ParentRoot: parent,
+			Body: &electra.BeaconBlockBody{
+				ETH1Data:              eth1Data(),
+				ProposerSlashings:     []*phase0.ProposerSlashing{},
+				AttesterSlashings:     []*electra.AttesterSlashing{},
+				Attestations:          atts,
+				Deposits:              []*phase0.Deposit{},
+				VoluntaryExits:        []*phase0.SignedVoluntaryExit{},
+				SyncAggregate:         syncAggregate(block),
+				ExecutionPayload:      denebPayload(),
+				BLSToExecutionChanges: []*capella.SignedBLSToExecutionChange{},
+				BlobKZGCommitments:    []deneb.KZGCommitment{},
+				ExecutionRequests: &electra.ExecutionRequests{
+					Deposits:       []*electra.DepositRequest{},
+					Withdrawals:    []*electra.WithdrawalRequest{},
+					Consolidations: []*electra.ConsolidationRequest{},
+				},
+			},
+		}}, nil
+	}
+
+	var atts []*phase0.Attestation
+	for _, att := range block.Attestations {
+		split, err := s.splitAttestation(ctx, att)
+		if err != nil {
+			return nil, err
+		}
+		atts = append(atts, split...)
+	}
+	if atts == nil {
+		atts = []*phase0.Attestation{}
+	}
+
+	switch dataVersion {
+	case spec.DataVersionPhase0:
+		return &phase0.SignedBeaconBlock{Message: &phase0.BeaconBlock{
+			Slot: slot, ProposerIndex: proposer, ParentRoot: parent,
+			Body: &phase0.BeaconBlockBody{
+				ETH1Data:          eth1Data(),
+				ProposerSlashings: []*phase0.ProposerSlashing{},
+				AttesterSlashings: []*phase0.AttesterSlashing{},
+				Attestations:      atts,
+				Deposits:          []*phase0.Deposit{},
+				VoluntaryExits:    []*phase0.SignedVoluntaryExit{},
+			},
+		}}, nil
+	case spec.DataVersionAltair:
+		return &altair.SignedBeaconBlock{Message: &altair.BeaconBlock{
+			Slot: slot, ProposerIndex: proposer, ParentRoot: parent,
+			Body: &altair.BeaconBlockBody{
+				ETH1Data:          eth1Data(),
+				ProposerSlashings: []*phase0.ProposerSlashing{},
+				AttesterSlashings: []*phase0.AttesterSlashing{},
+				Attestations:      atts,
+				Deposits:          []*phase0.Deposit{},
+				VoluntaryExits:    []*phase0.SignedVoluntaryExit{},
+				SyncAggregate:     syncAggregate(block),
+			},
+		}}, nil
+	case spec.DataVersionBellatrix:
+		return &bellatrix.SignedBeaconBlock{Message: &bellatrix.BeaconBlock{
+			Slot: slot, ProposerIndex: proposer, ParentRoot: parent,
+			Body: &bellatrix.BeaconBlockBody{
+				ETH1Data:          eth1Data(),
+				ProposerSlashings: []*phase0.ProposerSlashing{},
+				AttesterSlashings: []*phase0.AttesterSlashing{},
+				Attestations:      atts,
+				Deposits:          []*phase0.Deposit{},
+				VoluntaryExits:    []*phase0.SignedVoluntaryExit{},
+				SyncAggregate:     syncAggregate(block),
+				ExecutionPayload: &bellatrix.ExecutionPayload{
+					ExtraData:    []byte{},
+					Transactions: []bellatrix.Transaction{},
+				},
+			},
+		}}, nil
+	case spec.DataVersionCapella:
+		return &capella.SignedBeaconBlock{Message: &capella.BeaconBlock{
+			Slot: slot, ProposerIndex: proposer, ParentRoot: parent,
+			Body: &capella.BeaconBlockBody{
+				ETH1Data:          eth1Data(),
+				ProposerSlashings: []*phase0.ProposerSlashing{},
+				AttesterSlashings: []*phase0.AttesterSlashing{},
+				Attestations:      atts,
+				Deposits:          []*phase0.Deposit{},
+				VoluntaryExits:    []*phase0.SignedVoluntaryExit{},
+				SyncAggregate:     syncAggregate(block),
+				ExecutionPayload: &capella.ExecutionPayload{
+					ExtraData:    []byte{},
+					Transactions: []bellatrix.Transaction{},
+					Withdrawals:  []*capella.Withdrawal{},
+				},
+				BLSToExecutionChanges: []*capella.SignedBLSToExecutionChange{},
+			},
+		}}, nil
+	case spec.DataVersionDeneb:
+		return &deneb.SignedBeaconBlock{Message: &deneb.BeaconBlock{
+			Slot: slot, ProposerIndex: proposer, ParentRoot: parent,
+			Body: &deneb.BeaconBlockBody{
+				ETH1Data:              eth1Data(),
+				ProposerSlashings:     []*phase0.ProposerSlashing{},
+				AttesterSlashings:     []*phase0.AttesterSlashing{},
+				Attestations:          atts,
+				Deposits:              []*phase0.Deposit{},
+				VoluntaryExits:        []*phase0.SignedVoluntaryExit{},
+				SyncAggregate:         syncAggregate(block),
+				ExecutionPayload:      denebPayload(),
+				BLSToExecutionChanges: []*capella.SignedBLSToExecutionChange{},
+				BlobKZGCommitments:    []deneb.KZGCommitment{},
+			},
+		}}, nil
+	}
+	return nil, fmt.Errorf("no block format for version %s", version)
+}
+
+// splitAttestation returns one single-committee attestation per committee
+// whose bit is set in att, each with that committee's slice of the
+// aggregation bits.
+func (s *Server) splitAttestation(ctx context.Context, att domain.Attestation) ([]*phase0.Attestation, error) {
+	indices, sizes, err := s.committees(ctx, att)
+	if err != nil {
+		return nil, err
+	}
+	split := make([]*phase0.Attestation, 0, len(indices))
+	offset := 0
+	for _, index := range indices {
+		split = append(split, &phase0.Attestation{
+			AggregationBits: toBitlist(att.AggregationBits, offset, sizes[index]),
+			Data:            attestationData(att, index),
+		})
+		offset += sizes[index]
+	}
+	return split, nil
+}
+
+// committees returns the committees att aggregates, in ascending order, and
+// the sizes of the committees of its slot.
+func (s *Server) committees(ctx context.Context, att domain.Attestation) ([]domain.CommitteeIndex, domain.CommitteeSizeMap, error) {
+	committees, err := s.source.GetEpochCommittees(ctx, domain.Epoch(att.DataSlot/slotsPerEpoch))
+	if err != nil {
+		return nil, nil, fmt.Errorf("committees for the attestation of slot %d: %w", att.DataSlot, err)
+	}
+	sizes := committees.SizeMap(att.DataSlot)
+	indices := make([]domain.CommitteeIndex, 0, len(sizes))
+	for index := range sizes {
+		if int(index/8) < len(att.CommitteeBits) && att.CommitteeBits[index/8]&(1<<(index%8)) != 0 {
+			indices = append(indices, index)
+		}
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	return indices, sizes, nil
+}
+
+// toBitlist copies n bits of raw starting at bit offset into a bitlist of
+// length n.
+func toBitlist(raw []byte, offset, n int) bitfield.Bitlist {
+	list := bitfield.NewBitlist(uint64(n))
+	for i := 0; i < n; i++ {
+		bit := offset + i
+		if bit/8 < len(raw) && raw[bit/8]&(1<<(bit%8)) != 0 {
+			list.SetBitAt(uint64(i), true)
+		}
+	}
+	return list
+}
+
+func attestationData(att domain.Attestation, index domain.CommitteeIndex) *phase0.AttestationData {
+	epoch := phase0.Epoch(att.DataSlot / slotsPerEpoch)
+	return &phase0.AttestationData{
+		Slot:            phase0.Slot(att.DataSlot),
+		Index:           phase0.CommitteeIndex(index),
+		BeaconBlockRoot: phase0.Root(att.BeaconBlockRoot),
+		Source:          &phase0.Checkpoint{Epoch: max(epoch, 1) - 1},
+		Target:          &phase0.Checkpoint{Epoch: epoch, Root: phase0.Root(att.TargetRoot)},
+	}
+}
+
+func eth1Data() *phase0.ETH1Data {
+	return &phase0.ETH1Data{BlockHash: make([]byte, 32)}
+}
+
+// syncAggregate carries the block's sync committee bits; the committee
+// must have at most 512 positions, the mainnet size.
+func syncAggregate(block domain.Block) *altair.SyncAggregate {
+	bits := bitfield.NewBitvector512()
+	copy(bits, block.SyncCommitteeBits)
+	return &altair.SyncAggregate{SyncCommitteeBits: bits}
+}
+
+func denebPayload() *deneb.ExecutionPayload {
+	return &deneb.ExecutionPayload{
+		ExtraData:     []byte{},
+		BaseFeePerGas: uint256.NewInt(0),
+		Transactions:  []bellatrix.Transaction{},
+		Withdrawals:   []*capella.Withdrawal{},
+	}
+}
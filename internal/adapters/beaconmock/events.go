@@ -0,0 +1,57 @@
+package beaconmock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	apiv1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+// SetEvents replaces the events every subscription to the event stream
+// receives before the stream ends.
+func (s *Server) SetEvents(events ...domain.ChainEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = events
+}
+
+// Subscriptions returns how many times the event stream was opened.
+func (s *Server) Subscriptions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subscriptions
+}
+
+// handleEvents sends the events as server-sent events, whatever the topics
+// asked for, and closes the stream.
+func (s *Server) handleEvents(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	s.subscriptions++
+	events := s.events
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	for _, event := range events {
+		var payload any
+		switch event.Type {
+		case domain.ChainEventFinalizedCheckpoint:
+			payload = &apiv1.FinalizedCheckpointEvent{Epoch: phase0.Epoch(event.Epoch)}
+		case domain.ChainEventBlock:
+			payload = &apiv1.BlockEvent{Slot: phase0.Slot(event.Slot)}
+		case domain.ChainEventChainReorg:
+			payload = &apiv1.ChainReorgEvent{Slot: phase0.Slot(event.Slot), Depth: event.Depth, Epoch: phase0.Epoch(event.Epoch)}
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,39 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+)
+
+type fileDiscrepancyRecorder struct {
+	dir string
+}
+
+// NewFileDiscrepancyRecorder returns a recorder that writes the discrepancies
+// of each epoch as an indented JSON array to <dir>/epoch-<N>.json.
+func NewFileDiscrepancyRecorder(dir string) (ports.DiscrepancyRecorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating discrepancy directory: %w", err)
+	}
+	return &fileDiscrepancyRecorder{dir: dir}, nil
+}
+
+func (r *fileDiscrepancyRecorder) RecordDiscrepancies(_ context.Context, epoch domain.Epoch, discrepancies []domain.Discrepancy) error {
+	data, err := json.MarshalIndent(discrepancies, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(r.dir, fmt.Sprintf("epoch-%d.json", epoch))
+	// Write to a temporary file first so a crash never leaves a truncated file behind.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
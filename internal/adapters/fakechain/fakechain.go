@@ -0,0 +1,795 @@
+// Package fakechain is an in-memory beacon chain implementing
+// ports.BeaconChainAdapter, for deterministic tests of the duties checker.
+//
+// A chain is generated from a seed: a shuffled validator set split into
+// committees every epoch, a proposer per slot, missed slots, and blocks
+// carrying Electra-style aggregates (several committees per attestation)
+// where some validators did not attest, and optionally a sync committee
+// whose members sign some blocks and not others. Since the generator knows who
+// attested where, and for which head and target, Expected gives the outcome
+// every duty must have.
+package fakechain
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+)
+
+const (
+	slotsPerEpoch = 32
+	// inclusionWindow is how many slots after its duty slot an attestation
+	// still counts as included.
+	inclusionWindow = 32
+	// maxCommitteesPerSlot is MAX_COMMITTEES_PER_SLOT; committee bits are 8 bytes.
+	maxCommitteesPerSlot = 64
+	// targetCommitteeSize is TARGET_COMMITTEE_SIZE, used to derive the number
+	// of committees per slot as the spec does.
+	targetCommitteeSize = 128
+
+	// The rewards paid, in Gwei, for each part of a correct attestation and
+	// for each sync committee position per block. A missed source or target
+	// costs its reward as a penalty, as does a missed sync signature; a
+	// missed head costs nothing.
+	headReward   = 3_000
+	targetReward = 5_500
+	sourceReward = 3_000
+	syncReward   = 20_000
+)
+
+// Config describes the chain to generate.
+type Config struct {
+	// Seed makes the chain reproducible: the same Config always yields the
+	// same chain.
+	Seed int64
+	// Validators is the size of the active validator set, at least 32 times
+	// the committees per slot.
+	Validators int
+	// CommitteesPerSlot overrides the spec-derived number of committees per
+	// slot (Validators / 32 / 128, between 1 and 64) when positive.
+	CommitteesPerSlot int
+	// Epochs is how many epochs are generated, starting at 0. Duties of the
+	// last epoch cannot be fully evaluated since its inclusion window extends
+	// past the chain.
+	Epochs int
+	// MissedSlotRate is the probability that a slot has no block.
+	MissedSlotRate float64
+	// MissedAttestationRate is the probability that a validator does not
+	// attest for its duty.
+	MissedAttestationRate float64
+	// MaxInclusionDelay is the largest delay, in slots, at which an aggregate
+	// is included (more when the target slot is missed). Default 1.
+	MaxInclusionDelay int
+	// DuplicateRate is the probability that an aggregate is included again
+	// in a later block, as happens on mainnet.
+	DuplicateRate float64
+	// WrongHeadRate and WrongTargetRate are the probabilities that an
+	// aggregate votes for a head block or a target that is not canonical.
+	WrongHeadRate   float64
+	WrongTargetRate float64
+	// SyncCommitteeSize is the number of positions in the sync committee,
+	// drawn once for the whole chain. 0 means no sync committee, as before
+	// Altair.
+	SyncCommitteeSize int
+	// MissedSyncRate is the probability that a sync committee member does
+	// not sign a block.
+	MissedSyncRate float64
+}
+
+// genesisParentRoot is the parent root of the block at slot 0.
+var genesisParentRoot = domain.Root{0x9e}
+
+// blockRoot is the root of the block at slot; forkRoot that of a block at
+// slot on a fork, voted for by wrong votes.
+func blockRoot(slot domain.Slot) domain.Root {
+	r := domain.Root{0xb1}
+	binary.BigEndian.PutUint64(r[24:], uint64(slot))
+	return r
+}
+
+func forkRoot(slot domain.Slot) domain.Root {
+	r := domain.Root{0xf0}
+	binary.BigEndian.PutUint64(r[24:], uint64(slot))
+	return r
+}
+
+// inclusion is a block including a validator's attestation, and whether
+// that attestation voted for the canonical head and target.
+type inclusion struct {
+	slot             domain.Slot
+	headOK, targetOK bool
+}
+
+// Chain is a generated beacon chain. It is safe for concurrent use.
+type Chain struct {
+	cfg               Config
+	committeesPerSlot int
+
+	committees map[domain.Epoch]domain.EpochCommittees
+	duties     map[domain.Epoch][]domain.ValidatorDuty // indexed by validator index
+	proposers  map[domain.Slot]domain.ValidatorIndex
+	blocks     map[domain.Slot]*domain.Block
+	// inclusions holds, per epoch and validator, the blocks including an
+	// aggregate with the validator's bit set, in ascending slot order.
+	inclusions map[domain.Epoch]map[domain.ValidatorIndex][]inclusion
+	// syncPositions holds the sync committee positions of each member, and
+	// syncSigned the members that signed the block at each slot.
+	syncPositions map[domain.ValidatorIndex][]uint64
+	syncSigned    map[domain.Slot]map[domain.ValidatorIndex]bool
+
+	mu                sync.Mutex
+	finalized         domain.Epoch
+	blockFailures     map[domain.Slot]error
+	committeeFailures map[domain.Epoch]error
+}
+
+// New generates the chain described by cfg. The finalized epoch is the last
+// one whose duties can be fully evaluated, Epochs - 2.
+func New(cfg Config) (*Chain, error) {
+	if cfg.Epochs < 2 {
+		return nil, fmt.Errorf("need at least 2 epochs, got %d", cfg.Epochs)
+	}
+	if cfg.MaxInclusionDelay < 1 {
+		cfg.MaxInclusionDelay = 1
+	}
+	committeesPerSlot := cfg.CommitteesPerSlot
+	if committeesPerSlot <= 0 {
+		committeesPerSlot = max(1, min(maxCommitteesPerSlot, cfg.Validators/slotsPerEpoch/targetCommitteeSize))
+	}
+	if committeesPerSlot > maxCommitteesPerSlot {
+		return nil, fmt.Errorf("at most %d committees per slot, got %d", maxCommitteesPerSlot, committeesPerSlot)
+	}
+	if cfg.Validators < slotsPerEpoch*committeesPerSlot {
+		return nil, fmt.Errorf("%d validators cannot fill %d committees per slot", cfg.Validators, committeesPerSlot)
+	}
+
+	c := &Chain{
+		cfg:               cfg,
+		committeesPerSlot: committeesPerSlot,
+		committees:        make(map[domain.Epoch]domain.EpochCommittees),
+		duties:            make(map[domain.Epoch][]domain.ValidatorDuty),
+		proposers:         make(map[domain.Slot]domain.ValidatorIndex),
+		blocks:            make(map[domain.Slot]*domain.Block),
+		inclusions:        make(map[domain.Epoch]map[domain.ValidatorIndex][]inclusion),
+		syncPositions:     make(map[domain.ValidatorIndex][]uint64),
+		syncSigned:        make(map[domain.Slot]map[domain.ValidatorIndex]bool),
+		finalized:         domain.Epoch(cfg.Epochs - 2),
+		blockFailures:     make(map[domain.Slot]error),
+		committeeFailures: make(map[domain.Epoch]error),
+	}
+	c.generate(rand.New(rand.NewSource(cfg.Seed)))
+	return c, nil
+}
+
+func (c *Chain) lastSlot() domain.Slot {
+	return domain.Slot(c.cfg.Epochs*slotsPerEpoch - 1)
+}
+
+// generate builds committees, duties and blocks, then includes the
+// attestations of every slot. All randomness comes from rng, consumed in a
+// fixed order.
+func (c *Chain) generate(rng *rand.Rand) {
+	for epoch := domain.Epoch(0); epoch < domain.Epoch(c.cfg.Epochs); epoch++ {
+		c.shuffle(rng, epoch)
+	}
+	for slot := domain.Slot(0); slot <= c.lastSlot(); slot++ {
+		c.proposers[slot] = domain.ValidatorIndex(rng.Intn(c.cfg.Validators))
+		if slot > 0 && rng.Float64() < c.cfg.MissedSlotRate {
+			continue
+		}
+		c.blocks[slot] = &domain.Block{Slot: slot, Root: blockRoot(slot), ProposerIndex: c.proposers[slot]}
+	}
+	parent := genesisParentRoot
+	for slot := domain.Slot(0); slot <= c.lastSlot(); slot++ {
+		if b, ok := c.blocks[slot]; ok {
+			b.ParentRoot = parent
+			parent = blockRoot(slot)
+		}
+	}
+	for slot := domain.Slot(0); slot <= c.lastSlot(); slot++ {
+		c.attest(rng, slot)
+	}
+	// Drawn last, so that chains generated without a sync committee stay
+	// the same.
+	if c.cfg.SyncCommitteeSize > 0 {
+		c.syncCommittee(rng)
+	}
+}
+
+// syncCommittee draws the sync committee, where a validator may hold several
+// positions, and sets the bits of the members that signed each block.
+func (c *Chain) syncCommittee(rng *rand.Rand) {
+	members := make([]domain.ValidatorIndex, c.cfg.SyncCommitteeSize)
+	for pos := range members {
+		v := domain.ValidatorIndex(rng.Intn(c.cfg.Validators))
+		members[pos] = v
+		c.syncPositions[v] = append(c.syncPositions[v], uint64(pos))
+	}
+	for slot := domain.Slot(0); slot <= c.lastSlot(); slot++ {
+		b, ok := c.blocks[slot]
+		if !ok {
+			continue
+		}
+		// Drawn in position order, since map order is random.
+		signed := make(map[domain.ValidatorIndex]bool, len(c.syncPositions))
+		for _, v := range members {
+			if _, drawn := signed[v]; !drawn {
+				signed[v] = rng.Float64() >= c.cfg.MissedSyncRate
+			}
+		}
+		b.SyncCommitteeBits = make([]byte, (c.cfg.SyncCommitteeSize+7)/8)
+		for pos, v := range members {
+			if signed[v] {
+				b.SyncCommitteeBits[pos/8] |= 1 << (pos % 8)
+			}
+		}
+		c.syncSigned[slot] = signed
+	}
+}
+
+// shuffle assigns every validator to one committee of the epoch. As in the
+// spec, the shuffled set is cut into 32 × committeesPerSlot committees of
+// near-equal size.
+func (c *Chain) shuffle(rng *rand.Rand, epoch domain.Epoch) {
+	perm := rng.Perm(c.cfg.Validators)
+	total := slotsPerEpoch * c.committeesPerSlot
+	committees := make(domain.EpochCommittees, slotsPerEpoch)
+	duties := make([]domain.ValidatorDuty, c.cfg.Validators)
+	for k := 0; k < total; k++ {
+		slot := domain.Slot(epoch)*slotsPerEpoch + domain.Slot(k/c.committeesPerSlot)
+		index := domain.CommitteeIndex(k % c.committeesPerSlot)
+		start, end := c.cfg.Validators*k/total, c.cfg.Validators*(k+1)/total
+		members := make([]domain.ValidatorIndex, end-start)
+		for pos := range members {
+			v := domain.ValidatorIndex(perm[start+pos])
+			members[pos] = v
+			duties[v] = domain.ValidatorDuty{
+				ValidatorIndex:        v,
+				Slot:                  slot,
+				CommitteeIndex:        index,
+				ValidatorCommitteeIdx: uint64(pos),
+				CommitteeLength:       uint64(len(members)),
+				CommitteesAtSlot:      uint64(c.committeesPerSlot),
+			}
+		}
+		if committees[slot] == nil {
+			committees[slot] = make(map[domain.CommitteeIndex][]domain.ValidatorIndex, c.committeesPerSlot)
+		}
+		committees[slot][index] = members
+	}
+	c.committees[epoch] = committees
+	c.duties[epoch] = duties
+	c.inclusions[epoch] = make(map[domain.ValidatorIndex][]inclusion)
+}
+
+// headAt returns the root of the last block at or before slot.
+func (c *Chain) headAt(slot domain.Slot) domain.Root {
+	for ; slot > 0; slot-- {
+		if _, ok := c.blocks[slot]; ok {
+			break
+		}
+	}
+	return blockRoot(slot)
+}
+
+// attest decides who attested at slot and includes the aggregates. The
+// committees of the slot are split at random into two aggregates, each
+// covering several committees with their bits concatenated in committee
+// order, and included in the first block at or after a random delay. Each
+// aggregate votes for the canonical head and target unless drawn wrong.
+func (c *Chain) attest(rng *rand.Rand, slot domain.Slot) {
+	epoch := domain.Epoch(slot / slotsPerEpoch)
+	committees := c.committees[epoch][slot]
+
+	attested := make(map[domain.ValidatorIndex]bool)
+	for index := 0; index < c.committeesPerSlot; index++ {
+		for _, v := range committees[domain.CommitteeIndex(index)] {
+			attested[v] = rng.Float64() >= c.cfg.MissedAttestationRate
+		}
+	}
+
+	groups := make([][]domain.CommitteeIndex, 2)
+	for index := 0; index < c.committeesPerSlot; index++ {
+		g := rng.Intn(2)
+		groups[g] = append(groups[g], domain.CommitteeIndex(index))
+	}
+	for _, group := range groups {
+		delay := domain.Slot(1 + rng.Intn(c.cfg.MaxInclusionDelay))
+		duplicate := rng.Float64() < c.cfg.DuplicateRate
+		// Only drawn when set, so that chains generated without wrong votes
+		// stay the same.
+		headOK := c.cfg.WrongHeadRate == 0 || rng.Float64() >= c.cfg.WrongHeadRate
+		targetOK := c.cfg.WrongTargetRate == 0 || rng.Float64() >= c.cfg.WrongTargetRate
+		if len(group) == 0 {
+			continue
+		}
+		epochStart := domain.Slot(epoch) * slotsPerEpoch
+		att := domain.Attestation{
+			DataSlot:        slot,
+			CommitteeBits:   make([]byte, maxCommitteesPerSlot/8),
+			BeaconBlockRoot: c.headAt(slot),
+			TargetRoot:      c.headAt(epochStart),
+		}
+		if !headOK {
+			att.BeaconBlockRoot = forkRoot(slot)
+		}
+		if !targetOK {
+			att.TargetRoot = forkRoot(epochStart)
+		}
+		var members []domain.ValidatorIndex
+		for _, index := range group {
+			att.CommitteeBits[index/8] |= 1 << (index % 8)
+			members = append(members, committees[index]...)
+		}
+		att.AggregationBits = make([]byte, (len(members)+7)/8)
+		for bit, v := range members {
+			if attested[v] {
+				att.AggregationBits[bit/8] |= 1 << (bit % 8)
+			}
+		}
+
+		included, ok := c.include(slot+delay, att)
+		if !ok {
+			continue
+		}
+		for _, v := range members {
+			if attested[v] {
+				c.inclusions[epoch][v] = append(c.inclusions[epoch][v], inclusion{included, headOK, targetOK})
+			}
+		}
+		if duplicate {
+			if again, ok := c.include(included+1, att); ok {
+				for _, v := range members {
+					if attested[v] {
+						c.inclusions[epoch][v] = append(c.inclusions[epoch][v], inclusion{again, headOK, targetOK})
+					}
+				}
+			}
+		}
+	}
+}
+
+// include adds att to the first block at or after slot and returns that
+// block's slot, or false if there is none left in the chain.
+func (c *Chain) include(slot domain.Slot, att domain.Attestation) (domain.Slot, bool) {
+	for ; slot <= c.lastSlot(); slot++ {
+		if b, ok := c.blocks[slot]; ok {
+			b.Attestations = append(b.Attestations, att)
+			return slot, true
+		}
+	}
+	return 0, false
+}
+
+// SetFinalized moves the finalized epoch.
+func (c *Chain) SetFinalized(epoch domain.Epoch) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finalized = epoch
+}
+
+// FailBlock makes GetBlock return err for slot, as a node that cannot serve
+// the block would.
+func (c *Chain) FailBlock(slot domain.Slot, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blockFailures[slot] = err
+}
+
+// FailCommittees makes GetEpochCommittees return err for epoch.
+func (c *Chain) FailCommittees(epoch domain.Epoch, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.committeeFailures[epoch] = err
+}
+
+// Expected returns the result every duty of the epoch must have, for the
+// given validators, taking injected failures into account: a duty is unknown
+// rather than missed, and a vote unknown rather than judged, when data it
+// depends on cannot be fetched. Attestations carry the validator's
+// effectiveness for the epoch and the reward it missed, missed sync
+// committee signatures their missed reward, and every result whether the
+// network was degraded at its slot under domain.DefaultNetworkThresholds.
+// Results are ordered by duty type, slot and validator; reasons are left
+// empty.
+func (c *Chain) Expected(epoch domain.Epoch, indices []domain.ValidatorIndex) []domain.DutyResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	baseline, hasBaseline := c.expectedBaseline(epoch)
+	degraded := func(slot domain.Slot) bool {
+		return hasBaseline && baseline.DegradedAt(slot, domain.DefaultNetworkThresholds)
+	}
+	tracked := make(map[domain.ValidatorIndex]bool, len(indices))
+	for _, v := range indices {
+		tracked[v] = true
+	}
+
+	var results []domain.DutyResult
+	// scored holds the proposal and sync committee results each validator's
+	// attestation is scored with.
+	scored := make(map[domain.ValidatorIndex][]domain.DutyResult)
+	firstSlot := domain.Slot(epoch) * slotsPerEpoch
+	for slot := firstSlot; slot < firstSlot+slotsPerEpoch; slot++ {
+		proposer := c.proposers[slot]
+		if !tracked[proposer] {
+			continue
+		}
+		r := domain.DutyResult{Type: domain.DutyTypeProposal, ValidatorIndex: proposer, Epoch: epoch, Slot: slot}
+		_, proposed := c.blocks[slot]
+		switch {
+		case c.blockFailures[slot] != nil:
+			r.Outcome = domain.OutcomeUnknown
+		case proposed:
+			r.Outcome, r.InclusionSlot = domain.OutcomeSuccess, slot
+		default:
+			r.Outcome = domain.OutcomeMissed
+		}
+		r.NetworkDegraded = degraded(slot)
+		results = append(results, r)
+		scored[proposer] = append(scored[proposer], r)
+	}
+
+	for slot := firstSlot; slot < firstSlot+slotsPerEpoch; slot++ {
+		if _, ok := c.blocks[slot]; !ok {
+			continue
+		}
+		for _, v := range indices {
+			positions := c.syncPositions[v]
+			if len(positions) == 0 {
+				continue
+			}
+			r := domain.DutyResult{
+				Type:            domain.DutyTypeSyncCommittee,
+				ValidatorIndex:  v,
+				Epoch:           epoch,
+				Slot:            slot,
+				Outcome:         domain.OutcomeMissed,
+				NetworkDegraded: degraded(slot),
+			}
+			switch {
+			case c.blockFailures[slot] != nil:
+				r.Outcome = domain.OutcomeUnknown
+			case c.syncSigned[slot][v]:
+				r.Outcome, r.InclusionSlot = domain.OutcomeSuccess, slot
+			default:
+				r.MissedReward = 2 * syncReward * int64(len(positions))
+			}
+			results = append(results, r)
+			scored[v] = append(scored[v], r)
+		}
+	}
+
+	var attestations []domain.DutyResult
+	for _, v := range indices {
+		duty := c.duties[epoch][v]
+		r := domain.DutyResult{
+			Type:            domain.DutyTypeAttestation,
+			ValidatorIndex:  v,
+			Epoch:           epoch,
+			Slot:            duty.Slot,
+			CommitteeIndex:  duty.CommitteeIndex,
+			Outcome:         domain.OutcomeMissed,
+			NetworkDegraded: degraded(duty.Slot),
+		}
+		reward := c.attestationReward(epoch, v)
+		r.MissedReward = reward.Ideal - reward.Actual
+		if c.committeeFailures[epoch] != nil {
+			r.Outcome = domain.OutcomeUnknown
+			r.Effectiveness = expectedEffectiveness(r, scored[v])
+			attestations = append(attestations, r)
+			continue
+		}
+		for _, in := range c.inclusions[epoch][v] {
+			if in.slot <= duty.Slot+inclusionWindow && c.blockFailures[in.slot] == nil {
+				r.Outcome, r.InclusionSlot = domain.OutcomeSuccess, in.slot
+				r.Head = c.expectedVote(in.headOK, duty.Slot, epoch)
+				r.Target = c.expectedVote(in.targetOK, firstSlot, epoch)
+				break
+			}
+		}
+		if r.Outcome == domain.OutcomeMissed {
+			for slot := duty.Slot + 1; slot <= duty.Slot+inclusionWindow; slot++ {
+				if c.blockFailures[slot] != nil {
+					r.Outcome = domain.OutcomeUnknown
+					break
+				}
+			}
+		}
+		r.Effectiveness = expectedEffectiveness(r, scored[v])
+		attestations = append(attestations, r)
+	}
+	sort.Slice(attestations, func(i, j int) bool {
+		if attestations[i].Slot != attestations[j].Slot {
+			return attestations[i].Slot < attestations[j].Slot
+		}
+		return attestations[i].ValidatorIndex < attestations[j].ValidatorIndex
+	})
+	results = append(results, attestations...)
+
+	return results
+}
+
+// expectedEffectiveness scores an attestation with the validator's other
+// results of the epoch, spelling out the weights of
+// domain.EpochEffectiveness: source 14, target 26, head 14, 8 per proposal
+// and 2 per sync committee signature, only counting what could be decided.
+func expectedEffectiveness(attestation domain.DutyResult, others []domain.DutyResult) domain.Effectiveness {
+	var e domain.Effectiveness
+	switch attestation.Outcome {
+	case domain.OutcomeMissed:
+		e.Possible = 14 + 26 + 14
+	case domain.OutcomeSuccess:
+		delay := attestation.InclusionSlot - attestation.Slot
+		e.Possible = 14
+		if delay <= 5 {
+			e.Earned = 14
+		}
+		if attestation.Target != domain.VoteUnknown {
+			e.Possible += 26
+			if attestation.Target == domain.VoteCorrect {
+				e.Earned += 26
+			}
+		}
+		if attestation.Head != domain.VoteUnknown {
+			e.Possible += 14
+			if attestation.Head == domain.VoteCorrect && delay == 1 {
+				e.Earned += 14
+			}
+		}
+	}
+	for _, r := range others {
+		weight := uint32(8)
+		if r.Type == domain.DutyTypeSyncCommittee {
+			weight = 2
+		}
+		if r.Outcome != domain.OutcomeUnknown {
+			e.Possible += weight
+		}
+		if r.Outcome == domain.OutcomeSuccess {
+			e.Earned += weight
+		}
+	}
+	return e
+}
+
+// attestationReward is what v was paid for its attestation in epoch: the
+// first inclusion in the window decides, whether or not its block can be
+// fetched, as the beacon node's rewards do not depend on the checker.
+func (c *Chain) attestationReward(epoch domain.Epoch, v domain.ValidatorIndex) domain.ValidatorReward {
+	reward := domain.ValidatorReward{
+		ValidatorIndex: v,
+		Ideal:          headReward + targetReward + sourceReward,
+		Actual:         -targetReward - sourceReward,
+	}
+	duty := c.duties[epoch][v]
+	for _, in := range c.inclusions[epoch][v] {
+		if in.slot > duty.Slot+inclusionWindow {
+			break
+		}
+		reward.Actual = sourceReward - targetReward
+		if in.targetOK {
+			reward.Actual += 2 * targetReward
+		}
+		if in.headOK {
+			reward.Actual += headReward
+		}
+		break
+	}
+	return reward
+}
+
+// ExpectedBaseline returns the network baseline the checker must compute
+// for the epoch, and false if it cannot since the committees fail.
+func (c *Chain) ExpectedBaseline(epoch domain.Epoch) (domain.NetworkBaseline, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.expectedBaseline(epoch)
+}
+
+func (c *Chain) expectedBaseline(epoch domain.Epoch) (domain.NetworkBaseline, bool) {
+	if c.committeeFailures[epoch] != nil {
+		return domain.NetworkBaseline{}, false
+	}
+	firstSlot := domain.Slot(epoch) * slotsPerEpoch
+	var slots []domain.SlotParticipation
+	proposed, missed, unavailable := 0, 0, 0
+	for slot := firstSlot; slot < firstSlot+slotsPerEpoch; slot++ {
+		p := domain.SlotParticipation{Slot: slot}
+		for _, members := range c.committees[epoch][slot] {
+			p.Attesters += len(members)
+			for _, v := range members {
+				for _, in := range c.inclusions[epoch][v] {
+					if in.slot <= slot+inclusionWindow && c.blockFailures[in.slot] == nil {
+						p.Included++
+						break
+					}
+				}
+			}
+		}
+		for next := slot + 1; next <= slot+inclusionWindow; next++ {
+			if c.blockFailures[next] != nil {
+				p.Incomplete = true
+				break
+			}
+		}
+		slots = append(slots, p)
+
+		_, ok := c.blocks[slot]
+		switch {
+		case c.blockFailures[slot] != nil:
+			unavailable++
+		case ok:
+			proposed++
+		default:
+			missed++
+		}
+	}
+	return domain.NewNetworkBaseline(epoch, slots, proposed, missed, unavailable), true
+}
+
+// expectedVote is the vote the checker must find for a vote cast at slot:
+// it can only tell the canonical block at slot from the parent root of the
+// next block among those it fetches for the epoch, so the vote is unknown if
+// a failed block comes first or there is none.
+func (c *Chain) expectedVote(correct bool, slot domain.Slot, epoch domain.Epoch) domain.Vote {
+	last := min(domain.Slot(epoch)*slotsPerEpoch+2*slotsPerEpoch-1, c.lastSlot())
+	for next := slot + 1; next <= last; next++ {
+		if c.blockFailures[next] != nil {
+			return domain.VoteUnknown
+		}
+		if _, ok := c.blocks[next]; ok {
+			if correct {
+				return domain.VoteCorrect
+			}
+			return domain.VoteWrong
+		}
+	}
+	return domain.VoteUnknown
+}
+
+// The ports.BeaconChainAdapter implementation.
+
+var _ ports.BeaconChainAdapter = (*Chain)(nil)
+
+func (c *Chain) GetFinalizedEpoch(context.Context) (domain.Epoch, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.finalized, nil
+}
+
+func (c *Chain) GetFinalityCheckpoints(context.Context) (domain.FinalityCheckpoints, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return domain.FinalityCheckpoints{
+		Justified:     c.finalized + 1,
+		Finalized:     c.finalized,
+		FinalizedRoot: c.headAt(domain.Slot(c.finalized) * slotsPerEpoch),
+	}, nil
+}
+
+func (c *Chain) GetHeadSlot(context.Context) (domain.Slot, error) {
+	for slot := c.lastSlot(); ; slot-- {
+		if _, ok := c.blocks[slot]; ok {
+			return slot, nil
+		}
+	}
+}
+
+func (c *Chain) GetValidatorDutiesBatch(_ context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ValidatorDuty, error) {
+	duties, ok := c.duties[epoch]
+	if !ok {
+		return nil, fmt.Errorf("%w: epoch %d not in the chain", ports.ErrNotFound, epoch)
+	}
+	result := make([]domain.ValidatorDuty, 0, len(indices))
+	for _, v := range indices {
+		if int(v) >= len(duties) {
+			return nil, fmt.Errorf("unknown validator %d", v)
+		}
+		result = append(result, duties[v])
+	}
+	return result, nil
+}
+
+func (c *Chain) GetProposerDuties(_ context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ProposerDuty, error) {
+	if _, ok := c.duties[epoch]; !ok {
+		return nil, fmt.Errorf("%w: epoch %d not in the chain", ports.ErrNotFound, epoch)
+	}
+	tracked := make(map[domain.ValidatorIndex]bool, len(indices))
+	for _, v := range indices {
+		tracked[v] = true
+	}
+	var duties []domain.ProposerDuty
+	firstSlot := domain.Slot(epoch) * slotsPerEpoch
+	for slot := firstSlot; slot < firstSlot+slotsPerEpoch; slot++ {
+		if tracked[c.proposers[slot]] {
+			duties = append(duties, domain.ProposerDuty{ValidatorIndex: c.proposers[slot], Slot: slot})
+		}
+	}
+	return duties, nil
+}
+
+func (c *Chain) GetSyncCommitteeDuties(_ context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.SyncCommitteeDuty, error) {
+	if _, ok := c.duties[epoch]; !ok {
+		return nil, fmt.Errorf("%w: epoch %d not in the chain", ports.ErrNotFound, epoch)
+	}
+	var duties []domain.SyncCommitteeDuty
+	for _, v := range indices {
+		if positions := c.syncPositions[v]; len(positions) > 0 {
+			duties = append(duties, domain.SyncCommitteeDuty{ValidatorIndex: v, Positions: positions})
+		}
+	}
+	return duties, nil
+}
+
+func (c *Chain) GetAttestationRewards(_ context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ValidatorReward, error) {
+	if _, ok := c.duties[epoch]; !ok {
+		return nil, fmt.Errorf("%w: epoch %d not in the chain", ports.ErrNotFound, epoch)
+	}
+	rewards := make([]domain.ValidatorReward, 0, len(indices))
+	for _, v := range indices {
+		rewards = append(rewards, c.attestationReward(epoch, v))
+	}
+	return rewards, nil
+}
+
+func (c *Chain) GetSyncCommitteeRewards(_ context.Context, slot domain.Slot, indices []domain.ValidatorIndex) ([]domain.ValidatorReward, error) {
+	if _, ok := c.blocks[slot]; !ok {
+		return nil, fmt.Errorf("%w: no block at slot %d", ports.ErrNotFound, slot)
+	}
+	signed := c.syncSigned[slot]
+	var rewards []domain.ValidatorReward
+	for _, v := range indices {
+		positions := c.syncPositions[v]
+		if len(positions) == 0 {
+			continue
+		}
+		reward := syncReward * int64(len(positions))
+		if !signed[v] {
+			reward = -reward
+		}
+		rewards = append(rewards, domain.ValidatorReward{ValidatorIndex: v, Ideal: max(reward, -reward), Actual: reward})
+	}
+	return rewards, nil
+}
+
+func (c *Chain) GetBlock(_ context.Context, slot domain.Slot) (domain.Block, error) {
+	c.mu.Lock()
+	err := c.blockFailures[slot]
+	c.mu.Unlock()
+	if err != nil {
+		return domain.Block{}, err
+	}
+	b, ok := c.blocks[slot]
+	if !ok {
+		return domain.Block{}, fmt.Errorf("%w: no block at slot %d", ports.ErrNotFound, slot)
+	}
+	return *b, nil
+}
+
+func (c *Chain) GetEpochCommittees(_ context.Context, epoch domain.Epoch) (domain.EpochCommittees, error) {
+	c.mu.Lock()
+	err := c.committeeFailures[epoch]
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	committees, ok := c.committees[epoch]
+	if !ok {
+		return nil, fmt.Errorf("%w: epoch %d not in the chain", ports.ErrNotFound, epoch)
+	}
+	return committees, nil
+}
+
+func (c *Chain) GetAllActiveValidatorIndices(context.Context) ([]domain.ValidatorIndex, error) {
+	indices := make([]domain.ValidatorIndex, c.cfg.Validators)
+	for i := range indices {
+		indices[i] = domain.ValidatorIndex(i)
+	}
+	return indices, nil
+}
@@ -0,0 +1,105 @@
+package adapters
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+)
+
+// A fixture is a directory holding the beacon API calls made while checking
+// an epoch, to check it again offline (see NewRecordingBeaconAdapter and
+// OpenReplayBeaconAdapter):
+//
+//	manifest.json    what was recorded (FixtureManifest)
+//	calls.jsonl.gz   one fixtureCall per line, in call order
+const (
+	fixtureManifestFile = "manifest.json"
+	fixtureCallsFile    = "calls.jsonl.gz"
+)
+
+// FixtureManifest describes what a fixture was recorded for.
+type FixtureManifest struct {
+	Epoch      domain.Epoch            `json:"epoch"`
+	Validators []domain.ValidatorIndex `json:"validators"`
+	RecordedAt time.Time               `json:"recorded_at"`
+	// Source names the beacon nodes the calls were answered by.
+	Source string `json:"source,omitempty"`
+	// Note is free text, e.g. why the epoch was captured.
+	Note string `json:"note,omitempty"`
+}
+
+// Recorded port methods.
+const (
+	callFinalizedEpoch       = "GetFinalizedEpoch"
+	callFinalityCheckpoints  = "GetFinalityCheckpoints"
+	callHeadSlot             = "GetHeadSlot"
+	callValidatorDuties      = "GetValidatorDutiesBatch"
+	callProposerDuties       = "GetProposerDuties"
+	callSyncCommitteeDuties  = "GetSyncCommitteeDuties"
+	callAttestationRewards   = "GetAttestationRewards"
+	callSyncCommitteeRewards = "GetSyncCommitteeRewards"
+	callBlock                = "GetBlock"
+	callEpochCommittees      = "GetEpochCommittees"
+	callActiveValidators     = "GetAllActiveValidatorIndices"
+)
+
+// fixtureCall is one recorded call: its arguments, and either its result or
+// its error.
+type fixtureCall struct {
+	Method  string                  `json:"method"`
+	Epoch   *domain.Epoch           `json:"epoch,omitempty"`
+	Slot    *domain.Slot            `json:"slot,omitempty"`
+	Indices []domain.ValidatorIndex `json:"indices,omitempty"`
+	Result  json.RawMessage         `json:"result,omitempty"`
+	Error   *fixtureError           `json:"error,omitempty"`
+}
+
+// fixtureError keeps the class of a recorded error so that replay returns an
+// error matching the same ports sentinel.
+type fixtureError struct {
+	Kind    string `json:"kind,omitempty"`
+	Message string `json:"message"`
+}
+
+var fixtureErrorKinds = []struct {
+	kind string
+	err  error
+}{
+	{"not_found", ports.ErrNotFound},
+	{"timeout", ports.ErrTimeout},
+	{"server", ports.ErrServer},
+	{"unavailable", ports.ErrUnavailable},
+	{"unsupported_fork", ports.ErrUnsupportedFork},
+}
+
+func newFixtureError(err error) *fixtureError {
+	fe := &fixtureError{Message: err.Error()}
+	for _, k := range fixtureErrorKinds {
+		if errors.Is(err, k.err) {
+			fe.Kind = k.kind
+			break
+		}
+	}
+	return fe
+}
+
+// err rebuilds the recorded error: same message, same sentinel.
+func (e *fixtureError) err() error {
+	for _, k := range fixtureErrorKinds {
+		if e.Kind == k.kind {
+			return &recordedError{message: e.Message, kind: k.err}
+		}
+	}
+	return &recordedError{message: e.Message}
+}
+
+type recordedError struct {
+	message string
+	kind    error
+}
+
+func (e *recordedError) Error() string { return e.message }
+func (e *recordedError) Unwrap() error { return e.kind }
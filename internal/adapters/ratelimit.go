@@ -0,0 +1,57 @@
+package adapters
+
+import (
+	"context"
+	nethttp "net/http"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces requests evenly so that at most one starts per interval.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Wait blocks until the caller may start a request or ctx is done.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitedTransport applies a per-node rate limit to every HTTP request
+// sent to that node, including health checks.
+type rateLimitedTransport struct {
+	limiter *rateLimiter
+	next    nethttp.RoundTripper
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *nethttp.Request) (*nethttp.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
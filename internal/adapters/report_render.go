@@ -0,0 +1,223 @@
+package adapters
+
+import (
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+// Report formats, also the file extensions they are written with.
+var reportFormats = map[string]string{
+	"markdown": ".md",
+	"html":     ".html",
+	"json":     ".json",
+}
+
+// reportRewardsNote says what the missed rewards cover, so a reader does not
+// take them for everything the validators lost.
+const reportRewardsNote = "Missed rewards are those the beacon nodes report for attestations and " +
+	"sync committee signatures of finalized epochs; the rewards of missed block proposals are not estimated."
+
+var reportFuncs = map[string]interface{}{
+	"pct":   func(f float64) string { return fmt.Sprintf("%.2f%%", 100*f) },
+	"delay": func(f float64) string { return fmt.Sprintf("%.2f", f) },
+	"eth":   func(gwei int64) string { return fmt.Sprintf("%.6f", float64(gwei)/1e9) },
+	"date":  func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 MST") },
+	"group": func(name string) string {
+		if name == "" {
+			return "(ungrouped)"
+		}
+		return name
+	},
+	"rewardsNote": func() string { return reportRewardsNote },
+}
+
+const markdownReport = `# Validator performance report
+
+- Period: {{date .From}} to {{date .To}}
+- Epochs with stored results: {{.EpochsCovered}} of {{.EpochsExpected}}
+- Generated: {{date .GeneratedAt}}
+
+## Attestations
+
+| Group | Validators | Duties | Included | Missed | Unknown | Inclusion rate | Avg. inclusion delay | Optimal inclusions |
+|-------|-----------:|-------:|---------:|-------:|--------:|--------------:|---------------------:|-------------------:|
+{{- range .Groups}}
+| {{group .Name}} | {{.Validators}} | {{.Attestations.Duties}} | {{.Attestations.Included}} | {{.Attestations.Missed}} | {{.Attestations.Unknown}} | {{pct .Attestations.InclusionRate}} | {{delay .Attestations.AverageInclusionDelay}} | {{.Attestations.OptimalInclusions}} |
+{{- end}}
+{{- with .Total}}
+| **Total** | {{.Validators}} | {{.Attestations.Duties}} | {{.Attestations.Included}} | {{.Attestations.Missed}} | {{.Attestations.Unknown}} | {{pct .Attestations.InclusionRate}} | {{delay .Attestations.AverageInclusionDelay}} | {{.Attestations.OptimalInclusions}} |
+{{- end}}
+
+## Block proposals
+
+| Group | Duties | Proposed | Missed | Unknown |
+|-------|-------:|---------:|-------:|--------:|
+{{- range .Groups}}
+| {{group .Name}} | {{.Proposals.Duties}} | {{.Proposals.Proposed}} | {{.Proposals.Missed}} | {{.Proposals.Unknown}} |
+{{- end}}
+{{- with .Total}}
+| **Total** | {{.Proposals.Duties}} | {{.Proposals.Proposed}} | {{.Proposals.Missed}} | {{.Proposals.Unknown}} |
+{{- end}}
+{{range .Groups}}{{if .Proposals.Misses}}
+Missed proposals of {{group .Name}}:
+{{range .Proposals.Misses}}
+- slot {{.Slot}}, validator {{.ValidatorIndex}}{{if .Reason}}: {{.Reason}}{{end}}
+{{- end}}
+{{end}}{{end}}
+## Sync committee
+
+| Group | Duties | Included | Missed | Unknown | Participation rate |
+|-------|-------:|---------:|-------:|--------:|-------------------:|
+{{- range .Groups}}
+| {{group .Name}} | {{.SyncCommittee.Duties}} | {{.SyncCommittee.Included}} | {{.SyncCommittee.Missed}} | {{.SyncCommittee.Unknown}} | {{pct .SyncCommittee.ParticipationRate}} |
+{{- end}}
+{{- with .Total}}
+| **Total** | {{.SyncCommittee.Duties}} | {{.SyncCommittee.Included}} | {{.SyncCommittee.Missed}} | {{.SyncCommittee.Unknown}} | {{pct .SyncCommittee.ParticipationRate}} |
+{{- end}}
+
+## Missed rewards
+
+| Group | Missed rewards (ETH) |
+|-------|---------------------:|
+{{- range .Groups}}
+| {{group .Name}} | {{eth .MissedRewards}} |
+{{- end}}
+| **Total** | {{eth .Total.MissedRewards}} |
+
+{{rewardsNote}}
+`
+
+const htmlReport = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Validator performance report {{date .From}} to {{date .To}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.7em; }
+td.n { text-align: right; }
+tr.total { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Validator performance report</h1>
+<p>Period: {{date .From}} to {{date .To}}<br>
+Epochs with stored results: {{.EpochsCovered}} of {{.EpochsExpected}}<br>
+Generated: {{date .GeneratedAt}}</p>
+
+<h2>Attestations</h2>
+<table>
+<tr><th>Group</th><th>Validators</th><th>Duties</th><th>Included</th><th>Missed</th><th>Unknown</th><th>Inclusion rate</th><th>Avg. inclusion delay</th><th>Optimal inclusions</th></tr>
+{{- range .Groups}}
+<tr><td>{{group .Name}}</td>{{template "attestations" .}}</tr>
+{{- end}}
+<tr class="total"><td>Total</td>{{template "attestations" .Total}}</tr>
+</table>
+
+<h2>Block proposals</h2>
+<table>
+<tr><th>Group</th><th>Duties</th><th>Proposed</th><th>Missed</th><th>Unknown</th></tr>
+{{- range .Groups}}
+<tr><td>{{group .Name}}</td>{{template "proposals" .}}</tr>
+{{- end}}
+<tr class="total"><td>Total</td>{{template "proposals" .Total}}</tr>
+</table>
+{{range .Groups}}{{if .Proposals.Misses}}
+<p>Missed proposals of {{group .Name}}:</p>
+<ul>
+{{- range .Proposals.Misses}}
+<li>slot {{.Slot}}, validator {{.ValidatorIndex}}{{if .Reason}}: {{.Reason}}{{end}}</li>
+{{- end}}
+</ul>
+{{end}}{{end}}
+
+<h2>Sync committee</h2>
+<table>
+<tr><th>Group</th><th>Duties</th><th>Included</th><th>Missed</th><th>Unknown</th><th>Participation rate</th></tr>
+{{- range .Groups}}
+<tr><td>{{group .Name}}</td>{{template "syncCommittee" .}}</tr>
+{{- end}}
+<tr class="total"><td>Total</td>{{template "syncCommittee" .Total}}</tr>
+</table>
+
+<h2>Missed rewards</h2>
+<table>
+<tr><th>Group</th><th>Missed rewards (ETH)</th></tr>
+{{- range .Groups}}
+<tr><td>{{group .Name}}</td><td class="n">{{eth .MissedRewards}}</td></tr>
+{{- end}}
+<tr class="total"><td>Total</td><td class="n">{{eth .Total.MissedRewards}}</td></tr>
+</table>
+<p><em>{{rewardsNote}}</em></p>
+</body>
+</html>
+{{define "attestations"}}<td class="n">{{.Validators}}</td><td class="n">{{.Attestations.Duties}}</td><td class="n">{{.Attestations.Included}}</td><td class="n">{{.Attestations.Missed}}</td><td class="n">{{.Attestations.Unknown}}</td><td class="n">{{pct .Attestations.InclusionRate}}</td><td class="n">{{delay .Attestations.AverageInclusionDelay}}</td><td class="n">{{.Attestations.OptimalInclusions}}</td>{{end}}
+{{define "syncCommittee"}}<td class="n">{{.SyncCommittee.Duties}}</td><td class="n">{{.SyncCommittee.Included}}</td><td class="n">{{.SyncCommittee.Missed}}</td><td class="n">{{.SyncCommittee.Unknown}}</td><td class="n">{{pct .SyncCommittee.ParticipationRate}}</td>{{end}}
+{{define "proposals"}}<td class="n">{{.Proposals.Duties}}</td><td class="n">{{.Proposals.Proposed}}</td><td class="n">{{.Proposals.Missed}}</td><td class="n">{{.Proposals.Unknown}}</td>{{end}}
+`
+
+var (
+	markdownReportTemplate = template.Must(template.New("report").Funcs(reportFuncs).Parse(markdownReport))
+	htmlReportTemplate     = htmltemplate.Must(htmltemplate.New("report").Funcs(reportFuncs).Parse(htmlReport))
+)
+
+// IsReportFormat tells whether format is one RenderReport supports:
+// markdown, html or json.
+func IsReportFormat(format string) bool {
+	_, ok := reportFormats[format]
+	return ok
+}
+
+// RenderReport writes report to w in format.
+func RenderReport(w io.Writer, format string, report domain.PerformanceReport) error {
+	switch format {
+	case "markdown":
+		return markdownReportTemplate.Execute(w, report)
+	case "html":
+		return htmlReportTemplate.Execute(w, report)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// WriteReportFiles writes report to dir in each format, as
+// <name>.md, <name>.html and <name>.json, and returns the paths written.
+func WriteReportFiles(dir, name string, formats []string, report domain.PerformanceReport) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, format := range formats {
+		path := filepath.Join(dir, name+reportFormats[format])
+		tmp, err := os.CreateTemp(dir, ".report-*")
+		if err != nil {
+			return paths, err
+		}
+		err = RenderReport(tmp, format, report)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), path)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			return paths, fmt.Errorf("writing %s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
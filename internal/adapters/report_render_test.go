@@ -0,0 +1,77 @@
+package adapters
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+func TestWriteReportFiles(t *testing.T) {
+	group := domain.GroupReport{
+		Name:       "<operator-a>",
+		Validators: 2,
+		Attestations: domain.AttestationStats{
+			Duties: 450, Included: 448, Missed: 2, InclusionRate: 448.0 / 450, AverageInclusionDelay: 1.0625, OptimalInclusions: 430,
+		},
+		Proposals: domain.ProposalStats{
+			Duties: 2, Proposed: 1, Missed: 1,
+			Misses: []domain.MissedDuty{{Slot: 9920017, ValidatorIndex: 7, Reason: "no block at the slot"}},
+		},
+		SyncCommittee: domain.SyncCommitteeStats{Duties: 200, Included: 190, Missed: 10, ParticipationRate: 0.95},
+		MissedRewards: 1_234_567,
+	}
+	report := domain.PerformanceReport{
+		From:           time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+		To:             time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC),
+		GeneratedAt:    time.Date(2025, 6, 9, 0, 30, 0, 0, time.UTC),
+		EpochsExpected: 1575,
+		EpochsCovered:  1575,
+		Groups:         []domain.GroupReport{group},
+		Total:          group,
+	}
+	dir := t.TempDir()
+	paths, err := WriteReportFiles(dir, "weekly-2025-06-02", []string{"markdown", "html", "json"}, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"weekly-2025-06-02.md", "weekly-2025-06-02.html", "weekly-2025-06-02.json"}
+	for i := range want {
+		want[i] = filepath.Join(dir, want[i])
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("wrote %v, want %v", paths, want)
+	}
+
+	markdown, _ := os.ReadFile(paths[0])
+	for _, s := range []string{
+		"| <operator-a> | 2 | 450 | 448 | 2 | 0 | 99.56% | 1.06 | 430 |",
+		"- slot 9920017, validator 7: no block at the slot",
+		"| <operator-a> | 200 | 190 | 10 | 0 | 95.00% |",
+		"| **Total** | 0.001235 |",
+		"Epochs with stored results: 1575 of 1575",
+	} {
+		if !strings.Contains(string(markdown), s) {
+			t.Errorf("markdown report lacks %q:\n%s", s, markdown)
+		}
+	}
+	html, _ := os.ReadFile(paths[1])
+	if !strings.Contains(string(html), "&lt;operator-a&gt;") || strings.Contains(string(html), "<operator-a>") {
+		t.Errorf("group name not escaped in the HTML report:\n%s", html)
+	}
+	var decoded domain.PerformanceReport
+	data, _ := os.ReadFile(paths[2])
+	if err := json.Unmarshal(data, &decoded); err != nil || !reflect.DeepEqual(decoded, report) {
+		t.Errorf("JSON report %s does not round-trip (%v)", data, err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("%d files in the report directory, want 3", len(entries))
+	}
+}
@@ -0,0 +1,168 @@
+package adapters
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/parquet-go/parquet-go"
+)
+
+// ResultColumns are the columns of exported results, in order. They are a
+// documented interface (see the README): only append new ones.
+var ResultColumns = []string{
+	"epoch", "slot", "slot_time", "validator_index", "group", "duty",
+	"committee_index", "outcome", "inclusion_slot", "inclusion_delay", "reason",
+	"head_vote", "target_vote", "effectiveness", "effectiveness_weight",
+	"network_degraded", "missed_reward",
+}
+
+// csvResultWriter writes results as CSV with a header row. Empty cells stand
+// for absent values; times are RFC 3339 in UTC.
+type csvResultWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+// NewCSVResultWriter writes exported results to w as CSV.
+func NewCSVResultWriter(w io.Writer) (ports.ResultRowWriter, error) {
+	c := &csvResultWriter{w: csv.NewWriter(w), record: make([]string, len(ResultColumns))}
+	if err := c.w.Write(ResultColumns); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *csvResultWriter) Write(row domain.ResultRow) error {
+	c.record[0] = strconv.FormatUint(uint64(row.Epoch), 10)
+	c.record[1] = strconv.FormatUint(uint64(row.Slot), 10)
+	c.record[2] = row.SlotTime.UTC().Format(time.RFC3339)
+	c.record[3] = strconv.FormatUint(uint64(row.ValidatorIndex), 10)
+	c.record[4] = row.Group
+	c.record[5] = string(row.Duty)
+	c.record[6] = formatOptional(row.CommitteeIndex)
+	c.record[7] = string(row.Outcome)
+	c.record[8] = formatOptional(row.InclusionSlot)
+	c.record[9] = formatOptional(row.InclusionDelay)
+	c.record[10] = row.Reason
+	c.record[11] = string(row.HeadVote)
+	c.record[12] = string(row.TargetVote)
+	c.record[13], c.record[14] = "", ""
+	if e := row.Effectiveness; e != nil {
+		c.record[13] = strconv.FormatFloat(e.Score(), 'f', -1, 64)
+		c.record[14] = strconv.FormatUint(uint64(e.Possible), 10)
+	}
+	c.record[15] = strconv.FormatBool(row.NetworkDegraded)
+	c.record[16] = strconv.FormatInt(row.MissedReward, 10)
+	return c.w.Write(c.record)
+}
+
+func (c *csvResultWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func formatOptional[T ~uint64](v *T) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*v), 10)
+}
+
+// parquetResultRow is the Parquet schema of exported results; the columns
+// match ResultColumns.
+type parquetResultRow struct {
+	Epoch          uint64    `parquet:"epoch"`
+	Slot           uint64    `parquet:"slot"`
+	SlotTime       time.Time `parquet:"slot_time,timestamp(millisecond)"`
+	ValidatorIndex uint64    `parquet:"validator_index"`
+	Group          string    `parquet:"group,dict"`
+	Duty           string    `parquet:"duty,dict"`
+	CommitteeIndex *uint64   `parquet:"committee_index,optional"`
+	Outcome        string    `parquet:"outcome,dict"`
+	InclusionSlot  *uint64   `parquet:"inclusion_slot,optional"`
+	InclusionDelay *uint64   `parquet:"inclusion_delay,optional"`
+	Reason         string    `parquet:"reason"`
+	HeadVote       string    `parquet:"head_vote,dict"`
+	TargetVote     string    `parquet:"target_vote,dict"`
+	Effectiveness  *float64  `parquet:"effectiveness,optional"`
+	// EffectivenessWeight is the possible weight the score is a share of.
+	EffectivenessWeight *uint64 `parquet:"effectiveness_weight,optional"`
+	NetworkDegraded     bool    `parquet:"network_degraded"`
+	// MissedReward is in Gwei.
+	MissedReward int64 `parquet:"missed_reward"`
+}
+
+// parquetRowGroupSize bounds the rows buffered in memory before a row group
+// is written out.
+const parquetRowGroupSize = 100_000
+
+type parquetResultWriter struct {
+	w   *parquet.GenericWriter[parquetResultRow]
+	buf []parquetResultRow
+}
+
+// NewParquetResultWriter writes exported results to w as a zstd-compressed
+// Parquet file. Nothing is readable until Close writes the footer.
+func NewParquetResultWriter(w io.Writer) ports.ResultRowWriter {
+	return &parquetResultWriter{
+		w: parquet.NewGenericWriter[parquetResultRow](w,
+			parquet.Compression(&parquet.Zstd),
+			parquet.MaxRowsPerRowGroup(parquetRowGroupSize),
+		),
+		buf: make([]parquetResultRow, 0, 1024),
+	}
+}
+
+func (p *parquetResultWriter) Write(row domain.ResultRow) error {
+	p.buf = append(p.buf, parquetResultRow{
+		Epoch:           uint64(row.Epoch),
+		Slot:            uint64(row.Slot),
+		SlotTime:        row.SlotTime.UTC(),
+		ValidatorIndex:  uint64(row.ValidatorIndex),
+		Group:           row.Group,
+		Duty:            string(row.Duty),
+		CommitteeIndex:  optionalUint64(row.CommitteeIndex),
+		Outcome:         string(row.Outcome),
+		InclusionSlot:   optionalUint64(row.InclusionSlot),
+		InclusionDelay:  optionalUint64(row.InclusionDelay),
+		Reason:          row.Reason,
+		HeadVote:        string(row.HeadVote),
+		TargetVote:      string(row.TargetVote),
+		NetworkDegraded: row.NetworkDegraded,
+		MissedReward:    row.MissedReward,
+	})
+	if e := row.Effectiveness; e != nil {
+		score, weight := e.Score(), uint64(e.Possible)
+		last := &p.buf[len(p.buf)-1]
+		last.Effectiveness, last.EffectivenessWeight = &score, &weight
+	}
+	if len(p.buf) == cap(p.buf) {
+		return p.flush()
+	}
+	return nil
+}
+
+func (p *parquetResultWriter) flush() error {
+	_, err := p.w.Write(p.buf)
+	p.buf = p.buf[:0]
+	return err
+}
+
+func (p *parquetResultWriter) Close() error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+	return p.w.Close()
+}
+
+func optionalUint64[T ~uint64](v *T) *uint64 {
+	if v == nil {
+		return nil
+	}
+	u := uint64(*v)
+	return &u
+}
@@ -0,0 +1,128 @@
+package adapters
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/parquet-go/parquet-go"
+)
+
+var exportedResults = []domain.DutyResult{
+	{Type: domain.DutyTypeAttestation, ValidatorIndex: 7, Epoch: 10, Slot: 330, CommitteeIndex: 5,
+		Outcome: domain.OutcomeSuccess, InclusionSlot: 332, Head: domain.VoteWrong, Target: domain.VoteCorrect,
+		Effectiveness: domain.Effectiveness{Earned: 40, Possible: 54}, NetworkDegraded: true},
+	{Type: domain.DutyTypeProposal, ValidatorIndex: 8, Epoch: 10, Slot: 331,
+		Outcome: domain.OutcomeMissed, Reason: "no block, \"orphaned\""},
+	{Type: domain.DutyTypeAttestation, ValidatorIndex: 9, Epoch: 11, Slot: 360, CommitteeIndex: 0,
+		Outcome: domain.OutcomeUnknown, Reason: "block 361 unavailable", MissedReward: 11_500},
+}
+
+// storeAndExport stores exportedResults in a file store, reads them back
+// and writes them with w.
+func storeAndExport(t *testing.T, w ports.ResultRowWriter) {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := NewFileResultStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, epoch := range []domain.Epoch{10, 11} {
+		ew, err := store.BeginEpoch(epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range exportedResults {
+			if r.Epoch == epoch {
+				if err := ew.Write(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := ew.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reader := NewFileResultReader(dir)
+	epochs, err := reader.Epochs()
+	if err != nil || !reflect.DeepEqual(epochs, []domain.Epoch{10, 11}) {
+		t.Fatalf("stored epochs %v (%v), want [10 11]", epochs, err)
+	}
+	for _, epoch := range epochs {
+		err := reader.ReadEpoch(epoch, func(r domain.DutyResult) error {
+			return w.Write(domain.NewResultRow(r, "g", domain.MainnetGenesisTime))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCSVResultWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewCSVResultWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storeAndExport(t, w)
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		ResultColumns,
+		{"10", "330", "2020-12-01T13:06:23Z", "7", "g", "attestation", "5", "success", "332", "2", "", "wrong", "correct", "0.7407407407407407", "54", "true", "0"},
+		{"10", "331", "2020-12-01T13:06:35Z", "8", "g", "proposal", "", "missed", "", "", "no block, \"orphaned\"", "", "", "", "", "false", "0"},
+		{"11", "360", "2020-12-01T13:12:23Z", "9", "g", "attestation", "0", "unknown", "", "", "block 361 unavailable", "", "", "", "", "false", "11500"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got\n%q\nwant\n%q", records, want)
+	}
+}
+
+func TestParquetResultWriter(t *testing.T) {
+	var buf bytes.Buffer
+	storeAndExport(t, NewParquetResultWriter(&buf))
+
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var columns []string
+	for _, f := range file.Schema().Fields() {
+		columns = append(columns, f.Name())
+	}
+	if !reflect.DeepEqual(columns, ResultColumns) {
+		t.Errorf("columns %v, want %v", columns, ResultColumns)
+	}
+
+	rows, err := parquet.Read[parquetResultRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(exportedResults) {
+		t.Fatalf("read %d rows, want %d", len(rows), len(exportedResults))
+	}
+	first := rows[0]
+	if first.CommitteeIndex == nil || *first.CommitteeIndex != 5 || first.InclusionDelay == nil || *first.InclusionDelay != 2 ||
+		!first.SlotTime.Equal(time.Date(2020, 12, 1, 13, 6, 23, 0, time.UTC)) || first.HeadVote != "wrong" ||
+		first.EffectivenessWeight == nil || *first.EffectivenessWeight != 54 || !first.NetworkDegraded {
+		t.Errorf("first row %+v", first)
+	}
+	if second := rows[1]; second.CommitteeIndex != nil || second.InclusionSlot != nil || second.Reason != exportedResults[1].Reason ||
+		second.Effectiveness != nil || second.NetworkDegraded {
+		t.Errorf("second row %+v", second)
+	}
+	if third := rows[2]; third.MissedReward != 11_500 {
+		t.Errorf("third row %+v", third)
+	}
+}
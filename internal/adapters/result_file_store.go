@@ -0,0 +1,216 @@
+package adapters
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+const (
+	resultFilePrefix = "epoch-"
+	resultFileSuffix = ".jsonl.gz"
+	resultTmpPrefix  = ".tmp-"
+)
+
+// fileResultStore implements ports.ResultStore with one gzip-compressed JSON
+// Lines file per epoch, <dir>/epoch-<N>.jsonl.gz. Results are streamed to a
+// temporary file that is renamed into place on commit.
+type fileResultStore struct {
+	dir       string
+	retention int
+}
+
+// NewFileResultStore opens the result store in dir, keeping the results of
+// the latest retention epochs (0 keeps everything). Temporary files left by
+// a crash are removed.
+func NewFileResultStore(dir string, retention int) (ports.ResultStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating results directory: %w", err)
+	}
+	stale, err := filepath.Glob(filepath.Join(dir, resultTmpPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range stale {
+		logger.Warn("Removing incomplete results file %s", path)
+		os.Remove(path)
+	}
+	return &fileResultStore{dir: dir, retention: retention}, nil
+}
+
+func (s *fileResultStore) path(epoch domain.Epoch) string {
+	return resultFilePath(s.dir, epoch)
+}
+
+func resultFilePath(dir string, epoch domain.Epoch) string {
+	return epochFilePath(dir, resultFilePrefix, epoch, resultFileSuffix)
+}
+
+// epochs returns the committed epochs in ascending order.
+func (s *fileResultStore) epochs() ([]domain.Epoch, error) {
+	return epochFileEpochs(s.dir, resultFilePrefix, resultFileSuffix)
+}
+
+// epochFilePath is the path of the file of epoch in a store of per-epoch
+// files named <prefix><N><suffix>.
+func epochFilePath(dir, prefix string, epoch domain.Epoch, suffix string) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%d%s", prefix, epoch, suffix))
+}
+
+// epochFileEpochs returns the epochs of the files named <prefix><N><suffix>
+// in dir, in ascending order.
+func epochFileEpochs(dir, prefix, suffix string) ([]domain.Epoch, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var epochs []domain.Epoch
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		var epoch uint64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix), "%d", &epoch); err != nil {
+			continue
+		}
+		epochs = append(epochs, domain.Epoch(epoch))
+	}
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+	return epochs, nil
+}
+
+// pruneEpochFiles removes the files of the oldest epochs beyond retention
+// (0 keeps everything). what names the files in warnings.
+func pruneEpochFiles(dir, prefix, suffix string, retention int, what string) {
+	if retention <= 0 {
+		return
+	}
+	epochs, err := epochFileEpochs(dir, prefix, suffix)
+	if err != nil {
+		logger.Warn("Could not list %s for pruning: %v", what, err)
+		return
+	}
+	for len(epochs) > retention {
+		if err := os.Remove(epochFilePath(dir, prefix, epochs[0], suffix)); err != nil {
+			logger.Warn("Could not prune %s of epoch %d: %v", what, epochs[0], err)
+		}
+		epochs = epochs[1:]
+	}
+}
+
+func (s *fileResultStore) LastEpoch() (domain.Epoch, bool, error) {
+	epochs, err := s.epochs()
+	if err != nil || len(epochs) == 0 {
+		return 0, false, err
+	}
+	return epochs[len(epochs)-1], true, nil
+}
+
+func (s *fileResultStore) BeginEpoch(epoch domain.Epoch) (ports.EpochResultWriter, error) {
+	f, err := os.CreateTemp(s.dir, fmt.Sprintf("%s%d-*", resultTmpPrefix, epoch))
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	return &fileEpochWriter{store: s, epoch: epoch, file: f, gz: gz, enc: json.NewEncoder(gz)}, nil
+}
+
+// prune removes the oldest epochs beyond the retention.
+func (s *fileResultStore) prune() {
+	pruneEpochFiles(s.dir, resultFilePrefix, resultFileSuffix, s.retention, "results")
+}
+
+type fileEpochWriter struct {
+	store *fileResultStore
+	epoch domain.Epoch
+	file  *os.File
+	gz    *gzip.Writer
+	enc   *json.Encoder
+}
+
+func (w *fileEpochWriter) Write(r domain.DutyResult) error {
+	return w.enc.Encode(r)
+}
+
+// Commit flushes and syncs the temporary file, then atomically renames it
+// into place.
+func (w *fileEpochWriter) Commit() error {
+	if err := w.gz.Close(); err != nil {
+		w.Rollback()
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		w.Rollback()
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+	if err := os.Rename(w.file.Name(), w.store.path(w.epoch)); err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+	w.store.prune()
+	return nil
+}
+
+// Rollback discards everything written for the epoch.
+func (w *fileEpochWriter) Rollback() error {
+	w.file.Close()
+	return os.Remove(w.file.Name())
+}
+
+// fileResultReader implements ports.ResultReader over the files of a
+// fileResultStore.
+type fileResultReader struct {
+	dir string
+}
+
+// NewFileResultReader reads the results stored in dir by NewFileResultStore.
+// Unlike the store it does not create the directory or clean it up.
+func NewFileResultReader(dir string) ports.ResultReader {
+	return &fileResultReader{dir: dir}
+}
+
+func (r *fileResultReader) Epochs() ([]domain.Epoch, error) {
+	epochs, err := epochFileEpochs(r.dir, resultFilePrefix, resultFileSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return epochs, err
+}
+
+func (r *fileResultReader) ReadEpoch(epoch domain.Epoch, fn func(domain.DutyResult) error) error {
+	f, err := os.Open(resultFilePath(r.dir, epoch))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("reading results of epoch %d: %w", epoch, err)
+	}
+	dec := json.NewDecoder(gz)
+	for dec.More() {
+		var result domain.DutyResult
+		if err := dec.Decode(&result); err != nil {
+			return fmt.Errorf("reading results of epoch %d: %w", epoch, err)
+		}
+		if err := fn(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// webhookQueueSize is how many batches can wait for delivery before Notify
+// starts rejecting new ones.
+const webhookQueueSize = 64
+
+// webhookNotifier implements ports.Notifier by POSTing each batch of
+// notifications as JSON to a URL: {"notifications": [...]}. Batches are
+// queued and delivered in order by a background goroutine, so a slow webhook
+// does not hold up the checker.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+
+	mu     sync.Mutex
+	closed bool
+	queue  chan []domain.Notification
+	done   chan struct{}
+}
+
+// NewWebhookNotifier creates a notifier posting to url.
+func NewWebhookNotifier(url string) ports.Notifier {
+	w := &webhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan []domain.Notification, webhookQueueSize),
+		done:   make(chan struct{}),
+	}
+	go w.deliver()
+	return w
+}
+
+func (w *webhookNotifier) Notify(_ context.Context, notifications []domain.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errors.New("webhook notifier is closed")
+	}
+	select {
+	case w.queue <- notifications:
+		return nil
+	default:
+		return fmt.Errorf("webhook queue full, dropping %d notifications", len(notifications))
+	}
+}
+
+func (w *webhookNotifier) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d notification batches not delivered: %w", len(w.queue), ctx.Err())
+	}
+}
+
+func (w *webhookNotifier) deliver() {
+	defer close(w.done)
+	for notifications := range w.queue {
+		if err := w.post(notifications); err != nil {
+			logger.Error("Failed to send %d notifications: %v", len(notifications), err)
+		}
+	}
+}
+
+func (w *webhookNotifier) post(notifications []domain.Notification) error {
+	body, err := json.Marshal(struct {
+		Notifications []domain.Notification `json:"notifications"`
+	}{notifications})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package domain
+
+// SlotParticipation is how many members of the committees of a slot had
+// their attestation included on-chain within the inclusion window.
+type SlotParticipation struct {
+	Slot      Slot `json:"slot"`
+	Attesters int  `json:"attesters"`
+	Included  int  `json:"included"`
+	// Incomplete means a block of the slot's inclusion window could not be
+	// fetched, so Included may be short. Such slots are left out of the
+	// participation rates.
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// NetworkBaseline is how the whole network performed in an epoch, computed
+// from every attestation of the blocks that can include the epoch's
+// attestations, and from which of the epoch's slots have a block. It tells
+// the validators' own faults from those of the network.
+type NetworkBaseline struct {
+	Epoch Epoch `json:"epoch"`
+	// Participation is the share of all committee members of the epoch whose
+	// attestation was included, over the complete slots; nil if none is.
+	Participation *float64 `json:"participation"`
+	// ProposedSlots and MissedSlots count the slots of the epoch with and
+	// without a block; UnavailableSlots those whose block could not be
+	// fetched, left out of MissedSlotRate (nil if all are).
+	ProposedSlots    int      `json:"proposed_slots"`
+	MissedSlots      int      `json:"missed_slots"`
+	UnavailableSlots int      `json:"unavailable_slots,omitempty"`
+	MissedSlotRate   *float64 `json:"missed_slot_rate"`
+	// Slots holds the participation of each slot of the epoch, in order.
+	Slots []SlotParticipation `json:"slots"`
+}
+
+// NewNetworkBaseline computes the rates of an epoch's baseline from the
+// participation of its slots and the number of its slots with a block,
+// without one and unavailable.
+func NewNetworkBaseline(epoch Epoch, slots []SlotParticipation, proposed, missed, unavailable int) NetworkBaseline {
+	b := NetworkBaseline{
+		Epoch:            epoch,
+		ProposedSlots:    proposed,
+		MissedSlots:      missed,
+		UnavailableSlots: unavailable,
+		Slots:            slots,
+	}
+	attesters, included := 0, 0
+	for _, s := range slots {
+		if !s.Incomplete {
+			attesters += s.Attesters
+			included += s.Included
+		}
+	}
+	if attesters > 0 {
+		participation := float64(included) / float64(attesters)
+		b.Participation = &participation
+	}
+	if proposed+missed > 0 {
+		rate := float64(missed) / float64(proposed+missed)
+		b.MissedSlotRate = &rate
+	}
+	return b
+}
+
+// NetworkThresholds decide when the network counts as degraded: when the
+// participation of an epoch, or of one of its slots, is below
+// MinParticipation, or the missed-slot rate of the epoch above
+// MaxMissedSlotRate.
+type NetworkThresholds struct {
+	MinParticipation  float64
+	MaxMissedSlotRate float64
+}
+
+// DefaultNetworkThresholds are well below what a healthy network does:
+// mainnet usually includes over 95% of attestations and misses 1-2% of slots.
+var DefaultNetworkThresholds = NetworkThresholds{MinParticipation: 0.9, MaxMissedSlotRate: 0.1}
+
+// DegradedAt tells whether the network as a whole was degraded at slot, a
+// slot of the baseline's epoch: the whole epoch was, or the attesters of that
+// slot were.
+func (b NetworkBaseline) DegradedAt(slot Slot, t NetworkThresholds) bool {
+	if b.Participation != nil && *b.Participation < t.MinParticipation {
+		return true
+	}
+	if b.MissedSlotRate != nil && *b.MissedSlotRate > t.MaxMissedSlotRate {
+		return true
+	}
+	for _, s := range b.Slots {
+		if s.Slot == slot && !s.Incomplete && s.Attesters > 0 {
+			return float64(s.Included)/float64(s.Attesters) < t.MinParticipation
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package domain
+
+// Vote tells whether an included attestation voted for the canonical chain.
+type Vote string
+
+const (
+	VoteCorrect Vote = "correct"
+	VoteWrong   Vote = "wrong"
+	// VoteUnknown means the canonical root could not be determined, e.g.
+	// because a block after the slot could not be fetched.
+	VoteUnknown Vote = ""
+)
+
+// Effectiveness weights, taken from the participation weights of the
+// protocol since Altair (out of a total of 64). The protocol uses them to
+// share each epoch's rewards out between the duties of the whole network;
+// EpochEffectiveness applies them per duty instead (see there).
+const (
+	WeightSource        = 14
+	WeightTarget        = 26
+	WeightHead          = 14
+	WeightProposal      = 8
+	WeightSyncCommittee = 2
+)
+
+// Timeliness limits, in slots of inclusion delay, for the source and head
+// components of an attestation. The target counts within the whole
+// inclusion window.
+const (
+	timelySourceDelay = 5
+	timelyHeadDelay   = 1
+)
+
+// Effectiveness is the reward weight a validator earned in an epoch out of
+// the weight it could have earned. Keeping both, rather than their ratio,
+// lets scores be summed over epochs and validators.
+type Effectiveness struct {
+	Earned   uint32 `json:"earned"`
+	Possible uint32 `json:"possible"`
+}
+
+// Score is Earned / Possible, between 0 and 1, or 0 if nothing was possible.
+func (e Effectiveness) Score() float64 {
+	if e.Possible == 0 {
+		return 0
+	}
+	return float64(e.Earned) / float64(e.Possible)
+}
+
+// EpochEffectiveness scores a validator's epoch from its attestation result
+// and its proposal and sync committee results in the same epoch, weighting
+// each component with the protocol's participation weight:
+//
+//	source          14  attestation included within 5 slots
+//	target          26  attestation included with the correct target
+//	head            14  attestation included in the next slot with the correct head
+//	proposal         8  for each proposal duty, block proposed
+//	sync committee   2  for each block to sign, signature included
+//
+// The score is a heuristic, not the share of rewards earned. The attestation
+// components are in proportion to what they pay, but the proposal and sync
+// committee fractions of the network's rewards are applied here per proposal
+// and per block to sign, so both weigh far less than they pay: a sync
+// committee member earns about twice an epoch's attestation reward in every
+// slot, and a proposal more still. DutyResult.MissedReward has the Gwei.
+//
+// A missed attestation is possible source, target and head weight, none
+// earned. Components that could not be decided count neither as earned nor
+// as possible: all of the attestation when its outcome is unknown, the head
+// or target when the vote could not be checked, and unknown proposals and
+// sync committee signatures.
+func EpochEffectiveness(attestation DutyResult, others []DutyResult) Effectiveness {
+	var e Effectiveness
+	switch attestation.Outcome {
+	case OutcomeSuccess:
+		delay := attestation.InclusionSlot - attestation.Slot
+		e.Possible += WeightSource
+		if delay <= timelySourceDelay {
+			e.Earned += WeightSource
+		}
+		if attestation.Target != VoteUnknown {
+			e.Possible += WeightTarget
+			if attestation.Target == VoteCorrect {
+				e.Earned += WeightTarget
+			}
+		}
+		if attestation.Head != VoteUnknown {
+			e.Possible += WeightHead
+			if attestation.Head == VoteCorrect && delay <= timelyHeadDelay {
+				e.Earned += WeightHead
+			}
+		}
+	case OutcomeMissed:
+		e.Possible += WeightSource + WeightTarget + WeightHead
+	}
+	for _, r := range others {
+		weight := uint32(WeightProposal)
+		if r.Type == DutyTypeSyncCommittee {
+			weight = WeightSyncCommittee
+		}
+		switch r.Outcome {
+		case OutcomeSuccess:
+			e.Possible += weight
+			e.Earned += weight
+		case OutcomeMissed:
+			e.Possible += weight
+		}
+	}
+	return e
+}
+
+// EffectivenessRank is the effectiveness of one validator, or of the
+// validators sharing a group or label, summed over a range of epochs.
+type EffectivenessRank struct {
+	Key        string `json:"key"`
+	Validators int    `json:"validators"`
+	// Scored counts the validator epochs with a score.
+	Scored   int     `json:"scored"`
+	Earned   uint64  `json:"earned"`
+	Possible uint64  `json:"possible"`
+	Score    float64 `json:"score"`
+}
+
+// EffectivenessRanking ranks validators or groups of validators by their
+// effectiveness over [FromEpoch, ToEpoch], best first.
+type EffectivenessRanking struct {
+	FromEpoch Epoch               `json:"from_epoch"`
+	ToEpoch   Epoch               `json:"to_epoch"`
+	By        string              `json:"by"`
+	Ranks     []EffectivenessRank `json:"ranks"`
+}
@@ -0,0 +1,54 @@
+package domain
+
+import "testing"
+
+func TestEpochEffectiveness(t *testing.T) {
+	included := func(delay Slot, head, target Vote) DutyResult {
+		return DutyResult{Type: DutyTypeAttestation, Slot: 100, Outcome: OutcomeSuccess,
+			InclusionSlot: 100 + delay, Head: head, Target: target}
+	}
+	proposal := func(outcome DutyOutcome) DutyResult {
+		return DutyResult{Type: DutyTypeProposal, Outcome: outcome}
+	}
+	sync := func(outcome DutyOutcome) DutyResult {
+		return DutyResult{Type: DutyTypeSyncCommittee, Outcome: outcome}
+	}
+	tests := []struct {
+		name        string
+		attestation DutyResult
+		others      []DutyResult
+		want        Effectiveness
+	}{
+		{"optimal", included(1, VoteCorrect, VoteCorrect), nil, Effectiveness{Earned: 54, Possible: 54}},
+		{"late head", included(2, VoteCorrect, VoteCorrect), nil, Effectiveness{Earned: 40, Possible: 54}},
+		{"wrong head", included(1, VoteWrong, VoteCorrect), nil, Effectiveness{Earned: 40, Possible: 54}},
+		{"wrong target", included(1, VoteCorrect, VoteWrong), nil, Effectiveness{Earned: 28, Possible: 54}},
+		{"source too late", included(6, VoteCorrect, VoteCorrect), nil, Effectiveness{Earned: 26, Possible: 54}},
+		{"votes unknown", included(1, VoteUnknown, VoteUnknown), nil, Effectiveness{Earned: 14, Possible: 14}},
+		{"missed", DutyResult{Outcome: OutcomeMissed}, nil, Effectiveness{Possible: 54}},
+		{
+			"proposals", included(1, VoteCorrect, VoteCorrect),
+			[]DutyResult{proposal(OutcomeSuccess), proposal(OutcomeMissed), proposal(OutcomeUnknown)},
+			Effectiveness{Earned: 62, Possible: 70},
+		},
+		{
+			"unknown attestation", DutyResult{Outcome: OutcomeUnknown},
+			[]DutyResult{proposal(OutcomeSuccess)}, Effectiveness{Earned: 8, Possible: 8},
+		},
+		{
+			"sync committee", included(1, VoteCorrect, VoteCorrect),
+			[]DutyResult{sync(OutcomeSuccess), sync(OutcomeSuccess), sync(OutcomeMissed), sync(OutcomeUnknown)},
+			Effectiveness{Earned: 58, Possible: 60},
+		},
+		{
+			"proposal and sync committee", DutyResult{Outcome: OutcomeMissed},
+			[]DutyResult{proposal(OutcomeSuccess), sync(OutcomeSuccess), sync(OutcomeMissed)},
+			Effectiveness{Earned: 10, Possible: 66},
+		},
+	}
+	for _, tt := range tests {
+		if got := EpochEffectiveness(tt.attestation, tt.others); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
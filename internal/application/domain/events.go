@@ -0,0 +1,20 @@
+package domain
+
+// ChainEventType identifies a beacon node event the checker listens to.
+type ChainEventType string
+
+const (
+	ChainEventFinalizedCheckpoint ChainEventType = "finalized_checkpoint"
+	ChainEventBlock               ChainEventType = "block"
+	ChainEventChainReorg          ChainEventType = "chain_reorg"
+)
+
+// ChainEvent is one event from the beacon node event stream. Only the fields
+// relevant to its type are set: Epoch for a finalized checkpoint, Slot for a
+// block, and Slot, Epoch and Depth for a reorg.
+type ChainEvent struct {
+	Type  ChainEventType
+	Epoch Epoch
+	Slot  Slot
+	Depth uint64
+}
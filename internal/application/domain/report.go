@@ -0,0 +1,77 @@
+package domain
+
+import "time"
+
+// PerformanceReport summarizes the stored duty outcomes of a period per
+// group, e.g. for a client's uptime statement.
+type PerformanceReport struct {
+	// From and To bound the slot times of the duties covered, To excluded.
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	GeneratedAt time.Time `json:"generated_at"`
+	// EpochsExpected is the number of epochs in the period and EpochsCovered
+	// how many of them have stored results. Fewer covered epochs mean the
+	// service was down or results were pruned.
+	EpochsExpected int `json:"epochs_expected"`
+	EpochsCovered  int `json:"epochs_covered"`
+	// Groups are sorted by name, with ungrouped validators (Name "") last.
+	Groups []GroupReport `json:"groups"`
+	// Total sums all groups.
+	Total GroupReport `json:"total"`
+}
+
+// GroupReport is the performance of the validators of one group.
+type GroupReport struct {
+	Name          string             `json:"name"`
+	Validators    int                `json:"validators"`
+	Attestations  AttestationStats   `json:"attestations"`
+	Proposals     ProposalStats      `json:"proposals"`
+	SyncCommittee SyncCommitteeStats `json:"sync_committee"`
+	// MissedRewards sums the rewards missed on attestations and sync
+	// committee signatures, in Gwei (see DutyResult.MissedReward). Missed
+	// proposals are not counted.
+	MissedRewards int64 `json:"missed_rewards"`
+}
+
+// AttestationStats counts attestation outcomes. Unknown outcomes are left
+// out of the rates: they say nothing about the validator.
+type AttestationStats struct {
+	Duties   int `json:"duties"`
+	Included int `json:"included"`
+	Missed   int `json:"missed"`
+	Unknown  int `json:"unknown"`
+	// InclusionRate is Included / (Included + Missed), 0 without such duties.
+	InclusionRate float64 `json:"inclusion_rate"`
+	// AverageInclusionDelay is in slots over the included attestations, and
+	// OptimalInclusions counts those included in the next slot.
+	AverageInclusionDelay float64 `json:"average_inclusion_delay"`
+	OptimalInclusions     int     `json:"optimal_inclusions"`
+}
+
+// ProposalStats counts block proposal outcomes, listing the missed ones.
+type ProposalStats struct {
+	Duties   int          `json:"duties"`
+	Proposed int          `json:"proposed"`
+	Missed   int          `json:"missed"`
+	Unknown  int          `json:"unknown"`
+	Misses   []MissedDuty `json:"misses"`
+}
+
+// SyncCommitteeStats counts the blocks sync committee members had to sign
+// (see DutyTypeSyncCommittee).
+type SyncCommitteeStats struct {
+	Duties   int `json:"duties"`
+	Included int `json:"included"`
+	Missed   int `json:"missed"`
+	Unknown  int `json:"unknown"`
+	// ParticipationRate is Included / (Included + Missed), 0 without such
+	// duties.
+	ParticipationRate float64 `json:"participation_rate"`
+}
+
+// MissedDuty identifies a missed duty in a report.
+type MissedDuty struct {
+	Slot           Slot           `json:"slot"`
+	ValidatorIndex ValidatorIndex `json:"validator_index"`
+	Reason         string         `json:"reason,omitempty"`
+}
@@ -0,0 +1,217 @@
+package domain
+
+import "time"
+
+// DutyType identifies the kind of duty a result refers to.
+type DutyType string
+
+const (
+	DutyTypeAttestation DutyType = "attestation"
+	DutyTypeProposal    DutyType = "proposal"
+	// DutyTypeSyncCommittee is a sync committee member's signature, due in
+	// the sync aggregate of the block at the result's slot.
+	DutyTypeSyncCommittee DutyType = "sync_committee"
+)
+
+// DutyOutcome is the verdict for a single duty.
+type DutyOutcome string
+
+const (
+	OutcomeSuccess DutyOutcome = "success"
+	OutcomeMissed  DutyOutcome = "missed"
+	// OutcomeUnknown means the data needed to decide could not be obtained
+	// (e.g. the beacon node kept timing out). It is never counted as a miss.
+	OutcomeUnknown DutyOutcome = "unknown"
+)
+
+// DutyResult is the outcome of checking one duty of one validator.
+type DutyResult struct {
+	Type           DutyType       `json:"type"`
+	ValidatorIndex ValidatorIndex `json:"validator_index"`
+	Epoch          Epoch          `json:"epoch"`
+	Slot           Slot           `json:"slot"`
+	CommitteeIndex CommitteeIndex `json:"committee_index"` // attestations only
+
+	Outcome DutyOutcome `json:"outcome"`
+
+	// InclusionSlot is the slot of the block that included the attestation,
+	// or the proposed block's slot. Zero when the duty was missed.
+	InclusionSlot Slot `json:"inclusion_slot"`
+
+	// Head and Target tell whether an included attestation voted for the
+	// canonical head block and epoch checkpoint.
+	Head   Vote `json:"head,omitempty"`
+	Target Vote `json:"target,omitempty"`
+
+	// Effectiveness is the validator's score for the epoch, proposals and
+	// sync committee signatures included (see EpochEffectiveness). It is
+	// only set on attestations.
+	Effectiveness Effectiveness `json:"effectiveness,omitzero"`
+
+	// MissedReward is the reward, in Gwei, lost on the duty: the ideal
+	// reward minus the one paid, penalties included. It is only set on
+	// finalized attestations and sync committee misses, from the beacon
+	// node's rewards API.
+	MissedReward int64 `json:"missed_reward,omitempty"`
+
+	// NetworkDegraded is set when the network as a whole was degraded at the
+	// duty's slot (see NetworkBaseline.DegradedAt), so a miss is likely not
+	// the validator's own fault.
+	NetworkDegraded bool `json:"network_degraded,omitempty"`
+
+	// Reason explains a missed or unknown outcome.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Discrepancy records a disagreement between beacon nodes about data that
+// should be identical on every client (duties, committees, block contents).
+// Responses holds what each node returned, keyed by node name.
+type Discrepancy struct {
+	Epoch          Epoch                  `json:"epoch"`
+	Kind           string                 `json:"kind"`
+	ValidatorIndex *ValidatorIndex        `json:"validator_index,omitempty"`
+	Slot           *Slot                  `json:"slot,omitempty"`
+	CommitteeIndex *CommitteeIndex        `json:"committee_index,omitempty"`
+	Responses      map[string]interface{} `json:"responses"`
+}
+
+// Discrepancy kinds.
+const (
+	DiscrepancyProposerDuties = "proposer_duties"
+	DiscrepancyBlockProposed  = "block_proposed"
+	DiscrepancyAttesterDuty   = "attester_duty"
+	DiscrepancyCommitteeSize  = "committee_size"
+	DiscrepancyAttestation    = "attestation_presence"
+	// DiscrepancyAttestationInclusion is an attestation included on every
+	// node, but in different blocks or with different head or target votes.
+	DiscrepancyAttestationInclusion = "attestation_inclusion"
+	// DiscrepancySyncCommittee is a sync committee member whose duty, or
+	// signature in a block, differs between nodes.
+	DiscrepancySyncCommittee = "sync_committee"
+)
+
+// NotificationKind identifies why a notification was sent.
+type NotificationKind string
+
+const (
+	// NotificationProvisionalMiss is a duty found missed or unknown on the
+	// head chain, before finalization.
+	NotificationProvisionalMiss NotificationKind = "provisional_miss"
+	// NotificationCorrection is a finalized result that differs from the
+	// provisional one, e.g. because a reorg changed the chain.
+	NotificationCorrection NotificationKind = "correction"
+	// NotificationNonFinality means the network stopped finalizing for longer
+	// than the configured threshold; NotificationFinalityResumed ends it.
+	NotificationNonFinality     NotificationKind = "non_finality"
+	NotificationFinalityResumed NotificationKind = "finality_resumed"
+	// NotificationPerformanceReport carries a scheduled PerformanceReport.
+	NotificationPerformanceReport NotificationKind = "performance_report"
+	// NotificationCorrelatedFailure is a CorrelatedFailure, sent instead of
+	// the alerts of its individual misses.
+	NotificationCorrelatedFailure NotificationKind = "correlated_failure"
+)
+
+// CorrelatedFailure is a set of validators sharing a label that all missed
+// their duties at the same slot, which points to a shared machine, signer or
+// beacon node rather than to the validators.
+type CorrelatedFailure struct {
+	Epoch Epoch `json:"epoch"`
+	Slot  Slot  `json:"slot"`
+	// Label and Value name the affected group, e.g. machine=node-01. Both are
+	// empty when the validators share nothing but the slot.
+	Label      string           `json:"label,omitempty"`
+	Value      string           `json:"value,omitempty"`
+	Validators []ValidatorIndex `json:"validators"`
+	// NetworkDegraded is set when the network as a whole was degraded at
+	// the slot (see NetworkBaseline).
+	NetworkDegraded bool `json:"network_degraded,omitempty"`
+}
+
+// CorrelationPolicy configures correlated-miss detection. When at least
+// MinValidators validators with the same value of a label miss their duties
+// at the same slot, they are reported as one correlated failure instead of
+// one alert per duty. Labels are the label keys grouped on, in order of
+// precedence: a miss only counts towards the first group that reaches the
+// minimum. The misses left at a slot are then grouped by the slot alone.
+// MinValidators 0 disables the detection.
+type CorrelationPolicy struct {
+	MinValidators int
+	Labels        []string
+}
+
+// DefaultCorrelationPolicy reports 5 or more validators missing the same slot
+// together, grouped by machine first, then signer, then client.
+var DefaultCorrelationPolicy = CorrelationPolicy{MinValidators: 5, Labels: []string{"machine", "signer", "client"}}
+
+// Notification is an alert sent to the configured notifier. Duty alerts
+// carry the Result; chain-level alerts only a Message.
+type Notification struct {
+	Kind    NotificationKind `json:"kind"`
+	Message string           `json:"message,omitempty"`
+	Result  *DutyResult      `json:"result,omitempty"`
+	// Provisional is the head-chain result a correction replaces.
+	Provisional *DutyResult `json:"provisional,omitempty"`
+	// Report is set for NotificationPerformanceReport.
+	Report *PerformanceReport `json:"report,omitempty"`
+	// Correlated is set for NotificationCorrelatedFailure.
+	Correlated *CorrelatedFailure `json:"correlated,omitempty"`
+}
+
+// ResultRow is a DutyResult flattened for exports, with the columns analysts
+// need that are not stored with the result. Nil pointers are empty cells.
+type ResultRow struct {
+	Epoch          Epoch
+	Slot           Slot
+	SlotTime       time.Time
+	ValidatorIndex ValidatorIndex
+	Group          string
+	Duty           DutyType
+	// CommitteeIndex is only set for attestations.
+	CommitteeIndex *CommitteeIndex
+	Outcome        DutyOutcome
+	// InclusionSlot and InclusionDelay (slots after the duty slot) are only
+	// set for successful duties.
+	InclusionSlot  *Slot
+	InclusionDelay *uint64
+	Reason         string
+	HeadVote       Vote
+	TargetVote     Vote
+	// Effectiveness is only set on attestations that could be scored.
+	Effectiveness *Effectiveness
+	// MissedReward is in Gwei (see DutyResult.MissedReward).
+	MissedReward int64
+	// NetworkDegraded tells that the network as a whole struggled at the slot.
+	NetworkDegraded bool
+}
+
+// NewResultRow flattens r for a chain started at genesis.
+func NewResultRow(r DutyResult, group string, genesis time.Time) ResultRow {
+	row := ResultRow{
+		Epoch:           r.Epoch,
+		Slot:            r.Slot,
+		SlotTime:        SlotTime(genesis, r.Slot),
+		ValidatorIndex:  r.ValidatorIndex,
+		Group:           group,
+		Duty:            r.Type,
+		Outcome:         r.Outcome,
+		Reason:          r.Reason,
+		HeadVote:        r.Head,
+		TargetVote:      r.Target,
+		MissedReward:    r.MissedReward,
+		NetworkDegraded: r.NetworkDegraded,
+	}
+	if r.Effectiveness.Possible > 0 {
+		effectiveness := r.Effectiveness
+		row.Effectiveness = &effectiveness
+	}
+	if r.Type == DutyTypeAttestation {
+		committee := r.CommitteeIndex
+		row.CommitteeIndex = &committee
+	}
+	if r.Outcome == OutcomeSuccess {
+		inclusion := r.InclusionSlot
+		delay := uint64(r.InclusionSlot - r.Slot)
+		row.InclusionSlot, row.InclusionDelay = &inclusion, &delay
+	}
+	return row
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+const (
+	// SecondsPerSlot is the consensus SECONDS_PER_SLOT.
+	SecondsPerSlot = 12
+	slotsPerEpoch  = 32
+)
+
+// MainnetGenesisTime is the genesis time of Ethereum mainnet.
+var MainnetGenesisTime = time.Unix(1606824023, 0).UTC()
+
+// SlotTime returns the start time of slot on a chain started at genesis.
+func SlotTime(genesis time.Time, slot Slot) time.Time {
+	return genesis.Add(time.Duration(slot) * SecondsPerSlot * time.Second)
+}
+
+// EpochAt returns the epoch in progress at t on a chain started at genesis,
+// or 0 before genesis.
+func EpochAt(genesis, t time.Time) Epoch {
+	if t.Before(genesis) {
+		return 0
+	}
+	return Epoch(t.Sub(genesis) / (SecondsPerSlot * time.Second) / slotsPerEpoch)
+}
@@ -1,11 +1,66 @@
 package domain
 
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// DefaultConcurrency is the number of beacon requests a fan-out (block
+// preload, proposal checks) keeps in flight by default.
+const DefaultConcurrency = 8
+
 // Basic consensus types
 type Epoch uint64
 type Slot uint64
 type ValidatorIndex uint64
 type CommitteeIndex uint64
 
+// Root is a block or checkpoint root. It is written as 0x-prefixed hex.
+type Root [32]byte
+
+// IsZero tells whether the root is unset, e.g. in fixtures recorded before
+// roots were kept.
+func (r Root) IsZero() bool {
+	return r == Root{}
+}
+
+func (r Root) String() string {
+	return "0x" + hex.EncodeToString(r[:])
+}
+
+func (r Root) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Root) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(strings.TrimPrefix(string(text), "0x"))
+	if err != nil || len(b) != len(r) {
+		return fmt.Errorf("invalid root %q", text)
+	}
+	copy(r[:], b)
+	return nil
+}
+
+// FinalityCheckpoints are the latest justified and finalized epochs of the
+// node's head state. FinalizedRoot is the root of the finalized checkpoint
+// block: the last block at or before the first slot of Finalized.
+type FinalityCheckpoints struct {
+	Justified     Epoch
+	Finalized     Epoch
+	FinalizedRoot Root
+}
+
+// BeaconNodeStatus is the last known health of one beacon node.
+type BeaconNodeStatus struct {
+	Name           string `json:"name"`
+	Reachable      bool   `json:"reachable"`
+	Syncing        bool   `json:"syncing"`
+	HeadSlot       Slot   `json:"head_slot"`
+	FinalizedEpoch Epoch  `json:"finalized_epoch"`
+	Peers          int    `json:"peers"`
+}
+
 // ProposerDuty describes a scheduled block proposal for a validator.
 type ProposerDuty struct {
 	ValidatorIndex ValidatorIndex
@@ -22,6 +77,22 @@ type ValidatorDuty struct {
 	CommitteesAtSlot      uint64 // NEW electra: number of committees in this slot
 }
 
+// SyncCommitteeDuty describes a validator's membership of the sync
+// committee of an epoch: its positions in the committee, whose bits it
+// signs in the sync aggregate of every block.
+type SyncCommitteeDuty struct {
+	ValidatorIndex ValidatorIndex
+	Positions      []uint64
+}
+
+// ValidatorReward is what a validator was paid for a duty and the most it
+// could have been paid, in Gwei. Actual is negative for a penalty.
+type ValidatorReward struct {
+	ValidatorIndex ValidatorIndex
+	Ideal          int64
+	Actual         int64
+}
+
 // Attestation is a simplified representation of a beacon block attestation
 // sufficient for us to detect if a validator attested or not.
 type Attestation struct {
@@ -33,6 +104,11 @@ type Attestation struct {
 
 	// Bitfield of which validators (across all aggregated committees) participated.
 	AggregationBits []byte
+
+	// Head and target votes: the block root at DataSlot and the checkpoint
+	// root of its epoch, as seen by the attesters.
+	BeaconBlockRoot Root
+	TargetRoot      Root
 }
 
 // EpochCommittees maps:
@@ -41,3 +117,24 @@ type Attestation struct {
 type EpochCommittees map[Slot]map[CommitteeIndex][]ValidatorIndex
 
 type CommitteeSizeMap map[CommitteeIndex]int
+
+// SizeMap returns the size of each committee of the given slot.
+func (c EpochCommittees) SizeMap(slot Slot) CommitteeSizeMap {
+	sizeMap := make(CommitteeSizeMap, len(c[slot]))
+	for idx, members := range c[slot] {
+		sizeMap[idx] = len(members)
+	}
+	return sizeMap
+}
+
+// Block is the part of a beacon block the checker needs: its root, who
+// proposed it, the block it builds on, the attestations it includes and which
+// positions of the sync committee signed its parent (nil before Altair).
+type Block struct {
+	Slot              Slot
+	Root              Root
+	ProposerIndex     ValidatorIndex
+	ParentRoot        Root
+	Attestations      []Attestation
+	SyncCommitteeBits []byte
+}
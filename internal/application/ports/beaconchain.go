@@ -12,6 +12,12 @@ type BeaconChainAdapter interface {
 	// GetFinalizedEpoch returns the latest finalized epoch known by the node.
 	GetFinalizedEpoch(ctx context.Context) (domain.Epoch, error)
 
+	// GetFinalityCheckpoints returns the justified and finalized epochs of the head state.
+	GetFinalityCheckpoints(ctx context.Context) (domain.FinalityCheckpoints, error)
+
+	// GetHeadSlot returns the slot of the node's current head block.
+	GetHeadSlot(ctx context.Context) (domain.Slot, error)
+
 	// GetValidatorDutiesBatch returns attestation duties for the given validators in an epoch.
 	GetValidatorDutiesBatch(
 		ctx context.Context,
@@ -26,14 +32,38 @@ type BeaconChainAdapter interface {
 		indices []domain.ValidatorIndex,
 	) ([]domain.ProposerDuty, error)
 
-	// DidProposeBlock checks if a block was proposed at a specific slot (i.e. block exists).
-	DidProposeBlock(ctx context.Context, slot domain.Slot) (bool, error)
+	// GetSyncCommitteeDuties returns the sync committee positions of the given
+	// validators in an epoch; validators outside the committee have none.
+	GetSyncCommitteeDuties(
+		ctx context.Context,
+		epoch domain.Epoch,
+		indices []domain.ValidatorIndex,
+	) ([]domain.SyncCommitteeDuty, error)
+
+	// GetAttestationRewards returns the attestation rewards of the given
+	// validators for an epoch, which must be finalized or close to the head.
+	GetAttestationRewards(
+		ctx context.Context,
+		epoch domain.Epoch,
+		indices []domain.ValidatorIndex,
+	) ([]domain.ValidatorReward, error)
+
+	// GetSyncCommitteeRewards returns the sync committee rewards the block at
+	// the given slot paid to the given validators.
+	GetSyncCommitteeRewards(
+		ctx context.Context,
+		slot domain.Slot,
+		indices []domain.ValidatorIndex,
+	) ([]domain.ValidatorReward, error)
 
-	// GetBlockAttestations returns all attestations included in the block at the given slot.
-	GetBlockAttestations(ctx context.Context, slot domain.Slot) ([]domain.Attestation, error)
+	// GetBlock returns the block at the given slot, with its attestations.
+	// It returns an error wrapping ErrNotFound when no block was proposed at
+	// the slot (missed slot).
+	GetBlock(ctx context.Context, slot domain.Slot) (domain.Block, error)
 
-	// GetCommitteeSizeMap returns the size of each attestation committee for a specific slot.
-	GetCommitteeSizeMap(ctx context.Context, slot domain.Slot) (domain.CommitteeSizeMap, error)
+	// GetEpochCommittees returns the members of every attestation committee of
+	// every slot in the epoch, in a single call.
+	GetEpochCommittees(ctx context.Context, epoch domain.Epoch) (domain.EpochCommittees, error)
 
 	// GetAllActiveValidatorIndices returns all active validator indices known by the beacon node.
 	GetAllActiveValidatorIndices(ctx context.Context) ([]domain.ValidatorIndex, error)
@@ -0,0 +1,14 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+// DiscrepancyRecorder persists disagreements between beacon nodes found in
+// verification mode, so they can be investigated later.
+type DiscrepancyRecorder interface {
+	// RecordDiscrepancies stores all discrepancies found while checking an epoch.
+	RecordDiscrepancies(ctx context.Context, epoch domain.Epoch, discrepancies []domain.Discrepancy) error
+}
@@ -0,0 +1,30 @@
+package ports
+
+import "errors"
+
+// Error classes returned by BeaconChainAdapter implementations. Adapters wrap
+// the underlying error so that both errors.Is(err, ErrX) and the original
+// cause are available.
+var (
+	// ErrNotFound means the node answered that the data does not exist, e.g.
+	// no block at a missed slot. It is definitive.
+	ErrNotFound = errors.New("not found")
+
+	// ErrTimeout means the request did not complete in time. It is transient.
+	ErrTimeout = errors.New("timeout")
+
+	// ErrServer means the node failed to serve the request (5xx). It is transient.
+	ErrServer = errors.New("server error")
+
+	// ErrUnavailable means the node could not be reached. It is transient.
+	ErrUnavailable = errors.New("beacon node unavailable")
+
+	// ErrUnsupportedFork means the response is for a fork the adapter cannot
+	// decode. It is definitive until the adapter is updated.
+	ErrUnsupportedFork = errors.New("unsupported fork")
+)
+
+// IsTransient reports whether err may succeed if the call is retried.
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrServer) || errors.Is(err, ErrUnavailable)
+}
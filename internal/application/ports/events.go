@@ -0,0 +1,16 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+// ChainEventSource streams beacon chain events (new finalized checkpoints,
+// blocks, reorgs) so the checker can react without polling.
+type ChainEventSource interface {
+	// SubscribeChainEvents calls handler for every event until ctx is
+	// cancelled or the stream drops. It always returns a non-nil error
+	// explaining why the stream ended; callers are expected to reconnect.
+	SubscribeChainEvents(ctx context.Context, handler func(domain.ChainEvent)) error
+}
@@ -0,0 +1,9 @@
+package ports
+
+import "github.com/Marketen/duties-indexer/internal/application/domain"
+
+// BeaconNodeMonitor reports the health of the beacon nodes behind an adapter,
+// as of their last health check.
+type BeaconNodeMonitor interface {
+	NodeStatuses() []domain.BeaconNodeStatus
+}
@@ -0,0 +1,19 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+// Notifier delivers alerts about duties (provisional misses, corrections,
+// correlated failures) and the chain (non-finality) to an external system.
+type Notifier interface {
+	// Notify sends a batch of notifications, typically all those of one epoch.
+	// Implementations may deliver asynchronously.
+	Notify(ctx context.Context, notifications []domain.Notification) error
+
+	// Close delivers the notifications still pending, waiting at most until
+	// ctx is done, and releases the notifier.
+	Close(ctx context.Context) error
+}
@@ -0,0 +1,49 @@
+package ports
+
+import "github.com/Marketen/duties-indexer/internal/application/domain"
+
+// ResultStore persists the duty results of checked epochs. The results of an
+// epoch become visible all at once when its writer is committed, so an
+// interrupted check never leaves partial results behind. The latest committed
+// epoch doubles as the checkpoint the checker resumes from.
+type ResultStore interface {
+	// BeginEpoch starts writing the results of an epoch, replacing any
+	// previously committed results of the same epoch on commit.
+	BeginEpoch(epoch domain.Epoch) (EpochResultWriter, error)
+
+	// LastEpoch returns the latest committed epoch, and false if there is none.
+	LastEpoch() (domain.Epoch, bool, error)
+}
+
+// EpochResultWriter writes the results of one epoch. Exactly one of Commit
+// or Rollback must be called.
+type EpochResultWriter interface {
+	Write(result domain.DutyResult) error
+	Commit() error
+	Rollback() error
+}
+
+// ResultReader reads committed duty results back, e.g. for exports. It
+// never modifies the store, so it can be used while the checker writes.
+type ResultReader interface {
+	// Epochs returns the committed epochs in ascending order.
+	Epochs() ([]domain.Epoch, error)
+
+	// ReadEpoch calls fn for every result of a committed epoch, stopping at
+	// the first error fn returns. An epoch pruned since Epochs was called
+	// gives an error wrapping fs.ErrNotExist.
+	ReadEpoch(epoch domain.Epoch, fn func(domain.DutyResult) error) error
+}
+
+// ResultRowWriter writes exported results in a file format. Close flushes
+// what is buffered; it does not close the underlying writer.
+type ResultRowWriter interface {
+	Write(row domain.ResultRow) error
+	Close() error
+}
+
+// BaselineStore persists the network baseline of each finalized epoch, to
+// compare the validators' results with the network's later on.
+type BaselineStore interface {
+	SaveBaseline(baseline domain.NetworkBaseline) error
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"sort"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+// attestationKey identifies the committee an aggregate attests for.
+type attestationKey struct {
+	slot      domain.Slot
+	committee domain.CommitteeIndex
+}
+
+// includedAggregate is one on-chain aggregate seen from a single committee:
+// the committee's members start at bit offset of bits. head and target are
+// the roots its attesters voted for.
+type includedAggregate struct {
+	inclusionSlot domain.Slot
+	bits          []byte
+	offset        int
+	head, target  domain.Root
+}
+
+// attestationIndex maps each (slot, committee) of an epoch to the aggregates
+// that include it, in inclusion order. A duty is then checked by looking at
+// the few aggregates of its own committee instead of scanning every
+// attestation of its inclusion window.
+type attestationIndex map[attestationKey][]includedAggregate
+
+// buildAttestationIndex indexes the attestations for the slots of epoch found
+// in blocks. An aggregate covering several committees (Electra and
+// later) is indexed under each of them, with the committee's offset in the
+// aggregation bits computed from the committee sizes.
+func buildAttestationIndex(
+	epoch domain.Epoch,
+	committees domain.EpochCommittees,
+	blocks map[domain.Slot]domain.Block,
+) attestationIndex {
+	firstSlot := domain.Slot(epoch) * SlotsPerEpoch
+	lastSlot := firstSlot + SlotsPerEpoch - 1
+
+	inclusionSlots := make([]domain.Slot, 0, len(blocks))
+	for slot := range blocks {
+		inclusionSlots = append(inclusionSlots, slot)
+	}
+	sort.Slice(inclusionSlots, func(i, j int) bool { return inclusionSlots[i] < inclusionSlots[j] })
+
+	index := make(attestationIndex)
+	for _, inclusionSlot := range inclusionSlots {
+		for _, att := range blocks[inclusionSlot].Attestations {
+			if att.DataSlot < firstSlot || att.DataSlot > lastSlot || att.DataSlot >= inclusionSlot {
+				continue
+			}
+			slotCommittees := committees[att.DataSlot]
+			offset := 0
+			for i := 0; i < 64; i++ {
+				if !isBitSet(att.CommitteeBits, i) {
+					continue
+				}
+				committee := domain.CommitteeIndex(i)
+				key := attestationKey{slot: att.DataSlot, committee: committee}
+				index[key] = append(index[key], includedAggregate{
+					inclusionSlot: inclusionSlot,
+					bits:          att.AggregationBits,
+					offset:        offset,
+					head:          att.BeaconBlockRoot,
+					target:        att.TargetRoot,
+				})
+				offset += len(slotCommittees[committee])
+			}
+		}
+	}
+	return index
+}
+
+// find returns the earliest aggregate within the duty's inclusion window
+// with the validator's bit set.
+func (idx attestationIndex) find(duty domain.ValidatorDuty) (includedAggregate, bool) {
+	for _, agg := range idx[attestationKey{slot: duty.Slot, committee: duty.CommitteeIndex}] {
+		if agg.inclusionSlot > duty.Slot+32 {
+			break
+		}
+		if isBitSet(agg.bits, agg.offset+int(duty.ValidatorCommitteeIdx)) {
+			return agg, true
+		}
+	}
+	return includedAggregate{}, false
+}
+
+// countIncluded returns how many of the size members of a committee have
+// their bit set in an aggregate within the inclusion window of slot.
+func (idx attestationIndex) countIncluded(slot domain.Slot, committee domain.CommitteeIndex, size int) int {
+	aggregates := idx[attestationKey{slot: slot, committee: committee}]
+	n := 0
+	for position := 0; position < size; position++ {
+		for _, agg := range aggregates {
+			if agg.inclusionSlot > slot+32 {
+				break
+			}
+			if isBitSet(agg.bits, agg.offset+position) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// computeBitPosition returns the position of a validator's bit in the
+// aggregation bits of an attestation: the sizes of the aggregated committees
+// before its own, plus its position in its committee. It is the offset
+// buildAttestationIndex computes for each committee, for a single duty.
+func computeBitPosition(
+	committee domain.CommitteeIndex,
+	positionInCommittee uint64,
+	committeeBits []byte,
+	sizes domain.CommitteeSizeMap,
+) int {
+	position := 0
+	for i := 0; i < int(committee); i++ {
+		if isBitSet(committeeBits, i) {
+			position += sizes[domain.CommitteeIndex(i)]
+		}
+	}
+	return position + int(positionInCommittee)
+}
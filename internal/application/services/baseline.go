@@ -0,0 +1,114 @@
+package services
+
+import (
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// SetBaselineStore persists the network baseline of each finalized epoch to
+// store.
+func (a *DutiesChecker) SetBaselineStore(store ports.BaselineStore) {
+	a.baselines = store
+}
+
+// SetNetworkThresholds sets when the network counts as degraded at a duty's
+// slot. It can be replaced at runtime like the groups.
+func (a *DutiesChecker) SetNetworkThresholds(thresholds domain.NetworkThresholds) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.thresholds = thresholds
+}
+
+func (a *DutiesChecker) networkThresholds() domain.NetworkThresholds {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.thresholds
+}
+
+// LastBaseline returns the network baseline of the epoch checked last, by
+// CheckEpoch or the Run loop, and false if it could not be computed.
+func (a *DutiesChecker) LastBaseline() (domain.NetworkBaseline, bool) {
+	if a.baseline == nil {
+		return domain.NetworkBaseline{}, false
+	}
+	return *a.baseline, true
+}
+
+// useBaseline makes baseline, which may be nil, the one results are
+// annotated with until the next epoch, logs it and, for a finalized epoch,
+// stores it.
+func (a *DutiesChecker) useBaseline(baseline *domain.NetworkBaseline, finalized bool) {
+	a.baseline = baseline
+	if baseline == nil {
+		return
+	}
+	fields := logger.Fields{
+		"epoch":        uint64(baseline.Epoch),
+		"missed_slots": baseline.MissedSlots,
+	}
+	if baseline.Participation != nil {
+		fields["participation"] = *baseline.Participation
+	}
+	log := logger.With(fields)
+	thresholds := a.networkThresholds()
+	degraded := false
+	for _, s := range baseline.Slots {
+		degraded = degraded || baseline.DegradedAt(s.Slot, thresholds)
+	}
+	if degraded {
+		log.Warn("Network degraded in epoch %d", baseline.Epoch)
+	} else {
+		log.Info("Network baseline of epoch %d", baseline.Epoch)
+	}
+	if !finalized || a.baselines == nil {
+		return
+	}
+	if err := a.baselines.SaveBaseline(*baseline); err != nil {
+		logger.Error("Could not store the network baseline of epoch %d: %v", baseline.Epoch, err)
+	}
+}
+
+// annotate marks r when the network was degraded at its slot, according to
+// the baseline in use.
+func (a *DutiesChecker) annotate(r *domain.DutyResult) {
+	if a.baseline != nil && a.baseline.Epoch == r.Epoch {
+		r.NetworkDegraded = a.baseline.DegradedAt(r.Slot, a.networkThresholds())
+	}
+}
+
+// networkBaseline computes the participation of every committee member of
+// the epoch from the indexed attestations, and which of the epoch's slots
+// have a block. It needs the committees and the blocks of the epoch
+// (see fetchEpochAttestations).
+func (e *epochAttestations) networkBaseline(epoch domain.Epoch, blocks map[domain.Slot]domain.Block) domain.NetworkBaseline {
+	firstSlot := domain.Slot(epoch) * SlotsPerEpoch
+	slots := make([]domain.SlotParticipation, 0, SlotsPerEpoch)
+	proposed, missed, unavailable := 0, 0, 0
+	for slot := firstSlot; slot < firstSlot+SlotsPerEpoch; slot++ {
+		p := domain.SlotParticipation{Slot: slot}
+		for index, members := range e.committees[slot] {
+			p.Attesters += len(members)
+			p.Included += e.included.countIncluded(slot, index, len(members))
+		}
+		for next := slot + 1; next <= slot+32; next++ {
+			if _, ok := e.unavailableSlots[next]; ok {
+				p.Incomplete = true
+				break
+			}
+		}
+		slots = append(slots, p)
+
+		_, hasBlock := blocks[slot]
+		_, isUnavailable := e.unavailableSlots[slot]
+		switch {
+		case hasBlock:
+			proposed++
+		case isUnavailable:
+			unavailable++
+		default:
+			missed++
+		}
+	}
+	return domain.NewNetworkBaseline(epoch, slots, proposed, missed, unavailable)
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/testutil"
+)
+
+type memoryBaselineStore struct {
+	baselines map[domain.Epoch]domain.NetworkBaseline
+}
+
+func (s *memoryBaselineStore) SaveBaseline(b domain.NetworkBaseline) error {
+	s.baselines[b.Epoch] = b
+	return nil
+}
+
+// TestNetworkBaseline checks the stored baseline of finalized epochs against
+// the generator, and that results are marked degraded only on a degraded
+// network.
+func TestNetworkBaseline(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          fakechain.Config
+		failBlock    domain.Slot // relative to the epoch's first slot; 0 for none
+		wantDegraded bool
+	}{
+		{
+			name: "healthy network",
+			cfg:  fakechain.Config{Seed: 21, Validators: 1024, CommitteesPerSlot: 4, Epochs: 4, MissedAttestationRate: 0.02},
+		},
+		{
+			name:         "many missed slots",
+			cfg:          fakechain.Config{Seed: 22, Validators: 1024, CommitteesPerSlot: 4, Epochs: 4, MissedSlotRate: 0.3},
+			wantDegraded: true,
+		},
+		{
+			name:         "low participation",
+			cfg:          fakechain.Config{Seed: 23, Validators: 1024, CommitteesPerSlot: 4, Epochs: 4, MissedAttestationRate: 0.2},
+			wantDegraded: true,
+		},
+		{
+			name:      "unavailable blocks",
+			cfg:       fakechain.Config{Seed: 24, Validators: 1024, CommitteesPerSlot: 4, Epochs: 4, MissedAttestationRate: 0.02},
+			failBlock: 20,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			chain := testutil.NewChain(t, tt.cfg)
+			epoch, _ := chain.GetFinalizedEpoch(ctx)
+			if tt.failBlock > 0 {
+				chain.FailBlock(domain.Slot(epoch)*SlotsPerEpoch+tt.failBlock, fmt.Errorf("%w: status 500", ports.ErrServer))
+			}
+			indices := testutil.Validators(tt.cfg.Validators)
+
+			results, baselines := newMemoryResultStore(), &memoryBaselineStore{baselines: make(map[domain.Epoch]domain.NetworkBaseline)}
+			checker := NewDutiesChecker(chain, time.Minute, indices)
+			if err := checker.SetResultStore(results); err != nil {
+				t.Fatal(err)
+			}
+			checker.SetBaselineStore(baselines)
+			checker.checkLatestFinalizedEpoch(ctx)
+
+			want, _ := chain.ExpectedBaseline(epoch)
+			if got, ok := baselines.baselines[epoch]; !ok || !reflect.DeepEqual(got, want) {
+				t.Fatalf("stored baseline %+v, want %+v", got, want)
+			}
+			if tt.failBlock > 0 && (want.UnavailableSlots != 1 || !want.Slots[0].Incomplete) {
+				t.Errorf("failed block not accounted for: %+v", want)
+			}
+			got, _ := results.results(epoch)
+			testutil.AssertResults(t, got, chain.Expected(epoch, indices))
+			degraded := 0
+			for _, r := range got {
+				if r.NetworkDegraded {
+					degraded++
+				}
+			}
+			if tt.wantDegraded != (degraded > 0) {
+				t.Errorf("%d of %d results degraded, want degraded %v (baseline %+v)", degraded, len(got), tt.wantDegraded, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// SetCorrelationPolicy sets how misses are grouped into correlated failures.
+// It can be replaced at runtime like the groups.
+func (a *DutiesChecker) SetCorrelationPolicy(policy domain.CorrelationPolicy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.correlation = policy
+}
+
+func (a *DutiesChecker) correlationPolicy() domain.CorrelationPolicy {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.correlation
+}
+
+// failureKey identifies a correlated failure across the provisional and the
+// finalized check of its epoch.
+type failureKey struct {
+	slot  domain.Slot
+	label string
+	value string
+}
+
+func failureKeyOf(f domain.CorrelatedFailure) failureKey {
+	return failureKey{slot: f.Slot, label: f.Label, value: f.Value}
+}
+
+// correlateMisses groups the missed duties among results by slot and label
+// (see domain.CorrelationPolicy). It returns the correlated failures, in slot order,
+// and the results that are not part of any, in their original order.
+// Unknown outcomes are never correlated: they mean missing data, not a miss.
+func (a *DutiesChecker) correlateMisses(results []domain.DutyResult) ([]domain.CorrelatedFailure, []domain.DutyResult) {
+	policy := a.correlationPolicy()
+	if policy.MinValidators <= 0 {
+		return nil, results
+	}
+
+	bySlot := make(map[domain.Slot][]int)
+	var slots []domain.Slot
+	for i, r := range results {
+		if r.Outcome != domain.OutcomeMissed {
+			continue
+		}
+		if _, ok := bySlot[r.Slot]; !ok {
+			slots = append(slots, r.Slot)
+		}
+		bySlot[r.Slot] = append(bySlot[r.Slot], i)
+	}
+	slices.Sort(slots)
+
+	correlated := make([]bool, len(results))
+	var failures []domain.CorrelatedFailure
+	group := func(slot domain.Slot, label string) {
+		byValue := make(map[string][]int)
+		for _, i := range bySlot[slot] {
+			if correlated[i] {
+				continue
+			}
+			value := ""
+			if label != "" {
+				if value = a.ValidatorLabel(results[i].ValidatorIndex, label); value == "" {
+					continue
+				}
+			}
+			byValue[value] = append(byValue[value], i)
+		}
+		values := make([]string, 0, len(byValue))
+		for v := range byValue {
+			values = append(values, v)
+		}
+		slices.Sort(values)
+		for _, v := range values {
+			members := byValue[v]
+			validators := make([]domain.ValidatorIndex, len(members))
+			for j, i := range members {
+				validators[j] = results[i].ValidatorIndex
+			}
+			// A validator can miss both its proposal and its attestation.
+			slices.Sort(validators)
+			validators = slices.Compact(validators)
+			if len(validators) < policy.MinValidators {
+				continue
+			}
+			f := domain.CorrelatedFailure{
+				Epoch: results[members[0]].Epoch, Slot: slot, Label: label, Value: v, Validators: validators,
+			}
+			for _, i := range members {
+				correlated[i] = true
+				f.NetworkDegraded = f.NetworkDegraded || results[i].NetworkDegraded
+			}
+			failures = append(failures, f)
+		}
+	}
+	for _, slot := range slots {
+		for _, label := range policy.Labels {
+			group(slot, label)
+		}
+		group(slot, "")
+	}
+	if len(failures) == 0 {
+		return nil, results
+	}
+
+	rest := make([]domain.DutyResult, 0, len(results))
+	for i, r := range results {
+		if !correlated[i] {
+			rest = append(rest, r)
+		}
+	}
+	return failures, rest
+}
+
+// correlatedFailureText describes a correlated failure in one sentence, for
+// the log and the notification.
+func correlatedFailureText(f domain.CorrelatedFailure) string {
+	group := ""
+	if f.Label != "" {
+		group = fmt.Sprintf(" with %s=%s", f.Label, f.Value)
+	}
+	return fmt.Sprintf("%d validators%s missed their duties at slot %d", len(f.Validators), group, f.Slot)
+}
+
+// correlatedFailureNotifications logs each correlated failure, found on the
+// head chain if provisional, and returns their notifications.
+func correlatedFailureNotifications(failures []domain.CorrelatedFailure, provisional bool) []domain.Notification {
+	notifications := make([]domain.Notification, 0, len(failures))
+	for i := range failures {
+		f := &failures[i]
+		fields := logger.Fields{
+			"epoch":      uint64(f.Epoch),
+			"slot":       uint64(f.Slot),
+			"validators": len(f.Validators),
+		}
+		if f.Label != "" {
+			fields["label"] = f.Label
+			fields["value"] = f.Value
+		}
+		if f.NetworkDegraded {
+			fields["network_degraded"] = true
+		}
+		text := correlatedFailureText(*f)
+		if provisional {
+			logger.With(fields).Warn("Provisional: correlated failure on the head chain: %s", text)
+		} else {
+			logger.With(fields).Warn("Correlated failure: %s", text)
+		}
+		notifications = append(notifications, domain.Notification{
+			Kind: domain.NotificationCorrelatedFailure, Message: text, Correlated: f,
+		})
+	}
+	return notifications
+}
+
+// reportCorrelatedFailures looks for correlated failures among the misses of
+// the finalized epoch just checked and logs them. Those the provisional check
+// of the epoch did not already notify, with the same slot, label and value,
+// are sent to the notifier. Corrections to a miss that is part of a failure
+// sent now are folded into it instead of being sent one by one.
+func (a *DutiesChecker) reportCorrelatedFailures(ctx context.Context, epoch domain.Epoch) {
+	misses := a.misses
+	a.misses = nil
+	failures, _ := a.correlateMisses(misses)
+	notified := a.provisionalFailures[epoch]
+
+	type member struct {
+		validator domain.ValidatorIndex
+		slot      domain.Slot
+	}
+	covered := make(map[member]bool)
+	var notifications []domain.Notification
+	for _, n := range correlatedFailureNotifications(failures, false) {
+		if notified[failureKeyOf(*n.Correlated)] {
+			continue
+		}
+		for _, v := range n.Correlated.Validators {
+			covered[member{v, n.Correlated.Slot}] = true
+		}
+		notifications = append(notifications, n)
+	}
+	a.notify(ctx, notifications)
+
+	corrections := a.corrections[:0]
+	for _, c := range a.corrections {
+		if c.Result.Outcome == domain.OutcomeMissed && covered[member{c.Result.ValidatorIndex, c.Result.Slot}] {
+			continue
+		}
+		corrections = append(corrections, c)
+	}
+	a.corrections = corrections
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/testutil"
+)
+
+type recordingNotifier struct {
+	notifications []domain.Notification
+	batches       [][]domain.Notification
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, notifications []domain.Notification) error {
+	n.notifications = append(n.notifications, notifications...)
+	n.batches = append(n.batches, notifications)
+	return nil
+}
+
+func (n *recordingNotifier) Close(context.Context) error { return nil }
+
+func TestCorrelateMisses(t *testing.T) {
+	missed := func(validator domain.ValidatorIndex, slot domain.Slot) domain.DutyResult {
+		return domain.DutyResult{Type: domain.DutyTypeAttestation, ValidatorIndex: validator, Epoch: 1, Slot: slot, Outcome: domain.OutcomeMissed}
+	}
+	// Validators 0-5 run on node-01 with lighthouse, 6-9 on node-02 with
+	// lighthouse, 10-11 have no labels.
+	labels := make(map[domain.ValidatorIndex]map[string]string)
+	for i := domain.ValidatorIndex(0); i < 10; i++ {
+		machine := "node-01"
+		if i >= 6 {
+			machine = "node-02"
+		}
+		labels[i] = map[string]string{"machine": machine, "client": "lighthouse"}
+	}
+
+	tests := []struct {
+		name     string
+		policy   domain.CorrelationPolicy
+		results  []domain.DutyResult
+		want     []domain.CorrelatedFailure
+		wantRest int
+	}{
+		{
+			name:   "machine first, then client",
+			policy: domain.CorrelationPolicy{MinValidators: 3, Labels: []string{"machine", "client"}},
+			results: []domain.DutyResult{
+				missed(0, 40), missed(1, 40), missed(2, 40), missed(6, 40), missed(7, 40), missed(8, 40), missed(9, 40),
+				missed(3, 41), missed(6, 41), missed(7, 41),
+			},
+			want: []domain.CorrelatedFailure{
+				{Epoch: 1, Slot: 40, Label: "machine", Value: "node-01", Validators: []domain.ValidatorIndex{0, 1, 2}},
+				{Epoch: 1, Slot: 40, Label: "machine", Value: "node-02", Validators: []domain.ValidatorIndex{6, 7, 8, 9}},
+				{Epoch: 1, Slot: 41, Label: "client", Value: "lighthouse", Validators: []domain.ValidatorIndex{3, 6, 7}},
+			},
+		},
+		{
+			name:   "leftovers grouped by slot",
+			policy: domain.CorrelationPolicy{MinValidators: 3, Labels: []string{"machine"}},
+			results: []domain.DutyResult{
+				missed(0, 40), missed(6, 40), missed(10, 40), missed(11, 41), missed(10, 41),
+			},
+			want: []domain.CorrelatedFailure{
+				{Epoch: 1, Slot: 40, Validators: []domain.ValidatorIndex{0, 6, 10}},
+			},
+			wantRest: 2,
+		},
+		{
+			name:   "one validator counts once",
+			policy: domain.CorrelationPolicy{MinValidators: 2},
+			results: []domain.DutyResult{
+				missed(0, 40), {Type: domain.DutyTypeProposal, ValidatorIndex: 0, Epoch: 1, Slot: 40, Outcome: domain.OutcomeMissed},
+			},
+			wantRest: 2,
+		},
+		{
+			name:   "unknown outcomes and successes are not correlated",
+			policy: domain.CorrelationPolicy{MinValidators: 2},
+			results: []domain.DutyResult{
+				missed(0, 40),
+				{Type: domain.DutyTypeAttestation, ValidatorIndex: 1, Epoch: 1, Slot: 40, Outcome: domain.OutcomeUnknown},
+				{Type: domain.DutyTypeAttestation, ValidatorIndex: 2, Epoch: 1, Slot: 40, Outcome: domain.OutcomeSuccess},
+			},
+			wantRest: 3,
+		},
+		{
+			name:     "disabled",
+			policy:   domain.CorrelationPolicy{},
+			results:  []domain.DutyResult{missed(0, 40), missed(1, 40), missed(2, 40)},
+			wantRest: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewDutiesChecker(nil, time.Minute, nil)
+			checker.SetValidatorLabels(labels)
+			checker.SetCorrelationPolicy(tt.policy)
+			got, rest := checker.correlateMisses(tt.results)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("failures\n%+v\nwant\n%+v", got, tt.want)
+			}
+			if len(rest) != tt.wantRest {
+				t.Errorf("%d results left, want %d", len(rest), tt.wantRest)
+			}
+		})
+	}
+}
+
+// TestCorrelatedFailuresNotified checks that the misses of a finalized epoch
+// are notified as correlated failures of the validators of a machine.
+func TestCorrelatedFailuresNotified(t *testing.T) {
+	ctx := context.Background()
+	cfg := fakechain.Config{Seed: 31, Validators: 512, CommitteesPerSlot: 4, Epochs: 4, MissedAttestationRate: 0.5}
+	chain := testutil.NewChain(t, cfg)
+	epoch, _ := chain.GetFinalizedEpoch(ctx)
+	indices := testutil.Validators(cfg.Validators)
+	machine := func(v domain.ValidatorIndex) string { return fmt.Sprintf("node-%d", v%4) }
+	labels := make(map[domain.ValidatorIndex]map[string]string, len(indices))
+	for _, v := range indices {
+		labels[v] = map[string]string{"machine": machine(v)}
+	}
+
+	notifier := &recordingNotifier{}
+	checker := NewDutiesChecker(chain, time.Minute, indices)
+	checker.SetValidatorLabels(labels)
+	checker.SetCorrelationPolicy(domain.CorrelationPolicy{MinValidators: 2, Labels: []string{"machine"}})
+	checker.SetNotifier(notifier)
+	checker.checkLatestFinalizedEpoch(ctx)
+
+	type group struct {
+		slot    domain.Slot
+		machine string
+	}
+	missed := make(map[group]map[domain.ValidatorIndex]bool)
+	for _, r := range chain.Expected(epoch, indices) {
+		if r.Outcome != domain.OutcomeMissed {
+			continue
+		}
+		g := group{r.Slot, machine(r.ValidatorIndex)}
+		if missed[g] == nil {
+			missed[g] = make(map[domain.ValidatorIndex]bool)
+		}
+		missed[g][r.ValidatorIndex] = true
+	}
+
+	notified := make(map[group]bool)
+	for _, n := range notifier.notifications {
+		f := n.Correlated
+		if n.Kind != domain.NotificationCorrelatedFailure || f == nil {
+			t.Fatalf("unexpected notification %+v", n)
+		}
+		if f.Label == "" {
+			// Single misses on different machines at the same slot.
+			for _, v := range f.Validators {
+				if !missed[group{f.Slot, machine(v)}][v] {
+					t.Errorf("%s: validator %d did not miss", n.Message, v)
+				}
+			}
+			continue
+		}
+		g := group{f.Slot, f.Value}
+		if len(f.Validators) != len(missed[g]) {
+			t.Errorf("%s: %d validators, want %d", n.Message, len(f.Validators), len(missed[g]))
+		}
+		for _, v := range f.Validators {
+			if !missed[g][v] {
+				t.Errorf("%s: validator %d did not miss", n.Message, v)
+			}
+		}
+		notified[g] = true
+	}
+	for g, validators := range missed {
+		if len(validators) >= 2 && !notified[g] {
+			t.Errorf("no correlated failure for %d validators on %s at slot %d", len(validators), g.machine, g.slot)
+		}
+	}
+	if len(notified) == 0 {
+		t.Error("no correlated failures notified")
+	}
+}
+
+// provisionalChecker returns a checker that groups the misses of 3 or more
+// validators by machine, with epoch 2 of chain checked on the head chain.
+func provisionalChecker(t *testing.T, chain ports.BeaconChainAdapter, indices []domain.ValidatorIndex) (*DutiesChecker, *recordingNotifier) {
+	t.Helper()
+	labels := make(map[domain.ValidatorIndex]map[string]string, len(indices))
+	for _, v := range indices {
+		labels[v] = map[string]string{"machine": fmt.Sprintf("node-%d", v%2)}
+	}
+	notifier := &recordingNotifier{}
+	checker := NewDutiesChecker(chain, time.Minute, indices)
+	checker.SetValidatorLabels(labels)
+	checker.SetCorrelationPolicy(domain.CorrelationPolicy{MinValidators: 3, Labels: []string{"machine"}})
+	checker.SetNotifier(notifier)
+	checker.EnableHeadTracking(chain)
+	checker.evaluateProvisional(context.Background(), 2, 4*SlotsPerEpoch-1)
+	return checker, notifier
+}
+
+// TestProvisionalCorrelatedFailures checks that a provisional check sends
+// each miss once, in one batch: as part of a correlated failure or, if it is
+// in none, as a provisional miss.
+func TestProvisionalCorrelatedFailures(t *testing.T) {
+	cfg := fakechain.Config{Seed: 32, Validators: 256, CommitteesPerSlot: 2, Epochs: 4, MissedAttestationRate: 0.15}
+	chain := testutil.NewChain(t, cfg)
+	chain.SetFinalized(1)
+	indices := testutil.Validators(cfg.Validators)
+	_, notifier := provisionalChecker(t, chain, indices)
+	if len(notifier.batches) != 1 {
+		t.Fatalf("%d notification batches, want 1", len(notifier.batches))
+	}
+
+	type duty struct {
+		validator domain.ValidatorIndex
+		slot      domain.Slot
+	}
+	grouped := make(map[duty]bool)
+	var failures, misses int
+	for _, n := range notifier.batches[0] {
+		if n.Kind == domain.NotificationCorrelatedFailure {
+			failures++
+			for _, v := range n.Correlated.Validators {
+				grouped[duty{v, n.Correlated.Slot}] = true
+			}
+		}
+	}
+	single := make(map[duty]bool)
+	for _, n := range notifier.batches[0] {
+		if n.Kind != domain.NotificationProvisionalMiss {
+			continue
+		}
+		misses++
+		d := duty{n.Result.ValidatorIndex, n.Result.Slot}
+		if grouped[d] {
+			t.Errorf("provisional miss of validator %d at slot %d is part of a correlated failure", d.validator, d.slot)
+		}
+		single[d] = true
+	}
+	for _, r := range chain.Expected(2, indices) {
+		if d := (duty{r.ValidatorIndex, r.Slot}); r.Outcome != domain.OutcomeSuccess && !grouped[d] && !single[d] {
+			t.Errorf("%s duty of validator %d at slot %d is %s but was not notified", r.Type, d.validator, d.slot, r.Outcome)
+		}
+	}
+	if failures == 0 || misses == 0 {
+		t.Errorf("%d correlated failures and %d provisional misses, want some of both", failures, misses)
+	}
+}
+
+// TestFinalizedCorrelatedFailures checks that finalizing an epoch checked
+// provisionally only notifies the correlated failures not notified yet, and
+// folds the corrections to their misses into them.
+func TestFinalizedCorrelatedFailures(t *testing.T) {
+	ctx := context.Background()
+	cfg := fakechain.Config{Seed: 32, Validators: 256, CommitteesPerSlot: 2, Epochs: 4, MissedAttestationRate: 0.15}
+	chain := testutil.NewChain(t, cfg)
+	chain.SetFinalized(1)
+	indices := testutil.Validators(cfg.Validators)
+	checker, notifier := provisionalChecker(t, chain, indices)
+
+	// Pretend the head chain had included the attestations of the first
+	// correlated failure: it was not notified and its misses are corrections.
+	var unseen *domain.CorrelatedFailure
+	for _, n := range notifier.notifications {
+		if n.Kind == domain.NotificationCorrelatedFailure {
+			unseen = n.Correlated
+			break
+		}
+	}
+	if unseen == nil {
+		t.Fatal("no correlated failure")
+	}
+	delete(checker.provisionalFailures[2], failureKeyOf(*unseen))
+	for key, r := range checker.provisional[2] {
+		if r.Slot == unseen.Slot && slices.Contains(unseen.Validators, r.ValidatorIndex) && r.Outcome == domain.OutcomeMissed {
+			r.Outcome = domain.OutcomeSuccess
+			checker.provisional[2][key] = r
+		}
+	}
+
+	notifier.notifications = nil
+	chain.SetFinalized(2)
+	checker.checkLatestFinalizedEpoch(ctx)
+	if len(notifier.notifications) != 1 {
+		t.Fatalf("%d notifications, want the correlated failure only: %+v", len(notifier.notifications), notifier.notifications)
+	}
+	if n := notifier.notifications[0]; n.Kind != domain.NotificationCorrelatedFailure || !reflect.DeepEqual(*n.Correlated, *unseen) {
+		t.Errorf("notified %+v, want %+v", n, *unseen)
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+)
+
+// benchValidators approximates the mainnet active validator set.
+const benchValidators = 1 << 20
+
+// syntheticChain is a BeaconChainAdapter serving one fully populated epoch:
+// 64 committees per slot, and every slot's committees aggregated into a
+// single attestation in the next block, with every 100th validator missing.
+type syntheticChain struct {
+	ports.BeaconChainAdapter
+	epoch      domain.Epoch
+	committees domain.EpochCommittees
+	duties     []domain.ValidatorDuty // indexed by validator index
+	blocks     map[domain.Slot]domain.Block
+}
+
+func newSyntheticChain(epoch domain.Epoch, validators int) *syntheticChain {
+	c := &syntheticChain{
+		epoch:      epoch,
+		committees: make(domain.EpochCommittees),
+		duties:     make([]domain.ValidatorDuty, validators),
+		blocks:     make(map[domain.Slot]domain.Block),
+	}
+	const committeesPerSlot = 64
+	firstSlot := domain.Slot(epoch) * SlotsPerEpoch
+	perSlot := validators / int(SlotsPerEpoch)
+	perCommittee := perSlot / committeesPerSlot
+	v := 0
+	for s := domain.Slot(0); s < SlotsPerEpoch; s++ {
+		slot := firstSlot + s
+		c.committees[slot] = make(map[domain.CommitteeIndex][]domain.ValidatorIndex)
+		bits := make([]byte, (perSlot+7)/8+1)
+		for ci := 0; ci < committeesPerSlot; ci++ {
+			members := make([]domain.ValidatorIndex, perCommittee)
+			for pos := range members {
+				members[pos] = domain.ValidatorIndex(v)
+				c.duties[v] = domain.ValidatorDuty{
+					ValidatorIndex:        domain.ValidatorIndex(v),
+					Slot:                  slot,
+					CommitteeIndex:        domain.CommitteeIndex(ci),
+					ValidatorCommitteeIdx: uint64(pos),
+					CommitteeLength:       uint64(perCommittee),
+					CommitteesAtSlot:      committeesPerSlot,
+				}
+				if v%100 != 0 {
+					bit := ci*perCommittee + pos
+					bits[bit/8] |= 1 << (bit % 8)
+				}
+				v++
+			}
+			c.committees[slot][domain.CommitteeIndex(ci)] = members
+		}
+		c.blocks[slot+1] = domain.Block{Slot: slot + 1, Attestations: []domain.Attestation{{
+			DataSlot:        slot,
+			CommitteeBits:   []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			AggregationBits: bits,
+		}}}
+	}
+	for slot := firstSlot + SlotsPerEpoch + 1; slot < firstSlot+2*SlotsPerEpoch; slot++ {
+		c.blocks[slot] = domain.Block{Slot: slot}
+	}
+	return c
+}
+
+func (c *syntheticChain) GetValidatorDutiesBatch(_ context.Context, _ domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ValidatorDuty, error) {
+	duties := make([]domain.ValidatorDuty, len(indices))
+	for i, idx := range indices {
+		duties[i] = c.duties[idx]
+	}
+	return duties, nil
+}
+
+func (c *syntheticChain) GetEpochCommittees(context.Context, domain.Epoch) (domain.EpochCommittees, error) {
+	return c.committees, nil
+}
+
+func (c *syntheticChain) GetBlock(_ context.Context, slot domain.Slot) (domain.Block, error) {
+	if b, ok := c.blocks[slot]; ok {
+		return b, nil
+	}
+	return domain.Block{}, ports.ErrNotFound
+}
+
+// BenchmarkEvaluateAttestationsFullSet evaluates every attestation duty of an
+// epoch for ~1M validators. The block and committee data are built once;
+// allocations reported per op are those of the evaluation itself.
+func BenchmarkEvaluateAttestationsFullSet(b *testing.B) {
+	const epoch = 100
+	chain := newSyntheticChain(epoch, benchValidators)
+	indices := make([]domain.ValidatorIndex, benchValidators)
+	for i := range indices {
+		indices[i] = domain.ValidatorIndex(i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		missed := 0
+		_, total, err := evaluateAttestations(context.Background(), chain, domain.DefaultConcurrency, epoch, indices, nil,
+			func(_ domain.ValidatorDuty, r domain.DutyResult) {
+				if r.Outcome != domain.OutcomeSuccess {
+					missed++
+				}
+			})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if total != benchValidators || missed != (benchValidators+99)/100 {
+			b.Fatalf("got %d duties with %d not successful", total, missed)
+		}
+	}
+}
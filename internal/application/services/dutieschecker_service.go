@@ -2,24 +2,84 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Marketen/duties-indexer/internal/application/domain"
 	"github.com/Marketen/duties-indexer/internal/application/ports"
 	"github.com/Marketen/duties-indexer/internal/logger"
+	"github.com/Marketen/duties-indexer/internal/workerpool"
 )
 
 const SlotsPerEpoch = domain.Slot(32) // Ethereum consensus constant
 
 type DutiesChecker struct {
 	BeaconAdapter ports.BeaconChainAdapter
-	PollInterval  time.Duration
 
-	// Static set of validators we track, from env
+	// mu guards PollInterval, Concurrency, ValidatorIndices, groups, labels,
+	// thresholds, correlation and notifier, which can be replaced at runtime
+	// on config reload via their setters.
+	mu           sync.Mutex
+	PollInterval time.Duration
+	Concurrency  int
+
+	// Set of validators we track, from config
 	ValidatorIndices []domain.ValidatorIndex
+	// groups maps grouped validators to their group name, for logging.
+	groups map[domain.ValidatorIndex]string
+	// labels maps grouped validators to the labels of their group.
+	labels map[domain.ValidatorIndex]map[string]string
+	// thresholds decide when the network counts as degraded.
+	thresholds domain.NetworkThresholds
+
+	// Verification mode (see EnableVerification): when set, epochs are
+	// evaluated on each of these nodes and cross-checked instead of using BeaconAdapter.
+	verificationNodes   []VerificationNode
+	discrepancyRecorder ports.DiscrepancyRecorder
+
+	// events, when set, drives checks from the beacon node event stream.
+	events ports.ChainEventSource
+
+	// Provisional checks of unfinalized epochs (see EnableHeadTracking and
+	// SetNonFinalityPolicy) run against unfinalizedBeacon; their results are
+	// kept until the epoch is finalized and compared with the final ones,
+	// and provisionalFailures are the correlated failures already notified.
+	headTracking         bool
+	unfinalizedBeacon    ports.BeaconChainAdapter
+	nonFinality          NonFinalityPolicy
+	nonFinal             bool
+	provisional          map[domain.Epoch]map[dutyKey]domain.DutyResult
+	provisionalFailures  map[domain.Epoch]map[failureKey]bool
+	lastProvisionalEpoch domain.Epoch
+	corrections          []domain.Notification
+	notifier             ports.Notifier
+
+	// results, when set, stores the results of each finalized epoch through
+	// epochWriter (see SetResultStore).
+	results       ports.ResultStore
+	epochWriter   ports.EpochResultWriter
+	epochWriteErr error
+
+	// baseline is the network baseline of the epoch being checked, which
+	// its results are annotated with; baselines, when set, stores those of
+	// finalized epochs (see SetBaselineStore).
+	baseline  *domain.NetworkBaseline
+	baselines ports.BaselineStore
+
+	// correlation groups misses into correlated failures (guarded by mu);
+	// misses are those of the finalized epoch being checked.
+	correlation domain.CorrelationPolicy
+	misses      []domain.DutyResult
 
 	lastFinalizedEpoch domain.Epoch
-	checkedEpochs      map[domain.ValidatorIndex]domain.Epoch // latest epoch checked for each validator index
+	// processedEpoch is the last finalized epoch whose check completed, for
+	// readiness reporting from other goroutines; processed is false until then.
+	processedEpoch atomic.Uint64
+	processed      atomic.Bool
+	checkedEpochs  map[domain.ValidatorIndex]domain.Epoch // latest epoch checked for each validator index
 }
 
 // NewDutiesChecker constructs a DutiesChecker with dependencies injected.
@@ -31,22 +91,135 @@ func NewDutiesChecker(
 	return &DutiesChecker{
 		BeaconAdapter:      beacon,
 		PollInterval:       pollInterval,
+		Concurrency:        domain.DefaultConcurrency,
 		ValidatorIndices:   validatorIndices,
 		checkedEpochs:      make(map[domain.ValidatorIndex]domain.Epoch),
 		lastFinalizedEpoch: 0,
+		nonFinality:        DefaultNonFinalityPolicy,
+		thresholds:         domain.DefaultNetworkThresholds,
+		correlation:        domain.DefaultCorrelationPolicy,
 	}
 }
 
-// Run starts the periodic check loop. If at interval, ticker ticks but check has not
-// ended, we won't start a new check, we will just wait for the next tick.
-func (a *DutiesChecker) Run(ctx context.Context) {
-	ticker := time.NewTicker(a.PollInterval)
+// SetValidatorIndices replaces the tracked validators. It takes effect from the
+// next epoch check; the last processed epoch is kept, so newly added validators
+// start being checked at the next finalized epoch.
+func (a *DutiesChecker) SetValidatorIndices(indices []domain.ValidatorIndex) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ValidatorIndices = indices
+}
+
+// SetValidatorGroups sets the group of each grouped validator, added to the
+// log lines of its duties. It can be replaced at runtime like the indices.
+func (a *DutiesChecker) SetValidatorGroups(groups map[domain.ValidatorIndex]string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.groups = groups
+}
+
+// ValidatorGroup returns the group of a validator in the current configuration,
+// or "" if it is not grouped.
+func (a *DutiesChecker) ValidatorGroup(index domain.ValidatorIndex) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.groups[index]
+}
+
+// SetValidatorLabels sets the labels of each grouped validator. It can be
+// replaced at runtime like the groups.
+func (a *DutiesChecker) SetValidatorLabels(labels map[domain.ValidatorIndex]map[string]string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.labels = labels
+}
+
+// ValidatorLabel returns the value of a validator's label in the current
+// configuration, or "" if it does not have it.
+func (a *DutiesChecker) ValidatorLabel(index domain.ValidatorIndex, key string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.labels[index][key]
+}
+
+// SetPollInterval changes the polling interval. Run picks it up after the next tick.
+func (a *DutiesChecker) SetPollInterval(interval time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.PollInterval = interval
+}
+
+// SetConcurrency changes how many beacon requests each fan-out keeps in
+// flight. It takes effect from the next epoch check.
+func (a *DutiesChecker) SetConcurrency(n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Concurrency = n
+}
+
+func (a *DutiesChecker) concurrency() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.Concurrency
+}
+
+func (a *DutiesChecker) pollInterval() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.PollInterval
+}
+
+func (a *DutiesChecker) validatorIndices() []domain.ValidatorIndex {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.ValidatorIndices
+}
+
+// Run starts the check loop. Without an event source (see UseEventStream) it
+// polls on a ticker. With one, new finalized checkpoints trigger a check
+// immediately and new blocks trigger the head check (see EnableHeadTracking);
+// the ticker is only used while the stream is down. If at interval, ticker
+// ticks but check has not ended, we won't start a new check, we will just
+// wait for the next tick.
+//
+// Closing stop ends the loop once the check in progress, if any, is done;
+// cancelling ctx aborts that check too, rolling back its stored results.
+func (a *DutiesChecker) Run(ctx context.Context, stop <-chan struct{}) {
+	var streaming atomic.Bool
+	finalized := make(chan struct{}, 1)
+	head := make(chan struct{}, 1)
+	if a.events != nil {
+		go a.followEvents(ctx, &streaming, finalized, head)
+	}
+
+	interval := a.pollInterval()
+	ticker := time.NewTicker(interval)
 	a.checkLatestFinalizedEpoch(ctx)
+	a.checkHead(ctx)
 	defer ticker.Stop()
 	for {
+		// Checks can take a while; do not start another one once stopped.
+		select {
+		case <-stop:
+			return
+		default:
+		}
 		select {
+		case <-stop:
+			return
 		case <-ticker.C:
+			if !streaming.Load() {
+				a.checkLatestFinalizedEpoch(ctx)
+				a.checkHead(ctx)
+			}
+			if next := a.pollInterval(); next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+		case <-finalized:
 			a.checkLatestFinalizedEpoch(ctx)
+		case <-head:
+			a.checkHead(ctx)
 		case <-ctx.Done():
 			return
 		}
@@ -65,106 +238,434 @@ func (a *DutiesChecker) checkLatestFinalizedEpoch(ctx context.Context) {
 	}
 	a.lastFinalizedEpoch = finalizedEpoch
 	logger.Info("New finalized epoch %d detected.", finalizedEpoch)
+	a.beginEpoch(finalizedEpoch)
+	var checkErr error
+	defer func() { a.finishEpoch(ctx, finalizedEpoch, checkErr) }()
 
-	if len(a.ValidatorIndices) == 0 {
+	trackedIndices := a.validatorIndices()
+	if len(trackedIndices) == 0 {
 		logger.Warn("No validator indices configured; nothing to do.")
 		return
 	}
 
-	logger.Info("Tracking %d validator indices", len(a.ValidatorIndices))
-	validatorIndices := a.getValidatorsToCheck(a.ValidatorIndices, finalizedEpoch)
+	logger.Info("Tracking %d validator indices", len(trackedIndices))
+	validatorIndices := a.getValidatorsToCheck(trackedIndices, finalizedEpoch)
 	if len(validatorIndices) == 0 {
 		logger.Debug("No validators left to check for epoch %d", finalizedEpoch)
 		return
 	}
 
-	// Split proposal vs attestation logic
-	a.checkProposals(ctx, finalizedEpoch, validatorIndices)
-	a.checkAttestations(ctx, finalizedEpoch, validatorIndices)
+	if len(a.verificationNodes) > 0 {
+		checkErr = a.verifyEpoch(ctx, finalizedEpoch, validatorIndices)
+		return
+	}
+
+	// Split proposal vs attestation logic; proposals are part of the
+	// effectiveness scored with the attestations, and are reported with them
+	// once the network baseline of the epoch is known.
+	proposals, err := a.checkProposals(ctx, finalizedEpoch, validatorIndices)
+	if err != nil {
+		checkErr = err
+		return
+	}
+	checkErr = a.checkAttestations(ctx, finalizedEpoch, validatorIndices, proposals)
+}
+
+// LastProcessedEpoch returns the last finalized epoch whose check completed,
+// and false if none has yet. It is safe to call from any goroutine.
+func (a *DutiesChecker) LastProcessedEpoch() (domain.Epoch, bool) {
+	return domain.Epoch(a.processedEpoch.Load()), a.processed.Load()
 }
 
+// CheckEpoch evaluates the duties of the tracked validators in epoch once,
+// outside the Run loop, and returns the results: proposals first, then sync
+// committee signatures, then attestations, annotated with the network
+// baseline (see LastBaseline) and with the rewards missed.
+// Results are not logged, stored or compared with provisional ones, and
+// verification mode is not used.
+func (a *DutiesChecker) CheckEpoch(ctx context.Context, epoch domain.Epoch) ([]domain.DutyResult, error) {
+	indices := a.validatorIndices()
+	proposals, err := evaluateProposals(ctx, a.BeaconAdapter, a.concurrency(), epoch, indices)
+	if err != nil {
+		return nil, fmt.Errorf("fetching proposer duties: %w", err)
+	}
+	data := fetchEpochAttestations(ctx, a.BeaconAdapter, a.concurrency(), epoch)
+	data.rewards = true
+	a.useBaseline(data.baseline, false)
+	syncResults, err := data.evaluateSyncCommittee(ctx, a.BeaconAdapter, epoch, indices)
+	if err != nil {
+		return nil, fmt.Errorf("fetching sync committee duties: %w", err)
+	}
+	results := append(append([]domain.DutyResult(nil), proposals...), syncResults...)
+	for i := range results {
+		a.annotate(&results[i])
+	}
+	_, err = data.evaluate(ctx, a.BeaconAdapter, epoch, indices, results,
+		func(_ domain.ValidatorDuty, r domain.DutyResult) {
+			a.annotate(&r)
+			results = append(results, r)
+		})
+	if err != nil {
+		return nil, fmt.Errorf("fetching attester duties: %w", err)
+	}
+	return results, nil
+}
+
+func (a *DutiesChecker) markProcessed(epoch domain.Epoch) {
+	a.processedEpoch.Store(uint64(epoch))
+	a.processed.Store(true)
+}
+
+// checkProposals evaluates the proposals of the epoch and returns them, to
+// be reported by checkAttestations. It fails if the proposer duties could
+// not be fetched.
 func (a *DutiesChecker) checkProposals(
 	ctx context.Context,
 	finalizedEpoch domain.Epoch,
 	indices []domain.ValidatorIndex,
-) {
-	proposerDuties, err := a.BeaconAdapter.GetProposerDuties(ctx, finalizedEpoch, indices)
+) ([]domain.DutyResult, error) {
+	results, err := evaluateProposals(ctx, a.BeaconAdapter, a.concurrency(), finalizedEpoch, indices)
 	if err != nil {
-		logger.Error("Error fetching proposer duties: %v", err)
-		return
+		return nil, fmt.Errorf("fetching proposer duties: %w", err)
 	}
 
-	if len(proposerDuties) == 0 {
+	if len(results) == 0 {
 		logger.Warn("No proposer duties found for finalized epoch %d.", finalizedEpoch)
-		return
+		return nil, nil
+	}
+	return results, nil
+}
+
+// evaluateProposals fetches the proposer duties of the epoch and checks
+// whether each scheduled block exists and was proposed by the duty's
+// validator. Duties whose block could not be queried get an unknown
+// outcome. Blocks are checked concurrently, at most concurrency at a time;
+// results keep the order of the duties.
+func evaluateProposals(
+	ctx context.Context,
+	beacon ports.BeaconChainAdapter,
+	concurrency int,
+	epoch domain.Epoch,
+	indices []domain.ValidatorIndex,
+) ([]domain.DutyResult, error) {
+	proposerDuties, err := beacon.GetProposerDuties(ctx, epoch, indices)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, duty := range proposerDuties {
-		didPropose, err := a.BeaconAdapter.DidProposeBlock(ctx, duty.Slot)
-		if err != nil {
-			logger.Warn("⚠️ Could not determine if block was proposed at slot %d: %v", duty.Slot, err)
-			continue
+	blocks, errs := workerpool.Map(ctx, concurrency, proposerDuties,
+		func(ctx context.Context, duty domain.ProposerDuty) (*domain.Block, error) {
+			block, err := beacon.GetBlock(ctx, duty.Slot)
+			if errors.Is(err, ports.ErrNotFound) {
+				return nil, nil // Block was not proposed
+			}
+			if err != nil {
+				return nil, err
+			}
+			return &block, nil
+		})
+
+	var results []domain.DutyResult
+	for i, duty := range proposerDuties {
+		r := domain.DutyResult{
+			Type:           domain.DutyTypeProposal,
+			ValidatorIndex: duty.ValidatorIndex,
+			Epoch:          epoch,
+			Slot:           duty.Slot,
 		}
-		if didPropose {
-			logger.Info("✅ Validator %d successfully proposed a block at slot %d",
-				duty.ValidatorIndex, duty.Slot)
-		} else {
-			logger.Warn("❌ Validator %d was scheduled to propose at slot %d but did not",
-				duty.ValidatorIndex, duty.Slot)
+		block, err := blocks[i], errs[i]
+		switch {
+		case err != nil:
+			r.Outcome = domain.OutcomeUnknown
+			r.Reason = fmt.Sprintf("could not fetch block at slot %d: %v", duty.Slot, err)
+		case block != nil && block.ProposerIndex == duty.ValidatorIndex:
+			r.Outcome = domain.OutcomeSuccess
+			r.InclusionSlot = duty.Slot
+		case block != nil:
+			r.Outcome = domain.OutcomeMissed
+			r.Reason = fmt.Sprintf("block at slot %d was proposed by validator %d", duty.Slot, block.ProposerIndex)
+		default:
+			r.Outcome = domain.OutcomeMissed
+			r.Reason = fmt.Sprintf("no block at slot %d", duty.Slot)
 		}
+		results = append(results, r)
 	}
+	return results, nil
 }
 
 func (a *DutiesChecker) checkAttestations(
 	ctx context.Context,
 	finalizedEpoch domain.Epoch,
 	validatorIndices []domain.ValidatorIndex,
-) {
-	duties, err := a.BeaconAdapter.GetValidatorDutiesBatch(ctx, finalizedEpoch, validatorIndices)
-
+	proposals []domain.DutyResult,
+) error {
+	data := fetchEpochAttestations(ctx, a.BeaconAdapter, a.concurrency(), finalizedEpoch)
+	data.rewards = true
+	a.useBaseline(data.baseline, true)
+	for _, r := range proposals {
+		a.report(r)
+	}
+	syncResults, err := data.evaluateSyncCommittee(ctx, a.BeaconAdapter, finalizedEpoch, validatorIndices)
 	if err != nil {
-		logger.Error("Error fetching validator duties: %v", err)
-		return
+		logger.Error("Error fetching sync committee duties: %v", err)
 	}
-	if len(duties) == 0 {
+	for _, r := range syncResults {
+		a.report(r)
+	}
+	scored := append(append([]domain.DutyResult(nil), proposals...), syncResults...)
+	total, err := data.evaluate(ctx, a.BeaconAdapter, finalizedEpoch, validatorIndices, scored,
+		func(_ domain.ValidatorDuty, r domain.DutyResult) {
+			a.report(r)
+			a.markCheckedThisEpoch(r.ValidatorIndex, finalizedEpoch)
+		})
+	if err != nil {
+		return fmt.Errorf("fetching validator duties: %w", err)
+	}
+	if total == 0 {
 		logger.Warn("No duties found for finalized epoch %d. This should not happen!", finalizedEpoch)
-		return
 	}
+	return nil
+}
+
+// dutyChunkSize is how many validators' attester duties are requested, and
+// then evaluated, at a time. It keeps request bodies and the per-chunk duties
+// and results small when the whole validator set is tracked.
+const dutyChunkSize = 10_000
+
+// epochAttestations is the epoch-wide data that attestation duties are checked
+// against. It is fetched once per epoch, however many validators are tracked.
+type epochAttestations struct {
+	// committees holds the full membership of every committee of the epoch.
+	committees domain.EpochCommittees
+	// committeesErr and unavailableSlots record the data that could not be
+	// fetched; duties depending on it get an unknown outcome instead of a miss.
+	committeesErr    error
+	unavailableSlots map[domain.Slot]error
+	// included indexes the aggregates included on-chain by (slot, committee).
+	included attestationIndex
+	// parentRoots holds the parent root of every fetched block up to
+	// lastSlot, to tell which blocks are canonical (see blockRootAt).
+	parentRoots map[domain.Slot]domain.Root
+	lastSlot    domain.Slot
+	// syncBits holds the sync committee bits of every fetched block of the
+	// epoch itself.
+	syncBits map[domain.Slot][]byte
+	// rewards makes evaluate and evaluateSyncCommittee fetch the rewards
+	// missed on the duties. Only set for finalized epochs, whose rewards
+	// are final.
+	rewards bool
+	// baseline is the network baseline of the epoch, nil if the committees
+	// could not be fetched.
+	baseline *domain.NetworkBaseline
+}
 
-	// Collect unique duty slots.
-	dutySlots := make(map[domain.Slot]struct{})
-	for _, d := range duties {
-		dutySlots[d.Slot] = struct{}{}
+// evaluateAttestations fetches the epoch-wide data (see
+// fetchEpochAttestations) and evaluates the attester duties of the given
+// validators against it (see epochAttestations.evaluate).
+//
+// It returns the epoch-wide data and the number of duties evaluated. An error
+// fetching duties stops the evaluation; results already emitted stand.
+func evaluateAttestations(
+	ctx context.Context,
+	beacon ports.BeaconChainAdapter,
+	concurrency int,
+	epoch domain.Epoch,
+	validatorIndices []domain.ValidatorIndex,
+	proposals []domain.DutyResult,
+	emit func(domain.ValidatorDuty, domain.DutyResult),
+) (*epochAttestations, int, error) {
+	data := fetchEpochAttestations(ctx, beacon, concurrency, epoch)
+	total, err := data.evaluate(ctx, beacon, epoch, validatorIndices, proposals, emit)
+	return data, total, err
+}
+
+// fetchEpochAttestations fetches all committees of the epoch and its blocks
+// with those that can include its attestations, at most concurrency at a
+// time, and indexes the attestations. The network baseline of the epoch is
+// computed from them when the committees could be fetched.
+func fetchEpochAttestations(
+	ctx context.Context,
+	beacon ports.BeaconChainAdapter,
+	concurrency int,
+	epoch domain.Epoch,
+) *epochAttestations {
+	data := &epochAttestations{}
+
+	// Build: slot -> committee-index -> members from a single epoch-level
+	// committees call.
+	logger.Info("Fetching all committees for epoch %d", epoch)
+	data.committees, data.committeesErr = beacon.GetEpochCommittees(ctx, epoch)
+	if data.committeesErr != nil {
+		logger.Warn("Error fetching committees for epoch %d: %v", epoch, data.committeesErr)
+	}
+
+	// Attestations for the epoch's slots are included in the following 32
+	// slots; the epoch's first block only counts for the missed-slot rate.
+	firstSlot := domain.Slot(epoch) * SlotsPerEpoch
+	var blocks map[domain.Slot]domain.Block
+	data.lastSlot = firstSlot + 2*SlotsPerEpoch - 1
+	blocks, data.unavailableSlots = preloadBlocks(ctx, beacon, concurrency, firstSlot, data.lastSlot)
+	data.parentRoots = parentRoots(blocks)
+	data.syncBits = syncBitsOf(epoch, blocks)
+	if data.committeesErr == nil {
+		data.included = buildAttestationIndex(epoch, data.committees, blocks)
+		baseline := data.networkBaseline(epoch, blocks)
+		data.baseline = &baseline
+	}
+	return data
+}
+
+// evaluate requests the attester duties of the given validators
+// dutyChunkSize at a time and decides for each duty whether the validator's
+// attestation made it on-chain. Each result is passed to emit as soon as it
+// is known, so memory stays bounded by one chunk of duties. Each result is
+// scored with the validator's proposal and sync committee results in scored
+// (see domain.EpochEffectiveness) and, if e.rewards is set, carries the reward
+// the validator missed; rewards that cannot be fetched are left unset.
+func (e *epochAttestations) evaluate(
+	ctx context.Context,
+	beacon ports.BeaconChainAdapter,
+	epoch domain.Epoch,
+	validatorIndices []domain.ValidatorIndex,
+	scored []domain.DutyResult,
+	emit func(domain.ValidatorDuty, domain.DutyResult),
+) (int, error) {
+	scoredOf := make(map[domain.ValidatorIndex][]domain.DutyResult)
+	for _, r := range scored {
+		scoredOf[r.ValidatorIndex] = append(scoredOf[r.ValidatorIndex], r)
 	}
 
-	// Build: slot -> committee-index -> size using full committee info from beacon.
-	logger.Info("Fetching all committee sizes for %d unique duty slots", len(dutySlots))
-	slotCommitteeSizes := make(map[domain.Slot]domain.CommitteeSizeMap)
-	for slot := range dutySlots {
-		m, err := a.BeaconAdapter.GetCommitteeSizeMap(ctx, slot)
+	total := 0
+	for start := 0; start < len(validatorIndices); start += dutyChunkSize {
+		chunk := validatorIndices[start:min(start+dutyChunkSize, len(validatorIndices))]
+		duties, err := beacon.GetValidatorDutiesBatch(ctx, epoch, chunk)
 		if err != nil {
-			logger.Warn("Error fetching committee sizes for slot %d: %v", slot, err)
-			continue
+			return total, err
+		}
+		logger.Info("Searching attestations made in the next 32 slots for %d duties", len(duties))
+		var missedReward map[domain.ValidatorIndex]int64
+		if e.rewards {
+			rewards, err := beacon.GetAttestationRewards(ctx, epoch, chunk)
+			if err != nil {
+				logger.Warn("Error fetching attestation rewards for epoch %d: %v", epoch, err)
+			}
+			missedReward = missedRewards(rewards)
+		}
+		for _, duty := range duties {
+			r := e.evaluateDuty(epoch, duty)
+			r.Effectiveness = domain.EpochEffectiveness(r, scoredOf[duty.ValidatorIndex])
+			r.MissedReward = missedReward[duty.ValidatorIndex]
+			emit(duty, r)
+		}
+		total += len(duties)
+	}
+	return total, nil
+}
+
+// evaluateDuty decides the outcome of one attestation duty. A duty is only
+// missed when all the data it depends on was fetched; otherwise it is unknown.
+func (e *epochAttestations) evaluateDuty(epoch domain.Epoch, duty domain.ValidatorDuty) domain.DutyResult {
+	r := domain.DutyResult{
+		Type:           domain.DutyTypeAttestation,
+		ValidatorIndex: duty.ValidatorIndex,
+		Epoch:          epoch,
+		Slot:           duty.Slot,
+		CommitteeIndex: duty.CommitteeIndex,
+	}
+	if e.committeesErr != nil {
+		r.Outcome = domain.OutcomeUnknown
+		r.Reason = fmt.Sprintf("committees unavailable for epoch %d: %v", epoch, e.committeesErr)
+		return r
+	}
+	// The bit position is only meaningful if the duty matches the committee
+	// the node gave us; a mismatch means inconsistent beacon data, not a miss.
+	members := e.committees[duty.Slot][duty.CommitteeIndex]
+	if duty.ValidatorCommitteeIdx >= uint64(len(members)) || members[duty.ValidatorCommitteeIdx] != duty.ValidatorIndex {
+		r.Outcome = domain.OutcomeUnknown
+		r.Reason = fmt.Sprintf("duty position %d in committee %d of slot %d does not match committee membership",
+			duty.ValidatorCommitteeIdx, duty.CommitteeIndex, duty.Slot)
+		return r
+	}
+	if agg, found := e.included.find(duty); found {
+		r.Outcome = domain.OutcomeSuccess
+		r.InclusionSlot = agg.inclusionSlot
+		r.Head = e.vote(agg.head, duty.Slot)
+		r.Target = e.vote(agg.target, domain.Slot(epoch)*SlotsPerEpoch)
+		return r
+	}
+	// Not found: only a miss if every block of the inclusion window was fetched.
+	for slot := duty.Slot + 1; slot <= duty.Slot+32; slot++ {
+		if err, ok := e.unavailableSlots[slot]; ok {
+			r.Outcome = domain.OutcomeUnknown
+			r.Reason = fmt.Sprintf("block at slot %d in the inclusion window unavailable: %v", slot, err)
+			return r
+		}
+	}
+	r.Outcome = domain.OutcomeMissed
+	r.Reason = fmt.Sprintf("no aggregate with the validator's bit set in blocks %d-%d", duty.Slot+1, duty.Slot+32)
+	return r
+}
+
+// vote tells whether voted is the root of the canonical block at slot.
+func (e *epochAttestations) vote(voted domain.Root, slot domain.Slot) domain.Vote {
+	canonical, ok := e.blockRootAt(slot)
+	switch {
+	case !ok || voted.IsZero():
+		return domain.VoteUnknown
+	case voted == canonical:
+		return domain.VoteCorrect
+	default:
+		return domain.VoteWrong
+	}
+}
+
+// blockRootAt returns the root of the canonical block at slot, or of the last
+// one before it if the slot was missed: the parent root of the first block
+// after slot. It returns false if that block is not among the fetched ones,
+// or a slot before it could not be fetched.
+func (e *epochAttestations) blockRootAt(slot domain.Slot) (domain.Root, bool) {
+	for next := slot + 1; next <= e.lastSlot; next++ {
+		if _, ok := e.unavailableSlots[next]; ok {
+			return domain.Root{}, false
 		}
-		logger.Info("Slot %d commitee size gotten", slot)
-		slotCommitteeSizes[slot] = m
-	}
-
-	minSlot, maxSlot := getSlotRangeForDuties(duties)
-	slotAttestations := preloadSlotAttestations(ctx, a.BeaconAdapter, minSlot, maxSlot)
-
-	logger.Info("Searching attestations made in the next 32 slots for %d duties", len(duties))
-	for _, duty := range duties {
-		attestationFound := a.checkDutyAttestation(ctx, duty, slotAttestations, slotCommitteeSizes)
-		if !attestationFound {
-			logger.Warn(
-				" ❌ No attestation found for validator %d in finalized epoch %d; duty=%+v",
-				duty.ValidatorIndex,
-				finalizedEpoch,
-				duty,
-			)
+		if root, ok := e.parentRoots[next]; ok {
+			return root, !root.IsZero()
 		}
-		a.markCheckedThisEpoch(duty.ValidatorIndex, finalizedEpoch)
+	}
+	return domain.Root{}, false
+}
+
+// report annotates, logs and stores the finalized outcome of a single duty
+// and compares it with the provisional one, if any.
+func (a *DutiesChecker) report(r domain.DutyResult) {
+	a.annotate(&r)
+	a.logResult(r)
+	a.storeResult(r)
+	a.confirmProvisional(r)
+	if r.Outcome == domain.OutcomeMissed {
+		a.misses = append(a.misses, r)
+	}
+}
+
+// logResult logs the outcome of a single duty. The message only depends on
+// the duty type and outcome; the details are in the fields (see dutyFields).
+func (a *DutiesChecker) logResult(r domain.DutyResult) {
+	log := logger.With(a.dutyFields(r))
+	switch {
+	case r.Outcome == domain.OutcomeUnknown:
+		log.Warn("Could not determine %s duty", r.Type)
+	case r.Type == domain.DutyTypeProposal && r.Outcome == domain.OutcomeSuccess:
+		log.Info("Block proposed")
+	case r.Type == domain.DutyTypeProposal:
+		log.Warn("Block proposal missed")
+	case r.Type == domain.DutyTypeSyncCommittee && r.Outcome == domain.OutcomeSuccess:
+		log.Info("Sync committee signature included")
+	case r.Type == domain.DutyTypeSyncCommittee:
+		log.Warn("Sync committee signature missed")
+	case r.Outcome == domain.OutcomeSuccess:
+		log.Info("Attestation included")
+	default:
+		log.Warn("Attestation missed")
 	}
 }
 
@@ -190,96 +691,42 @@ func (a *DutiesChecker) markCheckedThisEpoch(index domain.ValidatorIndex, epoch
 	a.checkedEpochs[index] = epoch
 }
 
-// Important: This function assumes duties is not empty (at least one duty exists).
-func getSlotRangeForDuties(duties []domain.ValidatorDuty) (domain.Slot, domain.Slot) {
-	minSlot, maxSlot := duties[0].Slot, duties[0].Slot
-	for _, d := range duties {
-		if d.Slot < minSlot {
-			minSlot = d.Slot
-		}
-		if d.Slot > maxSlot {
-			maxSlot = d.Slot
-		}
+// preloadBlocks fetches every block in [firstSlot, lastSlot], at most
+// concurrency blocks at a time. Slots without a block (missed slots) are
+// simply absent from the result; slots that could not be fetched are
+// returned in the second map with their error.
+func preloadBlocks(ctx context.Context, beacon ports.BeaconChainAdapter, concurrency int, firstSlot, lastSlot domain.Slot) (map[domain.Slot]domain.Block, map[domain.Slot]error) {
+	var slots []domain.Slot
+	for slot := firstSlot; slot <= lastSlot; slot++ {
+		slots = append(slots, slot)
 	}
-	return minSlot, maxSlot
-}
-
-func preloadSlotAttestations(ctx context.Context, beacon ports.BeaconChainAdapter, minSlot, maxSlot domain.Slot) map[domain.Slot][]domain.Attestation {
-	result := make(map[domain.Slot][]domain.Attestation)
-	for slot := minSlot + 1; slot <= maxSlot+32; slot++ {
-		att, err := beacon.GetBlockAttestations(ctx, slot)
-		if err != nil {
-			logger.Warn("Error fetching attestations for slot %d: %v. Was this slot missed?", slot, err)
+	blocks, errs := workerpool.Map(ctx, concurrency, slots, beacon.GetBlock)
+
+	result := make(map[domain.Slot]domain.Block)
+	unavailable := make(map[domain.Slot]error)
+	for i, slot := range slots {
+		block, err := blocks[i], errs[i]
+		if errors.Is(err, ports.ErrNotFound) {
+			logger.Debug("No block at slot %d (missed slot)", slot)
 			continue
 		}
-		result[slot] = att
-	}
-	return result
-}
-
-// checkDutyAttestation checks if there is an attestation for the given duty in the next 32 slots.
-// It uses the committee size cache to avoid fetching committee sizes for every duty in repeated slots.
-func (a *DutiesChecker) checkDutyAttestation(
-	ctx context.Context,
-	duty domain.ValidatorDuty,
-	slotAttestations map[domain.Slot][]domain.Attestation,
-	slotCommitteeSizes map[domain.Slot]domain.CommitteeSizeMap,
-) bool {
-	committeeSizeMap, ok := slotCommitteeSizes[duty.Slot]
-	if !ok {
-		logger.Warn("No committee size map for duty slot %d", duty.Slot)
-		return false
-	}
-
-	for slot := duty.Slot + 1; slot <= duty.Slot+32; slot++ {
-		attestations := slotAttestations[slot]
-		for _, att := range attestations {
-			if att.DataSlot != duty.Slot {
-				continue
-			}
-			if !isBitSet(att.CommitteeBits, int(duty.CommitteeIndex)) {
-				continue
-			}
-			bitPosition := computeBitPosition(
-				duty.CommitteeIndex,
-				duty.ValidatorCommitteeIdx,
-				att.CommitteeBits,
-				committeeSizeMap,
-			)
-			if !isBitSet(att.AggregationBits, bitPosition) {
-				continue
-			}
-			logger.Info("✅ Validator %d attested in committee %d for duty slot %d (included in block slot %d)",
-				duty.ValidatorIndex, duty.CommitteeIndex, duty.Slot, slot)
-
-			return true
+		if err != nil {
+			logger.Warn("Error fetching block at slot %d: %v", slot, err)
+			unavailable[slot] = err
+			continue
 		}
+		result[slot] = block
 	}
-
-	return false
+	return result, unavailable
 }
 
-// computeBitPosition calculates the bit position for the validator in the committee bits.
-// It sums the sizes of all committees before the one the validator is in, and adds the validator's index in that committee.
-// This is used to determine if the validator's aggregation bit is set in the attestation.
-func computeBitPosition(
-	validatorCommitteeIndex domain.CommitteeIndex,
-	validatorIndexInCommittee uint64,
-	committeeBits []byte,
-	committeeSizeMap domain.CommitteeSizeMap,
-) int {
-	bitPosition := 0
-	for i := 0; i < 64; i++ {
-		if !isBitSet(committeeBits, i) { // if the committee bit is not set, dont add its size to final bit position
-			continue
-		}
-		if i == int(validatorCommitteeIndex) { // We got to the committee of the validator, we can stop here.
-			break
-		}
-		bitPosition += committeeSizeMap[domain.CommitteeIndex(i)] // Add the size of the committee to the bit position. Bit was set and it's not the committee of the validator.
+// parentRoots returns the parent root of each block.
+func parentRoots(blocks map[domain.Slot]domain.Block) map[domain.Slot]domain.Root {
+	roots := make(map[domain.Slot]domain.Root, len(blocks))
+	for slot, b := range blocks {
+		roots[slot] = b.ParentRoot
 	}
-	bitPosition += int(validatorIndexInCommittee)
-	return bitPosition
+	return roots
 }
 
 func isBitSet(bits []byte, index int) bool {
@@ -0,0 +1,408 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/testutil"
+)
+
+// memoryResultStore is a ports.ResultStore keeping committed epochs in memory.
+type memoryResultStore struct {
+	mu     sync.Mutex
+	epochs map[domain.Epoch][]domain.DutyResult
+}
+
+func newMemoryResultStore() *memoryResultStore {
+	return &memoryResultStore{epochs: make(map[domain.Epoch][]domain.DutyResult)}
+}
+
+func (s *memoryResultStore) BeginEpoch(epoch domain.Epoch) (ports.EpochResultWriter, error) {
+	return &memoryEpochWriter{store: s, epoch: epoch}, nil
+}
+
+func (s *memoryResultStore) LastEpoch() (domain.Epoch, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var last domain.Epoch
+	for epoch := range s.epochs {
+		last = max(last, epoch)
+	}
+	return last, len(s.epochs) > 0, nil
+}
+
+func (s *memoryResultStore) results(epoch domain.Epoch) ([]domain.DutyResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results, ok := s.epochs[epoch]
+	return results, ok
+}
+
+type memoryEpochWriter struct {
+	store   *memoryResultStore
+	epoch   domain.Epoch
+	results []domain.DutyResult
+}
+
+func (w *memoryEpochWriter) Write(r domain.DutyResult) error {
+	w.results = append(w.results, r)
+	return nil
+}
+
+func (w *memoryEpochWriter) Commit() error {
+	w.store.mu.Lock()
+	defer w.store.mu.Unlock()
+	w.store.epochs[w.epoch] = w.results
+	return nil
+}
+
+func (w *memoryEpochWriter) Rollback() error { return nil }
+
+// checkFinalizedEpoch runs one finalized epoch check of indices against
+// beacon and returns the stored results.
+func checkFinalizedEpoch(t *testing.T, beacon ports.BeaconChainAdapter, indices []domain.ValidatorIndex) []domain.DutyResult {
+	t.Helper()
+	ctx := context.Background()
+	epoch, err := beacon.GetFinalizedEpoch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newMemoryResultStore()
+	checker := NewDutiesChecker(beacon, time.Minute, indices)
+	if err := checker.SetResultStore(store); err != nil {
+		t.Fatal(err)
+	}
+	checker.checkLatestFinalizedEpoch(ctx)
+	results, ok := store.results(epoch)
+	if !ok {
+		t.Fatalf("no results committed for epoch %d", epoch)
+	}
+	return results
+}
+
+func countOutcomes(results []domain.DutyResult) map[domain.DutyOutcome]int {
+	counts := make(map[domain.DutyOutcome]int)
+	for _, r := range results {
+		counts[r.Outcome]++
+	}
+	return counts
+}
+
+func hasWrongVotes(results []domain.DutyResult) bool {
+	head, target := false, false
+	for _, r := range results {
+		head = head || r.Head == domain.VoteWrong
+		target = target || r.Target == domain.VoteWrong
+	}
+	return head && target
+}
+
+func hasMissedSyncRewards(results []domain.DutyResult) bool {
+	for _, r := range results {
+		if r.Type == domain.DutyTypeSyncCommittee && r.Outcome == domain.OutcomeMissed && r.MissedReward > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDutiesCheckerMatchesFakeChain(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  fakechain.Config
+	}{
+		{
+			name: "single committee per slot",
+			cfg:  fakechain.Config{Seed: 1, Validators: 512, Epochs: 4, MissedAttestationRate: 0.05},
+		},
+		{
+			name: "electra aggregates across committees",
+			cfg:  fakechain.Config{Seed: 2, Validators: 4096, CommitteesPerSlot: 16, Epochs: 4, MissedAttestationRate: 0.03},
+		},
+		{
+			name: "all 64 committees",
+			cfg:  fakechain.Config{Seed: 3, Validators: 8192, CommitteesPerSlot: 64, Epochs: 3, MissedAttestationRate: 0.02},
+		},
+		{
+			name: "missed slots and late inclusion",
+			cfg: fakechain.Config{
+				Seed: 4, Validators: 2048, CommitteesPerSlot: 4, Epochs: 4,
+				MissedSlotRate: 0.2, MissedAttestationRate: 0.05, MaxInclusionDelay: 6, DuplicateRate: 0.3,
+			},
+		},
+		{
+			name: "inclusion past the window",
+			cfg: fakechain.Config{
+				Seed: 5, Validators: 1024, CommitteesPerSlot: 2, Epochs: 4,
+				MissedSlotRate: 0.5, MaxInclusionDelay: 32,
+			},
+		},
+		{
+			name: "uneven committee sizes",
+			cfg:  fakechain.Config{Seed: 6, Validators: 1000, CommitteesPerSlot: 3, Epochs: 3, MissedAttestationRate: 0.1},
+		},
+		{
+			name: "wrong head and target votes",
+			cfg: fakechain.Config{
+				Seed: 13, Validators: 2048, CommitteesPerSlot: 4, Epochs: 4,
+				MissedSlotRate: 0.1, MissedAttestationRate: 0.05, MaxInclusionDelay: 3,
+				WrongHeadRate: 0.2, WrongTargetRate: 0.1,
+			},
+		},
+		{
+			name: "sync committee",
+			cfg: fakechain.Config{
+				Seed: 14, Validators: 1024, CommitteesPerSlot: 4, Epochs: 4,
+				MissedSlotRate: 0.1, MissedAttestationRate: 0.05,
+				SyncCommitteeSize: 512, MissedSyncRate: 0.1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := testutil.NewChain(t, tt.cfg)
+			indices := testutil.Validators(tt.cfg.Validators)
+			epoch, _ := chain.GetFinalizedEpoch(context.Background())
+
+			want := chain.Expected(epoch, indices)
+			got := checkFinalizedEpoch(t, chain, indices)
+			testutil.AssertResults(t, got, want)
+
+			counts := countOutcomes(want)
+			if counts[domain.OutcomeMissed] == 0 && (tt.cfg.MissedAttestationRate > 0 || tt.cfg.MissedSlotRate > 0) {
+				t.Errorf("chain has no missed duty, the test checks nothing: %v", counts)
+			}
+			if tt.cfg.WrongHeadRate > 0 && !hasWrongVotes(want) {
+				t.Error("chain has no wrong head or target vote, the test checks nothing")
+			}
+			if tt.cfg.MissedSyncRate > 0 && !hasMissedSyncRewards(want) {
+				t.Error("chain has no missed sync committee signature with a reward, the test checks nothing")
+			}
+			t.Logf("%d duties: %v", len(want), counts)
+		})
+	}
+}
+
+func TestDutiesCheckerTracksSubset(t *testing.T) {
+	chain := testutil.NewChain(t, fakechain.Config{
+		Seed: 7, Validators: 2048, CommitteesPerSlot: 8, Epochs: 4, MissedSlotRate: 0.1, MissedAttestationRate: 0.1,
+	})
+	var indices []domain.ValidatorIndex
+	for v := domain.ValidatorIndex(3); v < 2048; v += 17 {
+		indices = append(indices, v)
+	}
+	epoch, _ := chain.GetFinalizedEpoch(context.Background())
+
+	got := checkFinalizedEpoch(t, chain, indices)
+	testutil.AssertResults(t, got, chain.Expected(epoch, indices))
+}
+
+func TestDutiesCheckerUnavailableDataIsUnknown(t *testing.T) {
+	cfg := fakechain.Config{
+		Seed: 8, Validators: 2048, CommitteesPerSlot: 4, Epochs: 4, MissedAttestationRate: 0.05,
+		SyncCommitteeSize: 64, MissedSyncRate: 0.05,
+	}
+	indices := testutil.Validators(cfg.Validators)
+
+	t.Run("blocks", func(t *testing.T) {
+		chain := testutil.NewChain(t, cfg)
+		epoch, _ := chain.GetFinalizedEpoch(context.Background())
+		firstSlot := domain.Slot(epoch) * SlotsPerEpoch
+		// One block inside the epoch, one in the inclusion window after it.
+		chain.FailBlock(firstSlot+5, fmt.Errorf("%w: status 500", ports.ErrServer))
+		chain.FailBlock(firstSlot+40, fmt.Errorf("%w: status 503", ports.ErrServer))
+
+		want := chain.Expected(epoch, indices)
+		if countOutcomes(want)[domain.OutcomeUnknown] == 0 {
+			t.Fatal("failed blocks leave no duty unknown")
+		}
+		testutil.AssertResults(t, checkFinalizedEpoch(t, chain, indices), want)
+	})
+
+	t.Run("committees", func(t *testing.T) {
+		chain := testutil.NewChain(t, cfg)
+		epoch, _ := chain.GetFinalizedEpoch(context.Background())
+		chain.FailCommittees(epoch, fmt.Errorf("%w: status 500", ports.ErrServer))
+
+		got := checkFinalizedEpoch(t, chain, indices)
+		testutil.AssertResults(t, got, chain.Expected(epoch, indices))
+		for _, r := range got {
+			if r.Type == domain.DutyTypeAttestation && r.Outcome != domain.OutcomeUnknown {
+				t.Fatalf("attestation of validator %d is %s without committees", r.ValidatorIndex, r.Outcome)
+			}
+		}
+	})
+}
+
+func TestDutiesCheckerIsDeterministic(t *testing.T) {
+	cfg := fakechain.Config{
+		Seed: 9, Validators: 1024, CommitteesPerSlot: 4, Epochs: 3,
+		MissedSlotRate: 0.1, MissedAttestationRate: 0.1, MaxInclusionDelay: 3,
+	}
+	indices := testutil.Validators(cfg.Validators)
+	first := checkFinalizedEpoch(t, testutil.NewChain(t, cfg), indices)
+	second := checkFinalizedEpoch(t, testutil.NewChain(t, cfg), indices)
+	sortResults(first)
+	sortResults(second)
+	if len(first) != len(second) {
+		t.Fatalf("%d results, then %d", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("result %d differs: %+v, then %+v", i, first[i], second[i])
+		}
+	}
+}
+
+func sortResults(results []domain.DutyResult) {
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Slot != b.Slot {
+			return a.Slot < b.Slot
+		}
+		return a.ValidatorIndex < b.ValidatorIndex
+	})
+}
+
+func TestDutiesCheckerRunFollowsFinality(t *testing.T) {
+	cfg := fakechain.Config{Seed: 10, Validators: 512, CommitteesPerSlot: 2, Epochs: 5, MissedAttestationRate: 0.05}
+	chain := testutil.NewChain(t, cfg)
+	chain.SetFinalized(2)
+	indices := testutil.Validators(cfg.Validators)
+
+	store := newMemoryResultStore()
+	checker := NewDutiesChecker(chain, 10*time.Millisecond, indices)
+	if err := checker.SetResultStore(store); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		checker.Run(context.Background(), stop)
+	}()
+
+	waitForEpoch := func(epoch domain.Epoch) []domain.DutyResult {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if results, ok := store.results(epoch); ok {
+				return results
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("epoch %d was not checked", epoch)
+		return nil
+	}
+	testutil.AssertResults(t, waitForEpoch(2), chain.Expected(2, indices))
+	chain.SetFinalized(3)
+	testutil.AssertResults(t, waitForEpoch(3), chain.Expected(3, indices))
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after stop")
+	}
+	if epoch, ok := checker.LastProcessedEpoch(); !ok || epoch != 3 {
+		t.Errorf("last processed epoch %d (%t), want 3", epoch, ok)
+	}
+}
+
+// cancellingChain cancels the check in progress once committees are fetched,
+// as a shutdown after the grace period would.
+type cancellingChain struct {
+	*fakechain.Chain
+	cancel context.CancelFunc
+}
+
+func (c cancellingChain) GetEpochCommittees(ctx context.Context, epoch domain.Epoch) (domain.EpochCommittees, error) {
+	c.cancel()
+	return c.Chain.GetEpochCommittees(ctx, epoch)
+}
+
+func TestDutiesCheckerRollsBackInterruptedEpoch(t *testing.T) {
+	chain := testutil.NewChain(t, fakechain.Config{Seed: 11, Validators: 256, Epochs: 3})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := newMemoryResultStore()
+	checker := NewDutiesChecker(cancellingChain{Chain: chain, cancel: cancel}, time.Minute, testutil.Validators(256))
+	if err := checker.SetResultStore(store); err != nil {
+		t.Fatal(err)
+	}
+	checker.checkLatestFinalizedEpoch(ctx)
+
+	if _, ok := store.results(1); ok {
+		t.Error("results of the interrupted epoch were committed")
+	}
+	if epoch, ok := checker.LastProcessedEpoch(); ok {
+		t.Errorf("interrupted epoch %d marked as processed", epoch)
+	}
+}
+
+// failingDutiesChain fails the duty requests named by fail until it is
+// cleared.
+type failingDutiesChain struct {
+	*fakechain.Chain
+	fail *string
+}
+
+func (c failingDutiesChain) GetProposerDuties(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ProposerDuty, error) {
+	if *c.fail == "proposer" {
+		return nil, fmt.Errorf("%w: status 503", ports.ErrServer)
+	}
+	return c.Chain.GetProposerDuties(ctx, epoch, indices)
+}
+
+func (c failingDutiesChain) GetValidatorDutiesBatch(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ValidatorDuty, error) {
+	if *c.fail == "attester" {
+		return nil, fmt.Errorf("%w: status 503", ports.ErrServer)
+	}
+	return c.Chain.GetValidatorDutiesBatch(ctx, epoch, indices)
+}
+
+func TestDutiesCheckerRetriesFailedEpoch(t *testing.T) {
+	for _, fail := range []string{"proposer", "attester"} {
+		t.Run(fail, func(t *testing.T) {
+			ctx := context.Background()
+			chain := testutil.NewChain(t, fakechain.Config{Seed: 11, Validators: 256, Epochs: 3})
+			indices := testutil.Validators(256)
+			store := newMemoryResultStore()
+			checker := NewDutiesChecker(failingDutiesChain{Chain: chain, fail: &fail}, time.Minute, indices)
+			if err := checker.SetResultStore(store); err != nil {
+				t.Fatal(err)
+			}
+			checker.checkLatestFinalizedEpoch(ctx)
+
+			if _, ok := store.results(1); ok {
+				t.Error("results of the failed epoch were committed")
+			}
+			if epoch, ok := checker.LastProcessedEpoch(); ok {
+				t.Errorf("failed epoch %d marked as processed", epoch)
+			}
+
+			// The next check starts the epoch over.
+			fail = ""
+			checker.checkLatestFinalizedEpoch(ctx)
+			got, ok := store.results(1)
+			if !ok {
+				t.Fatal("the failed epoch was not retried")
+			}
+			testutil.AssertResults(t, got, chain.Expected(1, indices))
+			if epoch, ok := checker.LastProcessedEpoch(); !ok || epoch != 1 {
+				t.Errorf("last processed epoch %d, %t after the retry, want 1", epoch, ok)
+			}
+		})
+	}
+}
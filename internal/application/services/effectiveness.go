@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+)
+
+// RankEffectiveness sums the effectiveness stored for the last epochs epochs,
+// up to the latest stored one, per key of keyOf, and ranks the keys by score,
+// best first. keyOf names what is ranked (a validator, its group, one of its
+// labels); validators it gives no key are left out. by is only recorded in
+// the ranking.
+func RankEffectiveness(
+	reader ports.ResultReader,
+	epochs int,
+	by string,
+	keyOf func(domain.ValidatorIndex) string,
+) (domain.EffectivenessRanking, error) {
+	ranking := domain.EffectivenessRanking{By: by, Ranks: []domain.EffectivenessRank{}}
+	stored, err := reader.Epochs()
+	if err != nil {
+		return ranking, fmt.Errorf("listing stored epochs: %w", err)
+	}
+	if len(stored) == 0 || epochs <= 0 {
+		return ranking, nil
+	}
+	ranking.ToEpoch = stored[len(stored)-1]
+	if domain.Epoch(epochs) <= ranking.ToEpoch {
+		ranking.FromEpoch = ranking.ToEpoch - domain.Epoch(epochs) + 1
+	}
+
+	ranks := make(map[string]*rankAggregate)
+	for _, epoch := range stored {
+		if epoch < ranking.FromEpoch {
+			continue
+		}
+		err := reader.ReadEpoch(epoch, func(r domain.DutyResult) error {
+			if r.Effectiveness.Possible == 0 {
+				return nil
+			}
+			key := keyOf(r.ValidatorIndex)
+			if key == "" {
+				return nil
+			}
+			agg, ok := ranks[key]
+			if !ok {
+				agg = &rankAggregate{validators: make(map[domain.ValidatorIndex]struct{})}
+				ranks[key] = agg
+			}
+			agg.add(r)
+			return nil
+		})
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // pruned meanwhile
+		}
+		if err != nil {
+			return ranking, fmt.Errorf("reading epoch %d: %w", epoch, err)
+		}
+	}
+	for key, agg := range ranks {
+		r := agg.rank
+		r.Key = key
+		r.Validators = len(agg.validators)
+		if r.Possible > 0 {
+			r.Score = float64(r.Earned) / float64(r.Possible)
+		}
+		ranking.Ranks = append(ranking.Ranks, r)
+	}
+	sort.Slice(ranking.Ranks, func(i, j int) bool {
+		a, b := ranking.Ranks[i], ranking.Ranks[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Key < b.Key
+	})
+	return ranking, nil
+}
+
+type rankAggregate struct {
+	rank       domain.EffectivenessRank
+	validators map[domain.ValidatorIndex]struct{}
+}
+
+func (a *rankAggregate) add(r domain.DutyResult) {
+	a.validators[r.ValidatorIndex] = struct{}{}
+	a.rank.Scored++
+	a.rank.Earned += uint64(r.Effectiveness.Earned)
+	a.rank.Possible += uint64(r.Effectiveness.Possible)
+}
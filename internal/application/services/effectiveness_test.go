@@ -0,0 +1,55 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+func TestRankEffectiveness(t *testing.T) {
+	store := newMemoryResultStore()
+	scored := func(v domain.ValidatorIndex, epoch domain.Epoch, earned, possible uint32) domain.DutyResult {
+		return domain.DutyResult{Type: domain.DutyTypeAttestation, ValidatorIndex: v, Epoch: epoch,
+			Outcome: domain.OutcomeSuccess, Effectiveness: domain.Effectiveness{Earned: earned, Possible: possible}}
+	}
+	// Outside a window of 2 epochs.
+	store.epochs[3] = []domain.DutyResult{scored(1, 3, 0, 54)}
+	store.epochs[4] = []domain.DutyResult{
+		scored(1, 4, 54, 54), scored(2, 4, 40, 54), scored(3, 4, 54, 54),
+		{Type: domain.DutyTypeProposal, ValidatorIndex: 1, Epoch: 4, Outcome: domain.OutcomeSuccess},
+	}
+	store.epochs[5] = []domain.DutyResult{
+		scored(1, 5, 62, 62), scored(2, 5, 54, 54), scored(3, 5, 0, 54),
+		{Type: domain.DutyTypeAttestation, ValidatorIndex: 4, Epoch: 5, Outcome: domain.OutcomeUnknown},
+	}
+	machine := map[domain.ValidatorIndex]string{1: "m1", 2: "m1", 3: "m2", 4: "m2"}
+
+	ranking, err := RankEffectiveness(store, 2, "label:machine", func(v domain.ValidatorIndex) string { return machine[v] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := domain.EffectivenessRanking{
+		FromEpoch: 4, ToEpoch: 5, By: "label:machine",
+		Ranks: []domain.EffectivenessRank{
+			{Key: "m1", Validators: 2, Scored: 4, Earned: 210, Possible: 224, Score: 210.0 / 224},
+			{Key: "m2", Validators: 1, Scored: 2, Earned: 54, Possible: 108, Score: 0.5},
+		},
+	}
+	if !reflect.DeepEqual(ranking, want) {
+		t.Errorf("got %+v, want %+v", ranking, want)
+	}
+
+	ranking, err = RankEffectiveness(store, 10, "group", func(v domain.ValidatorIndex) string {
+		if v == 2 {
+			return "a"
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranking.FromEpoch != 0 || len(ranking.Ranks) != 1 || ranking.Ranks[0].Key != "a" || ranking.Ranks[0].Scored != 2 {
+		t.Errorf("ungrouped validators ranked or window wrong: %+v", ranking)
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// Reconnect backoff for the event stream.
+const (
+	eventsInitialBackoff = time.Second
+	eventsMaxBackoff     = 30 * time.Second
+)
+
+// UseEventStream makes Run react to the beacon node event stream instead of
+// polling. Polling resumes automatically whenever the stream is down.
+func (a *DutiesChecker) UseEventStream(source ports.ChainEventSource) {
+	a.events = source
+}
+
+// followEvents keeps the event stream subscribed until ctx is cancelled,
+// reconnecting with exponential backoff. streaming is true while events are
+// arriving; each finalized checkpoint is signalled on finalized and each new
+// block on head.
+func (a *DutiesChecker) followEvents(ctx context.Context, streaming *atomic.Bool, finalized, head chan<- struct{}) {
+	backoff := eventsInitialBackoff
+	for {
+		err := a.events.SubscribeChainEvents(ctx, func(event domain.ChainEvent) {
+			if !streaming.Swap(true) {
+				logger.Info("Receiving beacon node events; polling paused")
+			}
+			backoff = eventsInitialBackoff
+			handleChainEvent(event, finalized, head)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if streaming.Swap(false) {
+			logger.Warn("Beacon node event stream dropped, falling back to polling every %s: %v", a.pollInterval(), err)
+		} else {
+			logger.Debug("Beacon node event stream unavailable, retrying in %s: %v", backoff, err)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, eventsMaxBackoff)
+	}
+}
+
+func handleChainEvent(event domain.ChainEvent, finalized, head chan<- struct{}) {
+	switch event.Type {
+	case domain.ChainEventFinalizedCheckpoint:
+		logger.Debug("Finalized checkpoint event for epoch %d", event.Epoch)
+		signal(finalized)
+	case domain.ChainEventChainReorg:
+		logger.Warn("Chain reorg of depth %d at slot %d (epoch %d)", event.Depth, event.Slot, event.Epoch)
+	case domain.ChainEventBlock:
+		logger.Debug("Block event for slot %d", event.Slot)
+		signal(head)
+	}
+}
+
+// signal wakes up Run without blocking. A check already pending will pick up
+// the latest state, so extra signals are dropped.
+func signal(ch chan<- struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
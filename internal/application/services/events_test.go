@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters"
+	"github.com/Marketen/duties-indexer/internal/adapters/beaconmock"
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/testutil"
+)
+
+// TestFollowEventsReconnectsAfterEOF follows the event stream of a mock node
+// that closes it after every batch of events, and expects each finalized
+// checkpoint and block to be signalled and the stream to be reopened.
+func TestFollowEventsReconnectsAfterEOF(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	chain := testutil.NewChain(t, fakechain.Config{Seed: 1, Validators: 64, CommitteesPerSlot: 1, Epochs: 3})
+	node := beaconmock.New(chain)
+	t.Cleanup(node.Close)
+	node.SetEvents(
+		domain.ChainEvent{Type: domain.ChainEventBlock, Slot: 95},
+		domain.ChainEvent{Type: domain.ChainEventChainReorg, Slot: 95, Epoch: 2, Depth: 1},
+		domain.ChainEvent{Type: domain.ChainEventFinalizedCheckpoint, Epoch: 1},
+	)
+	beacon, err := adapters.NewFailoverBeaconAdapter(ctx, []adapters.BeaconNode{{Name: "mock", URL: node.URL}}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checker := NewDutiesChecker(beacon, time.Minute, nil)
+	checker.UseEventStream(beacon.EventSource(time.Minute))
+	var streaming atomic.Bool
+	finalized, head := make(chan struct{}, 1), make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		checker.followEvents(ctx, &streaming, finalized, head)
+		close(done)
+	}()
+
+	// The second batch only arrives if the stream is reopened after the
+	// node closes it.
+	timeout := time.After(5 * time.Second)
+	for batch := 1; batch <= 2; batch++ {
+		for _, ch := range []chan struct{}{head, finalized} {
+			select {
+			case <-ch:
+			case <-timeout:
+				t.Fatalf("batch %d not signalled; %d subscriptions", batch, node.Subscriptions())
+			}
+		}
+	}
+	if n := node.Subscriptions(); n < 2 {
+		t.Errorf("got %d subscriptions, want at least 2", n)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("followEvents did not return after ctx was cancelled")
+	}
+}
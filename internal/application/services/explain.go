@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+)
+
+// AttestationExplanation shows how the outcome of one attestation duty was
+// decided: the duty, the committee sizes the bit position is computed from,
+// and every attestation for the duty's slot in its inclusion window.
+type AttestationExplanation struct {
+	Slot           domain.Slot           `json:"slot"`
+	CommitteeIndex domain.CommitteeIndex `json:"committee_index"`
+	// Position is the validator's position in its committee.
+	Position         uint64 `json:"position"`
+	CommitteeLength  uint64 `json:"committee_length"`
+	CommitteesAtSlot uint64 `json:"committees_at_slot"`
+	// CommitteeSizes are the sizes of the committees of the duty's slot.
+	CommitteeSizes   map[domain.CommitteeIndex]int `json:"committee_sizes"`
+	CommitteesError  string                        `json:"committees_error,omitempty"`
+	WindowFirstSlot  domain.Slot                   `json:"window_first_slot"`
+	WindowLastSlot   domain.Slot                   `json:"window_last_slot"`
+	MissedSlots      []domain.Slot                 `json:"missed_slots"`
+	UnavailableSlots map[domain.Slot]string        `json:"unavailable_slots,omitempty"`
+	// OtherAttestations counts the attestations in the window for other slots.
+	OtherAttestations int                    `json:"other_attestations"`
+	Candidates        []CandidateAttestation `json:"candidates"`
+	// CanonicalHead and CanonicalTarget are the roots the head and target
+	// votes are checked against, when they could be determined.
+	CanonicalHead   *domain.Root `json:"canonical_head,omitempty"`
+	CanonicalTarget *domain.Root `json:"canonical_target,omitempty"`
+	// Network is the network baseline of the epoch, when the committees
+	// could be fetched.
+	Network *domain.NetworkBaseline `json:"network,omitempty"`
+	Result  domain.DutyResult       `json:"result"`
+}
+
+// CandidateAttestation is an on-chain attestation for the duty's slot and why
+// it does or does not include the validator.
+type CandidateAttestation struct {
+	InclusionSlot domain.Slot `json:"inclusion_slot"`
+	// CommitteeBits are the attestation's committee bits in hex, and
+	// Committees the committees they select.
+	CommitteeBits string                  `json:"committee_bits"`
+	Committees    []domain.CommitteeIndex `json:"committees"`
+	// HeadRoot and TargetRoot are the attestation's votes.
+	HeadRoot   domain.Root `json:"head_root"`
+	TargetRoot domain.Root `json:"target_root"`
+	// BitPosition is the validator's bit, or -1 if the attestation does not
+	// aggregate the validator's committee.
+	BitPosition int    `json:"bit_position"`
+	Match       bool   `json:"match"`
+	Why         string `json:"why"`
+}
+
+// DutyExplanation is everything ExplainDuties found for a validator in an
+// epoch.
+type DutyExplanation struct {
+	ValidatorIndex domain.ValidatorIndex `json:"validator_index"`
+	Epoch          domain.Epoch          `json:"epoch"`
+	Proposals      []domain.DutyResult   `json:"proposals"`
+	// SyncCommittee has a result per block of the epoch if the validator
+	// is in the sync committee.
+	SyncCommittee []domain.DutyResult     `json:"sync_committee,omitempty"`
+	Attestation   *AttestationExplanation `json:"attestation,omitempty"`
+}
+
+// ExplainDuties evaluates the duties of one validator in epoch like
+// CheckEpoch does and records the data each verdict was based on. The
+// blocks of the epoch and of its inclusion windows are fetched, as for a
+// check, for the network baseline. Sync committee results are listed, as
+// they are scored with the attestation, but not explained further.
+func (a *DutiesChecker) ExplainDuties(ctx context.Context, epoch domain.Epoch, validator domain.ValidatorIndex) (DutyExplanation, error) {
+	e := DutyExplanation{ValidatorIndex: validator, Epoch: epoch}
+	indices := []domain.ValidatorIndex{validator}
+	var err error
+	if e.Proposals, err = evaluateProposals(ctx, a.BeaconAdapter, a.concurrency(), epoch, indices); err != nil {
+		return e, fmt.Errorf("fetching proposer duties: %w", err)
+	}
+	duties, err := a.BeaconAdapter.GetValidatorDutiesBatch(ctx, epoch, indices)
+	if err != nil {
+		return e, fmt.Errorf("fetching attester duties: %w", err)
+	}
+	if len(duties) == 0 {
+		return e, nil
+	}
+	var data *epochAttestations
+	e.Attestation, data, err = explainAttestation(ctx, a.BeaconAdapter, a.concurrency(), epoch, duties[0])
+	if e.Attestation != nil {
+		x := e.Attestation
+		data.rewards = true
+		if e.SyncCommittee, err = data.evaluateSyncCommittee(ctx, a.BeaconAdapter, epoch, indices); err != nil {
+			return e, fmt.Errorf("fetching sync committee duties: %w", err)
+		}
+		scored := append(append([]domain.DutyResult(nil), e.Proposals...), e.SyncCommittee...)
+		x.Result.Effectiveness = domain.EpochEffectiveness(x.Result, scored)
+		if rewards, err := a.BeaconAdapter.GetAttestationRewards(ctx, epoch, indices); err == nil {
+			x.Result.MissedReward = missedRewards(rewards)[validator]
+		}
+		if x.Network != nil {
+			thresholds := a.networkThresholds()
+			x.Result.NetworkDegraded = x.Network.DegradedAt(x.Slot, thresholds)
+			for i := range e.Proposals {
+				e.Proposals[i].NetworkDegraded = x.Network.DegradedAt(e.Proposals[i].Slot, thresholds)
+			}
+			for i := range e.SyncCommittee {
+				e.SyncCommittee[i].NetworkDegraded = x.Network.DegradedAt(e.SyncCommittee[i].Slot, thresholds)
+			}
+		}
+	}
+	return e, err
+}
+
+func explainAttestation(
+	ctx context.Context,
+	beacon ports.BeaconChainAdapter,
+	concurrency int,
+	epoch domain.Epoch,
+	duty domain.ValidatorDuty,
+) (*AttestationExplanation, *epochAttestations, error) {
+	x := &AttestationExplanation{
+		Slot:             duty.Slot,
+		CommitteeIndex:   duty.CommitteeIndex,
+		Position:         duty.ValidatorCommitteeIdx,
+		CommitteeLength:  duty.CommitteeLength,
+		CommitteesAtSlot: duty.CommitteesAtSlot,
+		WindowFirstSlot:  duty.Slot + 1,
+		WindowLastSlot:   duty.Slot + SlotsPerEpoch,
+		MissedSlots:      []domain.Slot{},
+		UnavailableSlots: make(map[domain.Slot]string),
+		Candidates:       []CandidateAttestation{},
+	}
+
+	data := &epochAttestations{}
+	data.committees, data.committeesErr = beacon.GetEpochCommittees(ctx, epoch)
+	if errors.Is(data.committeesErr, context.Canceled) {
+		return nil, nil, data.committeesErr
+	}
+	if data.committeesErr != nil {
+		x.CommitteesError = data.committeesErr.Error()
+	}
+	sizes := data.committees.SizeMap(duty.Slot)
+	x.CommitteeSizes = sizes
+
+	// The blocks from the start of the epoch tell the canonical target, and
+	// all of them the network baseline.
+	firstSlot := domain.Slot(epoch) * SlotsPerEpoch
+	data.lastSlot = firstSlot + 2*SlotsPerEpoch - 1
+	blocks, unavailable := preloadBlocks(ctx, beacon, concurrency, firstSlot, data.lastSlot)
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	data.unavailableSlots = unavailable
+	data.parentRoots = parentRoots(blocks)
+	data.syncBits = syncBitsOf(epoch, blocks)
+	if data.committeesErr == nil {
+		data.included = buildAttestationIndex(epoch, data.committees, blocks)
+		baseline := data.networkBaseline(epoch, blocks)
+		x.Network = &baseline
+	}
+	x.Result = data.evaluateDuty(epoch, duty)
+	if root, ok := data.blockRootAt(duty.Slot); ok {
+		x.CanonicalHead = &root
+	}
+	if root, ok := data.blockRootAt(domain.Slot(epoch) * SlotsPerEpoch); ok {
+		x.CanonicalTarget = &root
+	}
+
+	for slot := x.WindowFirstSlot; slot <= x.WindowLastSlot; slot++ {
+		if err, ok := unavailable[slot]; ok {
+			x.UnavailableSlots[slot] = err.Error()
+			continue
+		}
+		block, ok := blocks[slot]
+		if !ok {
+			x.MissedSlots = append(x.MissedSlots, slot)
+			continue
+		}
+		for _, att := range block.Attestations {
+			if att.DataSlot != duty.Slot {
+				x.OtherAttestations++
+				continue
+			}
+			x.Candidates = append(x.Candidates, explainCandidate(duty, slot, att, sizes, data.committeesErr == nil))
+		}
+	}
+	return x, data, nil
+}
+
+// explainCandidate checks att against duty the way the attestation index
+// does, saying why it matches or not.
+func explainCandidate(
+	duty domain.ValidatorDuty,
+	inclusionSlot domain.Slot,
+	att domain.Attestation,
+	sizes domain.CommitteeSizeMap,
+	haveCommittees bool,
+) CandidateAttestation {
+	c := CandidateAttestation{
+		InclusionSlot: inclusionSlot,
+		CommitteeBits: hex.EncodeToString(att.CommitteeBits),
+		Committees:    []domain.CommitteeIndex{},
+		HeadRoot:      att.BeaconBlockRoot,
+		TargetRoot:    att.TargetRoot,
+		BitPosition:   -1,
+	}
+	for i := 0; i < 64; i++ {
+		if isBitSet(att.CommitteeBits, i) {
+			c.Committees = append(c.Committees, domain.CommitteeIndex(i))
+		}
+	}
+
+	switch {
+	case !isBitSet(att.CommitteeBits, int(duty.CommitteeIndex)):
+		c.Why = fmt.Sprintf("does not aggregate committee %d", duty.CommitteeIndex)
+	case !haveCommittees:
+		c.Why = "committee sizes unknown, bit position cannot be computed"
+	default:
+		c.BitPosition = computeBitPosition(duty.CommitteeIndex, duty.ValidatorCommitteeIdx, att.CommitteeBits, sizes)
+		offset := c.BitPosition - int(duty.ValidatorCommitteeIdx)
+		c.Match = isBitSet(att.AggregationBits, c.BitPosition)
+		if c.Match {
+			c.Why = fmt.Sprintf("bit %d (committee %d starts at %d, position %d) is set", c.BitPosition, duty.CommitteeIndex, offset, duty.ValidatorCommitteeIdx)
+		} else {
+			c.Why = fmt.Sprintf("bit %d (committee %d starts at %d, position %d) is not set", c.BitPosition, duty.CommitteeIndex, offset, duty.ValidatorCommitteeIdx)
+		}
+	}
+	return c
+}
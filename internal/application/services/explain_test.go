@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/testutil"
+)
+
+// TestExplainDutiesAgreesWithCheckEpoch explains every validator of a fake
+// chain and expects the verdicts of CheckEpoch, with candidates that
+// account for them.
+func TestExplainDutiesAgreesWithCheckEpoch(t *testing.T) {
+	ctx := context.Background()
+	chain := testutil.NewChain(t, fakechain.Config{
+		Seed: 8, Validators: 512, CommitteesPerSlot: 4, Epochs: 4,
+		MissedSlotRate: 0.15, MissedAttestationRate: 0.1, MaxInclusionDelay: 3, DuplicateRate: 0.2,
+		SyncCommitteeSize: 64, MissedSyncRate: 0.1,
+	})
+	indices := testutil.Validators(512)
+	epoch, _ := chain.GetFinalizedEpoch(ctx)
+	checker := NewDutiesChecker(chain, 0, indices)
+	results, err := checker.CheckEpoch(ctx, epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestations := make(map[domain.ValidatorIndex]domain.DutyResult)
+	proposals := make(map[domain.ValidatorIndex][]domain.DutyResult)
+	syncResults := make(map[domain.ValidatorIndex][]domain.DutyResult)
+	for _, r := range results {
+		switch r.Type {
+		case domain.DutyTypeProposal:
+			proposals[r.ValidatorIndex] = append(proposals[r.ValidatorIndex], r)
+		case domain.DutyTypeSyncCommittee:
+			syncResults[r.ValidatorIndex] = append(syncResults[r.ValidatorIndex], r)
+		default:
+			attestations[r.ValidatorIndex] = r
+		}
+	}
+
+	missed := 0
+	for _, v := range indices {
+		x, err := checker.ExplainDuties(ctx, epoch, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(x.Proposals) != len(proposals[v]) {
+			t.Errorf("validator %d: %d proposals explained, want %d", v, len(x.Proposals), len(proposals[v]))
+		}
+		if !reflect.DeepEqual(x.SyncCommittee, syncResults[v]) {
+			t.Errorf("validator %d: sync committee results %+v, checked %+v", v, x.SyncCommittee, syncResults[v])
+		}
+		a := x.Attestation
+		if a == nil {
+			t.Fatalf("validator %d: no attestation explained", v)
+		}
+		if a.Result != attestations[v] {
+			t.Errorf("validator %d: explained %+v, checked %+v", v, a.Result, attestations[v])
+			continue
+		}
+		var firstMatch domain.Slot
+		for _, c := range a.Candidates {
+			if c.Match && firstMatch == 0 {
+				firstMatch = c.InclusionSlot
+			}
+			if c.Why == "" {
+				t.Errorf("validator %d: candidate in block %d without a reason", v, c.InclusionSlot)
+			}
+		}
+		if firstMatch != a.Result.InclusionSlot {
+			t.Errorf("validator %d: first matching candidate in block %d, result included at %d", v, firstMatch, a.Result.InclusionSlot)
+		}
+		if a.Result.Outcome == domain.OutcomeMissed {
+			missed++
+		}
+	}
+	if missed == 0 {
+		t.Error("no missed attestation was explained")
+	}
+}
+
+func TestExplainDutiesUnavailableData(t *testing.T) {
+	ctx := context.Background()
+	chain := testutil.NewChain(t, fakechain.Config{Seed: 2, Validators: 256, CommitteesPerSlot: 2, Epochs: 4})
+	epoch, _ := chain.GetFinalizedEpoch(ctx)
+	duties, _ := chain.GetValidatorDutiesBatch(ctx, epoch, []domain.ValidatorIndex{10})
+	chain.FailBlock(duties[0].Slot+1, fmt.Errorf("%w: status 500", ports.ErrServer))
+	chain.FailCommittees(epoch, fmt.Errorf("%w: status 503", ports.ErrServer))
+
+	x, err := NewDutiesChecker(chain, 0, nil).ExplainDuties(ctx, epoch, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := x.Attestation
+	if a.Result.Outcome != domain.OutcomeUnknown || a.CommitteesError == "" {
+		t.Errorf("got %s with committees error %q, want unknown with the error", a.Result.Outcome, a.CommitteesError)
+	}
+	if _, ok := a.UnavailableSlots[duties[0].Slot+1]; !ok {
+		t.Errorf("slot %d not reported unavailable", duties[0].Slot+1)
+	}
+	for _, c := range a.Candidates {
+		if c.Match || c.BitPosition >= 0 {
+			t.Errorf("candidate %+v matched without committee sizes", c)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+)
+
+// ExportQuery selects the stored results to export. Ranges are combined: a
+// result is exported if it is within both the epoch range and the time range.
+type ExportQuery struct {
+	// FromEpoch and ToEpoch bound the epochs, inclusive; ToEpoch 0 means the
+	// latest stored epoch.
+	FromEpoch domain.Epoch
+	ToEpoch   domain.Epoch
+	// From and To bound the duty slot times to [From, To); zero times leave
+	// that end open.
+	From time.Time
+	To   time.Time
+	// Validators, if not empty, restricts the export to these validators.
+	Validators []domain.ValidatorIndex
+	// Group, if not empty, restricts the export to the validators of a group.
+	Group string
+}
+
+// ExportResults writes the stored results matching q to w, in epoch order,
+// and returns the number of rows written. groupOf gives the group column;
+// groups are those of the current configuration, not of the time the result
+// was stored. Epochs pruned while the export runs are skipped. w is not
+// closed.
+func ExportResults(
+	reader ports.ResultReader,
+	q ExportQuery,
+	genesis time.Time,
+	groupOf func(domain.ValidatorIndex) string,
+	w ports.ResultRowWriter,
+) (int, error) {
+	epochs, err := reader.Epochs()
+	if err != nil {
+		return 0, fmt.Errorf("listing stored epochs: %w", err)
+	}
+	from, to := q.FromEpoch, q.ToEpoch
+	if !q.From.IsZero() && domain.EpochAt(genesis, q.From) > from {
+		from = domain.EpochAt(genesis, q.From)
+	}
+	if !q.To.IsZero() {
+		if !q.To.After(genesis) || !q.To.After(q.From) {
+			return 0, nil
+		}
+		// The row filter below drops the slots of this epoch at or after To.
+		last := domain.EpochAt(genesis, q.To.Add(-time.Nanosecond))
+		if to == 0 || last < to {
+			to = last
+		}
+	}
+	var validators map[domain.ValidatorIndex]struct{}
+	if len(q.Validators) > 0 {
+		validators = make(map[domain.ValidatorIndex]struct{}, len(q.Validators))
+		for _, v := range q.Validators {
+			validators[v] = struct{}{}
+		}
+	}
+
+	rows := 0
+	for _, epoch := range epochs {
+		if epoch < from || (to != 0 && epoch > to) {
+			continue
+		}
+		err := reader.ReadEpoch(epoch, func(r domain.DutyResult) error {
+			if validators != nil {
+				if _, ok := validators[r.ValidatorIndex]; !ok {
+					return nil
+				}
+			}
+			group := groupOf(r.ValidatorIndex)
+			if q.Group != "" && group != q.Group {
+				return nil
+			}
+			row := domain.NewResultRow(r, group, genesis)
+			if (!q.From.IsZero() && row.SlotTime.Before(q.From)) || (!q.To.IsZero() && !row.SlotTime.Before(q.To)) {
+				return nil
+			}
+			rows++
+			return w.Write(row)
+		})
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return rows, fmt.Errorf("exporting epoch %d: %w", epoch, err)
+		}
+	}
+	return rows, nil
+}
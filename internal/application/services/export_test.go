@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/testutil"
+)
+
+// Epochs and ReadEpoch make memoryResultStore a ports.ResultReader.
+func (s *memoryResultStore) Epochs() ([]domain.Epoch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var epochs []domain.Epoch
+	for epoch := range s.epochs {
+		epochs = append(epochs, epoch)
+	}
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+	return epochs, nil
+}
+
+func (s *memoryResultStore) ReadEpoch(epoch domain.Epoch, fn func(domain.DutyResult) error) error {
+	results, ok := s.results(epoch)
+	if !ok {
+		return fmt.Errorf("epoch %d: %w", epoch, fs.ErrNotExist)
+	}
+	for _, r := range results {
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type rowCollector struct {
+	rows []domain.ResultRow
+}
+
+func (c *rowCollector) Write(row domain.ResultRow) error {
+	c.rows = append(c.rows, row)
+	return nil
+}
+
+func (c *rowCollector) Close() error { return nil }
+
+func TestExportResultsFilters(t *testing.T) {
+	ctx := context.Background()
+	chain := testutil.NewChain(t, fakechain.Config{
+		Seed: 4, Validators: 256, CommitteesPerSlot: 2, Epochs: 6,
+		MissedSlotRate: 0.1, MissedAttestationRate: 0.1, MaxInclusionDelay: 2,
+	})
+	store := newMemoryResultStore()
+	checker := NewDutiesChecker(chain, 0, testutil.Validators(256))
+	for epoch := domain.Epoch(1); epoch <= 3; epoch++ {
+		results, err := checker.CheckEpoch(ctx, epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.epochs[epoch] = results
+	}
+	genesis := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	groupOf := func(v domain.ValidatorIndex) string {
+		if v%2 == 0 {
+			return "even"
+		}
+		return ""
+	}
+	epochStart := func(epoch domain.Epoch) time.Time {
+		return domain.SlotTime(genesis, domain.Slot(epoch)*SlotsPerEpoch)
+	}
+
+	tests := []struct {
+		name string
+		q    ExportQuery
+		keep func(domain.DutyResult) bool
+	}{
+		{"all", ExportQuery{}, func(domain.DutyResult) bool { return true }},
+		{"epoch range", ExportQuery{FromEpoch: 2, ToEpoch: 2},
+			func(r domain.DutyResult) bool { return r.Epoch == 2 }},
+		{"from epoch", ExportQuery{FromEpoch: 2},
+			func(r domain.DutyResult) bool { return r.Epoch >= 2 }},
+		{"time range", ExportQuery{From: epochStart(2).Add(time.Minute), To: epochStart(3)},
+			func(r domain.DutyResult) bool {
+				return r.Epoch == 2 && r.Slot >= domain.Slot(2)*SlotsPerEpoch+5
+			}},
+		{"epoch and time range", ExportQuery{ToEpoch: 2, From: epochStart(2)},
+			func(r domain.DutyResult) bool { return r.Epoch == 2 }},
+		{"validators", ExportQuery{Validators: []domain.ValidatorIndex{3, 4, 200}},
+			func(r domain.DutyResult) bool {
+				return r.ValidatorIndex == 3 || r.ValidatorIndex == 4 || r.ValidatorIndex == 200
+			}},
+		{"group", ExportQuery{Group: "even", FromEpoch: 3},
+			func(r domain.DutyResult) bool { return r.Epoch == 3 && r.ValidatorIndex%2 == 0 }},
+		{"empty time range", ExportQuery{From: epochStart(2), To: epochStart(2)},
+			func(domain.DutyResult) bool { return false }},
+		{"before genesis", ExportQuery{To: genesis},
+			func(domain.DutyResult) bool { return false }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want []domain.ResultRow
+			for epoch := domain.Epoch(1); epoch <= 3; epoch++ {
+				for _, r := range store.epochs[epoch] {
+					if tt.keep(r) {
+						want = append(want, domain.NewResultRow(r, groupOf(r.ValidatorIndex), genesis))
+					}
+				}
+			}
+			got := &rowCollector{}
+			n, err := ExportResults(store, tt.q, genesis, groupOf, got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != len(got.rows) || len(got.rows) != len(want) {
+				t.Fatalf("exported %d rows (%d written), want %d", n, len(got.rows), len(want))
+			}
+			for i := range want {
+				if !reflect.DeepEqual(got.rows[i], want[i]) {
+					t.Fatalf("row %d: got %+v, want %+v", i, got.rows[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewResultRow(t *testing.T) {
+	genesis := domain.MainnetGenesisTime
+	success := domain.NewResultRow(domain.DutyResult{
+		Type: domain.DutyTypeAttestation, ValidatorIndex: 7, Epoch: 10, Slot: 330,
+		CommitteeIndex: 5, Outcome: domain.OutcomeSuccess, InclusionSlot: 332,
+	}, "a", genesis)
+	if success.CommitteeIndex == nil || *success.CommitteeIndex != 5 ||
+		success.InclusionSlot == nil || *success.InclusionSlot != 332 ||
+		success.InclusionDelay == nil || *success.InclusionDelay != 2 {
+		t.Errorf("successful attestation: %+v", success)
+	}
+	if want := genesis.Add(330 * 12 * time.Second); !success.SlotTime.Equal(want) {
+		t.Errorf("slot time %s, want %s", success.SlotTime, want)
+	}
+
+	missed := domain.NewResultRow(domain.DutyResult{
+		Type: domain.DutyTypeProposal, ValidatorIndex: 7, Epoch: 10, Slot: 331,
+		Outcome: domain.OutcomeMissed, Reason: "no block",
+	}, "", genesis)
+	if missed.CommitteeIndex != nil || missed.InclusionSlot != nil || missed.InclusionDelay != nil {
+		t.Errorf("missed proposal: %+v", missed)
+	}
+}
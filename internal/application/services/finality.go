@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// Fallbacks for checking duties while the network is not finalizing.
+const (
+	// FallbackJustified checks the latest justified epoch.
+	FallbackJustified = "justified"
+	// FallbackHead checks the epoch HeadOffset epochs behind the head.
+	FallbackHead = "head"
+	// FallbackNone only alerts; duties wait for finality.
+	FallbackNone = "none"
+)
+
+// NonFinalityPolicy configures non-finality detection. The network is
+// considered not finalizing when the head epoch is more than Threshold epochs
+// ahead of the finalized epoch (normally it is two).
+type NonFinalityPolicy struct {
+	Threshold  domain.Epoch
+	Fallback   string
+	HeadOffset domain.Epoch
+}
+
+// DefaultNonFinalityPolicy alerts after 4 epochs without finality and then
+// checks justified epochs.
+var DefaultNonFinalityPolicy = NonFinalityPolicy{Threshold: 4, Fallback: FallbackJustified, HeadOffset: 2}
+
+// SetNonFinalityPolicy sets when non-finality is reported and which epochs are
+// checked provisionally meanwhile, against beacon. As for head tracking,
+// beacon must not cache blocks by slot.
+func (a *DutiesChecker) SetNonFinalityPolicy(policy NonFinalityPolicy, beacon ports.BeaconChainAdapter) {
+	a.nonFinality = policy
+	if policy.Fallback != FallbackNone && a.unfinalizedBeacon == nil {
+		a.unfinalizedBeacon = beacon
+	}
+}
+
+// trackFinality compares the head epoch with the finalized checkpoint and
+// alerts when the distance crosses the threshold, in either direction. It
+// returns the justified epoch and whether the network is not finalizing.
+func (a *DutiesChecker) trackFinality(ctx context.Context, headEpoch domain.Epoch) (domain.Epoch, bool) {
+	checkpoints, err := a.BeaconAdapter.GetFinalityCheckpoints(ctx)
+	if err != nil {
+		logger.Error("Error fetching finality checkpoints: %v", err)
+		return 0, a.nonFinal
+	}
+	var distance domain.Epoch
+	if headEpoch > checkpoints.Finalized {
+		distance = headEpoch - checkpoints.Finalized
+	}
+	logger.Debug("Finality distance %d epochs (head epoch %d, justified %d, finalized %d)",
+		distance, headEpoch, checkpoints.Justified, checkpoints.Finalized)
+
+	switch {
+	case distance > a.nonFinality.Threshold && !a.nonFinal:
+		a.nonFinal = true
+		msg := fmt.Sprintf("Network is not finalizing: head epoch %d is %d epochs ahead of finalized epoch %d (threshold %d)",
+			headEpoch, distance, checkpoints.Finalized, a.nonFinality.Threshold)
+		if a.nonFinality.Fallback != FallbackNone {
+			msg += fmt.Sprintf("; checking %s epochs provisionally until finality resumes", a.nonFinality.Fallback)
+		}
+		logger.Warn("%s", msg)
+		a.notify(ctx, []domain.Notification{{Kind: domain.NotificationNonFinality, Message: msg}})
+	case distance <= a.nonFinality.Threshold && a.nonFinal:
+		a.nonFinal = false
+		msg := fmt.Sprintf("Finality resumed: finalized epoch %d, head epoch %d", checkpoints.Finalized, headEpoch)
+		logger.Info("%s", msg)
+		a.notify(ctx, []domain.Notification{{Kind: domain.NotificationFinalityResumed, Message: msg}})
+	case a.nonFinal:
+		logger.Debug("Still not finalizing: %d epochs since finalized epoch %d", distance, checkpoints.Finalized)
+	}
+	return checkpoints.Justified, a.nonFinal
+}
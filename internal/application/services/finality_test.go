@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/testutil"
+)
+
+func TestTrackFinality(t *testing.T) {
+	type step struct {
+		headEpoch, finalized domain.Epoch
+		wantNonFinal         bool
+		wantNotified         []domain.NotificationKind
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "finalizing",
+			steps: []step{
+				{headEpoch: 10, finalized: 8},
+				{headEpoch: 11, finalized: 9},
+			},
+		},
+		{
+			name: "threshold is not yet non-finality",
+			steps: []step{
+				{headEpoch: 10, finalized: 6},
+			},
+		},
+		{
+			name: "stops and resumes",
+			steps: []step{
+				{headEpoch: 10, finalized: 8},
+				{headEpoch: 11, finalized: 6, wantNonFinal: true, wantNotified: []domain.NotificationKind{domain.NotificationNonFinality}},
+				{headEpoch: 12, finalized: 6, wantNonFinal: true},
+				{headEpoch: 13, finalized: 11, wantNotified: []domain.NotificationKind{domain.NotificationFinalityResumed}},
+				{headEpoch: 14, finalized: 12},
+			},
+		},
+		{
+			name: "alerts again after resuming",
+			steps: []step{
+				{headEpoch: 20, finalized: 10, wantNonFinal: true, wantNotified: []domain.NotificationKind{domain.NotificationNonFinality}},
+				{headEpoch: 21, finalized: 19, wantNotified: []domain.NotificationKind{domain.NotificationFinalityResumed}},
+				{headEpoch: 30, finalized: 19, wantNonFinal: true, wantNotified: []domain.NotificationKind{domain.NotificationNonFinality}},
+			},
+		},
+		{
+			name: "finalized ahead of a lagging head",
+			steps: []step{
+				{headEpoch: 5, finalized: 8},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			chain := testutil.NewChain(t, fakechain.Config{Seed: 1, Validators: 64, CommitteesPerSlot: 1, Epochs: 2})
+			notifier := &recordingNotifier{}
+			checker := NewDutiesChecker(chain, time.Minute, nil)
+			checker.SetNonFinalityPolicy(NonFinalityPolicy{Threshold: 4, Fallback: FallbackNone}, nil)
+			checker.SetNotifier(notifier)
+
+			for i, s := range tt.steps {
+				notifier.notifications = nil
+				chain.SetFinalized(s.finalized)
+				justified, nonFinal := checker.trackFinality(ctx, s.headEpoch)
+				if nonFinal != s.wantNonFinal {
+					t.Errorf("step %d: not finalizing %t, want %t", i, nonFinal, s.wantNonFinal)
+				}
+				if justified != s.finalized+1 {
+					t.Errorf("step %d: justified epoch %d, want %d", i, justified, s.finalized+1)
+				}
+				var kinds []domain.NotificationKind
+				for _, n := range notifier.notifications {
+					kinds = append(kinds, n.Kind)
+				}
+				if !reflect.DeepEqual(kinds, s.wantNotified) {
+					t.Errorf("step %d: notified %v, want %v", i, kinds, s.wantNotified)
+				}
+			}
+		})
+	}
+}
+
+// TestNonFinalityFallback checks which epoch is checked provisionally while
+// the network is not finalizing, for each fallback.
+func TestNonFinalityFallback(t *testing.T) {
+	// The head is the last slot of epoch 9, so epoch 8 is the latest whose
+	// inclusion window is complete.
+	cfg := fakechain.Config{Seed: 5, Validators: 64, CommitteesPerSlot: 1, Epochs: 10}
+	tests := []struct {
+		name      string
+		finalized domain.Epoch
+		policy    NonFinalityPolicy
+		// wantEpoch is the epoch checked provisionally, or 0 for none.
+		wantEpoch domain.Epoch
+	}{
+		{name: "justified", finalized: 1, policy: NonFinalityPolicy{Threshold: 4, Fallback: FallbackJustified}, wantEpoch: 2},
+		{name: "head minus offset", finalized: 1, policy: NonFinalityPolicy{Threshold: 4, Fallback: FallbackHead, HeadOffset: 3}, wantEpoch: 6},
+		{name: "head offset capped at the complete epoch", finalized: 1, policy: NonFinalityPolicy{Threshold: 4, Fallback: FallbackHead}, wantEpoch: 8},
+		{name: "none", finalized: 1, policy: NonFinalityPolicy{Threshold: 4, Fallback: FallbackNone}},
+		{name: "finalizing", finalized: 7, policy: NonFinalityPolicy{Threshold: 4, Fallback: FallbackJustified}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := testutil.NewChain(t, cfg)
+			chain.SetFinalized(tt.finalized)
+			checker := NewDutiesChecker(chain, time.Minute, testutil.Validators(cfg.Validators))
+			checker.SetNonFinalityPolicy(tt.policy, chain)
+			checker.checkHead(context.Background())
+
+			var checked []domain.Epoch
+			for epoch := range checker.provisional {
+				checked = append(checked, epoch)
+			}
+			var want []domain.Epoch
+			if tt.wantEpoch != 0 {
+				want = []domain.Epoch{tt.wantEpoch}
+			}
+			if !slices.Equal(checked, want) {
+				t.Errorf("checked epochs %v provisionally, want %v", checked, want)
+			}
+		})
+	}
+}
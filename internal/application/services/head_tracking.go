@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// dutyKey identifies one duty of one validator, to match its provisional and
+// finalized results.
+type dutyKey struct {
+	dutyType  domain.DutyType
+	validator domain.ValidatorIndex
+	slot      domain.Slot
+}
+
+func keyOf(r domain.DutyResult) dutyKey {
+	return dutyKey{dutyType: r.Type, validator: r.ValidatorIndex, slot: r.Slot}
+}
+
+// maxProvisionalEpochs bounds how many epochs of provisional results are
+// kept waiting for finalization. During long non-finality the oldest are
+// dropped and can no longer be corrected.
+const maxProvisionalEpochs = 8
+
+// EnableHeadTracking turns on provisional checks: as soon as the inclusion
+// window of an epoch has passed on the head chain, its duties are evaluated
+// against beacon and misses are reported right away, about two epochs before
+// finality. The finalized check then confirms each duty and emits a
+// correction when the outcome changed. beacon must not cache blocks by slot,
+// since head blocks can still be reorged.
+func (a *DutiesChecker) EnableHeadTracking(beacon ports.BeaconChainAdapter) {
+	a.headTracking = true
+	a.unfinalizedBeacon = beacon
+}
+
+// SetNotifier sets where provisional misses, corrections and chain-level
+// alerts are sent, in addition to the log; nil sends them nowhere. It can be
+// replaced at runtime like the groups, and returns the previous notifier for
+// the caller to close.
+func (a *DutiesChecker) SetNotifier(notifier ports.Notifier) ports.Notifier {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	previous := a.notifier
+	a.notifier = notifier
+	return previous
+}
+
+// Notifier returns the notifier in use, or nil if there is none.
+func (a *DutiesChecker) Notifier() ports.Notifier {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.notifier
+}
+
+// checkHead follows the head of the chain: it tracks the finality distance
+// (see SetNonFinalityPolicy) and picks the unfinalized epoch to evaluate
+// provisionally, if any. With head tracking that is the latest epoch whose
+// inclusion window (its last slot plus 32) is complete on the head chain;
+// during non-finality without head tracking it is the policy's fallback
+// epoch. Like the finalized check, epochs skipped while the checker was busy
+// are not caught up.
+func (a *DutiesChecker) checkHead(ctx context.Context) {
+	head, err := a.BeaconAdapter.GetHeadSlot(ctx)
+	if err != nil {
+		logger.Error("Error fetching head slot: %v", err)
+		return
+	}
+	headEpoch := domain.Epoch(head / SlotsPerEpoch)
+	justified, nonFinal := a.trackFinality(ctx, headEpoch)
+
+	if a.unfinalizedBeacon == nil || head < 2*SlotsPerEpoch-1 {
+		return
+	}
+	ready := domain.Epoch((head - (2*SlotsPerEpoch - 1)) / SlotsPerEpoch)
+	var epoch domain.Epoch
+	switch {
+	case a.headTracking:
+		epoch = ready
+	case nonFinal && a.nonFinality.Fallback == FallbackJustified:
+		epoch = min(justified, ready)
+	case nonFinal && a.nonFinality.Fallback == FallbackHead && headEpoch >= a.nonFinality.HeadOffset:
+		epoch = min(headEpoch-a.nonFinality.HeadOffset, ready)
+	default:
+		return
+	}
+	if epoch <= a.lastFinalizedEpoch || epoch <= a.lastProvisionalEpoch {
+		return
+	}
+	a.lastProvisionalEpoch = epoch
+	a.evaluateProvisional(ctx, epoch, head)
+}
+
+// evaluateProvisional evaluates the duties of an unfinalized epoch, reports
+// misses right away and keeps the results to be confirmed at finalization.
+// Misses that are part of a correlated failure are notified as one.
+func (a *DutiesChecker) evaluateProvisional(ctx context.Context, epoch domain.Epoch, head domain.Slot) {
+	indices := a.validatorIndices()
+	if len(indices) == 0 {
+		return
+	}
+	logger.Info("Provisional check of epoch %d on the head chain (head slot %d)", epoch, head)
+
+	results := make(map[dutyKey]domain.DutyResult)
+	var misses []domain.DutyResult
+	record := func(r domain.DutyResult) {
+		a.annotate(&r)
+		results[keyOf(r)] = r
+		if r.Outcome == domain.OutcomeSuccess {
+			return
+		}
+		logger.With(a.dutyFields(r)).Warn("Provisional: %s duty is %s on the head chain", r.Type, r.Outcome)
+		misses = append(misses, r)
+	}
+
+	proposals, err := evaluateProposals(ctx, a.unfinalizedBeacon, a.concurrency(), epoch, indices)
+	if err != nil {
+		logger.Error("Provisional check: error fetching proposer duties for epoch %d: %v", epoch, err)
+	}
+	data := fetchEpochAttestations(ctx, a.unfinalizedBeacon, a.concurrency(), epoch)
+	a.useBaseline(data.baseline, false)
+	for _, r := range proposals {
+		record(r)
+	}
+	syncResults, err := data.evaluateSyncCommittee(ctx, a.unfinalizedBeacon, epoch, indices)
+	if err != nil {
+		logger.Error("Provisional check: error fetching sync committee duties for epoch %d: %v", epoch, err)
+	}
+	for _, r := range syncResults {
+		record(r)
+	}
+	scored := append(append([]domain.DutyResult(nil), proposals...), syncResults...)
+	_, err = data.evaluate(ctx, a.unfinalizedBeacon, epoch, indices, scored,
+		func(_ domain.ValidatorDuty, r domain.DutyResult) { record(r) })
+	if err != nil {
+		logger.Error("Provisional check: error fetching validator duties for epoch %d: %v", epoch, err)
+	}
+
+	if a.provisional == nil {
+		a.provisional = make(map[domain.Epoch]map[dutyKey]domain.DutyResult)
+		a.provisionalFailures = make(map[domain.Epoch]map[failureKey]bool)
+	}
+	a.provisional[epoch] = results
+	for len(a.provisional) > maxProvisionalEpochs {
+		oldest := epoch
+		for e := range a.provisional {
+			oldest = min(oldest, e)
+		}
+		logger.Debug("Dropping provisional results of epoch %d, still not finalized", oldest)
+		delete(a.provisional, oldest)
+		delete(a.provisionalFailures, oldest)
+	}
+
+	failures, rest := a.correlateMisses(misses)
+	if a.provisionalFailures[epoch] == nil {
+		a.provisionalFailures[epoch] = make(map[failureKey]bool)
+	}
+	for _, f := range failures {
+		a.provisionalFailures[epoch][failureKeyOf(f)] = true
+	}
+	notifications := correlatedFailureNotifications(failures, true)
+	for i := range rest {
+		notifications = append(notifications, domain.Notification{Kind: domain.NotificationProvisionalMiss, Result: &rest[i]})
+	}
+	a.notify(ctx, notifications)
+}
+
+// confirmProvisional compares a finalized result with the provisional result
+// of the same duty and queues a correction if the outcome changed.
+func (a *DutiesChecker) confirmProvisional(r domain.DutyResult) {
+	p, ok := a.provisional[r.Epoch][keyOf(r)]
+	if !ok || p.Outcome == r.Outcome {
+		return
+	}
+	fields := a.dutyFields(r)
+	fields["provisional_outcome"] = string(p.Outcome)
+	logger.With(fields).Warn("Correction: %s duty was %s on the head chain but is %s at finalization",
+		r.Type, p.Outcome, r.Outcome)
+	a.corrections = append(a.corrections, domain.Notification{
+		Kind: domain.NotificationCorrection, Result: &r, Provisional: &p,
+	})
+}
+
+// confirmProvisionalEpochs sends the corrections found while checking the
+// finalized epoch and drops the provisional results it supersedes.
+func (a *DutiesChecker) confirmProvisionalEpochs(ctx context.Context, finalized domain.Epoch) {
+	for epoch := range a.provisional {
+		if epoch <= finalized {
+			delete(a.provisional, epoch)
+			delete(a.provisionalFailures, epoch)
+		}
+	}
+	if len(a.corrections) > 0 {
+		logger.Warn("%d provisional results corrected at finalization of epoch %d", len(a.corrections), finalized)
+	}
+	a.notify(ctx, a.corrections)
+	a.corrections = nil
+}
+
+func (a *DutiesChecker) notify(ctx context.Context, notifications []domain.Notification) {
+	notifier := a.Notifier()
+	if notifier == nil || len(notifications) == 0 {
+		return
+	}
+	if err := notifier.Notify(ctx, notifications); err != nil {
+		logger.Error("Failed to send %d notifications: %v", len(notifications), err)
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/testutil"
+)
+
+// forkedHead is the head chain as seen before a reorg: the block at slot was
+// proposed by another validator, on a fork later dropped.
+type forkedHead struct {
+	ports.BeaconChainAdapter
+	slot domain.Slot
+}
+
+func (h forkedHead) GetBlock(ctx context.Context, slot domain.Slot) (domain.Block, error) {
+	b, err := h.BeaconChainAdapter.GetBlock(ctx, slot)
+	if err == nil && slot == h.slot {
+		b.ProposerIndex++
+	}
+	return b, err
+}
+
+// TestHeadTrackingCorrectsProvisionalResult checks an epoch on a head chain
+// where one block is by the wrong proposer, then at finalization, and expects
+// one correction for that proposal.
+func TestHeadTrackingCorrectsProvisionalResult(t *testing.T) {
+	ctx := context.Background()
+	cfg := fakechain.Config{Seed: 5, Validators: 64, CommitteesPerSlot: 1, Epochs: 10}
+	chain := testutil.NewChain(t, cfg)
+	chain.SetFinalized(1)
+
+	// The head is the last slot of epoch 9, so epoch 8 is checked
+	// provisionally.
+	const epoch = 8
+	slot := domain.Slot(epoch * SlotsPerEpoch)
+	for ; ; slot++ {
+		if _, err := chain.GetBlock(ctx, slot); err == nil {
+			break
+		}
+	}
+	block, _ := chain.GetBlock(ctx, slot)
+
+	notifier := &recordingNotifier{}
+	checker := NewDutiesChecker(chain, time.Minute, testutil.Validators(cfg.Validators))
+	if err := checker.SetResultStore(newMemoryResultStore()); err != nil {
+		t.Fatal(err)
+	}
+	checker.SetNotifier(notifier)
+	checker.EnableHeadTracking(forkedHead{BeaconChainAdapter: chain, slot: slot})
+	checker.checkHead(ctx)
+
+	var provisional *domain.DutyResult
+	for _, n := range notifier.notifications {
+		if n.Kind == domain.NotificationProvisionalMiss && n.Result.Type == domain.DutyTypeProposal && n.Result.Slot == slot {
+			provisional = n.Result
+		}
+	}
+	if provisional == nil || provisional.ValidatorIndex != block.ProposerIndex {
+		t.Fatalf("the proposal at slot %d was not reported missed on the head chain", slot)
+	}
+
+	notifier.notifications = nil
+	chain.SetFinalized(epoch)
+	checker.checkLatestFinalizedEpoch(ctx)
+
+	var corrections []domain.Notification
+	for _, n := range notifier.notifications {
+		if n.Kind == domain.NotificationCorrection {
+			corrections = append(corrections, n)
+		}
+	}
+	if len(corrections) != 1 {
+		t.Fatalf("got %d corrections, want 1: %+v", len(corrections), corrections)
+	}
+	c := corrections[0]
+	if c.Result.Type != domain.DutyTypeProposal || c.Result.Slot != slot || c.Result.ValidatorIndex != block.ProposerIndex {
+		t.Errorf("correction for %s duty of validator %d at slot %d, want the proposal of validator %d at slot %d",
+			c.Result.Type, c.Result.ValidatorIndex, c.Result.Slot, block.ProposerIndex, slot)
+	}
+	if c.Result.Outcome != domain.OutcomeSuccess || c.Provisional.Outcome != provisional.Outcome {
+		t.Errorf("corrected %s to %s, want %s to %s", c.Provisional.Outcome, c.Result.Outcome, provisional.Outcome, domain.OutcomeSuccess)
+	}
+	if len(checker.provisional) != 0 {
+		t.Errorf("provisional results of epochs %v kept after finalization", checker.provisional)
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// dutyFields returns the structured log fields of a duty result: validator,
+// group (for grouped validators), epoch, slot, committee (attestations),
+// outcome, inclusion_slot (successful duties), reason (unsuccessful ones),
+// missed_reward (in Gwei, when known) and network_degraded (duties of a slot
+// where the whole network struggled).
+func (a *DutiesChecker) dutyFields(r domain.DutyResult) logger.Fields {
+	fields := logger.Fields{
+		"duty":      string(r.Type),
+		"validator": uint64(r.ValidatorIndex),
+		"epoch":     uint64(r.Epoch),
+		"slot":      uint64(r.Slot),
+		"outcome":   string(r.Outcome),
+	}
+	if group := a.ValidatorGroup(r.ValidatorIndex); group != "" {
+		fields["group"] = group
+	}
+	if r.Type == domain.DutyTypeAttestation {
+		fields["committee"] = uint64(r.CommitteeIndex)
+	}
+	if r.Outcome == domain.OutcomeSuccess {
+		fields["inclusion_slot"] = uint64(r.InclusionSlot)
+	}
+	if r.Reason != "" {
+		fields["reason"] = r.Reason
+	}
+	if r.MissedReward != 0 {
+		fields["missed_reward"] = r.MissedReward
+	}
+	if r.NetworkDegraded {
+		fields["network_degraded"] = true
+	}
+	return fields
+}
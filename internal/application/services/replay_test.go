@@ -0,0 +1,128 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters"
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/testutil"
+)
+
+// TestReplayMatchesRecording records an epoch of a fake chain, failures
+// included, and checks that replaying the fixture yields the same results.
+func TestReplayMatchesRecording(t *testing.T) {
+	ctx := context.Background()
+	chain := testutil.NewChain(t, fakechain.Config{
+		Seed: 12, Validators: 2048, CommitteesPerSlot: 8, Epochs: 4,
+		MissedSlotRate: 0.1, MissedAttestationRate: 0.05, MaxInclusionDelay: 2,
+	})
+	chain.FailBlock(2*32+40, fmt.Errorf("%w: status 503", ports.ErrServer))
+	indices := testutil.Validators(2048)
+	epoch, _ := chain.GetFinalizedEpoch(ctx)
+
+	dir := t.TempDir()
+	recorder, err := adapters.NewRecordingBeaconAdapter(chain, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := NewDutiesChecker(recorder, 0, indices).CheckEpoch(ctx, epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(adapters.FixtureManifest{Epoch: epoch, Validators: indices, RecordedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertResults(t, recorded, chain.Expected(epoch, indices))
+
+	replay, manifest, err := adapters.OpenReplayBeaconAdapter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Epoch != epoch || len(manifest.Validators) != len(indices) {
+		t.Fatalf("manifest for epoch %d with %d validators, want epoch %d with %d", manifest.Epoch, len(manifest.Validators), epoch, len(indices))
+	}
+	replayed, err := NewDutiesChecker(replay, 0, indices).CheckEpoch(ctx, epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Error("replayed results differ from the recorded ones")
+		testutil.AssertResults(t, replayed, chain.Expected(epoch, indices))
+	}
+
+	t.Run("subset", func(t *testing.T) {
+		subset := indices[100:200]
+		got, err := NewDutiesChecker(replay, 0, subset).CheckEpoch(ctx, epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testutil.AssertResults(t, got, chain.Expected(epoch, subset))
+	})
+
+	t.Run("not recorded", func(t *testing.T) {
+		if _, err := replay.GetValidatorDutiesBatch(ctx, epoch, []domain.ValidatorIndex{5000}); err == nil {
+			t.Error("duties of an unrecorded validator were served")
+		}
+		if _, err := replay.GetBlock(ctx, 1000); err == nil || errors.Is(err, ports.ErrNotFound) {
+			t.Errorf("unrecorded block: got %v, want an error other than not found", err)
+		}
+	})
+}
+
+// TestReplayFixtures checks every fixture under testdata/fixtures against its
+// expected.jsonl, one DutyResult per line. Reasons are not compared. To add a
+// regression, record the epoch with "duties-indexer record", write the
+// outcomes it should have (e.g. from an explorer) and commit both.
+func TestReplayFixtures(t *testing.T) {
+	dirs, err := filepath.Glob(filepath.Join("testdata", "fixtures", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) == 0 {
+		t.Fatal("no fixtures found")
+	}
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			beacon, manifest, err := adapters.OpenReplayBeaconAdapter(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := NewDutiesChecker(beacon, 0, manifest.Validators).CheckEpoch(context.Background(), manifest.Epoch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testutil.AssertResults(t, got, readExpectedResults(t, filepath.Join(dir, "expected.jsonl")))
+		})
+	}
+}
+
+func readExpectedResults(t *testing.T, path string) []domain.DutyResult {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var results []domain.DutyResult
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var r domain.DutyResult
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		r.Reason = ""
+		results = append(results, r)
+	}
+	return results
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+)
+
+// Report schedules.
+const (
+	ReportDaily  = "daily"
+	ReportWeekly = "weekly"
+)
+
+// LastReportPeriod returns the last complete period of schedule before now,
+// in UTC: the previous day, or the previous week starting on Monday.
+func LastReportPeriod(schedule string, now time.Time) (from, to time.Time) {
+	now = now.UTC()
+	to = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if schedule == ReportWeekly {
+		to = to.AddDate(0, 0, -(int(to.Weekday())+6)%7)
+		return to.AddDate(0, 0, -7), to
+	}
+	return to.AddDate(0, 0, -1), to
+}
+
+// BuildReport aggregates the stored results of the duties in [from, to) per
+// group. groupOf gives the group of a validator, as for ExportResults.
+func BuildReport(
+	reader ports.ResultReader,
+	from, to time.Time,
+	genesis time.Time,
+	groupOf func(domain.ValidatorIndex) string,
+) (domain.PerformanceReport, error) {
+	report := domain.PerformanceReport{From: from, To: to, GeneratedAt: time.Now().UTC(), Groups: []domain.GroupReport{}}
+	agg := &reportAggregator{groups: make(map[string]*groupAggregate)}
+	if _, err := ExportResults(reader, ExportQuery{From: from, To: to}, genesis, groupOf, agg); err != nil {
+		return report, err
+	}
+
+	if to.After(from) && to.After(genesis) {
+		first := domain.EpochAt(genesis, from)
+		last := domain.EpochAt(genesis, to.Add(-time.Nanosecond))
+		report.EpochsExpected = int(last-first) + 1
+		epochs, err := reader.Epochs()
+		if err != nil {
+			return report, fmt.Errorf("listing stored epochs: %w", err)
+		}
+		for _, epoch := range epochs {
+			if epoch >= first && epoch <= last {
+				report.EpochsCovered++
+			}
+		}
+	}
+
+	names := make([]string, 0, len(agg.groups))
+	for name := range agg.groups {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == "" || names[j] == "" {
+			return names[j] == ""
+		}
+		return names[i] < names[j]
+	})
+	total := newGroupAggregate("")
+	for _, name := range names {
+		g := agg.groups[name]
+		report.Groups = append(report.Groups, g.report())
+		total.merge(g)
+	}
+	report.Total = total.report()
+	return report, nil
+}
+
+// reportAggregator is a ports.ResultRowWriter summing rows per group.
+type reportAggregator struct {
+	groups map[string]*groupAggregate
+}
+
+func (r *reportAggregator) Write(row domain.ResultRow) error {
+	g, ok := r.groups[row.Group]
+	if !ok {
+		g = newGroupAggregate(row.Group)
+		r.groups[row.Group] = g
+	}
+	g.add(row)
+	return nil
+}
+
+func (r *reportAggregator) Close() error { return nil }
+
+type groupAggregate struct {
+	stats          domain.GroupReport
+	validators     map[domain.ValidatorIndex]struct{}
+	inclusionDelay uint64
+}
+
+func newGroupAggregate(name string) *groupAggregate {
+	return &groupAggregate{
+		stats:      domain.GroupReport{Name: name, Proposals: domain.ProposalStats{Misses: []domain.MissedDuty{}}},
+		validators: make(map[domain.ValidatorIndex]struct{}),
+	}
+}
+
+func (g *groupAggregate) add(row domain.ResultRow) {
+	g.validators[row.ValidatorIndex] = struct{}{}
+	g.stats.MissedRewards += row.MissedReward
+	switch row.Duty {
+	case domain.DutyTypeSyncCommittee:
+		s := &g.stats.SyncCommittee
+		s.Duties++
+		switch row.Outcome {
+		case domain.OutcomeSuccess:
+			s.Included++
+		case domain.OutcomeMissed:
+			s.Missed++
+		default:
+			s.Unknown++
+		}
+		return
+	case domain.DutyTypeProposal:
+		p := &g.stats.Proposals
+		p.Duties++
+		switch row.Outcome {
+		case domain.OutcomeSuccess:
+			p.Proposed++
+		case domain.OutcomeMissed:
+			p.Missed++
+			p.Misses = append(p.Misses, domain.MissedDuty{Slot: row.Slot, ValidatorIndex: row.ValidatorIndex, Reason: row.Reason})
+		default:
+			p.Unknown++
+		}
+		return
+	}
+	a := &g.stats.Attestations
+	a.Duties++
+	switch row.Outcome {
+	case domain.OutcomeSuccess:
+		a.Included++
+		g.inclusionDelay += *row.InclusionDelay
+		if *row.InclusionDelay == 1 {
+			a.OptimalInclusions++
+		}
+	case domain.OutcomeMissed:
+		a.Missed++
+	default:
+		a.Unknown++
+	}
+}
+
+func (g *groupAggregate) merge(other *groupAggregate) {
+	for v := range other.validators {
+		g.validators[v] = struct{}{}
+	}
+	g.inclusionDelay += other.inclusionDelay
+	a, o := &g.stats.Attestations, other.stats.Attestations
+	a.Duties += o.Duties
+	a.Included += o.Included
+	a.Missed += o.Missed
+	a.Unknown += o.Unknown
+	a.OptimalInclusions += o.OptimalInclusions
+	p, op := &g.stats.Proposals, other.stats.Proposals
+	p.Duties += op.Duties
+	p.Proposed += op.Proposed
+	p.Missed += op.Missed
+	p.Unknown += op.Unknown
+	p.Misses = append(p.Misses, op.Misses...)
+	s, so := &g.stats.SyncCommittee, other.stats.SyncCommittee
+	s.Duties += so.Duties
+	s.Included += so.Included
+	s.Missed += so.Missed
+	s.Unknown += so.Unknown
+	g.stats.MissedRewards += other.stats.MissedRewards
+}
+
+// report returns the group's report with its rates computed and the missed
+// proposals in slot order.
+func (g *groupAggregate) report() domain.GroupReport {
+	r := g.stats
+	r.Validators = len(g.validators)
+	if decided := r.Attestations.Included + r.Attestations.Missed; decided > 0 {
+		r.Attestations.InclusionRate = float64(r.Attestations.Included) / float64(decided)
+	}
+	if decided := r.SyncCommittee.Included + r.SyncCommittee.Missed; decided > 0 {
+		r.SyncCommittee.ParticipationRate = float64(r.SyncCommittee.Included) / float64(decided)
+	}
+	if r.Attestations.Included > 0 {
+		r.Attestations.AverageInclusionDelay = float64(g.inclusionDelay) / float64(r.Attestations.Included)
+	}
+	sort.Slice(r.Proposals.Misses, func(i, j int) bool { return r.Proposals.Misses[i].Slot < r.Proposals.Misses[j].Slot })
+	return r
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+func TestLastReportPeriod(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 6, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		schedule string
+		now      time.Time
+		from, to time.Time
+	}{
+		{ReportDaily, day(4).Add(13 * time.Hour), day(3), day(4)},
+		{ReportDaily, day(4), day(3), day(4)},
+		// 2025-06-02 is a Monday.
+		{ReportWeekly, day(4).Add(time.Hour), day(2).AddDate(0, 0, -7), day(2)},
+		{ReportWeekly, day(2), day(2).AddDate(0, 0, -7), day(2)},
+		{ReportWeekly, day(8).Add(23 * time.Hour), day(2).AddDate(0, 0, -7), day(2)},
+		{ReportWeekly, day(9).Add(time.Minute), day(2), day(9)},
+	}
+	for _, tt := range tests {
+		from, to := LastReportPeriod(tt.schedule, tt.now)
+		if !from.Equal(tt.from) || !to.Equal(tt.to) {
+			t.Errorf("%s at %s: got [%s, %s), want [%s, %s)", tt.schedule, tt.now, from, to, tt.from, tt.to)
+		}
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	genesis := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := newMemoryResultStore()
+	attestation := func(v domain.ValidatorIndex, epoch domain.Epoch, outcome domain.DutyOutcome, delay domain.Slot) domain.DutyResult {
+		slot := domain.Slot(epoch)*SlotsPerEpoch + domain.Slot(v)
+		r := domain.DutyResult{Type: domain.DutyTypeAttestation, ValidatorIndex: v, Epoch: epoch, Slot: slot, Outcome: outcome}
+		if outcome == domain.OutcomeSuccess {
+			r.InclusionSlot = slot + delay
+		}
+		return r
+	}
+	proposal := func(v domain.ValidatorIndex, slot domain.Slot, outcome domain.DutyOutcome) domain.DutyResult {
+		r := domain.DutyResult{Type: domain.DutyTypeProposal, ValidatorIndex: v, Epoch: domain.Epoch(slot / SlotsPerEpoch), Slot: slot, Outcome: outcome}
+		if outcome == domain.OutcomeSuccess {
+			r.InclusionSlot = slot
+		}
+		return r
+	}
+	store.epochs[1] = []domain.DutyResult{
+		attestation(1, 1, domain.OutcomeSuccess, 1),
+		attestation(2, 1, domain.OutcomeSuccess, 3),
+		attestation(3, 1, domain.OutcomeMissed, 0),
+		proposal(2, 50, domain.OutcomeMissed),
+		proposal(3, 40, domain.OutcomeSuccess),
+	}
+	sync := func(v domain.ValidatorIndex, slot domain.Slot, outcome domain.DutyOutcome) domain.DutyResult {
+		r := domain.DutyResult{Type: domain.DutyTypeSyncCommittee, ValidatorIndex: v, Epoch: domain.Epoch(slot / SlotsPerEpoch), Slot: slot, Outcome: outcome}
+		if outcome == domain.OutcomeSuccess {
+			r.InclusionSlot = slot
+		} else {
+			r.MissedReward = 20_000
+		}
+		return r
+	}
+	store.epochs[1] = append(store.epochs[1],
+		sync(1, 40, domain.OutcomeSuccess),
+		sync(1, 41, domain.OutcomeMissed),
+		sync(3, 41, domain.OutcomeSuccess),
+	)
+	store.epochs[1][2].MissedReward = 11_500
+	store.epochs[2] = []domain.DutyResult{
+		attestation(1, 2, domain.OutcomeUnknown, 0),
+		attestation(2, 2, domain.OutcomeSuccess, 1),
+		attestation(3, 2, domain.OutcomeSuccess, 2),
+		proposal(1, 70, domain.OutcomeMissed),
+		proposal(2, 65, domain.OutcomeMissed),
+	}
+	// Outside the period.
+	store.epochs[5] = []domain.DutyResult{attestation(1, 5, domain.OutcomeMissed, 0)}
+
+	groupOf := func(v domain.ValidatorIndex) string {
+		if v == 3 {
+			return ""
+		}
+		return "a"
+	}
+	from := domain.SlotTime(genesis, SlotsPerEpoch)
+	report, err := BuildReport(store, from, from.Add(4*32*12*time.Second), genesis, groupOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.EpochsExpected != 4 || report.EpochsCovered != 2 {
+		t.Errorf("covered %d of %d epochs, want 2 of 4", report.EpochsCovered, report.EpochsExpected)
+	}
+	if len(report.Groups) != 2 || report.Groups[0].Name != "a" || report.Groups[1].Name != "" {
+		t.Fatalf("groups %+v, want a then ungrouped", report.Groups)
+	}
+	a := report.Groups[0]
+	wantAttestations := domain.AttestationStats{
+		Duties: 4, Included: 3, Unknown: 1, InclusionRate: 1, AverageInclusionDelay: 5.0 / 3, OptimalInclusions: 2,
+	}
+	if a.Validators != 2 || a.Attestations != wantAttestations {
+		t.Errorf("group a: %d validators, attestations %+v, want 2 and %+v", a.Validators, a.Attestations, wantAttestations)
+	}
+	if p := a.Proposals; p.Duties != 3 || p.Missed != 3 || len(p.Misses) != 3 ||
+		p.Misses[0].Slot != 50 || p.Misses[1].Slot != 65 || p.Misses[2].Slot != 70 {
+		t.Errorf("group a proposals %+v, want misses at 50, 65, 70", p)
+	}
+	wantSync := domain.SyncCommitteeStats{Duties: 2, Included: 1, Missed: 1, ParticipationRate: 0.5}
+	if a.SyncCommittee != wantSync || a.MissedRewards != 20_000 {
+		t.Errorf("group a: sync committee %+v, missed rewards %d, want %+v and 20000", a.SyncCommittee, a.MissedRewards, wantSync)
+	}
+
+	total := report.Total
+	if total.Validators != 3 || total.Attestations.Duties != 6 || total.Attestations.Missed != 1 ||
+		total.Attestations.InclusionRate != 0.8 || total.Proposals.Duties != 4 || total.Proposals.Proposed != 1 ||
+		total.SyncCommittee.Duties != 3 || total.SyncCommittee.ParticipationRate != 2.0/3 || total.MissedRewards != 31_500 {
+		t.Errorf("total %+v", total)
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// SetResultStore persists the results of each finalized epoch to store. An
+// epoch's results are committed only once its check completed, and rolled
+// back if the check is aborted, so the latest committed epoch is a reliable
+// checkpoint: the checker resumes after it.
+func (a *DutiesChecker) SetResultStore(store ports.ResultStore) error {
+	last, ok, err := store.LastEpoch()
+	if err != nil {
+		return fmt.Errorf("reading last stored epoch: %w", err)
+	}
+	a.results = store
+	if ok {
+		logger.Info("Resuming after epoch %d, the last one with stored results", last)
+		a.lastFinalizedEpoch = last
+		a.markProcessed(last)
+	}
+	return nil
+}
+
+// beginEpoch opens the result writer of a finalized epoch, if results are
+// stored, and resets the misses collected for correlation.
+func (a *DutiesChecker) beginEpoch(epoch domain.Epoch) {
+	a.misses = nil
+	if a.results == nil {
+		return
+	}
+	w, err := a.results.BeginEpoch(epoch)
+	if err != nil {
+		logger.Error("Could not store results of epoch %d: %v", epoch, err)
+		return
+	}
+	a.epochWriter, a.epochWriteErr = w, nil
+}
+
+// storeResult adds a finalized result to the epoch being checked. After the
+// first write error the epoch is no longer written and is rolled back.
+func (a *DutiesChecker) storeResult(r domain.DutyResult) {
+	if a.epochWriter == nil || a.epochWriteErr != nil {
+		return
+	}
+	a.epochWriteErr = a.epochWriter.Write(r)
+}
+
+// finishEpoch completes the check of a finalized epoch. If the check failed
+// with checkErr, or ctx was cancelled meanwhile, it is incomplete: its
+// stored results are rolled back, the epoch is not marked as processed and
+// the next check of the finalized epoch starts it over. Otherwise results
+// are committed, correlated failures and corrections to provisional results
+// are sent and the epoch is processed.
+func (a *DutiesChecker) finishEpoch(ctx context.Context, epoch domain.Epoch, checkErr error) {
+	w, writeErr := a.epochWriter, a.epochWriteErr
+	a.epochWriter, a.epochWriteErr = nil, nil
+
+	if checkErr != nil || ctx.Err() != nil {
+		if w != nil {
+			if err := w.Rollback(); err != nil {
+				logger.Error("Could not roll back results of epoch %d: %v", epoch, err)
+			}
+		}
+		if checkErr != nil {
+			logger.Error("Check of epoch %d failed; its results were rolled back and it will be retried: %v", epoch, checkErr)
+		} else {
+			logger.Warn("Check of epoch %d interrupted; its results were rolled back", epoch)
+		}
+		// Forget what the incomplete check did, so that it is redone in full.
+		a.lastFinalizedEpoch, _ = a.LastProcessedEpoch()
+		a.misses, a.corrections = nil, nil
+		for index, checked := range a.checkedEpochs {
+			if checked == epoch {
+				delete(a.checkedEpochs, index)
+			}
+		}
+		return
+	}
+	if w != nil {
+		switch {
+		case writeErr != nil:
+			logger.Error("Could not store results of epoch %d: %v", epoch, writeErr)
+			w.Rollback()
+		default:
+			if err := w.Commit(); err != nil {
+				logger.Error("Could not commit results of epoch %d: %v", epoch, err)
+			}
+		}
+	}
+	a.reportCorrelatedFailures(ctx, epoch)
+	a.confirmProvisionalEpochs(ctx, epoch)
+	a.markProcessed(epoch)
+}
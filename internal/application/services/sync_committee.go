@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// evaluateSyncCommittee requests the sync committee duties of the given
+// validators dutyChunkSize at a time and decides, for every member and every
+// block of the epoch, whether the member's signature is in the block's sync
+// aggregate: all its positions must be set. A slot without a block has no
+// aggregate and gives no result; a block that could not be fetched gives
+// unknown ones. Results are in slot order, members in duty order.
+func (e *epochAttestations) evaluateSyncCommittee(
+	ctx context.Context,
+	beacon ports.BeaconChainAdapter,
+	epoch domain.Epoch,
+	validatorIndices []domain.ValidatorIndex,
+) ([]domain.DutyResult, error) {
+	var duties []domain.SyncCommitteeDuty
+	for start := 0; start < len(validatorIndices); start += dutyChunkSize {
+		chunk := validatorIndices[start:min(start+dutyChunkSize, len(validatorIndices))]
+		chunkDuties, err := beacon.GetSyncCommitteeDuties(ctx, epoch, chunk)
+		if err != nil {
+			return nil, err
+		}
+		duties = append(duties, chunkDuties...)
+	}
+	if len(duties) == 0 {
+		return nil, nil
+	}
+	logger.Info("Checking %d sync committee members in the blocks of epoch %d", len(duties), epoch)
+
+	var results []domain.DutyResult
+	firstSlot := domain.Slot(epoch) * SlotsPerEpoch
+	for slot := firstSlot; slot < firstSlot+SlotsPerEpoch; slot++ {
+		fetchErr, unavailable := e.unavailableSlots[slot]
+		bits, proposed := e.syncBits[slot]
+		if !unavailable && !proposed {
+			continue
+		}
+		var missed []domain.ValidatorIndex
+		for _, duty := range duties {
+			r := domain.DutyResult{
+				Type:           domain.DutyTypeSyncCommittee,
+				ValidatorIndex: duty.ValidatorIndex,
+				Epoch:          epoch,
+				Slot:           slot,
+			}
+			switch {
+			case unavailable:
+				r.Outcome = domain.OutcomeUnknown
+				r.Reason = fmt.Sprintf("block at slot %d unavailable: %v", slot, fetchErr)
+			case signedAll(bits, duty.Positions):
+				r.Outcome = domain.OutcomeSuccess
+				r.InclusionSlot = slot
+			default:
+				r.Outcome = domain.OutcomeMissed
+				r.Reason = fmt.Sprintf("sync committee bits of the validator's positions %v not all set in block %d", duty.Positions, slot)
+				missed = append(missed, duty.ValidatorIndex)
+			}
+			results = append(results, r)
+		}
+		if e.rewards && len(missed) > 0 {
+			e.addSyncCommitteeRewards(ctx, beacon, slot, missed, results[len(results)-len(duties):])
+		}
+	}
+	return results, nil
+}
+
+// addSyncCommitteeRewards sets the missed reward of the misses among the
+// results of the block at slot. A failure leaves them unset.
+func (e *epochAttestations) addSyncCommitteeRewards(
+	ctx context.Context,
+	beacon ports.BeaconChainAdapter,
+	slot domain.Slot,
+	missed []domain.ValidatorIndex,
+	results []domain.DutyResult,
+) {
+	rewards, err := beacon.GetSyncCommitteeRewards(ctx, slot, missed)
+	if err != nil {
+		logger.Warn("Error fetching sync committee rewards of block %d: %v", slot, err)
+		return
+	}
+	missedReward := missedRewards(rewards)
+	for i := range results {
+		if results[i].Outcome == domain.OutcomeMissed {
+			results[i].MissedReward = missedReward[results[i].ValidatorIndex]
+		}
+	}
+}
+
+// missedRewards returns the reward each validator missed: ideal minus paid.
+func missedRewards(rewards []domain.ValidatorReward) map[domain.ValidatorIndex]int64 {
+	missed := make(map[domain.ValidatorIndex]int64, len(rewards))
+	for _, r := range rewards {
+		missed[r.ValidatorIndex] += r.Ideal - r.Actual
+	}
+	return missed
+}
+
+// syncBitsOf returns the sync committee bits of the blocks of epoch.
+func syncBitsOf(epoch domain.Epoch, blocks map[domain.Slot]domain.Block) map[domain.Slot][]byte {
+	bits := make(map[domain.Slot][]byte, SlotsPerEpoch)
+	firstSlot := domain.Slot(epoch) * SlotsPerEpoch
+	for slot := firstSlot; slot < firstSlot+SlotsPerEpoch; slot++ {
+		if b, ok := blocks[slot]; ok {
+			bits[slot] = b.SyncCommitteeBits
+		}
+	}
+	return bits
+}
+
+func signedAll(bits []byte, positions []uint64) bool {
+	for _, pos := range positions {
+		if !isBitSet(bits, int(pos)) {
+			return false
+		}
+	}
+	return true
+}
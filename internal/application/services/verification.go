@@ -0,0 +1,387 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+// VerificationNode is one beacon node whose answers are cross-checked in
+// verification mode.
+type VerificationNode struct {
+	Name    string
+	Adapter ports.BeaconChainAdapter
+}
+
+// EnableVerification switches the checker to verification mode: every epoch
+// is evaluated independently against each node, and a duty is only reported
+// when all nodes agree on it. Disagreements are handed to the recorder as
+// discrepancies instead of being reported as misses.
+func (a *DutiesChecker) EnableVerification(nodes []VerificationNode, recorder ports.DiscrepancyRecorder) {
+	a.verificationNodes = nodes
+	a.discrepancyRecorder = recorder
+}
+
+// attestationEvaluation is the outcome of checking the attestation duties of
+// an epoch against one node. Unlike the normal path, verification keeps every
+// duty and result of the epoch in memory to compare them across nodes.
+type attestationEvaluation struct {
+	*epochAttestations
+	duties []domain.ValidatorDuty
+	// results has one entry per duty, in the same order as duties.
+	results []domain.DutyResult
+}
+
+// nodeEvaluation is everything one node told us about an epoch. syncErr is
+// set when the node's sync committee duties could not be fetched, as before
+// Altair.
+type nodeEvaluation struct {
+	name          string
+	proposals     []domain.DutyResult
+	syncCommittee []domain.DutyResult
+	syncErr       error
+	attestations  *attestationEvaluation
+}
+
+func (a *DutiesChecker) verifyEpoch(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) error {
+	evals := evaluateOnAllNodes(ctx, a.verificationNodes, a.concurrency(), epoch, indices)
+	if len(evals) == 0 {
+		return fmt.Errorf("verification: no beacon node could evaluate epoch %d", epoch)
+	}
+	if len(evals) == 1 {
+		logger.Warn("Verification: only node %s could evaluate epoch %d; reporting its results unverified", evals[0].name, epoch)
+	}
+	// The baseline only annotates results, so the first node's is enough.
+	a.useBaseline(evals[0].attestations.baseline, true)
+
+	var discrepancies []domain.Discrepancy
+	discrepancies = append(discrepancies, a.compareProposals(epoch, evals)...)
+	discrepancies = append(discrepancies, a.compareSyncCommittee(epoch, evals)...)
+	discrepancies = append(discrepancies, a.compareAttestations(epoch, evals)...)
+
+	if len(discrepancies) == 0 {
+		logger.Info("Verification: %d beacon nodes agree on every duty of epoch %d", len(evals), epoch)
+		return nil
+	}
+	logger.Warn("Verification: %d discrepancies between beacon nodes in epoch %d", len(discrepancies), epoch)
+	if a.discrepancyRecorder == nil {
+		return nil
+	}
+	if err := a.discrepancyRecorder.RecordDiscrepancies(ctx, epoch, discrepancies); err != nil {
+		logger.Error("Verification: failed to record discrepancies for epoch %d: %v", epoch, err)
+	}
+	return nil
+}
+
+// evaluateOnAllNodes evaluates the epoch on every node concurrently. Nodes
+// that fail are logged and left out; the result keeps the configured order.
+func evaluateOnAllNodes(ctx context.Context, nodes []VerificationNode, concurrency int, epoch domain.Epoch, indices []domain.ValidatorIndex) []nodeEvaluation {
+	evals := make([]*nodeEvaluation, len(nodes))
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		wg.Add(1)
+		go func(i int, n VerificationNode) {
+			defer wg.Done()
+			proposals, err := evaluateProposals(ctx, n.Adapter, concurrency, epoch, indices)
+			if err != nil {
+				logger.Error("Verification: node %s failed to evaluate proposals for epoch %d: %v", n.Name, epoch, err)
+				return
+			}
+			data := fetchEpochAttestations(ctx, n.Adapter, concurrency, epoch)
+			data.rewards = true
+			syncResults, syncErr := data.evaluateSyncCommittee(ctx, n.Adapter, epoch, indices)
+			if syncErr != nil {
+				logger.Error("Verification: node %s failed to evaluate sync committee duties for epoch %d: %v", n.Name, epoch, syncErr)
+			}
+			attestations := &attestationEvaluation{epochAttestations: data}
+			scored := append(append([]domain.DutyResult(nil), proposals...), syncResults...)
+			_, err = data.evaluate(ctx, n.Adapter, epoch, indices, scored,
+				func(d domain.ValidatorDuty, r domain.DutyResult) {
+					attestations.duties = append(attestations.duties, d)
+					attestations.results = append(attestations.results, r)
+				})
+			if err != nil {
+				logger.Error("Verification: node %s failed to evaluate attestations for epoch %d: %v", n.Name, epoch, err)
+				return
+			}
+			evals[i] = &nodeEvaluation{
+				name: n.Name, proposals: proposals, syncCommittee: syncResults, syncErr: syncErr, attestations: attestations,
+			}
+		}(i, n)
+	}
+	wg.Wait()
+
+	var result []nodeEvaluation
+	for _, e := range evals {
+		if e != nil {
+			result = append(result, *e)
+		}
+	}
+	return result
+}
+
+// compareProposals reports the proposals every node agrees on and returns a
+// discrepancy for each slot where they differ, either on who had to propose
+// or on whether the block exists.
+func (a *DutiesChecker) compareProposals(epoch domain.Epoch, evals []nodeEvaluation) []domain.Discrepancy {
+	perNode := make([]map[domain.Slot]domain.DutyResult, len(evals))
+	var slots []domain.Slot
+	seen := make(map[domain.Slot]struct{})
+	for i, e := range evals {
+		perNode[i] = make(map[domain.Slot]domain.DutyResult)
+		for _, r := range e.proposals {
+			perNode[i][r.Slot] = r
+			if _, ok := seen[r.Slot]; !ok {
+				seen[r.Slot] = struct{}{}
+				slots = append(slots, r.Slot)
+			}
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+
+	var discrepancies []domain.Discrepancy
+	for _, slot := range slots {
+		responses := make(map[string]interface{}, len(evals))
+		sameDuty, sameOutcome := true, true
+		var unknown *domain.DutyResult
+		first, firstOK := perNode[0][slot]
+		for i, e := range evals {
+			r, ok := perNode[i][slot]
+			if ok {
+				responses[e.name] = r
+			} else {
+				responses[e.name] = nil
+			}
+			if ok != firstOK || r.ValidatorIndex != first.ValidatorIndex {
+				sameDuty = false
+			}
+			if r.Outcome != first.Outcome {
+				sameOutcome = false
+			}
+			if ok && r.Outcome == domain.OutcomeUnknown && unknown == nil {
+				unknown = &r
+			}
+		}
+
+		switch {
+		case !sameDuty:
+			discrepancies = append(discrepancies, domain.Discrepancy{
+				Epoch: epoch, Kind: domain.DiscrepancyProposerDuties, Slot: &slot, Responses: responses,
+			})
+		case unknown != nil:
+			// Missing data on one node is not a disagreement between clients.
+			a.report(*unknown)
+		case !sameOutcome:
+			v := first.ValidatorIndex
+			discrepancies = append(discrepancies, domain.Discrepancy{
+				Epoch: epoch, Kind: domain.DiscrepancyBlockProposed, ValidatorIndex: &v, Slot: &slot, Responses: responses,
+			})
+		default:
+			a.report(first)
+		}
+	}
+	return discrepancies
+}
+
+// compareSyncCommittee reports the sync committee results every node agrees
+// on and returns a discrepancy for each member and block where they differ,
+// on the duty or on whether the member's signature is included.
+// Nodes whose sync committee duties could not be fetched are left out; the
+// missed rewards are not compared, like the effectiveness scores.
+func (a *DutiesChecker) compareSyncCommittee(epoch domain.Epoch, evals []nodeEvaluation) []domain.Discrepancy {
+	type memberSlot struct {
+		validator domain.ValidatorIndex
+		slot      domain.Slot
+	}
+	var nodes []nodeEvaluation
+	for _, e := range evals {
+		if e.syncErr == nil {
+			nodes = append(nodes, e)
+		}
+	}
+	perNode := make([]map[memberSlot]domain.DutyResult, len(nodes))
+	var keys []memberSlot
+	seen := make(map[memberSlot]struct{})
+	for i, e := range nodes {
+		perNode[i] = make(map[memberSlot]domain.DutyResult)
+		for _, r := range e.syncCommittee {
+			k := memberSlot{validator: r.ValidatorIndex, slot: r.Slot}
+			perNode[i][k] = r
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].slot != keys[j].slot {
+			return keys[i].slot < keys[j].slot
+		}
+		return keys[i].validator < keys[j].validator
+	})
+
+	var discrepancies []domain.Discrepancy
+	for _, k := range keys {
+		responses := make(map[string]interface{}, len(nodes))
+		sameDuty, sameOutcome := true, true
+		var unknown *domain.DutyResult
+		first, firstOK := perNode[0][k]
+		for i, e := range nodes {
+			r, ok := perNode[i][k]
+			if ok {
+				responses[e.name] = r
+			} else {
+				responses[e.name] = nil
+			}
+			if ok != firstOK {
+				sameDuty = false
+			}
+			if r.Outcome != first.Outcome {
+				sameOutcome = false
+			}
+			if ok && r.Outcome == domain.OutcomeUnknown && unknown == nil {
+				unknown = &r
+			}
+		}
+
+		switch {
+		case unknown != nil && sameDuty:
+			// Missing data on one node is not a disagreement between clients.
+			a.report(*unknown)
+		case !sameDuty || !sameOutcome:
+			v, slot := k.validator, k.slot
+			discrepancies = append(discrepancies, domain.Discrepancy{
+				Epoch: epoch, Kind: domain.DiscrepancySyncCommittee, ValidatorIndex: &v, Slot: &slot, Responses: responses,
+			})
+		default:
+			a.report(first)
+		}
+	}
+	return discrepancies
+}
+
+// compareAttestations reports the attestation duties every node agrees on and
+// returns discrepancies for differing duties, committee sizes, attestation
+// presence or, for an attestation included on every node, inclusion slot and
+// head and target votes. The effectiveness score follows from these and is
+// not compared on its own. Committee size differences are recorded once per
+// slot; the duties in that slot are still reported if the nodes nevertheless
+// agree on them.
+func (a *DutiesChecker) compareAttestations(epoch domain.Epoch, evals []nodeEvaluation) []domain.Discrepancy {
+	type dutyAndResult struct {
+		duty   domain.ValidatorDuty
+		result domain.DutyResult
+	}
+	perNode := make([]map[domain.ValidatorIndex]dutyAndResult, len(evals))
+	var validators []domain.ValidatorIndex
+	seen := make(map[domain.ValidatorIndex]struct{})
+	for i, e := range evals {
+		perNode[i] = make(map[domain.ValidatorIndex]dutyAndResult)
+		for j, d := range e.attestations.duties {
+			perNode[i][d.ValidatorIndex] = dutyAndResult{duty: d, result: e.attestations.results[j]}
+			if _, ok := seen[d.ValidatorIndex]; !ok {
+				seen[d.ValidatorIndex] = struct{}{}
+				validators = append(validators, d.ValidatorIndex)
+			}
+		}
+	}
+	sort.Slice(validators, func(i, j int) bool { return validators[i] < validators[j] })
+
+	var discrepancies []domain.Discrepancy
+	checkedSlots := make(map[domain.Slot]struct{})
+	for _, v := range validators {
+		first, firstOK := perNode[0][v]
+
+		dutyResponses := make(map[string]interface{}, len(evals))
+		sameDuty := true
+		for i, e := range evals {
+			dr, ok := perNode[i][v]
+			if ok {
+				dutyResponses[e.name] = dr.duty
+			} else {
+				dutyResponses[e.name] = nil
+			}
+			if ok != firstOK || dr.duty != first.duty {
+				sameDuty = false
+			}
+		}
+		if !sameDuty {
+			discrepancies = append(discrepancies, domain.Discrepancy{
+				Epoch: epoch, Kind: domain.DiscrepancyAttesterDuty, ValidatorIndex: &v, Responses: dutyResponses,
+			})
+			continue
+		}
+
+		slot := first.duty.Slot
+		if _, done := checkedSlots[slot]; !done {
+			checkedSlots[slot] = struct{}{}
+			if d, ok := compareCommitteeSizes(epoch, slot, evals); !ok {
+				discrepancies = append(discrepancies, d)
+			}
+		}
+
+		resultResponses := make(map[string]interface{}, len(evals))
+		sameOutcome, sameInclusion := true, true
+		var unknown *domain.DutyResult
+		for i, e := range evals {
+			r := perNode[i][v].result
+			resultResponses[e.name] = r
+			if r.Outcome != first.result.Outcome {
+				sameOutcome = false
+			}
+			if r.InclusionSlot != first.result.InclusionSlot || r.Head != first.result.Head || r.Target != first.result.Target {
+				sameInclusion = false
+			}
+			if r.Outcome == domain.OutcomeUnknown && unknown == nil {
+				unknown = &r
+			}
+		}
+		if unknown != nil {
+			// Missing data on one node is not a disagreement between clients.
+			a.report(*unknown)
+			a.markCheckedThisEpoch(v, epoch)
+			continue
+		}
+		if !sameOutcome || !sameInclusion {
+			kind := domain.DiscrepancyAttestation
+			if sameOutcome {
+				kind = domain.DiscrepancyAttestationInclusion
+			}
+			committee := first.duty.CommitteeIndex
+			discrepancies = append(discrepancies, domain.Discrepancy{
+				Epoch: epoch, Kind: kind, ValidatorIndex: &v, Slot: &slot,
+				CommitteeIndex: &committee, Responses: resultResponses,
+			})
+			continue
+		}
+
+		a.report(first.result)
+		a.markCheckedThisEpoch(v, epoch)
+	}
+	return discrepancies
+}
+
+// compareCommitteeSizes checks that every node returned the same committee
+// sizes for the slot. It returns false and the discrepancy when they differ.
+func compareCommitteeSizes(epoch domain.Epoch, slot domain.Slot, evals []nodeEvaluation) (domain.Discrepancy, bool) {
+	responses := make(map[string]interface{}, len(evals))
+	same := true
+	first := evals[0].attestations.committees.SizeMap(slot)
+	for _, e := range evals {
+		sizes := e.attestations.committees.SizeMap(slot)
+		responses[e.name] = sizes
+		if !reflect.DeepEqual(sizes, first) {
+			same = false
+		}
+	}
+	if same {
+		return domain.Discrepancy{}, true
+	}
+	return domain.Discrepancy{Epoch: epoch, Kind: domain.DiscrepancyCommitteeSize, Slot: &slot, Responses: responses}, false
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/application/ports"
+	"github.com/Marketen/duties-indexer/internal/testutil"
+)
+
+// memoryDiscrepancyRecorder keeps the recorded discrepancies in memory.
+type memoryDiscrepancyRecorder struct {
+	mu            sync.Mutex
+	discrepancies []domain.Discrepancy
+}
+
+func (r *memoryDiscrepancyRecorder) RecordDiscrepancies(_ context.Context, _ domain.Epoch, discrepancies []domain.Discrepancy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.discrepancies = append(r.discrepancies, discrepancies...)
+	return nil
+}
+
+// divergentChain serves a fake chain with some answers changed, like a
+// client that disagrees with the others.
+type divergentChain struct {
+	*fakechain.Chain
+	block      func(domain.Block) (domain.Block, error)
+	duties     func([]domain.ValidatorDuty) []domain.ValidatorDuty
+	committees func(domain.EpochCommittees) domain.EpochCommittees
+}
+
+func (c divergentChain) GetBlock(ctx context.Context, slot domain.Slot) (domain.Block, error) {
+	b, err := c.Chain.GetBlock(ctx, slot)
+	if err != nil || c.block == nil {
+		return b, err
+	}
+	return c.block(b)
+}
+
+func (c divergentChain) GetValidatorDutiesBatch(ctx context.Context, epoch domain.Epoch, indices []domain.ValidatorIndex) ([]domain.ValidatorDuty, error) {
+	duties, err := c.Chain.GetValidatorDutiesBatch(ctx, epoch, indices)
+	if err != nil || c.duties == nil {
+		return duties, err
+	}
+	return c.duties(append([]domain.ValidatorDuty(nil), duties...)), nil
+}
+
+func (c divergentChain) GetEpochCommittees(ctx context.Context, epoch domain.Epoch) (domain.EpochCommittees, error) {
+	committees, err := c.Chain.GetEpochCommittees(ctx, epoch)
+	if err != nil || c.committees == nil {
+		return committees, err
+	}
+	copied := make(domain.EpochCommittees, len(committees))
+	for slot, bySlot := range committees {
+		copied[slot] = make(map[domain.CommitteeIndex][]domain.ValidatorIndex, len(bySlot))
+		for index, members := range bySlot {
+			copied[slot][index] = members
+		}
+	}
+	return c.committees(copied), nil
+}
+
+func TestVerificationDiscrepancies(t *testing.T) {
+	ctx := context.Background()
+	chain := testutil.NewChain(t, fakechain.Config{
+		Seed: 21, Validators: 512, CommitteesPerSlot: 2, Epochs: 4,
+		MissedSlotRate: 0.1, MissedAttestationRate: 0.05, MaxInclusionDelay: 2,
+		SyncCommitteeSize: 64, MissedSyncRate: 0.1,
+	})
+	indices := testutil.Validators(512)
+	epoch, _ := chain.GetFinalizedEpoch(ctx)
+	firstSlot := domain.Slot(epoch) * SlotsPerEpoch
+	var proposed domain.Slot
+	for slot := firstSlot + 1; ; slot++ {
+		if _, err := chain.GetBlock(ctx, slot); err == nil {
+			proposed = slot
+			break
+		}
+	}
+	duties, err := chain.GetValidatorDutiesBatch(ctx, epoch, indices[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := duties[0]
+
+	tests := []struct {
+		name      string
+		divergent divergentChain
+		// want are the discrepancy kinds expected, each at least once.
+		want []string
+		// dropped is set when disagreeing duties must not be stored; a
+		// committee size difference alone does not drop the duties.
+		dropped bool
+		// validator, if set, must not have an attestation result stored.
+		validator *domain.ValidatorIndex
+	}{
+		{name: "agree"},
+		{
+			name: "block proposed",
+			divergent: divergentChain{block: func(b domain.Block) (domain.Block, error) {
+				if b.Slot == proposed {
+					return domain.Block{}, ports.ErrNotFound
+				}
+				return b, nil
+			}},
+			want:    []string{domain.DiscrepancyBlockProposed},
+			dropped: true,
+		},
+		{
+			name: "attester duty",
+			divergent: divergentChain{duties: func(duties []domain.ValidatorDuty) []domain.ValidatorDuty {
+				for i := range duties {
+					if duties[i].ValidatorIndex == moved.ValidatorIndex {
+						duties[i].Slot++
+					}
+				}
+				return duties
+			}},
+			want:      []string{domain.DiscrepancyAttesterDuty},
+			dropped:   true,
+			validator: &moved.ValidatorIndex,
+		},
+		{
+			name: "committee size",
+			divergent: divergentChain{committees: func(c domain.EpochCommittees) domain.EpochCommittees {
+				members := c[moved.Slot][moved.CommitteeIndex]
+				c[moved.Slot][moved.CommitteeIndex] = members[:len(members)-1]
+				return c
+			}},
+			want: []string{domain.DiscrepancyCommitteeSize},
+		},
+		{
+			name: "sync committee signature",
+			divergent: divergentChain{block: func(b domain.Block) (domain.Block, error) {
+				if b.Slot == proposed {
+					bits := append([]byte(nil), b.SyncCommitteeBits...)
+					bits[0] ^= 0xff
+					b.SyncCommitteeBits = bits
+				}
+				return b, nil
+			}},
+			want:    []string{domain.DiscrepancySyncCommittee},
+			dropped: true,
+		},
+		{
+			name: "head vote",
+			divergent: divergentChain{block: func(b domain.Block) (domain.Block, error) {
+				atts := make([]domain.Attestation, len(b.Attestations))
+				for i, att := range b.Attestations {
+					att.BeaconBlockRoot = domain.Root{0xff}
+					atts[i] = att
+				}
+				b.Attestations = atts
+				return b, nil
+			}},
+			want:    []string{domain.DiscrepancyAttestationInclusion},
+			dropped: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.divergent.Chain = chain
+			recorder := &memoryDiscrepancyRecorder{}
+			store := newMemoryResultStore()
+			checker := NewDutiesChecker(chain, time.Minute, indices)
+			if err := checker.SetResultStore(store); err != nil {
+				t.Fatal(err)
+			}
+			checker.EnableVerification([]VerificationNode{{Name: "a", Adapter: chain}, {Name: "b", Adapter: tt.divergent}}, recorder)
+			checker.checkLatestFinalizedEpoch(ctx)
+			stored, _ := store.results(epoch)
+
+			kinds := make(map[string]int)
+			for _, d := range recorder.discrepancies {
+				kinds[d.Kind]++
+				if _, ok := d.Responses["b"]; !ok || len(d.Responses) != 2 {
+					t.Errorf("%s discrepancy without both responses: %+v", d.Kind, d.Responses)
+				}
+			}
+			for _, kind := range tt.want {
+				if kinds[kind] == 0 {
+					t.Errorf("no %s discrepancy, got %v", kind, kinds)
+				}
+			}
+			if len(tt.want) == 0 {
+				if len(kinds) > 0 {
+					t.Errorf("identical nodes disagree: %v", kinds)
+				}
+				testutil.AssertResults(t, stored, chain.Expected(epoch, indices))
+			}
+			if kinds[domain.DiscrepancyAttestation] > 0 && tt.name == "head vote" {
+				t.Errorf("differing votes reported as differing presence")
+			}
+			if tt.dropped && len(stored) >= len(chain.Expected(epoch, indices)) {
+				t.Errorf("%d results stored despite the discrepancies", len(stored))
+			}
+			if tt.validator != nil {
+				for _, r := range stored {
+					if r.Type == domain.DutyTypeAttestation && r.ValidatorIndex == *tt.validator {
+						t.Errorf("attestation of validator %d stored despite differing duties", r.ValidatorIndex)
+					}
+				}
+			}
+		})
+	}
+}
@@ -1,62 +1,536 @@
 package config
 
 import (
+	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"gopkg.in/yaml.v2"
 )
 
+const (
+	defaultPollInterval         = 60 * time.Second
+	defaultLogLevel             = "INFO"
+	defaultLogFormat            = "console"
+	defaultBeaconHealthInterval = 30 * time.Second
+	defaultHTTPListenAddress    = ":9090"
+	defaultDataDir              = "data"
+	defaultConcurrency          = domain.DefaultConcurrency
+	defaultShutdownGracePeriod  = 30 * time.Second
+)
+
+var defaultBeaconRetry = RetryConfig{
+	MaxAttempts:    4,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+}
+
+var defaultEvents = EventsConfig{
+	Enabled:     true,
+	IdleTimeout: 2 * time.Minute,
+}
+
+var defaultNonFinality = NonFinalityConfig{
+	ThresholdEpochs: 4,
+	Fallback:        "justified",
+	HeadOffset:      2,
+}
+
+var defaultResults = ResultsConfig{
+	Enabled:         true,
+	RetentionEpochs: 1575, // about a week
+}
+
+var defaultReports = ReportsConfig{
+	Formats: []string{"markdown", "html", "json"},
+}
+
+var defaultNetworkBaseline = NetworkBaselineConfig{
+	MinParticipation:  domain.DefaultNetworkThresholds.MinParticipation,
+	MaxMissedSlotRate: domain.DefaultNetworkThresholds.MaxMissedSlotRate,
+}
+
+var defaultCorrelatedFailures = CorrelatedFailuresConfig{
+	MinValidators: domain.DefaultCorrelationPolicy.MinValidators,
+	Labels:        slices.Clone(domain.DefaultCorrelationPolicy.Labels),
+}
+
+var defaultBlockCache = BlockCacheConfig{
+	MaxBlocks: 256,
+	MaxAge:    time.Hour,
+}
+
+var defaultReadiness = ReadinessConfig{
+	MaxEpochLag: 3,
+}
+
 // Config holds runtime configuration for the duties-indexer service.
+//
+// The yaml tags define the config file schema; see config.example.yaml for a
+// documented example.
 type Config struct {
-	BeaconNodeURL    string
-	PollInterval     time.Duration
-	ValidatorIndices []domain.ValidatorIndex
-}
-
-// Load reads configuration from environment variables.
-func Load() (*Config, error) {
-	beaconURL := strings.TrimSpace(os.Getenv("BEACON_NODE_URL"))
-	if beaconURL == "" {
-		return nil, fmt.Errorf("BEACON_NODE_URL is required")
-	}
-
-	intervalStr := strings.TrimSpace(os.Getenv("POLL_INTERVAL_SECONDS"))
-	if intervalStr == "" {
-		intervalStr = "60"
-	}
-	sec, err := strconv.Atoi(intervalStr)
-	if err != nil || sec <= 0 {
-		return nil, fmt.Errorf("invalid POLL_INTERVAL_SECONDS: %q", intervalStr)
-	}
-	pollInterval := time.Duration(sec) * time.Second
-
-	// VALIDATOR_INDICES is now optional. If empty, we leave ValidatorIndices
-	// empty and the main program will fall back to tracking all active validators.
-	valStr := strings.TrimSpace(os.Getenv("VALIDATOR_INDICES"))
-	indices := []domain.ValidatorIndex{}
-	if valStr != "" {
-		rawParts := strings.Split(valStr, ",")
-		indices = make([]domain.ValidatorIndex, 0, len(rawParts))
-		for _, p := range rawParts {
-			p = strings.TrimSpace(p)
-			if p == "" {
+	BeaconNodes          []BeaconNodeConfig       `yaml:"beacon_nodes"`
+	BeaconHealthInterval time.Duration            `yaml:"beacon_health_interval"`
+	BeaconRetry          RetryConfig              `yaml:"beacon_retry"`
+	BlockCache           BlockCacheConfig         `yaml:"block_cache"`
+	Events               EventsConfig             `yaml:"events"`
+	HeadTracking         HeadTrackingConfig       `yaml:"head_tracking"`
+	NonFinality          NonFinalityConfig        `yaml:"non_finality"`
+	NetworkBaseline      NetworkBaselineConfig    `yaml:"network_baseline"`
+	CorrelatedFailures   CorrelatedFailuresConfig `yaml:"correlated_failures"`
+	Notifications        NotificationsConfig      `yaml:"notifications"`
+	PollInterval         time.Duration            `yaml:"poll_interval"`
+	Concurrency          int                      `yaml:"concurrency"`
+	LogLevel             string                   `yaml:"log_level"`
+	LogFormat            string                   `yaml:"log_format"`
+	HTTPListenAddress    string                   `yaml:"http_listen_address"`
+	Readiness            ReadinessConfig          `yaml:"readiness"`
+	DataDir              string                   `yaml:"data_dir"`
+	GenesisTime          time.Time                `yaml:"genesis_time,omitempty"`
+	Results              ResultsConfig            `yaml:"results"`
+	Reports              ReportsConfig            `yaml:"reports"`
+	ShutdownGracePeriod  time.Duration            `yaml:"shutdown_grace_period"`
+	ValidatorIndices     []domain.ValidatorIndex  `yaml:"validators,omitempty"`
+	Groups               []GroupConfig            `yaml:"groups,omitempty"`
+	Verification         VerificationConfig       `yaml:"verification"`
+}
+
+// RetryConfig controls retries of transient beacon node errors (timeouts,
+// 5xx, unreachable node) with exponential backoff.
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// EventsConfig controls the beacon node event stream. When enabled, new
+// finalized checkpoints trigger a check immediately and poll_interval is only
+// used while the stream is down. A stream without any event for IdleTimeout
+// is considered dropped.
+type EventsConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+}
+
+// HeadTrackingConfig enables provisional checks on the head chain: each
+// epoch is evaluated as soon as its inclusion window has passed, and the
+// result is confirmed (or corrected) once the epoch is finalized.
+type HeadTrackingConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// NonFinalityConfig configures non-finality detection. When the head is more
+// than ThresholdEpochs ahead of the finalized epoch an alert is raised and,
+// until finality resumes, duties are checked provisionally on the latest
+// justified epoch ("justified"), on the epoch HeadOffset behind the head
+// ("head"), or not at all ("none").
+type NonFinalityConfig struct {
+	ThresholdEpochs uint64 `yaml:"threshold_epochs"`
+	Fallback        string `yaml:"fallback"`
+	HeadOffset      uint64 `yaml:"head_offset"`
+}
+
+// NetworkBaselineConfig decides when the network as a whole counts as
+// degraded at a duty's slot: when the participation of its epoch or of its
+// slot is below MinParticipation, or the missed-slot rate of its epoch above
+// MaxMissedSlotRate. Duty results and alerts are marked accordingly.
+type NetworkBaselineConfig struct {
+	MinParticipation  float64 `yaml:"min_participation"`
+	MaxMissedSlotRate float64 `yaml:"max_missed_slot_rate"`
+}
+
+// NetworkThresholds returns the configured thresholds for the checker.
+func (c *Config) NetworkThresholds() domain.NetworkThresholds {
+	return domain.NetworkThresholds{
+		MinParticipation:  c.NetworkBaseline.MinParticipation,
+		MaxMissedSlotRate: c.NetworkBaseline.MaxMissedSlotRate,
+	}
+}
+
+// CorrelatedFailuresConfig groups misses at the same slot: when at least
+// MinValidators validators with the same value of one of the group label
+// keys in Labels (tried in order), or failing that any MinValidators
+// validators, miss the slot, one correlated failure is reported instead of
+// an alert per duty. MinValidators 0 disables it.
+type CorrelatedFailuresConfig struct {
+	MinValidators int      `yaml:"min_validators"`
+	Labels        []string `yaml:"labels"`
+}
+
+// CorrelationPolicy returns the configured policy for the checker.
+func (c *Config) CorrelationPolicy() domain.CorrelationPolicy {
+	return domain.CorrelationPolicy{
+		MinValidators: c.CorrelatedFailures.MinValidators,
+		Labels:        c.CorrelatedFailures.Labels,
+	}
+}
+
+// NotificationsConfig configures where alerts are sent besides the log.
+type NotificationsConfig struct {
+	// WebhookURL receives a JSON POST per batch of notifications; empty disables it.
+	WebhookURL string `yaml:"webhook_url,omitempty"`
+}
+
+// ReadinessConfig tunes the /readyz endpoint. The instance is not ready when
+// the last processed epoch is more than MaxEpochLag epochs behind the
+// finalized epoch of the beacon nodes.
+type ReadinessConfig struct {
+	MaxEpochLag uint64 `yaml:"max_epoch_lag"`
+}
+
+// ResultsConfig controls the store of duty results under <data_dir>/results,
+// one file per finalized epoch. The latest stored epoch is also where the
+// checker resumes after a restart. RetentionEpochs 0 keeps every epoch.
+// ExportToken serves the results on GET /api/v1/results/export, and their
+// effectiveness ranking on GET /api/v1/effectiveness, to requests bearing
+// it; empty leaves both endpoints off.
+type ResultsConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	RetentionEpochs int    `yaml:"retention_epochs"`
+	ExportToken     string `yaml:"export_token,omitempty"`
+}
+
+// ReportsConfig schedules performance reports built from the stored results:
+// after each day or week (UTC, weeks starting on Monday) a report per group
+// is written to <data_dir>/reports in each of Formats and, with Notify, sent
+// through the notifier. An empty Schedule disables them.
+type ReportsConfig struct {
+	Schedule string   `yaml:"schedule,omitempty"`
+	Formats  []string `yaml:"formats"`
+	Notify   bool     `yaml:"notify"`
+}
+
+// BlockCacheConfig bounds the cache of finalized blocks shared by the
+// proposal and attestation checks. MaxBlocks 0 disables it.
+type BlockCacheConfig struct {
+	MaxBlocks int           `yaml:"max_blocks"`
+	MaxAge    time.Duration `yaml:"max_age"`
+}
+
+// VerificationConfig enables cross-client verification: every epoch is
+// evaluated against each of the listed beacon nodes and any disagreement is
+// recorded under <data_dir>/discrepancies instead of being reported as a miss.
+type VerificationConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Nodes names the beacon_nodes to cross-check; empty means all of them.
+	Nodes []string `yaml:"nodes,omitempty"`
+}
+
+// VerificationNodes returns the beacon nodes cross-checked in verification mode.
+func (c *Config) VerificationNodes() []BeaconNodeConfig {
+	if len(c.Verification.Nodes) == 0 {
+		return c.BeaconNodes
+	}
+	var nodes []BeaconNodeConfig
+	for _, name := range c.Verification.Nodes {
+		for _, n := range c.BeaconNodes {
+			if n.Name == name {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	return nodes
+}
+
+// BeaconNodeConfig is one beacon node endpoint. With several nodes, calls are
+// routed to the healthiest one and fail over to the others on errors. Name is
+// used in logs and metrics and defaults to the URL host.
+type BeaconNodeConfig struct {
+	Name string `yaml:"name,omitempty"`
+	URL  string `yaml:"url"`
+	// RateLimit caps the requests per second sent to this node; 0 means unlimited.
+	RateLimit float64 `yaml:"rate_limit,omitempty"`
+}
+
+// GroupConfig is a named set of validators, e.g. all keys of one client or
+// all keys running on one machine. Labels are free-form key/value pairs
+// (machine, client, signer, ...) attached to every validator in the group.
+type GroupConfig struct {
+	Name       string                  `yaml:"name"`
+	Validators []domain.ValidatorIndex `yaml:"validators"`
+	Labels     map[string]string       `yaml:"labels,omitempty"`
+}
+
+// TrackedValidators returns the sorted, de-duplicated union of the top-level
+// validators and the validators of every group. An empty result means "track
+// all active validators".
+func (c *Config) TrackedValidators() []domain.ValidatorIndex {
+	seen := make(map[domain.ValidatorIndex]struct{})
+	var result []domain.ValidatorIndex
+	add := func(indices []domain.ValidatorIndex) {
+		for _, idx := range indices {
+			if _, ok := seen[idx]; ok {
 				continue
 			}
-			n, err := strconv.ParseUint(p, 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("invalid validator index %q in VALIDATOR_INDICES: %w", p, err)
+			seen[idx] = struct{}{}
+			result = append(result, idx)
+		}
+	}
+	add(c.ValidatorIndices)
+	for _, g := range c.Groups {
+		add(g.Validators)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// ValidatorGroups maps each validator of a group to the group name.
+func (c *Config) ValidatorGroups() map[domain.ValidatorIndex]string {
+	groups := make(map[domain.ValidatorIndex]string)
+	for _, g := range c.Groups {
+		for _, idx := range g.Validators {
+			groups[idx] = g.Name
+		}
+	}
+	return groups
+}
+
+// ValidatorLabels maps each validator of a group to the labels of the group.
+func (c *Config) ValidatorLabels() map[domain.ValidatorIndex]map[string]string {
+	labels := make(map[domain.ValidatorIndex]map[string]string)
+	for _, g := range c.Groups {
+		if len(g.Labels) == 0 {
+			continue
+		}
+		for _, idx := range g.Validators {
+			labels[idx] = g.Labels
+		}
+	}
+	return labels
+}
+
+// YAML renders the configuration in config file format.
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+// Load builds the effective configuration from, in increasing order of
+// precedence: built-in defaults, the config file, command-line flags and
+// environment variables. The config file path is taken from --config or
+// CONFIG_FILE; without one, flags and environment alone are used.
+//
+// args are the command-line arguments after the program (and subcommand) name.
+func Load(args []string) (*Config, error) {
+	return LoadWithFlags(args, nil)
+}
+
+// LoadWithFlags is Load for commands with flags of their own: extra, if not
+// nil, registers them on the flag set before the arguments are parsed.
+func LoadWithFlags(args []string, extra func(fs *flag.FlagSet)) (*Config, error) {
+	fs := flag.NewFlagSet("duties-indexer", flag.ContinueOnError)
+	if extra != nil {
+		extra(fs)
+	}
+	configPath := fs.String("config", "", "path to the YAML config file (env CONFIG_FILE)")
+	beaconURLs := fs.String("beacon-node-url", "", "comma-separated beacon node HTTP API URLs (env BEACON_NODE_URL)")
+	concurrency := fs.Int("concurrency", 0, "beacon requests kept in flight per fan-out (env CONCURRENCY)")
+	pollInterval := fs.Duration("poll-interval", 0, "how often to poll for a new finalized epoch, e.g. 60s (env POLL_INTERVAL_SECONDS)")
+	validators := fs.String("validators", "", "comma-separated validator indices to track (env VALIDATOR_INDICES)")
+	logLevel := fs.String("log-level", "", "DEBUG, INFO, WARN or ERROR (env LOG_LEVEL)")
+	logFormat := fs.String("log-format", "", "console or json (env LOG_FORMAT)")
+	dataDir := fs.String("data-dir", "", "directory for persistent data (env DATA_DIR)")
+	verify := fs.Bool("verify", false, "enable cross-client verification mode")
+	events := fs.Bool("events", true, "react to the beacon node event stream instead of only polling")
+	httpListenAddress := fs.String("http-listen-address", "", "address for the HTTP server exposing /metrics, empty to disable (env HTTP_LISTEN_ADDRESS)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	cfg := &Config{
+		BeaconHealthInterval: defaultBeaconHealthInterval,
+		BeaconRetry:          defaultBeaconRetry,
+		BlockCache:           defaultBlockCache,
+		Events:               defaultEvents,
+		NonFinality:          defaultNonFinality,
+		NetworkBaseline:      defaultNetworkBaseline,
+		CorrelatedFailures:   defaultCorrelatedFailures,
+		PollInterval:         defaultPollInterval,
+		Concurrency:          defaultConcurrency,
+		LogLevel:             defaultLogLevel,
+		LogFormat:            defaultLogFormat,
+		HTTPListenAddress:    defaultHTTPListenAddress,
+		Readiness:            defaultReadiness,
+		DataDir:              defaultDataDir,
+		Results:              defaultResults,
+		Reports:              defaultReports,
+		ShutdownGracePeriod:  defaultShutdownGracePeriod,
+	}
+
+	// 1. Config file.
+	path := *configPath
+	if env := strings.TrimSpace(os.Getenv("CONFIG_FILE")); env != "" {
+		path = env
+	}
+	if path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. Flags, only those explicitly set on the command line.
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "beacon-node-url":
+			cfg.BeaconNodes = parseBeaconNodes(*beaconURLs)
+		case "poll-interval":
+			cfg.PollInterval = *pollInterval
+		case "concurrency":
+			cfg.Concurrency = *concurrency
+		case "validators":
+			indices, err := ParseIndices(*validators)
+			if err != nil && flagErr == nil {
+				flagErr = fmt.Errorf("invalid --validators: %w", err)
+			}
+			cfg.ValidatorIndices = indices
+		case "log-level":
+			cfg.LogLevel = *logLevel
+		case "log-format":
+			cfg.LogFormat = *logFormat
+		case "http-listen-address":
+			cfg.HTTPListenAddress = strings.TrimSpace(*httpListenAddress)
+		case "data-dir":
+			cfg.DataDir = strings.TrimSpace(*dataDir)
+		case "verify":
+			cfg.Verification.Enabled = *verify
+		case "events":
+			cfg.Events.Enabled = *events
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	// 3. Environment variables.
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	cfg.normalize()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile decodes the YAML file at path into cfg, overriding whatever cfg
+// already holds for the keys present in the file.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	// Strict mode rejects unknown keys so typos don't silently fall back to defaults.
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config) error {
+	if v := strings.TrimSpace(os.Getenv("BEACON_NODE_URL")); v != "" {
+		cfg.BeaconNodes = parseBeaconNodes(v)
+	}
+
+	if v := strings.TrimSpace(os.Getenv("POLL_INTERVAL_SECONDS")); v != "" {
+		sec, err := strconv.Atoi(v)
+		if err != nil || sec <= 0 {
+			return fmt.Errorf("invalid POLL_INTERVAL_SECONDS: %q", v)
+		}
+		cfg.PollInterval = time.Duration(sec) * time.Second
+	}
+
+	if v := strings.TrimSpace(os.Getenv("CONCURRENCY")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid CONCURRENCY: %q", v)
+		}
+		cfg.Concurrency = n
+	}
+
+	if v := strings.TrimSpace(os.Getenv("VALIDATOR_INDICES")); v != "" {
+		indices, err := ParseIndices(v)
+		if err != nil {
+			return fmt.Errorf("invalid VALIDATOR_INDICES: %w", err)
+		}
+		cfg.ValidatorIndices = indices
+	}
+
+	if v := strings.TrimSpace(os.Getenv("LOG_LEVEL")); v != "" {
+		cfg.LogLevel = v
+	}
+
+	if v := strings.TrimSpace(os.Getenv("LOG_FORMAT")); v != "" {
+		cfg.LogFormat = v
+	}
+
+	if v := strings.TrimSpace(os.Getenv("DATA_DIR")); v != "" {
+		cfg.DataDir = v
+	}
+
+	if v := strings.TrimSpace(os.Getenv("RESULTS_EXPORT_TOKEN")); v != "" {
+		cfg.Results.ExportToken = v
+	}
+
+	// Unlike the others, an explicitly empty HTTP_LISTEN_ADDRESS is meaningful: it disables the server.
+	if v, ok := os.LookupEnv("HTTP_LISTEN_ADDRESS"); ok {
+		cfg.HTTPListenAddress = strings.TrimSpace(v)
+	}
+	return nil
+}
+
+// normalize fills derived defaults after all sources have been merged.
+func (c *Config) normalize() {
+	c.LogLevel = strings.ToUpper(strings.TrimSpace(c.LogLevel))
+	c.LogFormat = strings.ToLower(strings.TrimSpace(c.LogFormat))
+	for i := range c.BeaconNodes {
+		n := &c.BeaconNodes[i]
+		n.URL = strings.TrimSpace(n.URL)
+		if n.Name == "" {
+			if u, err := url.Parse(n.URL); err == nil && u.Host != "" {
+				n.Name = u.Host
+			} else {
+				n.Name = fmt.Sprintf("node-%d", i)
 			}
-			indices = append(indices, domain.ValidatorIndex(n))
 		}
 	}
+}
+
+// parseBeaconNodes parses a comma-separated list of beacon node URLs.
+func parseBeaconNodes(s string) []BeaconNodeConfig {
+	var nodes []BeaconNodeConfig
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			nodes = append(nodes, BeaconNodeConfig{URL: p})
+		}
+	}
+	return nodes
+}
 
-	return &Config{
-		BeaconNodeURL:    beaconURL,
-		PollInterval:     pollInterval,
-		ValidatorIndices: indices,
-	}, nil
+// ParseIndices parses a comma-separated list of validator indices.
+func ParseIndices(s string) ([]domain.ValidatorIndex, error) {
+	rawParts := strings.Split(s, ",")
+	indices := make([]domain.ValidatorIndex, 0, len(rawParts))
+	for _, p := range rawParts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validator index %q: %w", p, err)
+		}
+		indices = append(indices, domain.ValidatorIndex(n))
+	}
+	return indices, nil
 }
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+// clearEnv blanks the environment variables Load reads, so the developer's
+// environment does not leak into the tests.
+func clearEnv(t *testing.T) {
+	for _, name := range []string{
+		"CONFIG_FILE", "BEACON_NODE_URL", "POLL_INTERVAL_SECONDS", "CONCURRENCY", "VALIDATOR_INDICES",
+		"LOG_LEVEL", "LOG_FORMAT", "DATA_DIR", "RESULTS_EXPORT_TOKEN",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+beacon_nodes:
+  - url: http://file:5052
+poll_interval: 30s
+concurrency: 4
+log_level: debug
+validators: [1, 2]
+`)
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		check func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "defaults",
+			args: []string{"--beacon-node-url", "http://flag:5052"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.PollInterval != defaultPollInterval || cfg.Concurrency != domain.DefaultConcurrency || cfg.LogLevel != "INFO" {
+					t.Errorf("defaults not applied: %+v", cfg)
+				}
+				if cfg.CorrelationPolicy().MinValidators != domain.DefaultCorrelationPolicy.MinValidators {
+					t.Errorf("correlation policy %+v, want the default", cfg.CorrelationPolicy())
+				}
+			},
+		},
+		{
+			name: "file",
+			args: []string{"--config", path},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.BeaconNodes[0].URL != "http://file:5052" || cfg.BeaconNodes[0].Name != "file:5052" {
+					t.Errorf("beacon nodes %+v, want the file's", cfg.BeaconNodes)
+				}
+				if cfg.PollInterval != 30*time.Second || cfg.Concurrency != 4 || cfg.LogLevel != "DEBUG" || len(cfg.ValidatorIndices) != 2 {
+					t.Errorf("file values not applied: %+v", cfg)
+				}
+				if cfg.DataDir != defaultDataDir {
+					t.Errorf("data_dir %q, want the default for a key missing from the file", cfg.DataDir)
+				}
+			},
+		},
+		{
+			name: "flags over file",
+			args: []string{"--config", path, "--concurrency", "2", "--validators", "7"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Concurrency != 2 || len(cfg.ValidatorIndices) != 1 || cfg.ValidatorIndices[0] != 7 {
+					t.Errorf("flags did not override the file: %+v", cfg)
+				}
+				if cfg.PollInterval != 30*time.Second {
+					t.Errorf("poll_interval %s, want the file's for a flag not set", cfg.PollInterval)
+				}
+			},
+		},
+		{
+			name: "env over flags",
+			args: []string{"--config", path, "--concurrency", "2", "--log-level", "WARN"},
+			env:  map[string]string{"CONCURRENCY": "3", "BEACON_NODE_URL": "http://env:5052,http://env2:5052"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Concurrency != 3 {
+					t.Errorf("concurrency %d, want CONCURRENCY's 3", cfg.Concurrency)
+				}
+				if cfg.LogLevel != "WARN" {
+					t.Errorf("log level %q, want the flag's", cfg.LogLevel)
+				}
+				if len(cfg.BeaconNodes) != 2 || cfg.BeaconNodes[1].URL != "http://env2:5052" {
+					t.Errorf("beacon nodes %+v, want BEACON_NODE_URL's", cfg.BeaconNodes)
+				}
+			},
+		},
+		{
+			name: "CONFIG_FILE",
+			env:  map[string]string{"CONFIG_FILE": path},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Concurrency != 4 {
+					t.Errorf("concurrency %d, want the file's", cfg.Concurrency)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "unknown key",
+			file: "beacon_nodes:\n  - url: http://localhost:5052\npoll_intervall: 30s\n",
+			want: "field poll_intervall not found",
+		},
+		{
+			name: "unknown nested key",
+			file: "beacon_nodes:\n  - url: http://localhost:5052\n    rate_limt: 5\n",
+			want: "field rate_limt not found",
+		},
+		{
+			name: "invalid flag value",
+			args: []string{"--beacon-node-url", "http://localhost:5052", "--validators", "1,x"},
+			want: "invalid --validators",
+		},
+		{
+			name: "invalid env value",
+			args: []string{"--beacon-node-url", "http://localhost:5052"},
+			env:  map[string]string{"POLL_INTERVAL_SECONDS": "0"},
+			want: "invalid POLL_INTERVAL_SECONDS",
+		},
+		{
+			name: "positional argument",
+			args: []string{"--beacon-node-url", "http://localhost:5052", "extra"},
+			want: "unexpected arguments: extra",
+		},
+		{
+			name: "invalid result",
+			args: []string{"--beacon-node-url", "ftp://localhost"},
+			want: "beacon_nodes[0].url",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"--config", writeConfig(t, tt.file)}, args...)
+			}
+			_, err := Load(args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+// KeepRestartOnly copies into next the settings of c that are bound at
+// startup and only change on a restart, and describes, one line per setting,
+// those that next tried to change. Call it before Diff, so that Diff only
+// reports what a reload applies.
+func (c *Config) KeepRestartOnly(next *Config) []string {
+	var kept []string
+	if !reflect.DeepEqual(next.BeaconNodes, c.BeaconNodes) {
+		kept = append(kept, "beacon_nodes: keeping the current nodes")
+		next.BeaconNodes = c.BeaconNodes
+	}
+	if next.BeaconHealthInterval != c.BeaconHealthInterval {
+		kept = append(kept, fmt.Sprintf("beacon_health_interval: keeping %s", c.BeaconHealthInterval))
+		next.BeaconHealthInterval = c.BeaconHealthInterval
+	}
+	if next.BeaconRetry != c.BeaconRetry {
+		kept = append(kept, fmt.Sprintf("beacon_retry: keeping %+v", c.BeaconRetry))
+		next.BeaconRetry = c.BeaconRetry
+	}
+	if next.Events != c.Events {
+		kept = append(kept, fmt.Sprintf("events: keeping %+v", c.Events))
+		next.Events = c.Events
+	}
+	if next.HeadTracking != c.HeadTracking {
+		kept = append(kept, fmt.Sprintf("head_tracking: keeping %+v", c.HeadTracking))
+		next.HeadTracking = c.HeadTracking
+	}
+	if next.Readiness != c.Readiness {
+		kept = append(kept, fmt.Sprintf("readiness: keeping %+v", c.Readiness))
+		next.Readiness = c.Readiness
+	}
+	if next.NonFinality != c.NonFinality {
+		kept = append(kept, fmt.Sprintf("non_finality: keeping %+v", c.NonFinality))
+		next.NonFinality = c.NonFinality
+	}
+	if next.BlockCache != c.BlockCache {
+		kept = append(kept, fmt.Sprintf("block_cache: keeping %+v", c.BlockCache))
+		next.BlockCache = c.BlockCache
+	}
+	if next.DataDir != c.DataDir {
+		kept = append(kept, fmt.Sprintf("data_dir: keeping %s", c.DataDir))
+		next.DataDir = c.DataDir
+	}
+	if !next.GenesisTime.Equal(c.GenesisTime) {
+		kept = append(kept, fmt.Sprintf("genesis_time: keeping %s", c.GenesisTime))
+		next.GenesisTime = c.GenesisTime
+	}
+	if next.Results != c.Results {
+		kept = append(kept, fmt.Sprintf("results: keeping %+v", c.Results.redacted()))
+		next.Results = c.Results
+	}
+	if !reflect.DeepEqual(next.Reports, c.Reports) {
+		kept = append(kept, fmt.Sprintf("reports: keeping %+v", c.Reports))
+		next.Reports = c.Reports
+	}
+	if !reflect.DeepEqual(next.Verification, c.Verification) {
+		kept = append(kept, "verification: keeping the current mode")
+		next.Verification = c.Verification
+	}
+	if next.LogFormat != c.LogFormat {
+		kept = append(kept, fmt.Sprintf("log_format: keeping %s", c.LogFormat))
+		next.LogFormat = c.LogFormat
+	}
+	if next.HTTPListenAddress != c.HTTPListenAddress {
+		kept = append(kept, fmt.Sprintf("http_listen_address: keeping %q", c.HTTPListenAddress))
+		next.HTTPListenAddress = c.HTTPListenAddress
+	}
+	return kept
+}
+
+// Diff describes, one line per change, how next differs from c. It is used to
+// log what a config reload changed. Secrets are redacted.
+func (c *Config) Diff(next *Config) []string {
+	var changes []string
+	if !reflect.DeepEqual(c.BeaconNodes, next.BeaconNodes) {
+		changes = append(changes, fmt.Sprintf("beacon_nodes: %v -> %v", c.BeaconNodes, next.BeaconNodes))
+	}
+	if c.BeaconHealthInterval != next.BeaconHealthInterval {
+		changes = append(changes, fmt.Sprintf("beacon_health_interval: %s -> %s", c.BeaconHealthInterval, next.BeaconHealthInterval))
+	}
+	if c.BeaconRetry != next.BeaconRetry {
+		changes = append(changes, fmt.Sprintf("beacon_retry: %+v -> %+v", c.BeaconRetry, next.BeaconRetry))
+	}
+	if c.BlockCache != next.BlockCache {
+		changes = append(changes, fmt.Sprintf("block_cache: %+v -> %+v", c.BlockCache, next.BlockCache))
+	}
+	if c.Events != next.Events {
+		changes = append(changes, fmt.Sprintf("events: %+v -> %+v", c.Events, next.Events))
+	}
+	if c.HeadTracking != next.HeadTracking {
+		changes = append(changes, fmt.Sprintf("head_tracking: %+v -> %+v", c.HeadTracking, next.HeadTracking))
+	}
+	if c.NonFinality != next.NonFinality {
+		changes = append(changes, fmt.Sprintf("non_finality: %+v -> %+v", c.NonFinality, next.NonFinality))
+	}
+	if c.NetworkBaseline != next.NetworkBaseline {
+		changes = append(changes, fmt.Sprintf("network_baseline: %+v -> %+v", c.NetworkBaseline, next.NetworkBaseline))
+	}
+	if !reflect.DeepEqual(c.CorrelatedFailures, next.CorrelatedFailures) {
+		changes = append(changes, fmt.Sprintf("correlated_failures: %+v -> %+v", c.CorrelatedFailures, next.CorrelatedFailures))
+	}
+	if c.Notifications != next.Notifications {
+		changes = append(changes, fmt.Sprintf("notifications: %+v -> %+v", c.Notifications.redacted(), next.Notifications.redacted()))
+	}
+	if c.HTTPListenAddress != next.HTTPListenAddress {
+		changes = append(changes, fmt.Sprintf("http_listen_address: %s -> %s", c.HTTPListenAddress, next.HTTPListenAddress))
+	}
+	if c.Readiness != next.Readiness {
+		changes = append(changes, fmt.Sprintf("readiness: %+v -> %+v", c.Readiness, next.Readiness))
+	}
+	if c.PollInterval != next.PollInterval {
+		changes = append(changes, fmt.Sprintf("poll_interval: %s -> %s", c.PollInterval, next.PollInterval))
+	}
+	if c.DataDir != next.DataDir {
+		changes = append(changes, fmt.Sprintf("data_dir: %s -> %s", c.DataDir, next.DataDir))
+	}
+	if !c.GenesisTime.Equal(next.GenesisTime) {
+		changes = append(changes, fmt.Sprintf("genesis_time: %s -> %s", c.GenesisTime, next.GenesisTime))
+	}
+	if c.Results != next.Results {
+		changes = append(changes, fmt.Sprintf("results: %+v -> %+v", c.Results.redacted(), next.Results.redacted()))
+	}
+	if !reflect.DeepEqual(c.Reports, next.Reports) {
+		changes = append(changes, fmt.Sprintf("reports: %+v -> %+v", c.Reports, next.Reports))
+	}
+	if c.ShutdownGracePeriod != next.ShutdownGracePeriod {
+		changes = append(changes, fmt.Sprintf("shutdown_grace_period: %s -> %s", c.ShutdownGracePeriod, next.ShutdownGracePeriod))
+	}
+	if !reflect.DeepEqual(c.Verification, next.Verification) {
+		changes = append(changes, fmt.Sprintf("verification: %+v -> %+v", c.Verification, next.Verification))
+	}
+	if c.Concurrency != next.Concurrency {
+		changes = append(changes, fmt.Sprintf("concurrency: %d -> %d", c.Concurrency, next.Concurrency))
+	}
+	if c.LogLevel != next.LogLevel {
+		changes = append(changes, fmt.Sprintf("log_level: %s -> %s", c.LogLevel, next.LogLevel))
+	}
+	if c.LogFormat != next.LogFormat {
+		changes = append(changes, fmt.Sprintf("log_format: %s -> %s", c.LogFormat, next.LogFormat))
+	}
+	if added, removed := diffIndices(c.ValidatorIndices, next.ValidatorIndices); added+removed > 0 {
+		changes = append(changes, fmt.Sprintf("validators: +%d -%d", added, removed))
+	}
+
+	oldGroups := make(map[string]GroupConfig, len(c.Groups))
+	for _, g := range c.Groups {
+		oldGroups[g.Name] = g
+	}
+	newGroups := make(map[string]struct{}, len(next.Groups))
+	for _, g := range next.Groups {
+		newGroups[g.Name] = struct{}{}
+		old, ok := oldGroups[g.Name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("groups: added %q (%d validators)", g.Name, len(g.Validators)))
+			continue
+		}
+		if added, removed := diffIndices(old.Validators, g.Validators); added+removed > 0 {
+			changes = append(changes, fmt.Sprintf("groups[%s].validators: +%d -%d", g.Name, added, removed))
+		}
+		if !reflect.DeepEqual(old.Labels, g.Labels) {
+			changes = append(changes, fmt.Sprintf("groups[%s].labels: %v -> %v", g.Name, old.Labels, g.Labels))
+		}
+	}
+	for _, g := range c.Groups {
+		if _, ok := newGroups[g.Name]; !ok {
+			changes = append(changes, fmt.Sprintf("groups: removed %q", g.Name))
+		}
+	}
+	return changes
+}
+
+func diffIndices(before, after []domain.ValidatorIndex) (added, removed int) {
+	inBefore := make(map[domain.ValidatorIndex]struct{}, len(before))
+	for _, idx := range before {
+		inBefore[idx] = struct{}{}
+	}
+	inAfter := make(map[domain.ValidatorIndex]struct{}, len(after))
+	for _, idx := range after {
+		inAfter[idx] = struct{}{}
+	}
+	for idx := range inAfter {
+		if _, ok := inBefore[idx]; !ok {
+			added++
+		}
+	}
+	for idx := range inBefore {
+		if _, ok := inAfter[idx]; !ok {
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// redacted hides the export token, which must not end up in the logs.
+func (r ResultsConfig) redacted() ResultsConfig {
+	if r.ExportToken != "" {
+		r.ExportToken = "<redacted>"
+	}
+	return r
+}
+
+// redacted hides all of the webhook URL but its scheme and host: webhook
+// URLs routinely carry a secret in their path or query.
+func (n NotificationsConfig) redacted() NotificationsConfig {
+	if n.WebhookURL == "" {
+		return n
+	}
+	u, err := url.Parse(n.WebhookURL)
+	if err != nil || u.Host == "" {
+		n.WebhookURL = "<redacted>"
+		return n
+	}
+	n.WebhookURL = u.Scheme + "://" + u.Host + "/<redacted>"
+	return n
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+func TestDiff(t *testing.T) {
+	current := validConfig()
+	current.Groups = []GroupConfig{{Name: "a", Validators: []domain.ValidatorIndex{1, 2}}}
+	next := validConfig()
+	next.PollInterval = 2 * time.Minute
+	next.Notifications.WebhookURL = "https://hooks.example.com/services/T000/B000/secret?token=abc"
+	next.Results.ExportToken = "hunter2"
+	next.Groups = []GroupConfig{
+		{Name: "a", Validators: []domain.ValidatorIndex{2, 3}, Labels: map[string]string{"machine": "m1"}},
+		{Name: "b", Validators: []domain.ValidatorIndex{4}},
+	}
+
+	changes := current.Diff(next)
+	want := []string{
+		"notifications: {WebhookURL:} -> {WebhookURL:https://hooks.example.com/<redacted>}",
+		"poll_interval: 1m0s -> 2m0s",
+		"results: {Enabled:true RetentionEpochs:1575 ExportToken:} -> {Enabled:true RetentionEpochs:1575 ExportToken:<redacted>}",
+		"groups[a].validators: +1 -1",
+		"groups[a].labels: map[] -> map[machine:m1]",
+		`groups: added "b" (1 validators)`,
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got changes\n%s\nwant\n%s", strings.Join(changes, "\n"), strings.Join(want, "\n"))
+	}
+	for _, c := range changes {
+		if strings.Contains(c, "secret") || strings.Contains(c, "hunter2") {
+			t.Errorf("secret in %q", c)
+		}
+	}
+	if changes := next.Diff(next); len(changes) > 0 {
+		t.Errorf("identical configurations differ: %q", changes)
+	}
+}
+
+func TestKeepRestartOnly(t *testing.T) {
+	current := validConfig()
+	next := validConfig()
+	next.BeaconNodes = next.BeaconNodes[:1]
+	next.DataDir = "/var/lib/other"
+	next.Results.ExportToken = "hunter2"
+	next.LogLevel = "DEBUG"
+
+	kept := current.KeepRestartOnly(next)
+	want := []string{
+		"beacon_nodes: keeping the current nodes",
+		"data_dir: keeping data",
+		"results: keeping {Enabled:true RetentionEpochs:1575 ExportToken:}",
+	}
+	if !reflect.DeepEqual(kept, want) {
+		t.Errorf("got kept\n%s\nwant\n%s", strings.Join(kept, "\n"), strings.Join(want, "\n"))
+	}
+	if len(next.BeaconNodes) != 2 || next.DataDir != "data" || next.Results.ExportToken != "" {
+		t.Errorf("restart-only settings not kept: %+v", next)
+	}
+	if changes := current.Diff(next); !reflect.DeepEqual(changes, []string{"log_level: INFO -> DEBUG"}) {
+		t.Errorf("Diff after KeepRestartOnly reports %q, want only the log level", changes)
+	}
+}
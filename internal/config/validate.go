@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+// ValidationError lists every problem found in a configuration, so the user
+// can fix them all in one go instead of one per restart.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the configuration for missing or inconsistent values.
+// Field names in the messages use the config file keys.
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(c.BeaconNodes) == 0 {
+		addf("beacon_nodes: at least one is required (config file, --beacon-node-url or BEACON_NODE_URL)")
+	}
+	nodeNames := make(map[string]struct{})
+	for i, n := range c.BeaconNodes {
+		if u, err := url.Parse(n.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addf("beacon_nodes[%d].url: %q is not an http(s) URL", i, n.URL)
+		}
+		if n.RateLimit < 0 {
+			addf("beacon_nodes[%d].rate_limit: must not be negative, got %g", i, n.RateLimit)
+		}
+		if _, dup := nodeNames[n.Name]; dup {
+			addf("beacon_nodes[%d].name: duplicate beacon node name %q", i, n.Name)
+		}
+		nodeNames[n.Name] = struct{}{}
+	}
+
+	if c.BeaconHealthInterval <= 0 {
+		addf("beacon_health_interval: must be positive, got %s", c.BeaconHealthInterval)
+	}
+
+	if c.BeaconRetry.MaxAttempts < 1 {
+		addf("beacon_retry.max_attempts: must be at least 1, got %d", c.BeaconRetry.MaxAttempts)
+	}
+	if c.BeaconRetry.InitialBackoff < 0 || c.BeaconRetry.MaxBackoff < c.BeaconRetry.InitialBackoff {
+		addf("beacon_retry: need 0 <= initial_backoff <= max_backoff, got %s and %s",
+			c.BeaconRetry.InitialBackoff, c.BeaconRetry.MaxBackoff)
+	}
+
+	if c.BlockCache.MaxBlocks < 0 {
+		addf("block_cache.max_blocks: must not be negative, got %d", c.BlockCache.MaxBlocks)
+	}
+	if c.BlockCache.MaxAge < 0 {
+		addf("block_cache.max_age: must not be negative, got %s", c.BlockCache.MaxAge)
+	}
+
+	if c.Events.Enabled && c.Events.IdleTimeout <= 0 {
+		addf("events.idle_timeout: must be positive, got %s", c.Events.IdleTimeout)
+	}
+
+	if c.NonFinality.ThresholdEpochs < 2 {
+		addf("non_finality.threshold_epochs: must be at least 2 (the normal finality distance), got %d", c.NonFinality.ThresholdEpochs)
+	}
+	switch c.NonFinality.Fallback {
+	case "justified", "none":
+	case "head":
+		if c.NonFinality.HeadOffset < 1 {
+			addf("non_finality.head_offset: must be at least 1, got %d", c.NonFinality.HeadOffset)
+		}
+	default:
+		addf("non_finality.fallback: %q is not one of justified, head, none", c.NonFinality.Fallback)
+	}
+
+	if p := c.NetworkBaseline.MinParticipation; p < 0 || p > 1 {
+		addf("network_baseline.min_participation: must be between 0 and 1, got %g", p)
+	}
+	if r := c.NetworkBaseline.MaxMissedSlotRate; r < 0 || r > 1 {
+		addf("network_baseline.max_missed_slot_rate: must be between 0 and 1, got %g", r)
+	}
+
+	if c.CorrelatedFailures.MinValidators < 0 {
+		addf("correlated_failures.min_validators: must not be negative, got %d", c.CorrelatedFailures.MinValidators)
+	}
+	seenLabels := make(map[string]bool, len(c.CorrelatedFailures.Labels))
+	for _, k := range c.CorrelatedFailures.Labels {
+		switch {
+		case strings.TrimSpace(k) == "":
+			addf("correlated_failures.labels: label keys must not be empty")
+		case seenLabels[k]:
+			addf("correlated_failures.labels: duplicate label %q", k)
+		}
+		seenLabels[k] = true
+	}
+
+	if c.Notifications.WebhookURL != "" {
+		if u, err := url.Parse(c.Notifications.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addf("notifications.webhook_url: %q is not an http(s) URL", c.Notifications.WebhookURL)
+		}
+	}
+
+	if c.PollInterval <= 0 {
+		addf("poll_interval: must be positive, got %s", c.PollInterval)
+	}
+
+	if c.Concurrency < 1 {
+		addf("concurrency: must be at least 1, got %d", c.Concurrency)
+	}
+
+	if c.DataDir == "" {
+		addf("data_dir: must not be empty")
+	}
+
+	if c.Results.RetentionEpochs < 0 {
+		addf("results.retention_epochs: must not be negative, got %d", c.Results.RetentionEpochs)
+	}
+	if c.Results.ExportToken != "" && !c.Results.Enabled {
+		addf("results.export_token: needs results.enabled")
+	}
+
+	switch c.Reports.Schedule {
+	case "":
+	case "daily", "weekly":
+		if !c.Results.Enabled {
+			addf("reports.schedule: needs results.enabled, reports are built from the stored results")
+		}
+		if c.Reports.Notify && c.Notifications.WebhookURL == "" {
+			addf("reports.notify: needs notifications.webhook_url")
+		}
+	default:
+		addf("reports.schedule: %q is not one of daily, weekly", c.Reports.Schedule)
+	}
+	for i, f := range c.Reports.Formats {
+		switch f {
+		case "markdown", "html", "json":
+		default:
+			addf("reports.formats[%d]: %q is not one of markdown, html, json", i, f)
+		}
+	}
+
+	if c.ShutdownGracePeriod < 0 {
+		addf("shutdown_grace_period: must not be negative, got %s", c.ShutdownGracePeriod)
+	}
+
+	if c.Verification.Enabled {
+		for i, name := range c.Verification.Nodes {
+			if _, ok := nodeNames[name]; !ok {
+				addf("verification.nodes[%d]: %q is not the name of a beacon node", i, name)
+			}
+		}
+		if len(c.VerificationNodes()) < 2 {
+			addf("verification: needs at least two beacon nodes to compare")
+		}
+	}
+
+	switch c.LogLevel {
+	case "DEBUG", "INFO", "WARN", "ERROR":
+	default:
+		addf("log_level: %q is not one of DEBUG, INFO, WARN, ERROR", c.LogLevel)
+	}
+
+	switch c.LogFormat {
+	case "console", "json":
+	default:
+		addf("log_format: %q is not one of console, json", c.LogFormat)
+	}
+
+	groupNames := make(map[string]struct{})
+	groupOf := make(map[domain.ValidatorIndex]string)
+	for i, g := range c.Groups {
+		name := g.Name
+		if name == "" {
+			addf("groups[%d].name: required", i)
+			name = fmt.Sprintf("groups[%d]", i)
+		} else if _, dup := groupNames[name]; dup {
+			addf("groups[%d].name: duplicate group name %q", i, name)
+		}
+		groupNames[name] = struct{}{}
+
+		if len(g.Validators) == 0 {
+			addf("groups[%d] (%s): validators must not be empty", i, name)
+		}
+		for _, idx := range g.Validators {
+			if other, ok := groupOf[idx]; ok && other != name {
+				addf("groups[%d] (%s): validator %d is already in group %q", i, name, idx, other)
+				continue
+			}
+			groupOf[idx] = name
+		}
+		for k := range g.Labels {
+			if strings.TrimSpace(k) == "" {
+				addf("groups[%d] (%s): label keys must not be empty", i, name)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
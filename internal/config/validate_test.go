@@ -0,0 +1,129 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+)
+
+// validConfig returns a configuration Validate accepts, built from the
+// defaults like Load does.
+func validConfig() *Config {
+	cfg := &Config{
+		BeaconNodes:          []BeaconNodeConfig{{Name: "a", URL: "http://a:5052"}, {Name: "b", URL: "http://b:5052"}},
+		BeaconHealthInterval: defaultBeaconHealthInterval,
+		BeaconRetry:          defaultBeaconRetry,
+		BlockCache:           defaultBlockCache,
+		Events:               defaultEvents,
+		NonFinality:          defaultNonFinality,
+		NetworkBaseline:      defaultNetworkBaseline,
+		CorrelatedFailures:   defaultCorrelatedFailures,
+		PollInterval:         defaultPollInterval,
+		Concurrency:          defaultConcurrency,
+		LogLevel:             defaultLogLevel,
+		LogFormat:            defaultLogFormat,
+		DataDir:              defaultDataDir,
+		Results:              defaultResults,
+		Reports:              defaultReports,
+	}
+	cfg.normalize()
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []string
+	}{
+		{"valid", func(c *Config) {}, nil},
+		{"no beacon node", func(c *Config) { c.BeaconNodes = nil }, []string{"beacon_nodes: at least one is required"}},
+		{
+			"bad beacon nodes",
+			func(c *Config) {
+				c.BeaconNodes[0].URL = "localhost:5052"
+				c.BeaconNodes[1].Name = "a"
+				c.BeaconNodes[1].RateLimit = -1
+			},
+			[]string{"beacon_nodes[0].url", "beacon_nodes[1].rate_limit", `duplicate beacon node name "a"`},
+		},
+		{
+			"retry backoff",
+			func(c *Config) {
+				c.BeaconRetry.MaxAttempts = 0
+				c.BeaconRetry.MaxBackoff = time.Millisecond
+			},
+			[]string{"beacon_retry.max_attempts", "initial_backoff <= max_backoff"},
+		},
+		{"head fallback offset", func(c *Config) { c.NonFinality.Fallback, c.NonFinality.HeadOffset = "head", 0 }, []string{"non_finality.head_offset"}},
+		{"unknown fallback", func(c *Config) { c.NonFinality.Fallback = "latest" }, []string{"non_finality.fallback"}},
+		{"baseline rates", func(c *Config) { c.NetworkBaseline.MinParticipation = 1.5 }, []string{"network_baseline.min_participation"}},
+		{
+			"correlation labels",
+			func(c *Config) { c.CorrelatedFailures.Labels = []string{"machine", "machine", " "} },
+			[]string{`duplicate label "machine"`, "label keys must not be empty"},
+		},
+		{"webhook url", func(c *Config) { c.Notifications.WebhookURL = "hooks.example.com" }, []string{"notifications.webhook_url"}},
+		{"concurrency", func(c *Config) { c.Concurrency = 0 }, []string{"concurrency: must be at least 1"}},
+		{
+			"export token without results",
+			func(c *Config) { c.Results.Enabled, c.Results.ExportToken = false, "secret" },
+			[]string{"results.export_token: needs results.enabled"},
+		},
+		{
+			"report schedule",
+			func(c *Config) { c.Reports.Schedule, c.Reports.Notify = "daily", true },
+			[]string{"reports.notify: needs notifications.webhook_url"},
+		},
+		{"report format", func(c *Config) { c.Reports.Formats = []string{"pdf"} }, []string{"reports.formats[0]"}},
+		{
+			"verification nodes",
+			func(c *Config) {
+				c.Verification.Enabled = true
+				c.Verification.Nodes = []string{"a", "c"}
+			},
+			[]string{`verification.nodes[1]: "c"`, "verification: needs at least two beacon nodes"},
+		},
+		{"log level", func(c *Config) { c.LogLevel = "TRACE" }, []string{"log_level"}},
+		{
+			"groups",
+			func(c *Config) {
+				c.Groups = []GroupConfig{
+					{Name: "g1", Validators: []domain.ValidatorIndex{1, 2}},
+					{Name: "g2", Validators: []domain.ValidatorIndex{2}},
+					{Name: "g1", Validators: []domain.ValidatorIndex{3}},
+					{Validators: nil},
+				}
+			},
+			[]string{`validator 2 is already in group "g1"`, `groups[2].name: duplicate group name "g1"`, "groups[3].name: required", "groups[3] (groups[3]): validators must not be empty"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("got %v, want a ValidationError", err)
+			}
+			if len(verr.Problems) != len(tt.want) {
+				t.Errorf("got problems %q, want %d", verr.Problems, len(tt.want))
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
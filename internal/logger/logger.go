@@ -1,58 +1,98 @@
+// Package logger is the structured logger of the service. Lines are written
+// by zerolog, either as JSON objects (one per line, for log pipelines such as
+// Loki) or in a human-readable console format. Logs of go-eth2-client go
+// through the same sink and level.
 package logger
 
 import (
-	"log"
+	"io"
 	"os"
 	"strings"
-)
+	"sync/atomic"
 
-type Level int
+	"github.com/rs/zerolog"
+	zerologlog "github.com/rs/zerolog/log"
+)
 
+// Output formats.
 const (
-	DebugLevel Level = iota
-	InfoLevel
-	WarnLevel
-	ErrorLevel
+	FormatConsole = "console"
+	FormatJSON    = "json"
 )
 
-var currentLevel = InfoLevel
+// current is atomic because lines are logged from many goroutines.
+var current atomic.Pointer[zerolog.Logger]
 
 func init() {
-	lvlStr := strings.ToUpper(strings.TrimSpace(os.Getenv("LOG_LEVEL")))
-	switch lvlStr {
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnixMs
+	Setup(os.Getenv("LOG_FORMAT"), os.Stderr)
+	SetLevel(os.Getenv("LOG_LEVEL"))
+}
+
+// Setup sets the output format, FormatConsole or FormatJSON (unknown values
+// fall back to console), and the writer lines go to. It also becomes the
+// global zerolog logger, which go-eth2-client captures when a client is
+// created, so it must be called before creating beacon node clients.
+func Setup(format string, w io.Writer) {
+	out := w
+	if strings.ToLower(strings.TrimSpace(format)) != FormatJSON {
+		out = zerolog.ConsoleWriter{Out: w, NoColor: true, TimeFormat: "2006-01-02T15:04:05.000Z07:00"}
+	}
+	l := zerolog.New(out).With().Timestamp().Logger()
+	current.Store(&l)
+	zerologlog.Logger = l
+}
+
+// SetLevel sets the minimum level that is logged, for this package and
+// go-eth2-client alike (its clients are created with the lowest level of
+// their own, so the global level set here applies to them too, also when
+// it changes later). Unknown values fall back to INFO.
+func SetLevel(lvl string) {
+	switch strings.ToUpper(strings.TrimSpace(lvl)) {
 	case "DEBUG":
-		currentLevel = DebugLevel
-	case "INFO", "":
-		currentLevel = InfoLevel
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	case "WARN":
-		currentLevel = WarnLevel
+		zerolog.SetGlobalLevel(zerolog.WarnLevel)
 	case "ERROR":
-		currentLevel = ErrorLevel
+		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
 	default:
-		currentLevel = InfoLevel
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	}
 }
 
-func Debug(format string, args ...interface{}) {
-	if currentLevel <= DebugLevel {
-		log.Printf("[DEBUG] "+format, args...)
-	}
-}
+// Fields are typed key-values attached to a line. In JSON they are top-level
+// keys keeping their type; on the console they follow the message as
+// key=value.
+type Fields map[string]any
 
-func Info(format string, args ...interface{}) {
-	if currentLevel <= InfoLevel {
-		log.Printf("[INFO] "+format, args...)
-	}
+// Entry logs lines carrying a set of fields.
+type Entry struct {
+	fields Fields
 }
 
-func Warn(format string, args ...interface{}) {
-	if currentLevel <= WarnLevel {
-		log.Printf("[WARN] "+format, args...)
-	}
+// With returns an Entry whose lines carry fields.
+func With(fields Fields) Entry {
+	return Entry{fields: fields}
 }
 
-func Error(format string, args ...interface{}) {
-	if currentLevel <= ErrorLevel {
-		log.Printf("[ERROR] "+format, args...)
+func (e Entry) Debug(format string, args ...interface{}) { e.log(zerolog.DebugLevel, format, args) }
+func (e Entry) Info(format string, args ...interface{})  { e.log(zerolog.InfoLevel, format, args) }
+func (e Entry) Warn(format string, args ...interface{})  { e.log(zerolog.WarnLevel, format, args) }
+func (e Entry) Error(format string, args ...interface{}) { e.log(zerolog.ErrorLevel, format, args) }
+
+func (e Entry) log(level zerolog.Level, format string, args []interface{}) {
+	// WithLevel returns nil below the global level; nil events are no-ops.
+	event := current.Load().WithLevel(level)
+	if event == nil {
+		return
+	}
+	if len(e.fields) > 0 {
+		event = event.Fields(map[string]interface{}(e.fields))
 	}
+	event.Msgf(format, args...)
 }
+
+func Debug(format string, args ...interface{}) { Entry{}.log(zerolog.DebugLevel, format, args) }
+func Info(format string, args ...interface{})  { Entry{}.log(zerolog.InfoLevel, format, args) }
+func Warn(format string, args ...interface{})  { Entry{}.log(zerolog.WarnLevel, format, args) }
+func Error(format string, args ...interface{}) { Entry{}.log(zerolog.ErrorLevel, format, args) }
@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	zerologlog "github.com/rs/zerolog/log"
+)
+
+func TestSetup(t *testing.T) {
+	defer Setup(os.Getenv("LOG_FORMAT"), os.Stderr)
+	defer SetLevel(os.Getenv("LOG_LEVEL"))
+	SetLevel("INFO")
+
+	tests := []struct {
+		name   string
+		format string
+		check  func(t *testing.T, line string)
+	}{
+		{
+			name:   "json",
+			format: FormatJSON,
+			check: func(t *testing.T, line string) {
+				var got map[string]any
+				if err := json.Unmarshal([]byte(line), &got); err != nil {
+					t.Fatalf("%q is not JSON: %v", line, err)
+				}
+				if got["level"] != "warn" || got["message"] != "Attestation missed at slot 40" {
+					t.Errorf("level %v, message %v", got["level"], got["message"])
+				}
+				// Fields keep their type.
+				if got["validator"] != float64(7) || got["group"] != "lido" || got["degraded"] != true {
+					t.Errorf("fields %v", got)
+				}
+				if _, ok := got["time"]; !ok {
+					t.Error("no time")
+				}
+			},
+		},
+		{
+			name:   "console",
+			format: FormatConsole,
+			check: func(t *testing.T, line string) {
+				for _, want := range []string{"WRN", "Attestation missed at slot 40", "validator=7", "group=lido", "degraded=true"} {
+					if !strings.Contains(line, want) {
+						t.Errorf("%q lacks %q", line, want)
+					}
+				}
+			},
+		},
+		{
+			name:   "unknown format is console",
+			format: "xml",
+			check: func(t *testing.T, line string) {
+				if strings.HasPrefix(line, "{") || !strings.Contains(line, "validator=7") {
+					t.Errorf("%q is not a console line", line)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			Setup(tt.format, &buf)
+			With(Fields{"validator": 7, "group": "lido", "degraded": true}).Warn("Attestation missed at slot %d", 40)
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != 1 {
+				t.Fatalf("%d lines, want 1:\n%s", len(lines), buf.String())
+			}
+			tt.check(t, lines[0])
+		})
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	defer Setup(os.Getenv("LOG_FORMAT"), os.Stderr)
+	defer SetLevel(os.Getenv("LOG_LEVEL"))
+	var buf bytes.Buffer
+	Setup(FormatJSON, &buf)
+	// A go-eth2-client client logs through a copy of the global logger, at
+	// the level it is created with.
+	client := zerologlog.Logger.Level(zerolog.TraceLevel)
+
+	tests := []struct {
+		level string
+		want  []string
+	}{
+		{level: "DEBUG", want: []string{"debug", "info", "warn", "error"}},
+		{level: "INFO", want: []string{"info", "warn", "error"}},
+		{level: "warn", want: []string{"warn", "error"}},
+		{level: "ERROR", want: []string{"error"}},
+		{level: "verbose", want: []string{"info", "warn", "error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			buf.Reset()
+			SetLevel(tt.level)
+			Debug("debug")
+			Info("info")
+			With(Fields{"validator": 1}).Warn("warn")
+			Error("error")
+			if got := messages(t, &buf); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("logged %v, want %v", got, tt.want)
+			}
+
+			buf.Reset()
+			client.Debug().Msg("debug")
+			client.Error().Msg("error")
+			want := []string{"error"}
+			if tt.want[0] == "debug" {
+				want = []string{"debug", "error"}
+			}
+			if got := messages(t, &buf); strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("client logged %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// messages returns the message of every JSON line in buf.
+func messages(t *testing.T, buf *bytes.Buffer) []string {
+	t.Helper()
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("%q is not JSON: %v", line, err)
+		}
+		got = append(got, entry.Message)
+	}
+	return got
+}
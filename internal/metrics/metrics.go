@@ -0,0 +1,79 @@
+// Package metrics holds the Prometheus metrics exported by duties-indexer.
+// They are registered on the default registry and served on /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "duties_indexer"
+
+var (
+	// BeaconRequests counts beacon node calls by the node that served them,
+	// the port method and the result: "success", "definitive" for an answer
+	// that is an error but final, e.g. no block at a slot, or "error".
+	BeaconRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "beacon_requests_total",
+		Help:      "Beacon node API calls by node, method and result.",
+	}, []string{"node", "method", "result"})
+
+	// BeaconRequestDuration observes beacon node call latency by node and method.
+	BeaconRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "beacon_request_duration_seconds",
+		Help:      "Beacon node API call latency by node and method.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"node", "method"})
+
+	// BeaconFailovers counts calls that failed on one node and were retried on the next.
+	BeaconFailovers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "beacon_failovers_total",
+		Help:      "Calls that failed on a beacon node and were retried on another, by failing node and method.",
+	}, []string{"node", "method"})
+
+	// BeaconNodeHealthy is 1 when the last health check found the node reachable and synced.
+	BeaconNodeHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "beacon_node_healthy",
+		Help:      "1 if the beacon node was reachable and synced at the last health check.",
+	}, []string{"node"})
+
+	// BeaconNodeHeadSlot is the head slot reported by the node at the last health check.
+	BeaconNodeHeadSlot = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "beacon_node_head_slot",
+		Help:      "Head slot reported by the beacon node at the last health check.",
+	}, []string{"node"})
+
+	// BeaconNodeFinalizedEpoch is the finalized epoch reported by the node at the last health check.
+	BeaconNodeFinalizedEpoch = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "beacon_node_finalized_epoch",
+		Help:      "Finalized epoch reported by the beacon node at the last health check.",
+	}, []string{"node"})
+
+	// BeaconNodePeers is the connected peer count reported by the node at the last health check.
+	BeaconNodePeers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "beacon_node_peers",
+		Help:      "Connected peers reported by the beacon node at the last health check.",
+	}, []string{"node"})
+
+	// BlockCacheRequests counts block lookups in the block cache by result
+	// (hit, miss, or unfinalized for slots that are not cached yet).
+	BlockCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "block_cache_requests_total",
+		Help:      "Block lookups in the block cache, by result (hit, miss or unfinalized).",
+	}, []string{"result"})
+
+	// BlockCacheSize is the number of blocks currently held in the block cache.
+	BlockCacheSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "block_cache_blocks",
+		Help:      "Number of blocks currently held in the block cache.",
+	})
+)
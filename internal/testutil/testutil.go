@@ -0,0 +1,75 @@
+// Package testutil holds the helpers shared by the tests of several
+// packages. Importing it lowers the log level to ERROR: one line per duty
+// would drown the test output.
+package testutil
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Marketen/duties-indexer/internal/adapters/fakechain"
+	"github.com/Marketen/duties-indexer/internal/application/domain"
+	"github.com/Marketen/duties-indexer/internal/logger"
+)
+
+func init() {
+	logger.SetLevel("ERROR")
+}
+
+// NewChain generates the fake chain described by cfg.
+func NewChain(t testing.TB, cfg fakechain.Config) *fakechain.Chain {
+	t.Helper()
+	chain, err := fakechain.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+// Validators returns the indices 0 to n-1.
+func Validators(n int) []domain.ValidatorIndex {
+	indices := make([]domain.ValidatorIndex, n)
+	for i := range indices {
+		indices[i] = domain.ValidatorIndex(i)
+	}
+	return indices
+}
+
+// AssertResults compares the checker's results with the expected ones,
+// ignoring order and reasons. Unsuccessful results must give a reason.
+func AssertResults(t testing.TB, got, want []domain.DutyResult) {
+	t.Helper()
+	key := func(r domain.DutyResult) string {
+		return fmt.Sprintf("%s/%d/%d", r.Type, r.Slot, r.ValidatorIndex)
+	}
+	byKey := make(map[string]domain.DutyResult, len(got))
+	for _, r := range got {
+		if _, dup := byKey[key(r)]; dup {
+			t.Errorf("duplicate result for %s", key(r))
+		}
+		if r.Outcome != domain.OutcomeSuccess && r.Reason == "" {
+			t.Errorf("%s: %s without a reason", key(r), r.Outcome)
+		}
+		r.Reason = ""
+		byKey[key(r)] = r
+	}
+	mismatches := 0
+	for _, w := range want {
+		g, ok := byKey[key(w)]
+		delete(byKey, key(w))
+		switch {
+		case !ok:
+			t.Errorf("%s: no result, want %s", key(w), w.Outcome)
+		case g != w:
+			t.Errorf("%s: got %+v, want %+v", key(w), g, w)
+		default:
+			continue
+		}
+		if mismatches++; mismatches >= 10 {
+			t.Fatal("too many mismatches")
+		}
+	}
+	for k, r := range byKey {
+		t.Errorf("%s: unexpected result %+v", k, r)
+	}
+}
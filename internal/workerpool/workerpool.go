@@ -0,0 +1,62 @@
+// Package workerpool runs fan-out work with bounded concurrency.
+package workerpool
+
+import (
+	"context"
+	"sync"
+)
+
+// Map calls fn for every item with at most concurrency calls in flight and
+// returns the results and errors in input order, so callers see the same
+// output regardless of scheduling. Once ctx is done no new calls are started
+// and the remaining items get ctx.Err() as their error.
+func Map[T, R any](
+	ctx context.Context,
+	concurrency int,
+	items []T,
+	fn func(ctx context.Context, item T) (R, error),
+) ([]R, []error) {
+	results := make([]R, len(items))
+	errs := make([]error, len(items))
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		if !acquire(ctx, sem) {
+			for j := i; j < len(items); j++ {
+				errs[j] = ctx.Err()
+			}
+			wg.Wait()
+			return results, errs
+		}
+		wg.Add(1)
+		go func(i int, item T) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i], errs[i] = fn(ctx, item)
+		}(i, item)
+	}
+	wg.Wait()
+	return results, errs
+}
+
+// acquire takes a slot in sem, or returns false once ctx is done. A slot
+// freed at the same time as ctx is cancelled is given back, so no call starts
+// after the cancellation.
+func acquire(ctx context.Context, sem chan struct{}) bool {
+	select {
+	case sem <- struct{}{}:
+		if ctx.Err() != nil {
+			<-sem
+			return false
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
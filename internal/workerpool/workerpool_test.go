@@ -0,0 +1,95 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMap(t *testing.T) {
+	items := make([]int, 50)
+	for i := range items {
+		items[i] = i
+	}
+	errOdd := errors.New("odd")
+
+	tests := []struct {
+		name        string
+		concurrency int
+	}{
+		{name: "sequential", concurrency: 1},
+		{name: "bounded", concurrency: 4},
+		{name: "more workers than items", concurrency: 100},
+		{name: "zero means one", concurrency: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := max(tt.concurrency, 1)
+			var inFlight, peak atomic.Int32
+			results, errs := Map(context.Background(), tt.concurrency, items, func(_ context.Context, item int) (int, error) {
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				// Finish out of order so results must be placed by index.
+				time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+				if item%2 == 1 {
+					return 0, errOdd
+				}
+				return item * 10, nil
+			})
+
+			if p := int(peak.Load()); p > limit {
+				t.Errorf("%d calls in flight, want at most %d", p, limit)
+			}
+			for i := range items {
+				switch {
+				case i%2 == 1 && !errors.Is(errs[i], errOdd):
+					t.Errorf("item %d: error %v, want %v", i, errs[i], errOdd)
+				case i%2 == 0 && (errs[i] != nil || results[i] != i*10):
+					t.Errorf("item %d: got %d, %v; want %d", i, results[i], errs[i], i*10)
+				}
+			}
+		})
+	}
+}
+
+func TestMapStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	items := make([]int, 20)
+	var calls atomic.Int32
+	_, errs := Map(ctx, 2, items, func(ctx context.Context, _ int) (int, error) {
+		if calls.Add(1) == 2 {
+			cancel()
+		}
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+
+	if n := int(calls.Load()); n != 2 {
+		t.Errorf("%d calls started, want 2: none after the cancellation", n)
+	}
+	for i, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("item %d: error %v, want %v", i, err, context.Canceled)
+		}
+	}
+}
+
+func TestMapEmpty(t *testing.T) {
+	results, errs := Map(context.Background(), 4, nil, func(context.Context, int) (int, error) {
+		t.Fatal("fn called without items")
+		return 0, nil
+	})
+	if !reflect.DeepEqual(results, []int{}) || !reflect.DeepEqual(errs, []error{}) {
+		t.Errorf("got %v, %v; want empty slices", results, errs)
+	}
+}